@@ -6,9 +6,17 @@ import (
 )
 
 var (
-	AccessKeyID string
-	SecretKey   string
-	Region      string
+	AccessKeyID          string
+	SecretKey            string
+	Region               string
+	SessionToken         string
+	Profile              string
+	AssumeRoleARN        string
+	AssumeRoleExternalID string
+	AssumeRoleSession    string
+	WebIdentityTokenFile string
+	GitHubOIDC           bool
+	EndpointURL          string
 )
 
 var Cmd = &cobra.Command{
@@ -42,13 +50,71 @@ func addFlags() {
 		"The AWS Region. If it's not set, "+
 			"it'll be read from the AWS_REGION and for the AWS_DEFAULT_REGION environment variable.")
 
+	Cmd.PersistentFlags().StringVarP(&SessionToken,
+		"aws-session-token",
+		"", "",
+		"The AWS Session Token, used along with temporary static credentials. If it's not set, "+
+			"it'll be read from the AWS_SESSION_TOKEN environment variable.")
+
+	Cmd.PersistentFlags().StringVarP(&Profile,
+		"aws-profile",
+		"", "",
+		"The AWS named profile (shared config or SSO) to resolve the credentials from. If it's not set, "+
+			"it'll be read from the AWS_PROFILE environment variable.")
+
+	Cmd.PersistentFlags().StringVarP(&AssumeRoleARN,
+		"assume-role-arn",
+		"", "",
+		"The ARN of the IAM role to assume on top of the resolved credentials. It's assumed with a web"+
+			" identity token if --web-identity-token-file or --github-oidc is set. The AWS_ROLE_ARN and "+
+			"AWS_WEB_IDENTITY_TOKEN_FILE environment variables are resolved by the default credentials chain.")
+
+	Cmd.PersistentFlags().StringVarP(&AssumeRoleExternalID,
+		"assume-role-external-id",
+		"", "",
+		"The external ID to pass while assuming the role set in --assume-role-arn. "+
+			"It can't be used with a web identity token.")
+
+	Cmd.PersistentFlags().StringVarP(&AssumeRoleSession,
+		"assume-role-session-name",
+		"", "",
+		"The session name to use while assuming the role set in --assume-role-arn. "+
+			"If it's not set, 'stiletto' will be used.")
+
+	Cmd.PersistentFlags().StringVarP(&WebIdentityTokenFile,
+		"web-identity-token-file",
+		"", "",
+		"Path to an OIDC web identity token file used to assume the role set in --assume-role-arn.")
+
+	Cmd.PersistentFlags().BoolVarP(&GitHubOIDC,
+		"github-oidc",
+		"", false,
+		"Assume the role set in --assume-role-arn with the OIDC token of the GitHub Actions runtime "+
+			"(it requires the 'id-token: write' permission).")
+
+	Cmd.PersistentFlags().StringVarP(&EndpointURL,
+		"aws-endpoint-url",
+		"", "",
+		"A custom endpoint for every AWS service call (E.g.: http://localhost:4566 for LocalStack). "+
+			"If it's not set, it'll be read from the AWS_ENDPOINT_URL environment variable.")
+
 	_ = viper.BindPFlag("aws-creds-access-key-id", Cmd.PersistentFlags().Lookup("aws-creds-access-key-id"))
 	_ = viper.BindPFlag("aws-creds-secret-key", Cmd.PersistentFlags().Lookup("aws-creds-secret-key"))
 	_ = viper.BindPFlag("aws-creds-region", Cmd.PersistentFlags().Lookup("aws-creds-region"))
+	_ = viper.BindPFlag("aws-session-token", Cmd.PersistentFlags().Lookup("aws-session-token"))
+	_ = viper.BindPFlag("aws-profile", Cmd.PersistentFlags().Lookup("aws-profile"))
+	_ = viper.BindPFlag("assume-role-arn", Cmd.PersistentFlags().Lookup("assume-role-arn"))
+	_ = viper.BindPFlag("assume-role-external-id", Cmd.PersistentFlags().Lookup("assume-role-external-id"))
+	_ = viper.BindPFlag("assume-role-session-name", Cmd.PersistentFlags().Lookup("assume-role-session-name"))
+	_ = viper.BindPFlag("web-identity-token-file", Cmd.PersistentFlags().Lookup("web-identity-token-file"))
+	_ = viper.BindPFlag("github-oidc", Cmd.PersistentFlags().Lookup("github-oidc"))
+	_ = viper.BindPFlag("aws-endpoint-url", Cmd.PersistentFlags().Lookup("aws-endpoint-url"))
 }
 
 func init() {
 	addFlags()
 	Cmd.AddCommand(ECRCmd)
 	Cmd.AddCommand(ECSCmd)
+	Cmd.AddCommand(LambdaCmd)
+	Cmd.AddCommand(S3Cmd)
 }
@@ -0,0 +1,167 @@
+package aws
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/api"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/task"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	lambdaFunctionName string
+	lambdaRuntime      string
+	lambdaZipFile      string
+	lambdaS3Bucket     string
+	lambdaS3Key        string
+	lambdaImageURI     string
+	lambdaAlias        string
+	lambdaDescription  string
+)
+
+var LambdaCmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "lambda",
+	Long: `The 'lambda' command automates the build and release of AWS Lambda functions,
+E.g.: 'package', 'publish', 'deploy'`,
+	Example: `
+  # Package the function code (mounted from the target directory) into a zip:
+  stiletto aws lambda --task=package --function-name=my-fn --lambda-runtime=python3.10
+
+  # Upload the zip package into S3:
+  stiletto aws lambda --task=publish --function-name=my-fn --lambda-s3-bucket=my-bucket
+
+  # Deploy the new code, publish a version and move the 'live' alias to it:
+  stiletto aws lambda --task=deploy --function-name=my-fn --lambda-s3-bucket=my-bucket --lambda-alias=live`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		// 1. Instantiate the pipeline runner, which will be used to run the tasks.
+		msg := tui.NewTUIMessage()
+		ux := tui.TUITitle{}
+
+		stackName := "AWS"
+		jobName := "LAMBDA"
+
+		cliGlobalArgs, err := config.GetCLIGlobalArgs()
+
+		if err != nil {
+			msg.ShowError("INIT", "Failed to resolve the arguments", err)
+			return err
+		}
+
+		if cliGlobalArgs.DryRun {
+			if err := api.DryRun(cmd.Context(), &cliGlobalArgs, stackName, jobName,
+				task.PlanTaskAWSLambda, task.InitOptions{
+					Task:           cliGlobalArgs.TaskName,
+					ActionCommands: cliGlobalArgs.CustomCommands,
+					Outputs:        cliGlobalArgs.Outputs,
+				}); err != nil {
+				return err
+			}
+
+			return nil
+		}
+
+		p, j, err := api.New(cmd.Context(), &cliGlobalArgs, stackName, jobName)
+		if errors.IsTaskSkippedError(err) {
+			task.ShowOutputStatus(stackName, jobName, cliGlobalArgs.TaskName,
+				task.NewSkippedOutput(err.Error()))
+			return nil
+		}
+
+		if err != nil {
+			task.ShowIfCancelled(stackName, jobName, cliGlobalArgs.TaskName)
+			return err
+		}
+
+		ux.ShowSubTitle("TASK:", cliGlobalArgs.TaskName)
+		ux.ShowTaskDetails(jobName, cliGlobalArgs.TaskName, j.WorkDirPath,
+			j.TargetDirPath,
+			j.MountDirPath)
+
+		out, err := task.RunTaskAWSLambda(task.InitOptions{
+			Task:            cliGlobalArgs.TaskName,
+			Stack:           stackName,
+			PipelineCfg:     p,
+			JobCfg:          j,
+			WorkDir:         p.PipelineOpts.WorkDir,
+			MountDir:        p.PipelineOpts.MountDir,
+			TargetDir:       p.PipelineOpts.TargetDir,
+			ActionCommands:  cliGlobalArgs.CustomCommands,
+			Outputs:         cliGlobalArgs.Outputs,
+			ArtifactsDir:    cliGlobalArgs.ArtifactsDir,
+			LogsDir:         cliGlobalArgs.LogsDir,
+			ShowOutput:      cliGlobalArgs.ShowOutput,
+			StderrTailLines: cliGlobalArgs.StderrTailLines,
+			Policy:          cliGlobalArgs.TaskPolicy,
+		})
+
+		if err != nil {
+			if task.ShowIfCancelled(stackName, jobName, cliGlobalArgs.TaskName) {
+				return err
+			}
+
+			msg.ShowError("", fmt.Sprintf("Failed to run task '%s' as part of job %s on stack '%s'",
+				cliGlobalArgs.TaskName, jobName, stackName), err)
+			return err
+		}
+
+		out.Status = task.OutputStatusSucceeded
+		task.ShowOutputStatus(stackName, jobName, cliGlobalArgs.TaskName, out)
+
+		return nil
+	},
+}
+
+func addLambdaCmdFlags() {
+	LambdaCmd.Flags().StringVarP(&lambdaFunctionName, "function-name", "", "",
+		"The name (or ARN) of the lambda function.")
+
+	LambdaCmd.Flags().StringVarP(&lambdaRuntime, "lambda-runtime", "", "python3.10",
+		"The lambda runtime, used to pick the container that packages the code. E.g.: python3.10, "+
+			"nodejs18.x, go1.x, provided.al2")
+
+	LambdaCmd.Flags().StringVarP(&lambdaZipFile, "lambda-zip-file", "", "",
+		"The host path of the zip package. If not specified, "+
+			"the default value is '<workdir>/.stiletto/lambda/<function-name>.zip'.")
+
+	LambdaCmd.Flags().StringVarP(&lambdaS3Bucket, "lambda-s3-bucket", "", "",
+		"The S3 bucket where the zip package is published.")
+
+	LambdaCmd.Flags().StringVarP(&lambdaS3Key, "lambda-s3-key", "", "",
+		"The S3 key of the zip package. If not specified, "+
+			"the default value is '<function-name>/<sha256>.zip'.")
+
+	LambdaCmd.Flags().StringVarP(&lambdaImageURI, "lambda-image-uri", "", "",
+		"The ECR image URI to deploy, for functions packaged as container images.")
+
+	LambdaCmd.Flags().StringVarP(&lambdaAlias, "lambda-alias", "", "",
+		"The alias to move to the published version. If not specified, no alias is updated.")
+
+	LambdaCmd.Flags().StringVarP(&lambdaDescription, "lambda-description", "", "",
+		"The description of the published version.")
+
+	err := LambdaCmd.MarkFlagRequired("function-name")
+	if err != nil {
+		panic(err)
+	}
+
+	_ = viper.BindPFlag("lambda-function-name", LambdaCmd.Flags().Lookup("function-name"))
+	_ = viper.BindPFlag("lambda-runtime", LambdaCmd.Flags().Lookup("lambda-runtime"))
+	_ = viper.BindPFlag("lambda-zip-file", LambdaCmd.Flags().Lookup("lambda-zip-file"))
+	_ = viper.BindPFlag("lambda-s3-bucket", LambdaCmd.Flags().Lookup("lambda-s3-bucket"))
+	_ = viper.BindPFlag("lambda-s3-key", LambdaCmd.Flags().Lookup("lambda-s3-key"))
+	_ = viper.BindPFlag("lambda-image-uri", LambdaCmd.Flags().Lookup("lambda-image-uri"))
+	_ = viper.BindPFlag("lambda-alias", LambdaCmd.Flags().Lookup("lambda-alias"))
+	_ = viper.BindPFlag("lambda-description", LambdaCmd.Flags().Lookup("lambda-description"))
+}
+
+func init() {
+	addLambdaCmdFlags()
+}
@@ -0,0 +1,189 @@
+package aws
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/api"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/task"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	s3Bucket                    string
+	s3Prefix                    string
+	s3SourceDir                 string
+	s3BuildCmd                  string
+	s3BuildImage                string
+	s3CacheControl              []string
+	s3Delete                    bool
+	s3DryRun                    bool
+	s3Concurrency               int
+	cloudFrontDistributionID    string
+	cloudFrontInvalidationPaths []string
+	cloudFrontWait              bool
+)
+
+var S3Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "s3",
+	Long: `The 's3' command publishes static sites and artifacts into S3 buckets,
+E.g.: 'sync'`,
+	Example: `
+  # Build the site in a container, and sync its 'build' directory into a bucket:
+  stiletto aws s3 --task=sync --s3-bucket=my-site --s3-source-dir=build \
+    --s3-build-image=node:18-alpine --s3-build-cmd="npm ci && npm run build"
+
+  # Set cache-control per glob, delete stale objects, and invalidate CloudFront:
+  stiletto aws s3 --task=sync --s3-bucket=my-site --s3-delete \
+    --s3-cache-control="*.html=no-cache" --s3-cache-control="assets/*=public, max-age=31536000, immutable" \
+    --cloudfront-distribution-id=E123456
+
+  # List the changes, without applying them:
+  stiletto aws s3 --task=sync --s3-bucket=my-site --s3-dry-run`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		// 1. Instantiate the pipeline runner, which will be used to run the tasks.
+		msg := tui.NewTUIMessage()
+		ux := tui.TUITitle{}
+
+		stackName := "AWS"
+		jobName := "S3"
+
+		cliGlobalArgs, err := config.GetCLIGlobalArgs()
+
+		if err != nil {
+			msg.ShowError("INIT", "Failed to resolve the arguments", err)
+			return err
+		}
+
+		if cliGlobalArgs.DryRun {
+			if err := api.DryRun(cmd.Context(), &cliGlobalArgs, stackName, jobName,
+				task.PlanTaskAWSS3, task.InitOptions{
+					Task:           cliGlobalArgs.TaskName,
+					ActionCommands: cliGlobalArgs.CustomCommands,
+					Outputs:        cliGlobalArgs.Outputs,
+				}); err != nil {
+				return err
+			}
+
+			return nil
+		}
+
+		p, j, err := api.New(cmd.Context(), &cliGlobalArgs, stackName, jobName)
+		if errors.IsTaskSkippedError(err) {
+			task.ShowOutputStatus(stackName, jobName, cliGlobalArgs.TaskName,
+				task.NewSkippedOutput(err.Error()))
+			return nil
+		}
+
+		if err != nil {
+			task.ShowIfCancelled(stackName, jobName, cliGlobalArgs.TaskName)
+			return err
+		}
+
+		ux.ShowSubTitle("TASK:", cliGlobalArgs.TaskName)
+		ux.ShowTaskDetails(jobName, cliGlobalArgs.TaskName, j.WorkDirPath,
+			j.TargetDirPath,
+			j.MountDirPath)
+
+		out, err := task.RunTaskAWSS3(task.InitOptions{
+			Task:            cliGlobalArgs.TaskName,
+			Stack:           stackName,
+			PipelineCfg:     p,
+			JobCfg:          j,
+			WorkDir:         p.PipelineOpts.WorkDir,
+			MountDir:        p.PipelineOpts.MountDir,
+			TargetDir:       p.PipelineOpts.TargetDir,
+			ActionCommands:  cliGlobalArgs.CustomCommands,
+			Outputs:         cliGlobalArgs.Outputs,
+			ArtifactsDir:    cliGlobalArgs.ArtifactsDir,
+			LogsDir:         cliGlobalArgs.LogsDir,
+			ShowOutput:      cliGlobalArgs.ShowOutput,
+			StderrTailLines: cliGlobalArgs.StderrTailLines,
+			Policy:          cliGlobalArgs.TaskPolicy,
+		})
+
+		if err != nil {
+			if task.ShowIfCancelled(stackName, jobName, cliGlobalArgs.TaskName) {
+				return err
+			}
+
+			msg.ShowError("", fmt.Sprintf("Failed to run task '%s' as part of job %s on stack '%s'",
+				cliGlobalArgs.TaskName, jobName, stackName), err)
+			return err
+		}
+
+		out.Status = task.OutputStatusSucceeded
+		task.ShowOutputStatus(stackName, jobName, cliGlobalArgs.TaskName, out)
+
+		return nil
+	},
+}
+
+func addS3CmdFlags() {
+	S3Cmd.Flags().StringVarP(&s3Bucket, "s3-bucket", "", "",
+		"The name of the S3 bucket to sync into.")
+
+	S3Cmd.Flags().StringVarP(&s3Prefix, "s3-prefix", "", "",
+		"The key prefix (folder) in the bucket to sync into.")
+
+	S3Cmd.Flags().StringVarP(&s3SourceDir, "s3-source-dir", "", "dist",
+		"The directory (relative to the target directory, in the container) to sync.")
+
+	S3Cmd.Flags().StringVarP(&s3BuildCmd, "s3-build-cmd", "", "",
+		"A command run in the container before exporting the source directory. E.g.: 'npm run build'")
+
+	S3Cmd.Flags().StringVarP(&s3BuildImage, "s3-build-image", "", "",
+		"The image of the container that runs the build command. If not specified, "+
+			"the job's default container is used.")
+
+	S3Cmd.Flags().StringSliceVarP(&s3CacheControl, "s3-cache-control", "", []string{},
+		"Cache-Control rules in the form 'glob=value'. The first matching rule wins.")
+
+	S3Cmd.Flags().BoolVarP(&s3Delete, "s3-delete", "", false,
+		"Delete the objects that don't exist in the source directory.")
+
+	S3Cmd.Flags().BoolVarP(&s3DryRun, "s3-dry-run", "", false,
+		"List the uploads and deletions, without applying them.")
+
+	S3Cmd.Flags().IntVarP(&s3Concurrency, "s3-concurrency", "", 8,
+		"The number of concurrent uploads.")
+
+	S3Cmd.Flags().StringVarP(&cloudFrontDistributionID, "cloudfront-distribution-id", "", "",
+		"The CloudFront distribution to invalidate after the sync. If not specified, "+
+			"no invalidation is created.")
+
+	S3Cmd.Flags().StringSliceVarP(&cloudFrontInvalidationPaths, "cloudfront-invalidation-paths",
+		"", []string{"/*"}, "The paths to invalidate in the CloudFront distribution.")
+
+	S3Cmd.Flags().BoolVarP(&cloudFrontWait, "cloudfront-wait", "", true,
+		"Wait until the CloudFront invalidation is completed.")
+
+	err := S3Cmd.MarkFlagRequired("s3-bucket")
+	if err != nil {
+		panic(err)
+	}
+
+	_ = viper.BindPFlag("s3-bucket", S3Cmd.Flags().Lookup("s3-bucket"))
+	_ = viper.BindPFlag("s3-prefix", S3Cmd.Flags().Lookup("s3-prefix"))
+	_ = viper.BindPFlag("s3-source-dir", S3Cmd.Flags().Lookup("s3-source-dir"))
+	_ = viper.BindPFlag("s3-build-cmd", S3Cmd.Flags().Lookup("s3-build-cmd"))
+	_ = viper.BindPFlag("s3-build-image", S3Cmd.Flags().Lookup("s3-build-image"))
+	_ = viper.BindPFlag("s3-cache-control", S3Cmd.Flags().Lookup("s3-cache-control"))
+	_ = viper.BindPFlag("s3-delete", S3Cmd.Flags().Lookup("s3-delete"))
+	_ = viper.BindPFlag("s3-dry-run", S3Cmd.Flags().Lookup("s3-dry-run"))
+	_ = viper.BindPFlag("s3-concurrency", S3Cmd.Flags().Lookup("s3-concurrency"))
+	_ = viper.BindPFlag("cloudfront-distribution-id", S3Cmd.Flags().Lookup("cloudfront-distribution-id"))
+	_ = viper.BindPFlag("cloudfront-invalidation-paths", S3Cmd.Flags().Lookup("cloudfront-invalidation-paths"))
+	_ = viper.BindPFlag("cloudfront-wait", S3Cmd.Flags().Lookup("cloudfront-wait"))
+}
+
+func init() {
+	addS3CmdFlags()
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "cache",
+	Long: `The 'cache' command manages the dependency cache volumes (E.g.: npm, pip, go modules)
+that the jobs mount into their containers, per stack and --cache-namespace.`,
+	Example: `
+  # Prune the cache volumes of the 'feature-x' namespace:
+  stiletto cache prune --cache-namespace=feature-x`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(PruneCmd)
+}
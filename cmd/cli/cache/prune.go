@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	pruneStack string
+	pruneAll   bool
+)
+
+var PruneCmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "prune",
+	Long: `The 'prune' command discards the cache volumes of a namespace (--cache-namespace) and,
+optionally, of a single stack. The next runs start with new, empty, volumes; the dagger engine
+reclaims the space of the discarded ones when it garbage collects its cache.
+
+The volumes are tracked in a state file in the user cache dir (E.g.: ~/.cache/stiletto), so
+pruning only affects the runs on this host. On ephemeral runners (E.g.: CI), where the state
+file doesn't persist between runs, pruning has no effect: use a new --cache-namespace instead
+(E.g.: suffixed with a version that is bumped to discard the cache).`,
+	Example: `
+  # Prune the cache volumes of the docker stack, in the default namespace:
+  stiletto cache prune --stack=docker
+
+  # Prune the cache volumes of all the namespaces and stacks:
+  stiletto cache prune --all
+
+  # On ephemeral runners, switch to new volumes through the namespace instead:
+  stiletto docker --task=build --cache-namespace=main-v2`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		msg := tui.NewTUIMessage()
+		prefix := "CACHE:PRUNE"
+
+		namespace := ""
+		if !pruneAll {
+			namespace = daggerio.NormaliseCacheNamespace(viper.GetString("cache-namespace"))
+		}
+
+		statePath, err := daggerio.GetCacheStatePath()
+		if err != nil {
+			msg.ShowError(prefix, "Failed to resolve the cache state file", err)
+			return err
+		}
+
+		state, err := daggerio.LoadCacheState(statePath)
+		if err != nil {
+			msg.ShowError(prefix, "Failed to load the cache state", err)
+			return err
+		}
+
+		pruned := state.Prune(namespace, pruneStack)
+
+		if err := state.Save(); err != nil {
+			msg.ShowError(prefix, "Failed to save the cache state", err)
+			return err
+		}
+
+		if len(pruned) == 0 {
+			msg.ShowWarning(prefix, "No cache volumes were recorded for the namespace and stack "+
+				"passed, the next runs will use new volumes anyway")
+			return nil
+		}
+
+		rows := [][]string{{"VOLUME"}}
+		for _, name := range pruned {
+			rows = append(rows, []string{name})
+		}
+
+		tui.ShowTable(rows)
+		msg.ShowSuccess(prefix, fmt.Sprintf("%d cache volumes pruned", len(pruned)))
+
+		return nil
+	},
+}
+
+func addPruneCmdFlags() {
+	PruneCmd.Flags().StringVarP(&pruneStack, "stack", "", "",
+		"The stack (E.g.: docker, python, aws) whose cache volumes are pruned. "+
+			"If it's not set, the volumes of all the stacks are pruned.")
+
+	PruneCmd.Flags().BoolVarP(&pruneAll, "all", "", false,
+		"Prune the cache volumes of all the namespaces, not only the --cache-namespace one.")
+}
+
+func init() {
+	addPruneCmdFlags()
+}
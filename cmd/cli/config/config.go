@@ -0,0 +1,26 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "config",
+	Long: `The 'config' command describes the config files (.stiletto.yaml): the keys of every
+command (any flag can be set by its name), the 'profiles', and the 'stacks', 'tasks' and 'jobs'
+sections. The config is validated before any command runs.`,
+	Example: `
+  # Print the JSON Schema of the config files:
+  stiletto config schema`,
+	// Overrides the one of the root command, so the schema is printed even if the config is
+	// invalid.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(SchemaCmd)
+}
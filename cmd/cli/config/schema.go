@@ -0,0 +1,37 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/tui"
+	stilettoCfg "github.com/Excoriate/stiletto/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var SchemaCmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "schema",
+	Long: `The 'schema' command prints the JSON Schema of the config files, so the editors complete
+and check them, and they can be linted in CI. E.g.: with the YAML language server, add this line
+at the top of .stiletto.yaml:
+
+  # yaml-language-server: $schema=./stiletto.schema.json`,
+	Example: `
+  # Write the schema next to the config file:
+  stiletto config schema > stiletto.schema.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		schema, err := json.MarshalIndent(stilettoCfg.GetJSONSchema(), "", "  ")
+		if err != nil {
+			tui.NewTUIMessage().ShowError("CONFIG:SCHEMA", "Failed to generate the JSON Schema", err)
+			return err
+		}
+
+		fmt.Println(string(schema))
+
+		return nil
+	},
+}
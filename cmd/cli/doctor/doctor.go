@@ -0,0 +1,135 @@
+package doctor
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/doctor"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+var (
+	doctorChecks        []string
+	doctorTargetModule  string
+	doctorDaggerTimeout time.Duration
+)
+
+var Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "doctor",
+	Long: `The 'doctor' command checks the environment that the pipelines run on, and reports each
+problem with a hint to fix it:
+
+  config     The config files (and the --profile) can be read, and the stacks and tasks are valid.
+  runtime    docker is installed and its daemon is reachable (the dagger engine runs on it).
+  dagger     The dagger engine can be reached, along with the SDK and CLI versions.
+  aws        The AWS credentials and region are resolved (as the runs do).
+  ecr-login  The aws and docker binaries, for the host ECR login (not needed with --run-in-vendor).
+  git        The work dir (or the --target-module) is in a git repository, as terragrunt requires.
+  dotenv     The .env files passed (--dot-env-file, --environment) can be loaded.
+
+The 'ecr-login' and 'git' checks only fail if an ECR registry, or a terragrunt module, is
+configured; otherwise they warn. It exits with 1 if any check fails.`,
+	Example: `
+  # Check everything:
+  stiletto doctor
+
+  # Check the terragrunt module, and the dagger engine:
+  stiletto doctor --checks=git,dagger --target-module=infra/vpc`,
+	// Overrides the one of the root command, so the errors of the config files are reported by
+	// the 'config' check, instead of stopping the command.
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		msg := tui.NewTUIMessage()
+		prefix := "DOCTOR"
+
+		config.ShowCLITitle()
+
+		workDirCfg, err := pipeline.IsWorkDirValid(viper.GetString("work-dir"))
+		if err != nil {
+			msg.ShowError(prefix, "Failed to resolve the work dir", err)
+			return err
+		}
+
+		// The module isn't bound to viper, since the terragrunt command binds the same key.
+		targetModule := doctorTargetModule
+		if targetModule == "" {
+			targetModule = viper.GetString("target-module")
+		}
+
+		checks, err := doctor.FilterChecks(doctor.GetChecks(doctor.Options{
+			WorkDir:       workDirCfg.Path,
+			TargetModule:  targetModule,
+			ECRRegistry:   viper.GetString("ecr-registry"),
+			RunInVendor:   viper.GetBool("run-in-vendor"),
+			DotEnvFiles:   viper.GetStringSlice("dot-env-file"),
+			Environment:   viper.GetString("environment"),
+			DaggerTimeout: doctorDaggerTimeout,
+		}), doctorChecks)
+
+		if err != nil {
+			msg.ShowError(prefix, "Invalid --checks", err)
+			return err
+		}
+
+		results := doctor.Run(cmd.Context(), checks)
+
+		counts := map[string]int{}
+		for _, r := range results {
+			counts[r.Status]++
+			title := fmt.Sprintf("%s:%s", prefix, strings.ToUpper(r.Check))
+
+			switch r.Status {
+			case doctor.StatusOK:
+				msg.ShowSuccess(title, r.Detail)
+			case doctor.StatusWarn:
+				msg.ShowWarning(title, r.Detail)
+			case doctor.StatusFail:
+				msg.ShowError(title, r.Detail, nil)
+			default:
+				msg.ShowInfo(title, fmt.Sprintf("Skipped: %s", r.Detail))
+			}
+
+			if r.Hint != "" {
+				msg.ShowInfo(title, fmt.Sprintf("Hint: %s", r.Hint))
+			}
+		}
+
+		summary := fmt.Sprintf("%d ok, %d warnings, %d failed, %d skipped",
+			counts[doctor.StatusOK], counts[doctor.StatusWarn], counts[doctor.StatusFail],
+			counts[doctor.StatusSkip])
+
+		if doctor.HasFailures(results) {
+			msg.ShowError(prefix, summary, nil)
+			return fmt.Errorf("%d check(s) failed", counts[doctor.StatusFail])
+		}
+
+		msg.ShowSuccess(prefix, summary)
+
+		return nil
+	},
+}
+
+func addDoctorCmdFlags() {
+	Cmd.Flags().StringSliceVarP(&doctorChecks, "checks", "", []string{},
+		"Checks to run (E.g.: 'aws,git'). All of them are run by default.")
+
+	Cmd.Flags().StringVarP(&doctorTargetModule, "target-module", "", "",
+		"The terragrunt module (relative to the work dir) that should be in a git repository.")
+
+	Cmd.Flags().DurationVarP(&doctorDaggerTimeout, "dagger-timeout", "",
+		doctor.DefaultDaggerTimeout,
+		"Time to wait for the dagger engine. The first connection downloads the engine.")
+}
+
+func init() {
+	addDoctorCmdFlags()
+}
@@ -0,0 +1,22 @@
+package env
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "env",
+	Long: `The 'env' command inspects the environment variables that the jobs set in their
+containers, based on the same scan options (E.g.: --scan-aws-keys, --dot-env-file, etc.).`,
+	Example: `
+  # Explain where each environment variable comes from:
+  stiletto env explain --scan-all-env-vars --set-env=STAGE=dev`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(ExplainCmd)
+}
@@ -0,0 +1,148 @@
+package env
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/secrets"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/job"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var jobName string
+
+var ExplainCmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "explain",
+	Long: `The 'explain' command prints each environment variable that a job would set, along with
+the source that won and the sources it shadowed. The values are always masked.`,
+	Example: `
+  # Explain the environment variables of the 'ecs' job, with its own precedence (if any):
+  stiletto env explain --job=ecs --scan-aws-keys --scan-all-env-vars`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		msg := tui.NewTUIMessage()
+		prefix := "ENV:EXPLAIN"
+
+		cliGlobalArgs, err := config.GetCLIGlobalArgs()
+		if err != nil {
+			msg.ShowError(prefix, "Failed to resolve the arguments", err)
+			return err
+		}
+
+		taskName := cliGlobalArgs.TaskName
+		if taskName == "" {
+			taskName = "explain"
+		}
+
+		p, err := pipeline.New(cmd.Context(), cliGlobalArgs.WorkingDir, cliGlobalArgs.MountDir,
+			cliGlobalArgs.TargetDir, taskName,
+			cliGlobalArgs.ScanEnvVarKeys,
+			cliGlobalArgs.EnvKeyValuePairsToSetString, cliGlobalArgs.ScanAWSKeys,
+			cliGlobalArgs.ScanTerraformVars, cliGlobalArgs.ScanAllEnvVars,
+			cliGlobalArgs.DotEnvFiles, cliGlobalArgs.Environment,
+			cliGlobalArgs.ScanEnvVarsWithPrefix,
+			cliGlobalArgs.InitDaggerWithWorkDirByDefault)
+
+		if err != nil {
+			msg.ShowError(prefix, "Failed pipeline initialization", err)
+			return err
+		}
+
+		precedence, err := job.GetEnvPrecedence(jobName)
+		if err != nil {
+			msg.ShowError(prefix, "Failed to resolve the env vars precedence", err)
+			return err
+		}
+
+		isScanDotEnv := len(cliGlobalArgs.DotEnvFiles) > 0 || cliGlobalArgs.Environment != ""
+
+		sources, origins, err := job.ScanEnvVarsSources(p, job.InitOptions{
+			Name:                    common.NormaliseStringUpper(jobName),
+			WorkDir:                 p.PipelineOpts.WorkDir,
+			TargetDir:               p.PipelineOpts.TargetDir,
+			MountDir:                p.PipelineOpts.MountDir,
+			ScanAWSEnvVars:          cliGlobalArgs.ScanAWSKeys,
+			ScanTerraformEnvVars:    cliGlobalArgs.ScanTerraformVars,
+			IsScanEnvVarsFromDotEnv: isScanDotEnv,
+			IsScanEnvVarsFromPrefix: len(cliGlobalArgs.ScanEnvVarsWithPrefix) > 0,
+			EnvVarsToSet:            cliGlobalArgs.EnvKeyValuePairsToSetString,
+			EnvVarsToScan:           cliGlobalArgs.ScanEnvVarKeys,
+			DotEnvFiles:             cliGlobalArgs.DotEnvFiles,
+			Environment:             cliGlobalArgs.Environment,
+			EnvVarsWithPrefixToScan: cliGlobalArgs.ScanEnvVarsWithPrefix,
+			EnvPrecedence:           precedence,
+			HostEnvFilter: filesystem.HostEnvFilter{
+				Allow: cliGlobalArgs.EnvAllow,
+				Deny:  cliGlobalArgs.EnvDeny,
+			},
+		})
+
+		if err != nil {
+			msg.ShowError(prefix, "Failed to scan the environment variables", err)
+			return err
+		}
+
+		_, report := job.ResolveEnvVars(sources, origins, precedence)
+
+		msg.ShowInfo(prefix, fmt.Sprintf("Precedence (lowest to highest): %s",
+			strings.Join(precedence, " < ")))
+
+		if len(report) == 0 {
+			msg.ShowWarning(prefix, "No environment variables would be set, "+
+				"check the scan options passed")
+			return nil
+		}
+
+		secretsRegistry := secrets.NewDefaultRegistry(p.PipelineOpts.WorkDirPath)
+
+		rows := [][]string{{"KEY", "SOURCE", "VALUE", "SHADOWED"}}
+		for _, r := range report {
+			var shadowed []string
+			for _, s := range r.Shadowed {
+				shadowed = append(shadowed, fmt.Sprintf("%s=%s", getSourceLabel(s.Source,
+					s.Origin), job.MaskEnvVarValue(s.Value)))
+			}
+
+			// Secret references aren't sensitive, the values they resolve to are.
+			value := job.MaskEnvVarValue(r.Value)
+			if common.IsStringInSlice(r.Source, job.SecretRefSources) &&
+				secretsRegistry.IsReference(r.Value) {
+				value = fmt.Sprintf("%s (secret)", r.Value)
+			}
+
+			rows = append(rows, []string{r.Key, getSourceLabel(r.Source, r.Origin), value,
+				strings.Join(shadowed, ", ")})
+		}
+
+		tui.ShowTable(rows)
+
+		return nil
+	},
+}
+
+// getSourceLabel shows the origin of the source (E.g.: the .env file), if it's known.
+func getSourceLabel(source, origin string) string {
+	if origin == "" {
+		return source
+	}
+
+	return fmt.Sprintf("%s (%s)", source, origin)
+}
+
+func addExplainCmdFlags() {
+	ExplainCmd.Flags().StringVarP(&jobName, "job", "", "",
+		"The job (E.g.: ecs, ecr, build, terragrunt) whose env precedence is used, "+
+			"if it's overridden in the config file.")
+}
+
+func init() {
+	addExplainCmdFlags()
+}
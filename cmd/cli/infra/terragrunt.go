@@ -3,13 +3,13 @@ package infra
 import (
 	"fmt"
 	"github.com/Excoriate/stiletto/internal/api"
+	"github.com/Excoriate/stiletto/internal/errors"
 	"github.com/Excoriate/stiletto/internal/tui"
 	"github.com/Excoriate/stiletto/pkg/config"
 	"github.com/Excoriate/stiletto/pkg/task"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"log"
-	"os"
 )
 
 var (
@@ -119,19 +119,44 @@ var TgCmd = &cobra.Command{
 
 		return argErr
 	},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
 		stackName := "INFRA:TERRAGRUNT"
 		jobName := "IAC"
 
 		cliGlobalArgs, err := config.GetCLIGlobalArgs()
 
 		if err != nil {
-			panic(err)
+			msg.ShowError("INIT", "Failed to resolve the arguments", err)
+			return err
+		}
+
+		if cliGlobalArgs.DryRun {
+			if err := api.DryRun(cmd.Context(), &cliGlobalArgs, stackName, jobName,
+				task.PlanTaskInfraTerraGrunt, task.InitOptions{
+					Task:           cliGlobalArgs.TaskName,
+					ActionCommands: cliGlobalArgs.CustomCommands,
+					Outputs:        cliGlobalArgs.Outputs,
+				}); err != nil {
+				return err
+			}
+
+			return nil
+		}
+
+		p, j, err := api.New(cmd.Context(), &cliGlobalArgs, stackName, jobName)
+		if errors.IsTaskSkippedError(err) {
+			task.ShowOutputStatus(stackName, jobName, cliGlobalArgs.TaskName,
+				task.NewSkippedOutput(err.Error()))
+			return nil
 		}
 
-		p, j, err := api.New(&cliGlobalArgs, stackName, jobName)
 		if err != nil {
-			panic(err)
+			task.ShowIfCancelled(stackName, jobName, cliGlobalArgs.TaskName)
+			return err
 		}
 
 		ux.ShowSubTitle("TASK:", cliGlobalArgs.TaskName)
@@ -139,23 +164,37 @@ var TgCmd = &cobra.Command{
 			j.TargetDirPath,
 			j.MountDirPath)
 
-		err = task.RunTaskInfraTerraGrunt(task.InitOptions{
-			Task:           cliGlobalArgs.TaskName,
-			Stack:          stackName,
-			PipelineCfg:    p,
-			JobCfg:         j,
-			WorkDir:        p.PipelineOpts.WorkDir,
-			MountDir:       p.PipelineOpts.MountDir,
-			TargetDir:      p.PipelineOpts.TargetDir,
-			ActionCommands: cliGlobalArgs.CustomCommands,
+		out, err := task.RunTaskInfraTerraGrunt(task.InitOptions{
+			Task:            cliGlobalArgs.TaskName,
+			Stack:           stackName,
+			PipelineCfg:     p,
+			JobCfg:          j,
+			WorkDir:         p.PipelineOpts.WorkDir,
+			MountDir:        p.PipelineOpts.MountDir,
+			TargetDir:       p.PipelineOpts.TargetDir,
+			ActionCommands:  cliGlobalArgs.CustomCommands,
+			Outputs:         cliGlobalArgs.Outputs,
+			ArtifactsDir:    cliGlobalArgs.ArtifactsDir,
+			LogsDir:         cliGlobalArgs.LogsDir,
+			ShowOutput:      cliGlobalArgs.ShowOutput,
+			StderrTailLines: cliGlobalArgs.StderrTailLines,
+			Policy:          cliGlobalArgs.TaskPolicy,
 		})
 
 		if err != nil {
+			if task.ShowIfCancelled(stackName, jobName, cliGlobalArgs.TaskName) {
+				return err
+			}
+
 			msg.ShowError("", fmt.Sprintf("Failed to run task '%s' as part of job %s on stack '%s'",
 				cliGlobalArgs.TaskName, jobName, stackName), err)
-			os.Exit(1)
+			return err
 		}
 
+		out.Status = task.OutputStatusSucceeded
+		task.ShowOutputStatus(stackName, jobName, cliGlobalArgs.TaskName, out)
+
+		return nil
 	},
 }
 
@@ -0,0 +1,169 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"time"
+)
+
+var lockUpdate []string
+
+var Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "lock [stacks]",
+	Long: `The 'lock' command resolves the image of each stack (E.g.: 'alpine:latest') to its current
+digest, and writes them into the stiletto.lock file of the work dir. The next runs use the
+locked digests ('image@sha256:...'), so a moved tag doesn't change them. A stack whose image
+changed since it was locked is stale: the runs warn about it, or fail with --lock-mode=strict.
+
+Only the stacks in use are locked: the ones passed as arguments or else, the ones declared in
+the config file and the ones already in the lock file. Without --update, only the stacks that
+are missing or stale in the lock file are resolved. Stacks pinned by digest in the config file
+aren't locked.`,
+	Example: `
+  # Lock the stacks declared in the config file (or already locked), if they aren't locked yet:
+  stiletto lock
+
+  # Lock the docker and terragrunt stacks:
+  stiletto lock docker infra:terragrunt
+
+  # Re-resolve the digest of the terragrunt stack:
+  stiletto lock --update infra:terragrunt`,
+	Args: cobra.ArbitraryArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		msg := tui.NewTUIMessage()
+		prefix := "LOCK"
+
+		fail := func(detail string, err error) error {
+			msg.ShowError(prefix, detail, err)
+			return err
+		}
+
+		workDirCfg, err := pipeline.IsWorkDirValid(viper.GetString("work-dir"))
+		if err != nil {
+			return fail("Failed to resolve the work dir", err)
+		}
+
+		stacks, err := config.GetStacks()
+		if err != nil {
+			return fail("Failed to resolve the stacks", err)
+		}
+
+		lockPath := filepath.Join(workDirCfg.Path, daggerio.LockFileName)
+		lock, err := daggerio.LoadLockFile(lockPath)
+		if err != nil {
+			return fail("Failed to read the lock file", err)
+		}
+
+		if lock == nil {
+			lock = daggerio.NewLockFile()
+		}
+
+		selected, err := lock.SelectStacks(stacks, append(args, lockUpdate...),
+			config.GetConfiguredStackNames())
+		if err != nil {
+			return fail("Failed to resolve the stacks to lock", err)
+		}
+
+		if len(selected) == 0 {
+			msg.ShowWarning(prefix, "There is no stack to lock: pass them (E.g.: 'stiletto lock "+
+				"docker'), or declare them in the config file")
+			return nil
+		}
+
+		toResolve, err := lock.GetStacksToResolve(selected, lockUpdate)
+		if err != nil {
+			return fail("Failed to resolve the stacks to lock", err)
+		}
+
+		if len(lockUpdate) == 0 {
+			for _, name := range lock.Prune(stacks) {
+				msg.ShowInfo(prefix, fmt.Sprintf("Removed the stack %s from the lock file", name))
+			}
+		}
+
+		if len(toResolve) > 0 {
+			if err := resolveStacks(cmd.Context(), lock, selected, toResolve); err != nil {
+				return fail("Failed to lock the stacks", err)
+			}
+		}
+
+		if err := lock.Save(lockPath); err != nil {
+			return fail("Failed to write the lock file", err)
+		}
+
+		resolved := map[string]bool{}
+		for _, name := range toResolve {
+			resolved[name] = true
+		}
+
+		rows := [][]string{{"STACK", "IMAGE", "DIGEST", "STATUS"}}
+		for _, name := range daggerio.GetStackNames(selected) {
+			status := lock.GetStatus(selected[name])
+			digest := lock.Stacks[name].Digest
+
+			if status == daggerio.LockStatusPinned {
+				digest = selected[name].Digest
+			} else if resolved[name] {
+				status = "updated"
+			}
+
+			rows = append(rows, []string{name, selected[name].GetImageRef(), digest, status})
+		}
+
+		tui.ShowTable(rows)
+		msg.ShowSuccess(prefix, fmt.Sprintf("%d stack(s) resolved, lock file written to %s",
+			len(toResolve), lockPath))
+
+		return nil
+	},
+}
+
+// resolveStacks resolves the digest of the image of each stack into the lock file.
+func resolveStacks(ctx context.Context, lock *daggerio.LockFile,
+	stacks map[string]daggerio.StackDefinition, toResolve []string) error {
+	client, err := daggerio.NewDaggerClient("", &ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the dagger engine: %w", err)
+	}
+
+	defer client.Close()
+
+	for _, name := range toResolve {
+		image := stacks[name].GetImageRef()
+
+		digest, err := daggerio.ResolveImageDigest(ctx, client, image)
+		if err != nil {
+			return fmt.Errorf("failed to resolve the digest of the stack %s: %w", name, err)
+		}
+
+		lock.Stacks[name] = daggerio.LockedImage{
+			Image:      image,
+			Digest:     digest,
+			ResolvedAt: time.Now().UTC(),
+		}
+	}
+
+	return nil
+}
+
+func addLockCmdFlags() {
+	Cmd.Flags().StringSliceVarP(&lockUpdate, "update", "", []string{},
+		"Stacks (E.g.: infra:terragrunt) whose digest is re-resolved, even if their lock is "+
+			"up-to-date. It can be passed multiple times.")
+}
+
+func init() {
+	addLockCmdFlags()
+}
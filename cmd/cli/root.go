@@ -4,11 +4,25 @@ import (
 	"context"
 	"fmt"
 	"github.com/Excoriate/stiletto/cmd/cli/aws"
+	"github.com/Excoriate/stiletto/cmd/cli/cache"
+	configCmd "github.com/Excoriate/stiletto/cmd/cli/config"
 	"github.com/Excoriate/stiletto/cmd/cli/docker"
+	"github.com/Excoriate/stiletto/cmd/cli/doctor"
+	"github.com/Excoriate/stiletto/cmd/cli/env"
 	"github.com/Excoriate/stiletto/cmd/cli/infra"
+	"github.com/Excoriate/stiletto/cmd/cli/lock"
+	"github.com/Excoriate/stiletto/cmd/cli/run"
+	"github.com/Excoriate/stiletto/cmd/cli/stacks"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/task"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"os"
+	"strings"
+	"time"
 )
 
 var (
@@ -23,10 +37,35 @@ var (
 	GlobalScanTFVars                  bool
 	GlobalScanEnvVarsWithPrefix       []string
 	GlobalScanAllEnvVars              bool
-	GlobalDotEnvFile                  string
+	GlobalEnvAllow                    []string
+	GlobalEnvDeny                     []string
+	GlobalDotEnvFiles                 []string
+	GlobalEnvironment                 string
 	GlobalCustomCMDs                  []string
 	GlobalDaggerInitClientWithWorkDir bool
 	GlobalRunInVendor                 bool
+	GlobalEnvPrecedence               []string
+	GlobalOnlyIfChanged               bool
+	GlobalSince                       string
+	GlobalChangedPaths                []string
+	GlobalUploadInclude               []string
+	GlobalUploadExclude               []string
+	GlobalOutputs                     []string
+	GlobalArtifactsDir                string
+	GlobalCacheNamespace              string
+	GlobalCacheLockFileKey            bool
+	GlobalNoCacheVolumes              bool
+	GlobalLockMode                    string
+	GlobalLogsDir                     string
+	GlobalShowOutput                  bool
+	GlobalStderrTailLines             int
+	GlobalTimeout                     time.Duration
+	GlobalRetries                     int
+	GlobalRetryBackoff                time.Duration
+	GlobalRetryOn                     []string
+	GlobalDryRun                      bool
+	GlobalDryRunFormat                string
+	GlobalProfile                     string
 
 	// Configuration file
 	cfgFile string
@@ -40,13 +79,38 @@ var rootCmd = &cobra.Command{
   stiletto <command> <subcommand> --workdir /path/to/working/directory --task
   E.g.:
   stiletto aws ecr --workdir /path/to/working/directory  --task=push`,
+	// The config files (and the profile) are loaded before the flags are validated; the errors
+	// are returned here, so the commands don't run with a partial (or invalid) config.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := config.GetLoadedConfig(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		if err := config.ValidateConfig(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		_ = cmd.Help()
 	},
 }
 
 func Execute() {
-	err := rootCmd.ExecuteContext(context.Background())
+	// Cancelled on SIGINT/SIGTERM, so the work in progress stops and the cleanup hooks run.
+	ctx, stop := common.NotifyInterrupt(context.Background())
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+
+	common.RunCleanups()
+
+	if sig := common.GetInterruptSignal(); sig != nil {
+		os.Exit(common.GetInterruptExitCode(sig))
+	}
+
 	if err != nil {
 		os.Exit(1)
 	}
@@ -84,12 +148,14 @@ func addPersistentFlags() {
 	rootCmd.PersistentFlags().StringToStringVarP(&GlobalEnvKeyValuePairsToSet,
 		"set-env",
 		"e", map[string]string{},
-		"List of environment variable key-value pairs to set.")
+		"List of environment variable key-value pairs to set. Values can be secret references "+
+			"(E.g.: ssm:///app/db/password, secretsmanager://prod/api#key, file://./secrets/token, "+
+			"env://OTHER_VAR), that are resolved and set as secrets.")
 
-	rootCmd.PersistentFlags().StringSliceVarP(&GlobalCustomCommands,
+	rootCmd.PersistentFlags().StringArrayVarP(&GlobalCustomCommands,
 		"commands",
 		"c", []string{},
-		"List of custom commands to run.")
+		"Custom command to run, repeat the flag to run several ones (commas aren't separators).")
 
 	rootCmd.PersistentFlags().BoolVarP(&GlobalScanAWSKeys,
 		"scan-aws-keys",
@@ -112,10 +178,142 @@ func addPersistentFlags() {
 		"", false,
 		"Scan all environment variables and set them into the generated containers.")
 
-	rootCmd.PersistentFlags().StringSliceVarP(&GlobalCustomCMDs,
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalEnvAllow,
+		"env-allow",
+		"", []string{},
+		"Globs (E.g.: 'APP_*') of the host environment variables to pass when "+
+			"--scan-all-env-vars is set. If passed, only the matching variables are passed, "+
+			"including the ones excluded by default.")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalEnvDeny,
+		"env-deny",
+		"", []string{},
+		"Globs (E.g.: '*_TOKEN') of the host environment variables to exclude when "+
+			"--scan-all-env-vars is set. Host specific (E.g.: PATH, HOME, SSH_*) and CI internal "+
+			"variables are always excluded, unless they're allowed explicitly.")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalUploadInclude,
+		"include",
+		"", []string{},
+		"Patterns (.dockerignore syntax, relative to the work dir. E.g.: 'src/', 'package*.json') "+
+			"of the files to upload into the dagger engine. If passed, only the matching files are "+
+			"uploaded.")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalUploadExclude,
+		"exclude",
+		"", []string{},
+		"Patterns (.dockerignore syntax, relative to the work dir. E.g.: '**/node_modules', '.git') "+
+			"of the files to not upload into the dagger engine. They're applied after the ones of "+
+			"the .stilettoignore file (gitignore syntax), and the .dockerignore file (docker tasks). "+
+			"The .stiletto dirs, and the logs and artifacts dirs, are always excluded (use "+
+			"'!<pattern>' to re-include them).")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalOutputs,
+		"output",
+		"", []string{},
+		"Paths in the container (relative to its workdir, globs allowed. E.g.: 'dist', "+
+			"'build/*.zip') to export into the artifacts dir after the task succeeds.")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalArtifactsDir,
+		"artifacts-dir",
+		"", "",
+		"Host directory (relative to the work dir) where the outputs are exported. "+
+			"Defaults to '.stiletto/artifacts' under the target dir.")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalCacheNamespace,
+		"cache-namespace",
+		"", daggerio.DefaultCacheNamespace,
+		"Namespace of the dependency cache volumes (E.g.: the branch name), "+
+			"to isolate them from the ones of other namespaces.")
+
+	rootCmd.PersistentFlags().BoolVarP(&GlobalCacheLockFileKey,
+		"cache-lockfile-key",
+		"", false,
+		"Key the dependency cache volumes by the hash of the lock files (E.g.: package-lock.json, "+
+			"go.sum) in the target dir, so a dependency change starts from an empty cache.")
+
+	rootCmd.PersistentFlags().BoolVarP(&GlobalNoCacheVolumes,
+		"no-cache-volumes",
+		"", false,
+		"Don't mount the dependency cache volumes (E.g.: ~/.npm, ~/.cache/pip) into the containers.")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalLockMode,
+		"lock-mode",
+		"", daggerio.LockModeWarn,
+		"What to do when the stack isn't locked in the stiletto.lock file of the work dir, or its "+
+			"lock is stale: 'warn' (run with the unpinned image), 'strict' (fail), or 'ignore' "+
+			"(don't use the lock file).")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalLogsDir,
+		"logs-dir",
+		"", "",
+		"Host directory (relative to the work dir) where the stdout and stderr of each command are "+
+			"written, under '<run>/<task>/<n>-<cmd>.log'. Defaults to '.stiletto/logs'.")
+
+	rootCmd.PersistentFlags().BoolVarP(&GlobalShowOutput,
+		"show-output",
+		"", false,
+		"Print the stdout and stderr of each command once it completes (not live), prefixed "+
+			"with '[job/task/step]'.")
+
+	rootCmd.PersistentFlags().IntVarP(&GlobalStderrTailLines,
+		"stderr-tail",
+		"", task.DefaultStderrTailLines,
+		"Number of stderr lines of a failed command reported in the error.")
+
+	rootCmd.PersistentFlags().DurationVarP(&GlobalTimeout,
+		"timeout",
+		"", 0,
+		"Maximum duration (E.g.: 10m) of each attempt of the task action, cancelling it when "+
+			"exceeded. No timeout by default. It can be set per task in the config file "+
+			"('tasks.<task>.timeout', or 'jobs.<job>.tasks.<task>.timeout' for a single job).")
+
+	rootCmd.PersistentFlags().IntVarP(&GlobalRetries,
+		"retries",
+		"", 1,
+		"Maximum number of attempts of the task action (1 means no retries). The actions that "+
+			"aren't idempotent (E.g.: deploy, apply) are only retried with --retry-on. It can be "+
+			"set per task in the config file ('tasks.<task>.retry.max-attempts', or "+
+			"'jobs.<job>.tasks.<task>.retry.max-attempts' for a single job).")
+
+	rootCmd.PersistentFlags().DurationVarP(&GlobalRetryBackoff,
+		"retry-backoff",
+		"", common.DefaultRetryBackoff,
+		"Wait before the first retry, doubled on each further retry (up to 1m).")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalRetryOn,
+		"retry-on",
+		"", []string{},
+		"Regular expressions matched against the error (and the stderr) of a failed attempt; "+
+			"only the matching failures are retried. If none is passed, any failure is retried.")
+
+	rootCmd.PersistentFlags().BoolVarP(&GlobalDryRun,
+		"dry-run",
+		"", false,
+		"Resolve the pipeline (dirs, image, env vars, mounts and the commands of the task) and "+
+			"print it, without running it, nor connecting to the dagger engine or AWS.")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalDryRunFormat,
+		"dry-run-format",
+		"", "text",
+		"Format of the --dry-run report: 'text' or 'json'.")
+
+	rootCmd.PersistentFlags().StringVarP(&cfgFile,
+		"config",
+		"", "",
+		"Config file to use, instead of the .stiletto.yaml files found from the work dir up to "+
+			"the git root. The user-level one (~/.stiletto.yaml) is still merged first.")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalProfile,
+		"profile",
+		"", "",
+		"Profile of the config files to apply (from the 'profiles' section). "+
+			"E.g.: 'dev', 'prod'.")
+
+	rootCmd.PersistentFlags().StringArrayVarP(&GlobalCustomCMDs,
 		"custom-cmds",
 		"u", []string{},
-		"List of custom commands to run.")
+		"Custom command to run, repeat the flag to run several ones (commas aren't separators).")
 
 	rootCmd.PersistentFlags().BoolVarP(&GlobalDaggerInitClientWithWorkDir,
 		"init-dagger-with-workdir",
@@ -127,10 +325,44 @@ func addPersistentFlags() {
 		"", false,
 		"Run in vendor mode. If so, it'll limit some 'host' specific commands to run.")
 
-	rootCmd.PersistentFlags().StringVarP(&GlobalDotEnvFile,
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalDotEnvFiles,
 		"dot-env-file",
+		"", []string{},
+		"Scan environment variables from a .env file and set them into the generated containers. "+
+			"It can be passed multiple times, being the later files the winners. Values can be "+
+			"secret references, as in --set-env.")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalEnvironment,
+		"environment",
+		"", "",
+		"Environment whose .env files are loaded from the work dir, if they exist: .env, "+
+			".env.<environment>, .env.local and .env.<environment>.local (later files win). "+
+			"Files passed with --dot-env-file take precedence over them.")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalEnvPrecedence,
+		"env-precedence",
+		"", []string{},
+		"Order in which the environment variables sources are merged, from the lowest to the "+
+			"highest precedence. E.g.: host,git,prefix,custom,terraform,aws,dotenv,set (default). "+
+			"It can be set per job in the config file, under 'jobs.<job>.env-precedence'.")
+
+	rootCmd.PersistentFlags().BoolVarP(&GlobalOnlyIfChanged,
+		"only-if-changed",
+		"", false,
+		"Skip the task if no file changed under its target dir (or --changed-paths). Changes are "+
+			"computed between --since and HEAD, plus the uncommitted and untracked (not ignored) ones.")
+
+	rootCmd.PersistentFlags().StringVarP(&GlobalSince,
+		"since",
 		"", "",
-		"Scan environment variables from a .env file and set them into the generated containers.")
+		"Git revision (E.g.: origin/main, v1.2.0, HEAD~1) to compute the changes from, "+
+			"with --only-if-changed. If it's not set, only the uncommitted changes are checked.")
+
+	rootCmd.PersistentFlags().StringSliceVarP(&GlobalChangedPaths,
+		"changed-paths",
+		"", []string{},
+		"Extra paths (relative to the work dir) whose changes make the task run, "+
+			"with --only-if-changed. E.g.: shared modules.")
 
 	_ = viper.BindPFlag("task", rootCmd.PersistentFlags().Lookup("task"))
 	_ = viper.BindPFlag("work-dir", rootCmd.PersistentFlags().Lookup("work-dir"))
@@ -141,43 +373,87 @@ func addPersistentFlags() {
 	_ = viper.BindPFlag("scan-aws-keys", rootCmd.PersistentFlags().Lookup("scan-aws-keys"))
 	_ = viper.BindPFlag("scan-terraform-vars", rootCmd.PersistentFlags().Lookup("scan-terraform-vars"))
 	_ = viper.BindPFlag("scan-env-vars-prefix", rootCmd.PersistentFlags().Lookup("scan-env-vars-prefix"))
+	_ = viper.BindPFlag("commands", rootCmd.PersistentFlags().Lookup("commands"))
 	_ = viper.BindPFlag("custom-cmds", rootCmd.PersistentFlags().Lookup("custom-cmds"))
 	_ = viper.BindPFlag("init-dagger-with-workdir", rootCmd.PersistentFlags().Lookup(
 		"init-dagger-with-workdir"))
 	_ = viper.BindPFlag("run-in-vendor", rootCmd.PersistentFlags().Lookup("run-in-vendor"))
 	_ = viper.BindPFlag("scan-all-env-vars", rootCmd.PersistentFlags().Lookup("scan-all-env-vars"))
+	_ = viper.BindPFlag("env-allow", rootCmd.PersistentFlags().Lookup("env-allow"))
+	_ = viper.BindPFlag("env-deny", rootCmd.PersistentFlags().Lookup("env-deny"))
 	_ = viper.BindPFlag("dot-env-file", rootCmd.PersistentFlags().Lookup("dot-env-file"))
+	_ = viper.BindPFlag("environment", rootCmd.PersistentFlags().Lookup("environment"))
+	_ = viper.BindPFlag("env-precedence", rootCmd.PersistentFlags().Lookup("env-precedence"))
+	_ = viper.BindPFlag("only-if-changed", rootCmd.PersistentFlags().Lookup("only-if-changed"))
+	_ = viper.BindPFlag("since", rootCmd.PersistentFlags().Lookup("since"))
+	_ = viper.BindPFlag("changed-paths", rootCmd.PersistentFlags().Lookup("changed-paths"))
+	_ = viper.BindPFlag("include", rootCmd.PersistentFlags().Lookup("include"))
+	_ = viper.BindPFlag("exclude", rootCmd.PersistentFlags().Lookup("exclude"))
+	_ = viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	_ = viper.BindPFlag("artifacts-dir", rootCmd.PersistentFlags().Lookup("artifacts-dir"))
+	_ = viper.BindPFlag("cache-namespace", rootCmd.PersistentFlags().Lookup("cache-namespace"))
+	_ = viper.BindPFlag("cache-lockfile-key", rootCmd.PersistentFlags().Lookup("cache-lockfile-key"))
+	_ = viper.BindPFlag("no-cache-volumes", rootCmd.PersistentFlags().Lookup("no-cache-volumes"))
+	_ = viper.BindPFlag("lock-mode", rootCmd.PersistentFlags().Lookup("lock-mode"))
+	_ = viper.BindPFlag("logs-dir", rootCmd.PersistentFlags().Lookup("logs-dir"))
+	_ = viper.BindPFlag("show-output", rootCmd.PersistentFlags().Lookup("show-output"))
+	_ = viper.BindPFlag("stderr-tail", rootCmd.PersistentFlags().Lookup("stderr-tail"))
+	_ = viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	_ = viper.BindPFlag("retries", rootCmd.PersistentFlags().Lookup("retries"))
+	_ = viper.BindPFlag("retry-backoff", rootCmd.PersistentFlags().Lookup("retry-backoff"))
+	_ = viper.BindPFlag("retry-on", rootCmd.PersistentFlags().Lookup("retry-on"))
+	_ = viper.BindPFlag("dry-run", rootCmd.PersistentFlags().Lookup("dry-run"))
+	_ = viper.BindPFlag("dry-run-format", rootCmd.PersistentFlags().Lookup("dry-run-format"))
+	_ = viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
 }
 
 func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Find home directory.
-		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
-
-		// Search config in home directory with name ".k8sgpt.git" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigType("yaml")
-		viper.SetConfigName(".stiletto")
-
-		_ = viper.SafeWriteConfig()
-		//if err != nil {
-		//	// Check if error relates to the file already exist.
-		//	// If it does, then it's fine, otherwise, exit.
-		//	if !os.IsExist(err) {
-		//		fmt.Println(err)
-		//		os.Exit(1)
-		//	}
-		//	//os.Exit(1)
-		//}
+	viper.AutomaticEnv() // read in environment variables that match
+
+	// The user-level config file, and the project ones (from the git root down to the work dir)
+	// are merged, without writing any. The errors are returned before running the command.
+	files, err := config.GetConfigFiles(viper.GetString("work-dir"), cfgFile)
+	if err == nil {
+		err = config.LoadConfigFiles(files, viper.GetString("profile"))
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	if err != nil {
+		return
+	}
+
+	setRequiredFlagsFromConfig(rootCmd)
+
+	loaded, _ := config.GetLoadedConfig()
+	if len(loaded.Files) == 0 {
+		return
+	}
+
+	usedFiles := strings.Join(loaded.Files, ", ")
+	if loaded.Profile != "" {
+		usedFiles = fmt.Sprintf("%s (profile: %s)", usedFiles, loaded.Profile)
+	}
+
+	// On stderr, so it doesn't break the machine-readable output (E.g.: --dry-run-format=json).
+	_, _ = fmt.Fprintln(os.Stderr, "Using config files:", usedFiles)
+}
+
+// setRequiredFlagsFromConfig sets the required flags that weren't passed, but are in the config
+// files (or the profile), so they don't fail the validation of the required flags.
+func setRequiredFlagsFromConfig(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if _, required := f.Annotations[cobra.BashCompOneRequiredFlag]; !required || f.Changed {
+			return
+		}
+
+		if !viper.InConfig(f.Name) || viper.GetString(f.Name) == "" {
+			return
+		}
+
+		_ = cmd.Flags().Set(f.Name, viper.GetString(f.Name))
+	})
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	for _, c := range cmd.Commands() {
+		setRequiredFlagsFromConfig(c)
 	}
 }
 
@@ -187,6 +463,13 @@ func init() {
 	rootCmd.AddCommand(docker.Cmd)
 	rootCmd.AddCommand(aws.Cmd)
 	rootCmd.AddCommand(infra.Cmd)
+	rootCmd.AddCommand(env.Cmd)
+	rootCmd.AddCommand(cache.Cmd)
+	rootCmd.AddCommand(stacks.Cmd)
+	rootCmd.AddCommand(lock.Cmd)
+	rootCmd.AddCommand(run.Cmd)
+	rootCmd.AddCommand(doctor.Cmd)
+	rootCmd.AddCommand(configCmd.Cmd)
 
 	_ = rootCmd.MarkFlagRequired("task")
 	_ = rootCmd.MarkFlagRequired("workdir")
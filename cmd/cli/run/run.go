@@ -0,0 +1,159 @@
+package run
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/api"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/task"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runStack    string
+	runCommands []string
+	runShell    bool
+	runScript   string
+)
+
+var Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "run",
+	Long: `The 'run' command runs arbitrary commands in the container of any stack (see
+'stiletto stacks list'), with the target dir mounted and the environment variables resolved as in
+any other task. The commands run sequentially, stopping on the first one that fails. If no
+command (or script) is passed, the default commands of the stack are run.
+
+The commands are split into their arguments following the shell quoting rules, but they don't
+run in a shell: use --shell for pipelines, redirections or variables. Commands run with --shell,
+and scripts, stop on the first failure, on unset variables and on failed pipelines
+('set -euo pipefail').`,
+	Example: `
+  # Run the tests and the linter of a python project:
+  stiletto run --stack=python --cmd "pytest -q" --cmd "ruff check ."
+
+  # Run a pipeline through a shell:
+  stiletto run --stack=alpine --shell --cmd "cat requirements.txt | grep -v '^#' | wc -l"
+
+  # Run a script of the work dir:
+  stiletto run --stack=alpine --script scripts/ci.sh`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		ux := tui.TUITitle{}
+		msg := tui.NewTUIMessage()
+
+		jobName := "RUN"
+
+		cliGlobalArgs, err := config.GetCLIGlobalArgs()
+
+		if err != nil {
+			msg.ShowError("INIT", "Failed to resolve the arguments", err)
+			return err
+		}
+
+		// The policy of the task (E.g.: 'tasks.run') is resolved along with the job.
+		if cliGlobalArgs.TaskName == "" {
+			cliGlobalArgs.TaskName = "run"
+		}
+
+		// The commands passed to this command win over the global ones (--commands).
+		commands := cliGlobalArgs.CustomCommands
+		if len(runCommands) > 0 {
+			commands = runCommands
+		}
+
+		if cliGlobalArgs.DryRun {
+			if err := api.DryRun(cmd.Context(), &cliGlobalArgs, runStack, jobName,
+				task.PlanTaskRun, task.InitOptions{
+					Task:           cliGlobalArgs.TaskName,
+					ActionCommands: commands,
+					ShellCommands:  runShell,
+					Script:         runScript,
+					Outputs:        cliGlobalArgs.Outputs,
+				}); err != nil {
+				return err
+			}
+
+			return nil
+		}
+
+		p, j, err := api.New(cmd.Context(), &cliGlobalArgs, runStack, jobName)
+		if errors.IsTaskSkippedError(err) {
+			task.ShowOutputStatus(runStack, jobName, cliGlobalArgs.TaskName,
+				task.NewSkippedOutput(err.Error()))
+			return nil
+		}
+
+		if err != nil {
+			task.ShowIfCancelled(runStack, jobName, cliGlobalArgs.TaskName)
+			return err
+		}
+
+		ux.ShowSubTitle("TASK:", cliGlobalArgs.TaskName)
+		ux.ShowTaskDetails(jobName, cliGlobalArgs.TaskName, j.WorkDirPath,
+			j.TargetDirPath,
+			j.MountDirPath)
+
+		out, err := task.RunTaskRun(task.InitOptions{
+			Task:            cliGlobalArgs.TaskName,
+			Stack:           j.Stack,
+			PipelineCfg:     p,
+			JobCfg:          j,
+			WorkDir:         p.PipelineOpts.WorkDir,
+			MountDir:        p.PipelineOpts.MountDir,
+			TargetDir:       p.PipelineOpts.TargetDir,
+			ActionCommands:  commands,
+			ShellCommands:   runShell,
+			Script:          runScript,
+			Outputs:         cliGlobalArgs.Outputs,
+			ArtifactsDir:    cliGlobalArgs.ArtifactsDir,
+			LogsDir:         cliGlobalArgs.LogsDir,
+			ShowOutput:      cliGlobalArgs.ShowOutput,
+			StderrTailLines: cliGlobalArgs.StderrTailLines,
+			Policy:          cliGlobalArgs.TaskPolicy,
+		})
+
+		if err != nil {
+			if task.ShowIfCancelled(runStack, jobName, cliGlobalArgs.TaskName) {
+				return err
+			}
+
+			msg.ShowError("", fmt.Sprintf("Failed to run task '%s' as part of job %s on stack '%s'",
+				cliGlobalArgs.TaskName, jobName, j.Stack), err)
+			return err
+		}
+
+		out.Status = task.OutputStatusSucceeded
+		task.ShowOutputStatus(j.Stack, jobName, cliGlobalArgs.TaskName, out)
+
+		return nil
+	},
+}
+
+func addRunCmdFlags() {
+	Cmd.Flags().StringVarP(&runStack, "stack", "", "",
+		"The stack (E.g.: python, alpine, or one declared in the config file) whose container "+
+			"runs the commands.")
+
+	Cmd.Flags().StringArrayVarP(&runCommands, "cmd", "", []string{},
+		"Command to run (E.g.: \"pytest -q\"). It can be passed multiple times, the commands "+
+			"run in the order passed.")
+
+	Cmd.Flags().BoolVarP(&runShell, "shell", "", false,
+		"Run the commands through 'sh -c', so pipelines, redirections and variables work.")
+
+	Cmd.Flags().StringVarP(&runScript, "script", "", "",
+		"Script (relative to the work dir) mounted into the container and run after the "+
+			"commands. Shell scripts run with 'set -euo pipefail'; other scripts run with the "+
+			"interpreter of their shebang.")
+
+	_ = Cmd.MarkFlagRequired("stack")
+}
+
+func init() {
+	addRunCmdFlags()
+}
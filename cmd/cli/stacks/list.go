@@ -0,0 +1,79 @@
+package stacks
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var ListCmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "list",
+	Long: `The 'list' command shows the effective definition of each stack: its image, and the
+defaults (env vars, working dir, cache mounts, pre-requisite files and commands) of its
+containers. Stacks are declared, or the built-in ones overridden, in the config file. E.g.:
+
+  stacks:
+    infra:terragrunt:
+      image: alpine/terragrunt
+      version: 1.5.7
+    node:
+      image: node:18-alpine
+      env: ["NODE_ENV=production"]
+      workdir: /app
+      cache-mounts: ["npm", "apk"]
+      prerequisites: ["package.json"]
+      commands: ["npm ci", "npm run build"]`,
+	Example: `
+  # List the effective stacks:
+  stiletto stacks list`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The errors are shown here; Execute runs the cleanups and exits.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		msg := tui.NewTUIMessage()
+		prefix := "STACKS:LIST"
+
+		stacks, err := config.GetStacks()
+		if err != nil {
+			msg.ShowError(prefix, "Failed to resolve the stacks", err)
+			return err
+		}
+
+		rows := [][]string{{"STACK", "IMAGE", "ENV", "WORKDIR", "CACHE MOUNTS", "PREREQUISITES",
+			"COMMANDS", "SOURCE"}}
+
+		for _, name := range daggerio.GetStackNames(stacks) {
+			stack := stacks[name]
+
+			var env []string
+			for _, key := range filesystem.GetSortedEnvVarKeys(stack.Env) {
+				env = append(env, fmt.Sprintf("%s=%s", key, stack.Env[key]))
+			}
+
+			rows = append(rows, []string{name, stack.GetImageRef(), formatList(env, ", "),
+				formatList([]string{stack.WorkDir}, ""), formatList(stack.CacheMounts, ", "),
+				formatList(stack.PreRequisites, ", "), formatList([]string{common.JoinCommands(stack.Commands, "; ")}, ""),
+				stack.Source})
+		}
+
+		tui.ShowTable(rows)
+
+		return nil
+	},
+}
+
+func formatList(values []string, sep string) string {
+	joined := strings.Join(values, sep)
+	if joined == "" {
+		return "-"
+	}
+
+	return joined
+}
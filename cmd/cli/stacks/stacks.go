@@ -0,0 +1,22 @@
+package stacks
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Version: "v0.0.1",
+	Use:     "stacks",
+	Long: `The 'stacks' command shows the stacks the jobs run on: the built-in ones, and the ones
+declared (or overridden) in the 'stacks' section of the config file.`,
+	Example: `
+  # List the effective stacks:
+  stiletto stacks list`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(ListCmd)
+}
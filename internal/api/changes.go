@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"path/filepath"
+	"strings"
+)
+
+// checkChangedPaths returns a TaskSkippedError if no file changed under the target dir (or the
+// extra --changed-paths) since HEAD diverged from the --since revision.
+func checkChangedPaths(p *pipeline.Config, cliArgs *config.CLIGlobalArgs) error {
+	msg := tui.NewTUIMessage()
+
+	paths := []string{p.PipelineOpts.TargetDirPath}
+	for _, path := range cliArgs.ChangedPaths {
+		if path == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.PipelineOpts.WorkDirPath, path)
+		}
+
+		paths = append(paths, path)
+	}
+
+	changes, err := filesystem.GetGitChangedFiles(p.PipelineOpts.WorkDirPath, cliArgs.Since)
+	if err != nil {
+		return errors.NewPipelineConfigurationError("Failed to compute the changed files "+
+			"(--only-if-changed requires the work dir to be a git repository)", err)
+	}
+
+	// Only the uncommitted changes are checked if there's no revision.
+	scope := "uncommitted"
+	if cliArgs.Since != "" {
+		scope = fmt.Sprintf("since '%s'", cliArgs.Since)
+	}
+
+	matched := changes.FilterChangedFiles(paths)
+	if len(matched) == 0 {
+		return errors.NewTaskSkippedError(fmt.Sprintf("no files changed (%s) under %s",
+			scope, strings.Join(paths, ", ")))
+	}
+
+	msg.ShowInfo("CHANGES", fmt.Sprintf("%d file(s) changed (%s): %s", len(matched), scope,
+		strings.Join(matched, ", ")))
+
+	return nil
+}
@@ -0,0 +1,380 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/secrets"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/job"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"github.com/Excoriate/stiletto/pkg/task"
+	"github.com/pterm/pterm"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Formats of the dry-run report.
+const (
+	DryRunFormatText = "text"
+	DryRunFormatJSON = "json"
+)
+
+// DryRunFormats are the formats supported by --dry-run-format.
+var DryRunFormats = []string{DryRunFormatText, DryRunFormatJSON}
+
+// DryRunEnvVar is an env var that the job would set, with its value masked.
+type DryRunEnvVar struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	Value  string `json:"value"`
+}
+
+// DryRunReport is everything that a run would use, resolved without running it.
+type DryRunReport struct {
+	Stack string `json:"stack"`
+	Job   string `json:"job"`
+	Task  string `json:"task"`
+
+	WorkDir   string `json:"work_dir"`
+	MountDir  string `json:"mount_dir"`
+	TargetDir string `json:"target_dir"`
+	// Workdir of the commands, in the container.
+	ContainerWorkDir string `json:"container_work_dir"`
+
+	Image         string           `json:"image"`
+	EnvPrecedence []string         `json:"env_precedence"`
+	Env           []DryRunEnvVar   `json:"env"`
+	Mounts        []task.PlanMount `json:"mounts"`
+	Plan          task.ActionPlan  `json:"plan"`
+}
+
+// DryRun resolves the pipeline (its dirs, image, env vars, mounts and the commands of the
+// action) and prints it, without connecting to the dagger engine, nor to AWS.
+func DryRun(ctx context.Context, cliArgs *config.CLIGlobalArgs, stack, jobName string,
+	plan task.PlanFunc, opt task.InitOptions) error {
+	msg := tui.NewTUIMessage()
+	prefix := "DRY-RUN"
+
+	format := common.NormaliseStringLower(cliArgs.DryRunFormat)
+	if format == "" {
+		format = DryRunFormatText
+	}
+
+	if !common.IsStringInSlice(format, DryRunFormats) {
+		err := errors.NewArgumentError(fmt.Sprintf("Invalid --dry-run-format '%s', it should be "+
+			"one of: %s", cliArgs.DryRunFormat, strings.Join(DryRunFormats, ", ")), nil)
+		msg.ShowError(prefix, "Failed to run the dry-run", err)
+		return err
+	}
+
+	// The JSON report is the only output, so it can be parsed.
+	if format == DryRunFormatJSON {
+		pterm.DisableOutput()
+	}
+
+	report, err := getDryRunReport(ctx, cliArgs, stack, jobName, plan, opt)
+
+	pterm.EnableOutput()
+
+	if err != nil {
+		msg.ShowError(prefix, "Failed to resolve the pipeline", err)
+		return err
+	}
+
+	if format == DryRunFormatJSON {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.NewPipelineConfigurationError("Failed to encode the dry-run report", err)
+		}
+
+		_, err = fmt.Fprintln(os.Stdout, string(out))
+		return err
+	}
+
+	showDryRunReport(report)
+
+	return nil
+}
+
+func getDryRunReport(ctx context.Context, cliArgs *config.CLIGlobalArgs, stack, jobName string,
+	plan task.PlanFunc, opt task.InitOptions) (DryRunReport, error) {
+	stackNormalised := common.NormaliseStringUpper(stack)
+	jobNormalised := common.NormaliseStringUpper(jobName)
+
+	// The AWS credentials aren't resolved (it'd call AWS), only the keys that they'd set are
+	// listed.
+	p, err := pipeline.New(ctx, cliArgs.WorkingDir, cliArgs.MountDir,
+		cliArgs.TargetDir, cliArgs.TaskName,
+		cliArgs.ScanEnvVarKeys,
+		cliArgs.EnvKeyValuePairsToSetString, false,
+		cliArgs.ScanTerraformVars, cliArgs.ScanAllEnvVars,
+		cliArgs.DotEnvFiles, cliArgs.Environment, cliArgs.ScanEnvVarsWithPrefix,
+		cliArgs.InitDaggerWithWorkDirByDefault)
+
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	stackDefinition, err := config.GetStack(stackNormalised)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	stackDefinition, err = applyLockFile(p, stackDefinition, cliArgs.LockMode)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	image, err := daggerio.GetContainerImagePerStack(stackDefinition)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	envPrecedence, err := job.GetEnvPrecedence(jobNormalised)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	if cliArgs.TaskPolicy, err = config.GetTaskPolicy(jobNormalised, cliArgs.TaskName,
+		cliArgs.TaskPolicy); err != nil {
+		return DryRunReport{}, err
+	}
+
+	env, err := getDryRunEnvVars(p, cliArgs, stackDefinition, jobNormalised, envPrecedence)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	mounts, err := getDryRunMounts(p, cliArgs, stackDefinition)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	opt.Stack = stackNormalised
+	opt.PipelineCfg = p
+	opt.WorkDir = p.PipelineOpts.WorkDir
+	opt.MountDir = p.PipelineOpts.MountDir
+	opt.TargetDir = p.PipelineOpts.TargetDir
+
+	actionPlan, err := plan(opt, stackDefinition)
+	if err != nil {
+		return DryRunReport{}, err
+	}
+
+	containerWorkDir := actionPlan.ContainerWorkDir
+	if containerWorkDir == "" {
+		containerWorkDir = daggerio.NormaliseDaggerPath(p.PipelineOpts.TargetDir)
+	}
+
+	return DryRunReport{
+		Stack:            stackNormalised,
+		Job:              jobNormalised,
+		Task:             cliArgs.TaskName,
+		WorkDir:          p.PipelineOpts.WorkDirPath,
+		MountDir:         p.PipelineOpts.MountDirPath,
+		TargetDir:        p.PipelineOpts.TargetDirPath,
+		ContainerWorkDir: containerWorkDir,
+		Image:            image,
+		EnvPrecedence:    envPrecedence,
+		Env:              env,
+		Mounts:           append(mounts, actionPlan.Mounts...),
+		Plan:             actionPlan,
+	}, nil
+}
+
+// getDryRunEnvVars returns the env vars that the job would set in its container, with the source
+// that won. The values are masked, and the secret references aren't resolved.
+func getDryRunEnvVars(p *pipeline.Config, cliArgs *config.CLIGlobalArgs,
+	stack daggerio.StackDefinition, jobName string, precedence []string) ([]DryRunEnvVar, error) {
+	sources, origins, err := job.ScanEnvVarsSources(p, job.InitOptions{
+		Name:                    jobName,
+		WorkDir:                 p.PipelineOpts.WorkDir,
+		TargetDir:               p.PipelineOpts.TargetDir,
+		MountDir:                p.PipelineOpts.MountDir,
+		ScanAWSEnvVars:          false,
+		ScanTerraformEnvVars:    cliArgs.ScanTerraformVars,
+		IsScanEnvVarsFromDotEnv: len(cliArgs.DotEnvFiles) > 0 || cliArgs.Environment != "",
+		IsScanEnvVarsFromPrefix: len(cliArgs.ScanEnvVarsWithPrefix) > 0,
+		EnvVarsToSet:            cliArgs.EnvKeyValuePairsToSetString,
+		EnvVarsToScan:           cliArgs.ScanEnvVarKeys,
+		DotEnvFiles:             cliArgs.DotEnvFiles,
+		Environment:             cliArgs.Environment,
+		EnvVarsWithPrefixToScan: cliArgs.ScanEnvVarsWithPrefix,
+		EnvPrecedence:           precedence,
+		HostEnvFilter: filesystem.HostEnvFilter{
+			Allow: cliArgs.EnvAllow,
+			Deny:  cliArgs.EnvDeny,
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cliArgs.ScanAWSKeys {
+		awsEnvVars := awscloud.GetCredentialsAsEnvVarsMap(awscloud.AWSCredentials{})
+		for key := range awsEnvVars {
+			awsEnvVars[key] = awsEnvVarPlaceholder
+		}
+
+		sources[job.EnvSourceAWS] = awsEnvVars
+	}
+
+	_, report := job.ResolveEnvVars(sources, origins, precedence)
+	secretsRegistry := secrets.NewDefaultRegistry(p.PipelineOpts.WorkDirPath)
+
+	overridden := map[string]bool{}
+	for _, r := range report {
+		overridden[r.Key] = true
+	}
+
+	// The env of the stack is set first, so any source overrides it.
+	var env []DryRunEnvVar
+	for _, key := range filesystem.GetSortedEnvVarKeys(stack.Env) {
+		if overridden[key] {
+			continue
+		}
+
+		env = append(env, DryRunEnvVar{Key: key, Source: "stack",
+			Value: job.MaskEnvVarValue(stack.Env[key])})
+	}
+
+	for _, r := range report {
+		source := r.Source
+		if r.Origin != "" {
+			source = fmt.Sprintf("%s (%s)", r.Source, r.Origin)
+		}
+
+		value := job.MaskEnvVarValue(r.Value)
+		switch {
+		case r.Value == awsEnvVarPlaceholder:
+			value = r.Value
+		case common.IsStringInSlice(r.Source, job.SecretRefSources) &&
+			secretsRegistry.IsReference(r.Value):
+			value = fmt.Sprintf("%s (secret)", r.Value)
+		}
+
+		env = append(env, DryRunEnvVar{Key: r.Key, Source: source, Value: value})
+	}
+
+	sort.SliceStable(env, func(i, j int) bool {
+		return env[i].Key < env[j].Key
+	})
+
+	return env, nil
+}
+
+const awsEnvVarPlaceholder = "<resolved at run time>"
+
+// getDryRunMounts returns the dirs mounted into the container: the work dir, and the cache
+// volumes of the stack.
+func getDryRunMounts(p *pipeline.Config, cliArgs *config.CLIGlobalArgs,
+	stack daggerio.StackDefinition) ([]task.PlanMount, error) {
+	mounts := []task.PlanMount{{
+		Source: p.PipelineOpts.WorkDirPath,
+		Target: daggerio.ContainerMountPathPrefix,
+		Kind:   "dir",
+	}}
+
+	// The state isn't updated, the volumes aren't used.
+	var state *daggerio.CacheState
+	if statePath, err := daggerio.GetCacheStatePath(); err == nil {
+		state, _ = daggerio.LoadCacheState(statePath)
+	}
+
+	volumes, err := daggerio.GetStackCacheVolumes(stack, daggerio.CacheOptions{
+		Disabled:       cliArgs.NoCacheVolumes,
+		Namespace:      cliArgs.CacheNamespace,
+		KeyByLockFiles: cliArgs.CacheLockFileKey,
+	}, p.PipelineOpts.TargetDirPath, state)
+
+	if err != nil {
+		return nil, errors.NewPipelineConfigurationError("Failed to resolve the cache volumes", err)
+	}
+
+	for _, v := range volumes {
+		mounts = append(mounts, task.PlanMount{Source: v.Name, Target: v.Path, Kind: "cache"})
+	}
+
+	return mounts, nil
+}
+
+func showDryRunReport(r DryRunReport) {
+	msg := tui.NewTUIMessage()
+	ux := tui.TUITitle{}
+
+	ux.ShowSubTitle("DRY-RUN:", fmt.Sprintf("%s %s (task %s)", r.Stack, r.Job, r.Task))
+
+	tui.ShowTable([][]string{
+		{"", ""},
+		{"Work dir", r.WorkDir},
+		{"Mount dir", r.MountDir},
+		{"Target dir", r.TargetDir},
+		{"Image", r.Image},
+		{"Workdir in the container", r.ContainerWorkDir},
+	})
+
+	msg.ShowInfo("ENV", fmt.Sprintf("Precedence (lowest to highest): %s",
+		strings.Join(r.EnvPrecedence, " < ")))
+
+	if len(r.Env) > 0 {
+		rows := [][]string{{"KEY", "SOURCE", "VALUE"}}
+		for _, e := range r.Env {
+			rows = append(rows, []string{e.Key, e.Source, e.Value})
+		}
+
+		tui.ShowTable(rows)
+	} else {
+		msg.ShowWarning("ENV", "No environment variables would be set")
+	}
+
+	rows := [][]string{{"SOURCE", "TARGET", "KIND"}}
+	for _, m := range r.Mounts {
+		rows = append(rows, []string{m.Source, m.Target, m.Kind})
+	}
+
+	tui.ShowTable(rows)
+
+	for _, check := range r.Plan.PreRequisites {
+		if check.Exists {
+			msg.ShowSuccess("PRE-REQUISITES", fmt.Sprintf("%s exists", check.Path))
+			continue
+		}
+
+		msg.ShowWarning("PRE-REQUISITES", fmt.Sprintf("%s doesn't exist, the run would fail",
+			check.Path))
+	}
+
+	rows = [][]string{{"#", "KIND", "IMAGE", "COMMAND"}}
+	for i, step := range r.Plan.Steps {
+		image := step.Image
+		if image == "" && (step.Kind == task.ActionStepKindContainer) {
+			image = r.Image
+		}
+
+		command := step.Description
+		if len(step.Command) > 0 {
+			command = common.JoinCommand(step.Command)
+		}
+
+		rows = append(rows, []string{fmt.Sprintf("%d", i+1), step.Kind, image, command})
+	}
+
+	msg.ShowInfo(r.Plan.Action, fmt.Sprintf("%d step(s) would run", len(r.Plan.Steps)))
+	tui.ShowTable(rows)
+
+	if len(r.Plan.Outputs) > 0 {
+		msg.ShowInfo("OUTPUTS", fmt.Sprintf("Exported into the artifacts dir: %s",
+			strings.Join(r.Plan.Outputs, ", ")))
+	}
+
+	msg.ShowSuccess("DRY-RUN", "Nothing was run, nor connected to the dagger engine or AWS")
+}
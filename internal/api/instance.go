@@ -1,14 +1,18 @@
 package api
 
 import (
+	"context"
 	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
 	"github.com/Excoriate/stiletto/internal/tui"
 	"github.com/Excoriate/stiletto/pkg/config"
 	"github.com/Excoriate/stiletto/pkg/job"
 	"github.com/Excoriate/stiletto/pkg/pipeline"
 )
 
-func New(cliArgs *config.CLIGlobalArgs, stack, jobName string) (*pipeline.Config, *job.Job, error) {
+func New(ctx context.Context, cliArgs *config.CLIGlobalArgs, stack, jobName string) (*pipeline.Config, *job.Job, error) {
 	msg := tui.NewTUIMessage()
 	ux := tui.TUITitle{}
 
@@ -18,12 +22,12 @@ func New(cliArgs *config.CLIGlobalArgs, stack, jobName string) (*pipeline.Config
 	config.ShowCLITitle()
 
 	// Pipeline instance.
-	p, err := pipeline.New(cliArgs.WorkingDir, cliArgs.MountDir,
+	p, err := pipeline.New(ctx, cliArgs.WorkingDir, cliArgs.MountDir,
 		cliArgs.TargetDir, cliArgs.TaskName,
 		cliArgs.ScanEnvVarKeys,
 		cliArgs.EnvKeyValuePairsToSetString, cliArgs.ScanAWSKeys,
 		cliArgs.ScanTerraformVars, cliArgs.ScanAllEnvVars,
-		cliArgs.DotEnvFile, cliArgs.ScanEnvVarsWithPrefix,
+		cliArgs.DotEnvFiles, cliArgs.Environment, cliArgs.ScanEnvVarsWithPrefix,
 		cliArgs.InitDaggerWithWorkDirByDefault)
 
 	if err != nil {
@@ -31,14 +35,52 @@ func New(cliArgs *config.CLIGlobalArgs, stack, jobName string) (*pipeline.Config
 		return nil, nil, err
 	}
 
+	if cliArgs.OnlyIfChanged {
+		if err := checkChangedPaths(p, cliArgs); err != nil {
+			if !errors.IsTaskSkippedError(err) {
+				msg.ShowError("INIT", "Failed to check the changed files", err)
+			}
+
+			return p, nil, err
+		}
+	}
+
 	ux.ShowSubTitle(stackNormalised, jobNormalised)
 	ux.ShowInitDetails(jobNormalised, cliArgs.TaskName, p.PipelineOpts.WorkDirPath,
 		p.PipelineOpts.TargetDirPath, p.PipelineOpts.MountDirPath)
 
+	envPrecedence, err := job.GetEnvPrecedence(jobNormalised)
+	if err != nil {
+		msg.ShowError("INIT", "Failed to resolve the env vars precedence", err)
+		return nil, nil, err
+	}
+
+	// The settings of the task in the job ('jobs.<job>.tasks.<task>') win.
+	taskPolicy, err := config.GetTaskPolicy(jobNormalised, cliArgs.TaskName, cliArgs.TaskPolicy)
+	if err != nil {
+		msg.ShowError("INIT", "Failed to resolve the execution policy of the task", err)
+		return nil, nil, err
+	}
+
+	cliArgs.TaskPolicy = taskPolicy
+
+	stackDefinition, err := config.GetStack(stackNormalised)
+	if err != nil {
+		msg.ShowError("INIT", "Failed to resolve the stack", err)
+		return nil, nil, err
+	}
+
+	stackDefinition, err = applyLockFile(p, stackDefinition, cliArgs.LockMode)
+	if err != nil {
+		msg.ShowError("INIT", "Failed to apply the lock file", err)
+		return nil, nil, err
+	}
+
 	// 2. Initialising the job.
 	j, jobErr := job.NewJob(p, job.InitOptions{
-		Name:  cliArgs.TaskName,
-		Stack: stackNormalised,
+		Name:            cliArgs.TaskName,
+		Stack:           stackNormalised,
+		StackDefinition: stackDefinition,
 
 		// Pipeline reference.
 		PipelineCfg: p,
@@ -51,12 +93,27 @@ func New(cliArgs *config.CLIGlobalArgs, stack, jobName string) (*pipeline.Config
 		// Environmental configuration
 		ScanAWSEnvVars:          cliArgs.ScanAWSKeys,
 		ScanTerraformEnvVars:    cliArgs.ScanTerraformVars,
-		IsScanEnvVarsFromDotEnv: cliArgs.DotEnvFile != "",
+		IsScanEnvVarsFromDotEnv: len(cliArgs.DotEnvFiles) > 0 || cliArgs.Environment != "",
 		IsScanEnvVarsFromPrefix: len(cliArgs.ScanEnvVarsWithPrefix) > 0,
 		EnvVarsToSet:            cliArgs.EnvKeyValuePairsToSetString,
 		EnvVarsToScan:           cliArgs.ScanEnvVarKeys,
-		DotEnvFile:              cliArgs.DotEnvFile,
+		DotEnvFiles:             cliArgs.DotEnvFiles,
+		Environment:             cliArgs.Environment,
 		EnvVarsWithPrefixToScan: cliArgs.ScanEnvVarsWithPrefix,
+		EnvPrecedence:           envPrecedence,
+		HostEnvFilter: filesystem.HostEnvFilter{
+			Allow: cliArgs.EnvAllow,
+			Deny:  cliArgs.EnvDeny,
+		},
+		UploadFilter: filesystem.UploadFilter{
+			Include: cliArgs.UploadInclude,
+			Exclude: cliArgs.UploadExclude,
+		},
+		CacheOptions: daggerio.CacheOptions{
+			Disabled:       cliArgs.NoCacheVolumes,
+			Namespace:      cliArgs.CacheNamespace,
+			KeyByLockFiles: cliArgs.CacheLockFileKey,
+		},
 	})
 
 	if jobErr != nil {
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"path/filepath"
+	"strings"
+)
+
+// applyLockFile pins the stack to the digest of the stiletto.lock file in the work dir. A stale
+// (or missing) lock is a warning, or an error with the strict --lock-mode.
+func applyLockFile(p *pipeline.Config, stack daggerio.StackDefinition,
+	lockMode string) (daggerio.StackDefinition, error) {
+	msg := tui.NewTUIMessage()
+	prefix := "LOCK"
+
+	if lockMode == "" {
+		lockMode = daggerio.LockModeWarn
+	}
+
+	switch lockMode {
+	case daggerio.LockModeIgnore:
+		return stack, nil
+	case daggerio.LockModeWarn, daggerio.LockModeStrict:
+	default:
+		return stack, errors.NewPipelineConfigurationError(fmt.Sprintf("Invalid --lock-mode '%s', "+
+			"it should be one of: %s", lockMode, strings.Join(daggerio.LockModes, ", ")), nil)
+	}
+
+	lockPath := filepath.Join(p.PipelineOpts.WorkDirPath, daggerio.LockFileName)
+	lock, err := daggerio.LoadLockFile(lockPath)
+	if err != nil {
+		return stack, errors.NewPipelineConfigurationError("Failed to read the lock file", err)
+	}
+
+	if lock == nil {
+		if lockMode == daggerio.LockModeStrict {
+			return stack, errors.NewPipelineConfigurationError(fmt.Sprintf("The lock file %s "+
+				"doesn't exist, run 'stiletto lock' to create it", lockPath), nil)
+		}
+
+		return stack, nil
+	}
+
+	locked, status := lock.Apply(stack)
+
+	switch status {
+	case daggerio.LockStatusLocked:
+		msg.ShowInfo(prefix, fmt.Sprintf("Using the locked image of the stack %s: %s", stack.Name,
+			locked.GetImageRef()))
+		return locked, nil
+	case daggerio.LockStatusPinned:
+		return locked, nil
+	}
+
+	detail := fmt.Sprintf("The lock of the stack %s is %s (image %s), run 'stiletto lock "+
+		"--update %s' to update it", stack.Name, status, stack.GetImageRef(),
+		strings.ToLower(stack.Name))
+
+	if lockMode == daggerio.LockModeStrict {
+		return stack, errors.NewPipelineConfigurationError(detail, nil)
+	}
+
+	msg.ShowWarning(prefix, detail)
+
+	return stack, nil
+}
@@ -2,28 +2,117 @@ package clients
 
 import (
 	"context"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
 	"github.com/Excoriate/stiletto/internal/tui"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsCfg "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
-func GetAWS(region string) (aws.Config, error) {
+// AWSClientFactory builds the AWS SDK clients, all of them bound to the same credentials and
+// (optionally) to a custom endpoint, E.g.: LocalStack, or a local stub server.
+type AWSClientFactory struct {
+	Config      aws.Config
+	EndpointURL string
+}
+
+// NewAWSClientFactory returns a client factory for the credentials options (see
+// awscloud.GetCredentialsOptions), including the custom endpoint, if any. The clients are built
+// from the aws.Config of the options, whose credentials cache refreshes the temporary credentials
+// (E.g.: an assumed role, or SSO) when they expire during a long run.
+func NewAWSClientFactory(ctx context.Context,
+	opt awscloud.AWSCredentialsOptions) (*AWSClientFactory, error) {
 	uxLog := tui.NewTUIMessage()
-	awsAuth, err := awsCfg.LoadDefaultConfig(context.TODO(), awsCfg.WithRegion(region))
+
+	if err := awscloud.ValidateEndpointURL(opt.EndpointURL); err != nil {
+		uxLog.ShowError("AWS", "Failed to initialise AWS SDK, the custom endpoint is invalid", err)
+		return nil, err
+	}
+
+	if opt.EndpointURL != "" {
+		uxLog.ShowWarning("AWS", "Using the custom AWS endpoint: "+opt.EndpointURL)
+	}
+
+	awsAuth, err := awscloud.GetAWSConfig(ctx, opt)
 	if err != nil {
 		uxLog.ShowError("AWS", "Failed to get AWS credentials. Cannot initialise AWS SDK", err)
+		return nil, err
+	}
+
+	return &AWSClientFactory{
+		Config:      awsAuth,
+		EndpointURL: opt.EndpointURL,
+	}, nil
+}
+
+func (f *AWSClientFactory) CloudFront() *cloudfront.Client {
+	return cloudfront.NewFromConfig(f.Config)
+}
+
+func (f *AWSClientFactory) ECS() *ecs.Client {
+	return ecs.NewFromConfig(f.Config)
+}
+
+func (f *AWSClientFactory) ECR() *ecr.Client {
+	return ecr.NewFromConfig(f.Config)
+}
+
+func (f *AWSClientFactory) Lambda() *lambda.Client {
+	return lambda.NewFromConfig(f.Config)
+}
+
+// S3 returns an S3 client. With a custom endpoint, path-style addressing is used, since
+// emulators don't resolve virtual-hosted bucket names.
+func (f *AWSClientFactory) S3() *s3.Client {
+	return s3.NewFromConfig(f.Config, func(o *s3.Options) {
+		o.UsePathStyle = f.EndpointURL != ""
+	})
+}
+
+func (f *AWSClientFactory) SecretsManager() *secretsmanager.Client {
+	return secretsmanager.NewFromConfig(f.Config)
+}
+
+func (f *AWSClientFactory) SSM() *ssm.Client {
+	return ssm.NewFromConfig(f.Config)
+}
+
+func (f *AWSClientFactory) STS() *sts.Client {
+	return sts.NewFromConfig(f.Config)
+}
+
+// GetAWSClientFactory returns a client factory for the credentials of the flags and env vars,
+// the same ones the containers get (see awscloud.GetCredentials).
+func GetAWSClientFactory(ctx context.Context) (*AWSClientFactory, error) {
+	opt, err := awscloud.GetCredentialsOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAWSClientFactory(ctx, opt)
+}
+
+// GetAWS returns the AWS SDK configuration of the flags and env vars.
+func GetAWS(ctx context.Context) (aws.Config, error) {
+	f, err := GetAWSClientFactory(ctx)
+	if err != nil {
 		return aws.Config{}, err
 	}
 
-	return awsAuth, nil
+	return f.Config, nil
 }
 
-func GetAWSECSClient(region string) (*ecs.Client, error) {
-	awsAuth, err := GetAWS(region)
+func GetAWSECSClient(ctx context.Context) (*ecs.Client, error) {
+	f, err := GetAWSClientFactory(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return ecs.NewFromConfig(awsAuth), nil
+	return f.ECS(), nil
 }
@@ -0,0 +1,493 @@
+package clients
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// awsStubServer is a minimal stand-in for the AWS APIs (like LocalStack would be), that
+// records the calls received.
+type awsStubServer struct {
+	mu        sync.Mutex
+	calls     []string
+	payloads  map[string]map[string]interface{}
+	s3Objects map[string]string // key -> ETag, listed by ListObjectsV2.
+}
+
+func (s *awsStubServer) record(call string, payload map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, call)
+	s.payloads[call] = payload
+}
+
+func (s *awsStubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	// ECS (JSON 1.1 protocol).
+	if target := r.Header.Get("X-Amz-Target"); strings.HasPrefix(target, "AmazonEC2ContainerService") {
+		operation := target[strings.Index(target, ".")+1:]
+		payload := map[string]interface{}{}
+		_ = json.Unmarshal(body, &payload)
+		s.record("ecs:"+operation, payload)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+		switch operation {
+		case "DescribeTaskDefinition":
+			_, _ = w.Write([]byte(`{"taskDefinition":{"family":"app","containerDefinitions":[{"name":"app","image":"old:1"}]}}`))
+		case "RegisterTaskDefinition":
+			_, _ = w.Write([]byte(`{"taskDefinition":{"family":"app","taskDefinitionArn":"arn:aws:ecs:us-east-1:000000000000:task-definition/app:2"}}`))
+		case "UpdateService":
+			_, _ = w.Write([]byte(`{"service":{"serviceName":"svc"}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+		return
+	}
+
+	// STS (query protocol).
+	if form, _ := url.ParseQuery(string(body)); strings.HasPrefix(form.Get("Action"), "AssumeRole") {
+		action := form.Get("Action")
+		s.record("sts:"+action, map[string]interface{}{
+			"role-arn":           form.Get("RoleArn"),
+			"role-session-name":  form.Get("RoleSessionName"),
+			"external-id":        form.Get("ExternalId"),
+			"web-identity-token": form.Get("WebIdentityToken"),
+		})
+
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(fmt.Sprintf(`<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>role-secret</SecretAccessKey>
+      <SessionToken>role-token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser><Arn>%[2]s/stiletto</Arn><AssumedRoleId>AROA:stiletto</AssumedRoleId></AssumedRoleUser>
+  </%[1]sResult>
+  <ResponseMetadata><RequestId>stub</RequestId></ResponseMetadata>
+</%[1]sResponse>`, action, form.Get("RoleArn"))))
+		return
+	}
+
+	if strings.Contains(string(body), "Action=GetCallerIdentity") {
+		// The access key that signed the call, E.g.: the one of the assumed role.
+		accessKey := ""
+		if parts := strings.SplitN(r.Header.Get("Authorization"), "Credential=", 2); len(parts) == 2 {
+			accessKey = strings.SplitN(parts[1], "/", 2)[0]
+		}
+
+		s.record("sts:GetCallerIdentity", map[string]interface{}{"access-key": accessKey})
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>arn:aws:iam::000000000000:root</Arn>
+    <UserId>000000000000</UserId>
+    <Account>000000000000</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata><RequestId>stub</RequestId></ResponseMetadata>
+</GetCallerIdentityResponse>`))
+		return
+	}
+
+	// Lambda (REST-JSON protocol).
+	if strings.HasPrefix(r.URL.Path, "/2015-03-31/functions/") {
+		payload := map[string]interface{}{}
+		_ = json.Unmarshal(body, &payload)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/code"):
+			s.record("lambda:UpdateFunctionCode", payload)
+			_, _ = w.Write([]byte(`{"FunctionName":"fn","CodeSha256":"sha","LastUpdateStatus":"InProgress"}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/configuration"):
+			s.record("lambda:GetFunctionConfiguration", nil)
+			_, _ = w.Write([]byte(`{"FunctionName":"fn","LastUpdateStatus":"Successful"}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/versions"):
+			s.record("lambda:PublishVersion", payload)
+			_, _ = w.Write([]byte(`{"FunctionArn":"arn:aws:lambda:us-east-1:000000000000:function:fn:3","Version":"3"}`))
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/aliases/"):
+			s.record("lambda:UpdateAlias", payload)
+			w.Header().Set("X-Amzn-ErrorType", "ResourceNotFoundException")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"Type":"User","Message":"Alias not found"}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/aliases"):
+			s.record("lambda:CreateAlias", payload)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"AliasArn":"arn:aws:lambda:us-east-1:000000000000:function:fn:live","Name":"live"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+		return
+	}
+
+	// CloudFront (REST-XML protocol).
+	if strings.HasPrefix(r.URL.Path, "/2020-05-31/distribution/") {
+		w.Header().Set("Content-Type", "text/xml")
+		invalidation := `<Invalidation><Id>I1</Id><Status>%s</Status><CreateTime>2023-01-01T00:00:00Z</CreateTime>
+  <InvalidationBatch><CallerReference>ref</CallerReference><Paths><Quantity>1</Quantity><Items><Path>/*</Path></Items></Paths></InvalidationBatch>
+</Invalidation>`
+
+		if r.Method == http.MethodPost {
+			s.record("cloudfront:CreateInvalidation", map[string]interface{}{"body": string(body)})
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(fmt.Sprintf(invalidation, "InProgress")))
+			return
+		}
+
+		s.record("cloudfront:GetInvalidation", nil)
+		_, _ = w.Write([]byte(fmt.Sprintf(invalidation, "Completed")))
+		return
+	}
+
+	// S3 (REST, path-style).
+	if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+		bucket := strings.Trim(r.URL.Path, "/")
+		s.record("s3:ListObjectsV2", map[string]interface{}{"bucket": bucket})
+		w.Header().Set("Content-Type", "application/xml")
+
+		contents := "<Contents><Key>index.html</Key><Size>10</Size></Contents>"
+		if s.s3Objects != nil {
+			contents = ""
+			for key, etag := range s.s3Objects {
+				contents += fmt.Sprintf(`<Contents><Key>%s</Key><ETag>&quot;%s&quot;</ETag></Contents>`,
+					key, etag)
+			}
+		}
+
+		_, _ = w.Write([]byte(fmt.Sprintf(`<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>%s</Name><IsTruncated>false</IsTruncated>%s
+</ListBucketResult>`, bucket, contents)))
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		s.record("s3:PutObject:"+strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 2)[1],
+			map[string]interface{}{
+				"content-type":  r.Header.Get("Content-Type"),
+				"cache-control": r.Header.Get("Cache-Control"),
+			})
+		w.Header().Set("ETag", `"etag"`)
+		return
+	}
+
+	if r.Method == http.MethodPost && r.URL.Query().Has("delete") {
+		s.record("s3:DeleteObjects", map[string]interface{}{"body": string(body)})
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(`<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></DeleteResult>`))
+		return
+	}
+
+	w.WriteHeader(http.StatusNotImplemented)
+}
+
+func newStubServer(t *testing.T) (*httptest.Server, *awsStubServer) {
+	stub := &awsStubServer{payloads: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	return server, stub
+}
+
+func newStubFactory(t *testing.T) (*AWSClientFactory, *awsStubServer) {
+	server, stub := newStubServer(t)
+
+	f, err := NewAWSClientFactory(context.Background(), awscloud.AWSCredentialsOptions{
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		SessionToken:    "test",
+		Region:          "us-east-1",
+		EndpointURL:     server.URL,
+	})
+
+	assert.NoError(t, err, "The client factory should be created with a custom endpoint")
+
+	return f, stub
+}
+
+func TestNewAWSClientFactoryInvalidEndpoint(t *testing.T) {
+	_, err := NewAWSClientFactory(context.Background(), awscloud.AWSCredentialsOptions{
+		Region: "us-east-1", EndpointURL: "localhost:4566"})
+	assert.Error(t, err, "An endpoint without scheme should be rejected")
+}
+
+func TestAWSClientFactoryAgainstStub(t *testing.T) {
+	t.Run("STS calls are sent to the custom endpoint", func(t *testing.T) {
+		f, stub := newStubFactory(t)
+
+		out, err := f.STS().GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+		assert.NoError(t, err)
+		assert.Equal(t, "000000000000", aws.ToString(out.Account))
+		assert.Equal(t, []string{"sts:GetCallerIdentity"}, stub.calls)
+	})
+
+	t.Run("S3 uses path-style addressing", func(t *testing.T) {
+		f, stub := newStubFactory(t)
+
+		out, err := f.S3().ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
+			Bucket: aws.String("assets"),
+		})
+		assert.NoError(t, err)
+		assert.Len(t, out.Contents, 1)
+		assert.Equal(t, "assets", stub.payloads["s3:ListObjectsV2"]["bucket"])
+	})
+
+	t.Run("ECS deployment flow runs end to end", func(t *testing.T) {
+		f, stub := newStubFactory(t)
+		client := f.ECS()
+
+		taskDef, err := awscloud.GetECSTaskDefinition(context.Background(), client, "app")
+		assert.NoError(t, err)
+
+		arn, err := awscloud.UpdateECSTaskContainerDefinition(context.Background(), client, taskDef,
+			awscloud.ECSTaskDefContainerDefUpdateOptions{
+				ImageURL: "registry/app",
+				Version:  "v2",
+			})
+		assert.NoError(t, err)
+		assert.Equal(t, "arn:aws:ecs:us-east-1:000000000000:task-definition/app:2", arn)
+
+		err = awscloud.UpdateECSService(context.Background(), client, awscloud.ECSUpdateServiceOptions{
+			Cluster:    "cluster",
+			Service:    "svc",
+			TaskDefARN: arn,
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"ecs:DescribeTaskDefinition", "ecs:RegisterTaskDefinition",
+			"ecs:UpdateService"}, stub.calls)
+
+		containerDefs := stub.payloads["ecs:RegisterTaskDefinition"]["containerDefinitions"].([]interface{})
+		assert.Equal(t, "registry/app:v2", containerDefs[0].(map[string]interface{})["image"])
+	})
+	t.Run("Lambda deployment flow creates the missing alias", func(t *testing.T) {
+		f, stub := newStubFactory(t)
+
+		result, err := awscloud.DeployLambdaFunction(context.Background(), f.Lambda(), awscloud.LambdaDeployOptions{
+			FunctionName: "fn",
+			Code: awscloud.LambdaCodeLocation{
+				S3Bucket: "artifacts",
+				S3Key:    "fn/sha.zip",
+			},
+			Alias: "live",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "3", result.Version)
+		assert.Equal(t, "live", result.Alias)
+
+		assert.Equal(t, []string{"lambda:UpdateFunctionCode", "lambda:GetFunctionConfiguration",
+			"lambda:PublishVersion", "lambda:UpdateAlias", "lambda:CreateAlias"}, stub.calls)
+		assert.Equal(t, "fn/sha.zip", stub.payloads["lambda:UpdateFunctionCode"]["S3Key"])
+		assert.Equal(t, "sha", stub.payloads["lambda:PublishVersion"]["CodeSha256"])
+		assert.Equal(t, "3", stub.payloads["lambda:CreateAlias"]["FunctionVersion"])
+	})
+
+	t.Run("Lambda code location should be unique", func(t *testing.T) {
+		f, _ := newStubFactory(t)
+
+		_, err := awscloud.DeployLambdaFunction(context.Background(), f.Lambda(), awscloud.LambdaDeployOptions{
+			FunctionName: "fn",
+			Code: awscloud.LambdaCodeLocation{
+				ImageURI: "registry/fn:v1",
+				S3Bucket: "artifacts",
+				S3Key:    "fn/sha.zip",
+			},
+		})
+		assert.Error(t, err, "More than one code location should be rejected")
+	})
+	t.Run("S3 sync uploads only the changed files, and deletes the stale ones", func(t *testing.T) {
+		f, stub := newStubFactory(t)
+
+		dir := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(dir, "assets"), 0755)
+		_ = os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>unchanged</h1>"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "about.html"), []byte("<h1>new</h1>"), 0644)
+		_ = os.WriteFile(filepath.Join(dir, "assets", "app.js"), []byte("console.log(1)"), 0644)
+
+		unchanged := md5.Sum([]byte("<h1>unchanged</h1>"))
+		stub.s3Objects = map[string]string{
+			"site/index.html":      hex.EncodeToString(unchanged[:]),
+			"site/assets/app.js":   "outdated",
+			"site/assets/stale.js": "stale",
+		}
+
+		opt := awscloud.S3SyncOptions{
+			Bucket:    "assets",
+			Prefix:    "site",
+			SourceDir: dir,
+			Delete:    true,
+			CacheControlRules: []awscloud.S3CacheControlRule{
+				{Glob: "*.html", CacheControl: "no-cache"},
+				{Glob: "assets/*", CacheControl: "max-age=31536000"},
+			},
+		}
+
+		opt.DryRun = true
+		plan, err := awscloud.SyncDirToS3(context.Background(), f.S3(), opt)
+		assert.NoError(t, err)
+		assert.Len(t, plan.Upload, 2)
+		assert.Equal(t, []string{"site/assets/stale.js"}, plan.Delete)
+		assert.Equal(t, 1, plan.Unchanged)
+		assert.Equal(t, []string{"s3:ListObjectsV2"}, stub.calls, "Dry-run should not apply changes")
+
+		opt.DryRun = false
+		_, err = awscloud.SyncDirToS3(context.Background(), f.S3(), opt)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "text/html; charset=utf-8", stub.payloads["s3:PutObject:site/about.html"]["content-type"])
+		assert.Equal(t, "no-cache", stub.payloads["s3:PutObject:site/about.html"]["cache-control"])
+		assert.Equal(t, "max-age=31536000", stub.payloads["s3:PutObject:site/assets/app.js"]["cache-control"])
+		assert.NotContains(t, stub.calls, "s3:PutObject:site/index.html")
+		assert.Contains(t, stub.payloads["s3:DeleteObjects"]["body"], "site/assets/stale.js")
+	})
+
+	t.Run("CloudFront invalidation waits for completion", func(t *testing.T) {
+		f, stub := newStubFactory(t)
+
+		id, err := awscloud.InvalidateCloudFrontPaths(context.Background(), f.CloudFront(),
+			awscloud.CloudFrontInvalidationOptions{
+				DistributionID: "E123",
+				Wait:           true,
+			})
+		assert.NoError(t, err)
+		assert.Equal(t, "I1", id)
+		assert.Equal(t, []string{"cloudfront:CreateInvalidation", "cloudfront:GetInvalidation"},
+			stub.calls)
+		assert.Contains(t, stub.payloads["cloudfront:CreateInvalidation"]["body"], "<Path>/*</Path>")
+	})
+}
+
+func TestResolveCredentialsAssumeRoleAgainstStub(t *testing.T) {
+	// The base credentials, the role is assumed on top of them.
+	newOptions := func(endpointURL string) awscloud.AWSCredentialsOptions {
+		return awscloud.AWSCredentialsOptions{
+			AccessKeyID:     "AKIABASE",
+			SecretAccessKey: "base-secret",
+			Region:          "us-east-1",
+			AssumeRoleARN:   "arn:aws:iam::000000000000:role/deployer",
+			RoleSessionName: "ci",
+			EndpointURL:     endpointURL,
+		}
+	}
+
+	t.Run("AssumeRole passes the external ID, and the clients use the role", func(t *testing.T) {
+		server, stub := newStubServer(t)
+		opt := newOptions(server.URL)
+		opt.ExternalID = "ext-id"
+
+		creds, err := awscloud.ResolveCredentials(context.Background(), opt)
+		assert.NoError(t, err)
+		assert.Equal(t, "ASIAROLE", creds.AccessKeyID)
+		assert.Equal(t, "role-token", creds.SessionToken)
+		assert.True(t, creds.CanExpire)
+
+		assert.Equal(t, map[string]interface{}{
+			"role-arn":           "arn:aws:iam::000000000000:role/deployer",
+			"role-session-name":  "ci",
+			"external-id":        "ext-id",
+			"web-identity-token": "",
+		}, stub.payloads["sts:AssumeRole"])
+
+		f, err := NewAWSClientFactory(context.Background(), opt)
+		assert.NoError(t, err)
+
+		_, err = f.STS().GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+		assert.NoError(t, err)
+		assert.Equal(t, "ASIAROLE", stub.payloads["sts:GetCallerIdentity"]["access-key"])
+		assert.Equal(t, []string{"sts:AssumeRole", "sts:GetCallerIdentity"}, stub.calls,
+			"The credentials of the role should be cached, and shared with the clients")
+	})
+
+	t.Run("The GitHub OIDC env vars don't replace an explicit role", func(t *testing.T) {
+		server, stub := newStubServer(t)
+		t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL+"/token")
+		t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+
+		_, err := awscloud.ResolveCredentials(context.Background(), newOptions(server.URL))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"sts:AssumeRole"}, stub.calls)
+	})
+
+	t.Run("A web identity token file assumes the role with it", func(t *testing.T) {
+		server, stub := newStubServer(t)
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		assert.NoError(t, os.WriteFile(tokenFile, []byte("file-token"), 0600))
+
+		opt := newOptions(server.URL)
+		opt.WebIdentityTokenFile = tokenFile
+
+		creds, err := awscloud.ResolveCredentials(context.Background(), opt)
+		assert.NoError(t, err)
+		assert.Equal(t, "ASIAROLE", creds.AccessKeyID)
+		assert.Equal(t, []string{"sts:AssumeRoleWithWebIdentity"}, stub.calls)
+		assert.Equal(t, "file-token",
+			stub.payloads["sts:AssumeRoleWithWebIdentity"]["web-identity-token"])
+		assert.Equal(t, "arn:aws:iam::000000000000:role/deployer",
+			stub.payloads["sts:AssumeRoleWithWebIdentity"]["role-arn"])
+	})
+
+	t.Run("The GitHub OIDC token assumes the role, if it's requested", func(t *testing.T) {
+		server, stub := newStubServer(t)
+		oidc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"value":"github-token"}`))
+		}))
+		t.Cleanup(oidc.Close)
+
+		t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", oidc.URL)
+		t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+
+		opt := newOptions(server.URL)
+		opt.GitHubOIDC = true
+
+		_, err := awscloud.ResolveCredentials(context.Background(), opt)
+		assert.NoError(t, err)
+		assert.Equal(t, "github-token",
+			stub.payloads["sts:AssumeRoleWithWebIdentity"]["web-identity-token"])
+	})
+
+	t.Run("The external ID is rejected with a web identity token", func(t *testing.T) {
+		server, stub := newStubServer(t)
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		assert.NoError(t, os.WriteFile(tokenFile, []byte("file-token"), 0600))
+
+		opt := newOptions(server.URL)
+		opt.WebIdentityTokenFile = tokenFile
+		opt.ExternalID = "ext-id"
+
+		_, err := awscloud.ResolveCredentials(context.Background(), opt)
+		assert.Error(t, err)
+		assert.Empty(t, stub.calls, "No role should be assumed")
+	})
+
+	t.Run("The GitHub OIDC token fails outside of GitHub Actions", func(t *testing.T) {
+		server, _ := newStubServer(t)
+		t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+		t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "")
+
+		opt := newOptions(server.URL)
+		opt.GitHubOIDC = true
+
+		_, err := awscloud.ResolveCredentials(context.Background(), opt)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,71 @@
+package awscloud
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"time"
+)
+
+const cloudFrontInvalidationWaitTimeout = 15 * time.Minute
+
+type CloudFrontInvalidationOptions struct {
+	DistributionID string
+	Paths          []string
+	Wait           bool
+	WaitTimeout    time.Duration
+}
+
+// InvalidateCloudFrontPaths creates an invalidation, and (optionally) waits until it's
+// completed. It returns the invalidation ID.
+func InvalidateCloudFrontPaths(ctx context.Context, client *cloudfront.Client,
+	opt CloudFrontInvalidationOptions) (string, error) {
+	if opt.DistributionID == "" {
+		return "", fmt.Errorf("the cloudfront distribution ID is empty")
+	}
+
+	paths := opt.Paths
+	if len(paths) == 0 {
+		paths = []string{"/*"}
+	}
+
+	out, err := client.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(opt.DistributionID),
+		InvalidationBatch: &types.InvalidationBatch{
+			CallerReference: aws.String(fmt.Sprintf("stiletto-%d", time.Now().UnixNano())),
+			Paths: &types.Paths{
+				Items:    paths,
+				Quantity: aws.Int32(int32(len(paths))),
+			},
+		},
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("failed to create the invalidation on distribution %s: %w",
+			opt.DistributionID, err)
+	}
+
+	invalidationID := aws.ToString(out.Invalidation.Id)
+
+	if !opt.Wait {
+		return invalidationID, nil
+	}
+
+	timeout := opt.WaitTimeout
+	if timeout <= 0 {
+		timeout = cloudFrontInvalidationWaitTimeout
+	}
+
+	waiter := cloudfront.NewInvalidationCompletedWaiter(client)
+	if err := waiter.Wait(ctx, &cloudfront.GetInvalidationInput{
+		DistributionId: aws.String(opt.DistributionID),
+		Id:             aws.String(invalidationID),
+	}, timeout); err != nil {
+		return invalidationID, fmt.Errorf("failed waiting for the invalidation %s to complete: %w",
+			invalidationID, err)
+	}
+
+	return invalidationID, nil
+}
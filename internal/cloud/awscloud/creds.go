@@ -1,106 +1,423 @@
 package awscloud
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/errors"
 	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsCfg "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRoleSessionName = "stiletto"
+	githubOIDCAudience     = "sts.amazonaws.com"
 )
 
 type AWSCredentials struct {
 	AccessKeyID     string
 	SecretAccessKey string
+	SessionToken    string
 	Region          string
+	// Source is the provider that resolved the credentials (E.g.: 'StaticCredentials',
+	//'AssumeRoleProvider', 'WebIdentityCredentials').
+	Source    string
+	CanExpire bool
+	Expires   time.Time
 }
 
-func GetAWSRegionSet() (string, error) {
-	cfg := config.Cfg{}
-	awsRegion, viperErr := cfg.GetFromViper("aws-creds-region")
-	awsRegionEnvVars, envVarErr := cfg.GetFromEnvVars("AWS_REGION")
-	awsDefaultRegionEnvVar, awsDefaultRegionEnvVarErr := cfg.GetFromEnvVars("AWS_DEFAULT_REGION")
+// AWSCredentialsOptions holds the inputs (flags, or their env vars equivalents) used to
+// resolve the AWS credentials chain.
+type AWSCredentialsOptions struct {
+	AccessKeyID          string
+	SecretAccessKey      string
+	SessionToken         string
+	Region               string
+	Profile              string
+	AssumeRoleARN        string
+	ExternalID           string
+	RoleSessionName      string
+	WebIdentityTokenFile string
+	// GitHubOIDC assumes the role with the OIDC token of the GitHub Actions runtime.
+	GitHubOIDC bool
+	// EndpointURL is a custom endpoint (E.g.: LocalStack) used by the STS calls, when a role
+	// is assumed.
+	EndpointURL string
+}
 
-	//if viperErr != nil && envVarErr != nil {
-	if viperErr != nil && envVarErr != nil && awsDefaultRegionEnvVarErr != nil {
-		return "", errors.NewAWSCfgError("AWS_REGION is not set ("+
-			"check the flags passed or exported env vars)", nil)
+var (
+	awsConfigCache   = map[AWSCredentialsOptions]aws.Config{}
+	awsConfigCacheMu sync.Mutex
+)
+
+// getFirstNonEmpty returns the first non-empty value, looking first into the value of the config
+// (E.g.: a flag) and then into the environment variables passed, in order.
+func getFirstNonEmpty(cfgValue string, envKeys ...string) string {
+	if value := common.NormaliseNoSpaces(cfgValue); value != "" {
+		return value
 	}
 
-	if viperErr == nil {
-		return awsRegion.Value.(string), nil
+	for _, key := range envKeys {
+		if value := common.NormaliseNoSpaces(os.Getenv(key)); value != "" {
+			return value
+		}
 	}
 
-	if envVarErr == nil {
-		return awsRegionEnvVars.Value.(string), nil
+	return ""
+}
+
+func GetAWSRegionSet() (string, error) {
+	awsCfg, err := config.GetAWSConfig()
+	if err != nil {
+		return "", err
 	}
 
-	if awsDefaultRegionEnvVarErr == nil {
-		return awsDefaultRegionEnvVar.Value.(string), nil
+	region := getFirstNonEmpty(awsCfg.Region, "AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		return "", errors.NewAWSCfgError("AWS_REGION is not set ("+
+			"check the flags passed or exported env vars)", nil)
 	}
 
-	return awsRegionEnvVars.Value.(string), nil
+	return region, nil
 }
 
 func GetAWSAccessKeyID() (string, error) {
-	cfg := config.Cfg{}
-	awsAccessKeyID, viperErr := cfg.GetFromViper("aws-creds-access-key-id")
-	awsAccessKeyIDEnvVars, envVarErr := cfg.GetFromEnvVars("AWS_ACCESS_KEY_ID")
+	awsCfg, err := config.GetAWSConfig()
+	if err != nil {
+		return "", err
+	}
 
-	if viperErr != nil && envVarErr != nil {
+	accessKeyID := getFirstNonEmpty(awsCfg.AccessKeyID, "AWS_ACCESS_KEY_ID")
+	if accessKeyID == "" {
 		return "", errors.NewAWSCfgError("AWS_ACCESS_KEY_ID is not set ("+
 			"check the flags passed or exported env vars)", nil)
 	}
 
-	if viperErr == nil {
-		return awsAccessKeyID.Value.(string), nil
-	}
-
-	return awsAccessKeyIDEnvVars.Value.(string), nil
-
+	return accessKeyID, nil
 }
 
 func GetAWSSecretAccessKey() (string, error) {
-	cfg := config.Cfg{}
-	secretAccessKey, viperErr := cfg.GetFromViper("aws-creds-secret-access-key")
-	secretAccessKeyEnvVars, envVarErr := cfg.GetFromEnvVars("AWS_SECRET_ACCESS_KEY")
+	awsCfg, err := config.GetAWSConfig()
+	if err != nil {
+		return "", err
+	}
 
-	if viperErr != nil && envVarErr != nil {
+	secretAccessKey := getFirstNonEmpty(awsCfg.SecretKey, "AWS_SECRET_ACCESS_KEY")
+	if secretAccessKey == "" {
 		return "", errors.NewAWSCfgError("AWS_SECRET_ACCESS_KEY is not set ("+
 			"check the flags passed or exported env vars)", nil)
 	}
 
-	if viperErr == nil {
-		return secretAccessKey.Value.(string), nil
+	return secretAccessKey, nil
+}
+
+// GetCredentialsOptions builds the credential options from the flags passed, falling back to
+// the well-known AWS environment variables. The role and the web identity token are only taken
+// from the flags: the AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE env vars are resolved by the SDK
+// default chain, so the ambient env never replaces an explicit flag.
+func GetCredentialsOptions() (AWSCredentialsOptions, error) {
+	awsCfg, err := config.GetAWSConfig()
+	if err != nil {
+		return AWSCredentialsOptions{}, err
 	}
 
-	return secretAccessKeyEnvVars.Value.(string), nil
+	return AWSCredentialsOptions{
+		// Static keys are only taken from the flags. If they're exported as env vars,
+		// the SDK default chain will pick them up (along with AWS_SESSION_TOKEN).
+		AccessKeyID:          getFirstNonEmpty(awsCfg.AccessKeyID),
+		SecretAccessKey:      getFirstNonEmpty(awsCfg.SecretKey),
+		SessionToken:         getFirstNonEmpty(awsCfg.SessionToken),
+		Region:               getFirstNonEmpty(awsCfg.Region, "AWS_REGION", "AWS_DEFAULT_REGION"),
+		Profile:              getFirstNonEmpty(awsCfg.Profile),
+		AssumeRoleARN:        getFirstNonEmpty(awsCfg.AssumeRoleARN),
+		ExternalID:           getFirstNonEmpty(awsCfg.AssumeRoleExternalID),
+		RoleSessionName:      getFirstNonEmpty(awsCfg.AssumeRoleSessionName, "AWS_ROLE_SESSION_NAME"),
+		WebIdentityTokenFile: getFirstNonEmpty(awsCfg.WebIdentityTokenFile),
+		GitHubOIDC:           awsCfg.GitHubOIDC,
+		EndpointURL:          getFirstNonEmpty(awsCfg.EndpointURL, "AWS_ENDPOINT_URL"),
+	}, nil
+}
+
+// GitHubOIDCTokenRetriever fetches an OIDC token from the GitHub Actions runtime, using the
+// ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN env vars.
+type GitHubOIDCTokenRetriever struct {
+	RequestURL   string
+	RequestToken string
+	Audience     string
+	HTTPClient   *http.Client
 }
 
-func GetCredentials() (AWSCredentials, error) {
-	awsRegion, err := GetAWSRegionSet()
+func (g GitHubOIDCTokenRetriever) GetIdentityToken() ([]byte, error) {
+	reqURL, err := url.Parse(g.RequestURL)
 	if err != nil {
-		return AWSCredentials{}, errors.NewTaskConfigurationError(
-			"Failed to obtain AWS credentials.", err)
+		return nil, fmt.Errorf("invalid GitHub OIDC request URL: %w", err)
+	}
+
+	if g.Audience != "" {
+		q := reqURL.Query()
+		q.Set("audience", g.Audience)
+		reqURL.RawQuery = q.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+g.RequestToken)
+
+	httpClient := g.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request GitHub OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to request GitHub OIDC token, status code %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		Value string `json:"value"`
+	}
+
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub OIDC token response: %w", err)
+	}
+
+	if tokenResp.Value == "" {
+		return nil, fmt.Errorf("GitHub OIDC token response is empty")
+	}
+
+	return []byte(tokenResp.Value), nil
+}
+
+// getIdentityTokenRetriever returns the web identity token source set in the options (a token
+// file, or the GitHub Actions OIDC runtime), or nil if none is set.
+func getIdentityTokenRetriever(opt AWSCredentialsOptions) (stscreds.IdentityTokenRetriever, error) {
+	if opt.WebIdentityTokenFile != "" && opt.GitHubOIDC {
+		return nil, errors.NewAWSCfgError("Either a web identity token file or the GitHub OIDC "+
+			"token can be used to assume a role, not both", nil)
+	}
+
+	if opt.WebIdentityTokenFile != "" {
+		return stscreds.IdentityTokenFile(opt.WebIdentityTokenFile), nil
+	}
+
+	if !opt.GitHubOIDC {
+		return nil, nil
+	}
+
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+
+	if requestURL == "" || requestToken == "" {
+		return nil, errors.NewAWSCfgError("The GitHub OIDC token is not available (the "+
+			"ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN env vars are not set),"+
+			" check that the workflow has the 'id-token: write' permission", nil)
+	}
+
+	return GitHubOIDCTokenRetriever{
+		RequestURL:   requestURL,
+		RequestToken: requestToken,
+		Audience:     githubOIDCAudience,
+	}, nil
+}
+
+// LoadAWSConfig resolves an aws.Config from the options passed. Static keys passed explicitly
+// win; otherwise, the named profile or the SDK default chain (env vars, shared config, SSO,
+// instance or container roles) is used. If a role ARN is set, the role is assumed on top of
+// it, either with the base credentials or through a web identity token.
+func LoadAWSConfig(ctx context.Context, opt AWSCredentialsOptions) (aws.Config, error) {
+	var loadOpts []func(*awsCfg.LoadOptions) error
+
+	if opt.Region != "" {
+		loadOpts = append(loadOpts, awsCfg.WithRegion(opt.Region))
+	}
+
+	if opt.EndpointURL != "" {
+		if err := ValidateEndpointURL(opt.EndpointURL); err != nil {
+			return aws.Config{}, err
+		}
+
+		loadOpts = append(loadOpts, awsCfg.WithEndpointResolverWithOptions(
+			GetEndpointResolver(opt.EndpointURL)))
+	}
+
+	if opt.AccessKeyID != "" || opt.SecretAccessKey != "" {
+		if opt.AccessKeyID == "" || opt.SecretAccessKey == "" {
+			return aws.Config{}, errors.NewAWSCfgError("Both the access key id and the secret"+
+				" access key should be passed when static credentials are used", nil)
+		}
+
+		loadOpts = append(loadOpts, awsCfg.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(opt.AccessKeyID, opt.SecretAccessKey,
+				opt.SessionToken)))
+	} else if opt.Profile != "" {
+		loadOpts = append(loadOpts, awsCfg.WithSharedConfigProfile(opt.Profile))
 	}
 
-	awsAccessKeyID, err := GetAWSAccessKeyID()
+	cfg, err := awsCfg.LoadDefaultConfig(ctx, loadOpts...)
 	if err != nil {
-		return AWSCredentials{}, errors.NewTaskConfigurationError("Failed to obtain AWS credentials.", err)
+		return aws.Config{}, errors.NewAWSCfgError("Failed to load the AWS configuration", err)
+	}
+
+	if cfg.Region == "" {
+		return aws.Config{}, errors.NewAWSCfgError("AWS_REGION is not set ("+
+			"check the flags passed, the profile or exported env vars)", nil)
 	}
 
-	awsSecretAccessKey, err := GetAWSSecretAccessKey()
+	tokenRetriever, err := getIdentityTokenRetriever(opt)
 	if err != nil {
-		return AWSCredentials{}, errors.NewTaskConfigurationError("Failed to obtain AWS credentials.", err)
+		return aws.Config{}, err
+	}
+
+	if opt.AssumeRoleARN == "" {
+		if tokenRetriever != nil {
+			return aws.Config{}, errors.NewAWSCfgError("A web identity token is set, but there's no"+
+				" role to assume (--assume-role-arn)", nil)
+		}
+
+		return cfg, nil
+	}
+
+	sessionName := opt.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+
+	stsClient := sts.NewFromConfig(cfg)
+
+	if tokenRetriever != nil {
+		if opt.ExternalID != "" {
+			return aws.Config{}, errors.NewAWSCfgError("The external ID can't be passed while "+
+				"assuming a role with a web identity token", nil)
+		}
+
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(stsClient,
+			opt.AssumeRoleARN, tokenRetriever, func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = sessionName
+			}))
+
+		return cfg, nil
+	}
+
+	cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsClient,
+		opt.AssumeRoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+			if opt.ExternalID != "" {
+				o.ExternalID = aws.String(opt.ExternalID)
+			}
+		}))
+
+	return cfg, nil
+}
+
+// GetAWSConfig returns the aws.Config of the options, loaded once and shared by the SDK clients.
+// Its credentials cache refreshes the temporary credentials (E.g.: an assumed role) when they
+// expire, so the clients never use a stale snapshot.
+func GetAWSConfig(ctx context.Context, opt AWSCredentialsOptions) (aws.Config, error) {
+	awsConfigCacheMu.Lock()
+	cached, ok := awsConfigCache[opt]
+	awsConfigCacheMu.Unlock()
+
+	if ok {
+		return cached, nil
+	}
+
+	cfg, err := LoadAWSConfig(ctx, opt)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	awsConfigCacheMu.Lock()
+	defer awsConfigCacheMu.Unlock()
+
+	// Another caller may have loaded it meanwhile; keep a single credentials cache.
+	if cached, ok := awsConfigCache[opt]; ok {
+		return cached, nil
+	}
+
+	awsConfigCache[opt] = cfg
+
+	return cfg, nil
+}
+
+// ResolveCredentials resolves a snapshot of the (possibly temporary) credentials for the options
+// passed, to inject them into the containers. The SDK clients use GetAWSConfig instead. No lock
+// is held while the credentials are retrieved: the credentials cache of the config handles the
+// concurrent calls.
+func ResolveCredentials(ctx context.Context, opt AWSCredentialsOptions) (AWSCredentials, error) {
+	cfg, err := GetAWSConfig(ctx, opt)
+	if err != nil {
+		return AWSCredentials{}, err
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return AWSCredentials{}, errors.NewAWSCfgError("Failed to retrieve AWS credentials", err)
 	}
 
 	return AWSCredentials{
-		AccessKeyID:     awsAccessKeyID,
-		SecretAccessKey: awsSecretAccessKey,
-		Region:          awsRegion,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Region:          cfg.Region,
+		Source:          creds.Source,
+		CanExpire:       creds.CanExpire,
+		Expires:         creds.Expires,
 	}, nil
 }
 
+// GetCredentials resolves the AWS credentials from the flags and env vars. The context bounds the
+// calls of the credential providers (E.g.: SSO, an assumed role).
+func GetCredentials(ctx context.Context) (AWSCredentials, error) {
+	opt, err := GetCredentialsOptions()
+	if err != nil {
+		return AWSCredentials{}, errors.NewTaskConfigurationError(
+			"Failed to obtain AWS credentials.", err)
+	}
+
+	creds, err := ResolveCredentials(ctx, opt)
+	if err != nil {
+		return AWSCredentials{}, errors.NewTaskConfigurationError(
+			"Failed to obtain AWS credentials.", err)
+	}
+
+	return creds, nil
+}
+
 func GetCredentialsAsEnvVarsMap(cred AWSCredentials) map[string]string {
-	return map[string]string{
+	envVars := map[string]string{
 		"AWS_ACCESS_KEY_ID":     cred.AccessKeyID,
 		"AWS_SECRET_ACCESS_KEY": cred.SecretAccessKey,
 		"AWS_REGION":            cred.Region,
+		"AWS_DEFAULT_REGION":    cred.Region,
 	}
+
+	if cred.SessionToken != "" {
+		envVars["AWS_SESSION_TOKEN"] = cred.SessionToken
+	}
+
+	return envVars
 }
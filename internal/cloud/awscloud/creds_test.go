@@ -0,0 +1,127 @@
+package awscloud
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func isolateAWSEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+}
+
+func TestResolveCredentials(t *testing.T) {
+	t.Run("Env vars credentials keep the session token", func(t *testing.T) {
+		isolateAWSEnv(t)
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIAENV")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+		t.Setenv("AWS_SESSION_TOKEN", "env-token")
+
+		creds, err := ResolveCredentials(context.Background(), AWSCredentialsOptions{
+			Region: "us-east-1",
+		})
+
+		assert.NoError(t, err, "Credentials exported as env vars should be resolved")
+		assert.Equal(t, "AKIAENV", creds.AccessKeyID)
+		assert.Equal(t, "env-secret", creds.SecretAccessKey)
+		assert.Equal(t, "env-token", creds.SessionToken)
+		assert.Equal(t, "us-east-1", creds.Region)
+	})
+
+	t.Run("Static credentials passed take precedence", func(t *testing.T) {
+		isolateAWSEnv(t)
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIAENV")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+
+		creds, err := ResolveCredentials(context.Background(), AWSCredentialsOptions{
+			AccessKeyID:     "AKIASTATIC",
+			SecretAccessKey: "static-secret",
+			SessionToken:    "static-token",
+			Region:          "eu-west-1",
+		})
+
+		assert.NoError(t, err, "Static credentials should be resolved")
+		assert.Equal(t, "AKIASTATIC", creds.AccessKeyID)
+		assert.Equal(t, "static-token", creds.SessionToken)
+	})
+
+	t.Run("Partial static credentials fail", func(t *testing.T) {
+		isolateAWSEnv(t)
+
+		_, err := ResolveCredentials(context.Background(), AWSCredentialsOptions{
+			AccessKeyID: "AKIAPARTIAL",
+			Region:      "eu-west-1",
+		})
+
+		assert.Error(t, err, "An access key without its secret should fail")
+	})
+
+	t.Run("Missing region fails", func(t *testing.T) {
+		isolateAWSEnv(t)
+		t.Setenv("AWS_REGION", "")
+		t.Setenv("AWS_DEFAULT_REGION", "")
+
+		_, err := ResolveCredentials(context.Background(), AWSCredentialsOptions{
+			AccessKeyID:     "AKIANOREGION",
+			SecretAccessKey: "secret",
+		})
+
+		assert.Error(t, err, "Credentials without a region should fail")
+	})
+}
+
+func TestGetCredentialsAsEnvVarsMap(t *testing.T) {
+	envVars := GetCredentialsAsEnvVarsMap(AWSCredentials{
+		AccessKeyID:     "AKIA",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Region:          "us-east-1",
+	})
+
+	assert.Equal(t, "token", envVars["AWS_SESSION_TOKEN"])
+	assert.Equal(t, "us-east-1", envVars["AWS_DEFAULT_REGION"])
+
+	envVars = GetCredentialsAsEnvVarsMap(AWSCredentials{AccessKeyID: "AKIA", SecretAccessKey: "secret"})
+	_, ok := envVars["AWS_SESSION_TOKEN"]
+	assert.False(t, ok, "The session token should not be set for long-term credentials")
+}
+
+func TestGitHubOIDCTokenRetriever(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" ||
+			r.URL.Query().Get("audience") != githubOIDCAudience {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"value":"oidc-token"}`))
+	}))
+	defer server.Close()
+
+	token, err := GitHubOIDCTokenRetriever{
+		RequestURL:   server.URL + "/token?api-version=2.0",
+		RequestToken: "request-token",
+		Audience:     githubOIDCAudience,
+	}.GetIdentityToken()
+
+	assert.NoError(t, err, "The OIDC token should be retrieved")
+	assert.Equal(t, "oidc-token", string(token))
+
+	_, err = GitHubOIDCTokenRetriever{
+		RequestURL:   server.URL,
+		RequestToken: "wrong-token",
+		Audience:     githubOIDCAudience,
+	}.GetIdentityToken()
+
+	assert.Error(t, err, "A rejected OIDC token request should fail")
+}
@@ -6,7 +6,9 @@ import (
 	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/errors"
 	"github.com/Excoriate/stiletto/internal/tui"
+	"os"
 	"os/exec"
+	"strings"
 )
 
 func GetImageURL(repository, tag string) string {
@@ -27,13 +29,42 @@ func GetECRPublishAddress(registry, repository string) string {
 	return fmt.Sprintf("%s/%s", registryNormalised, repoNormalised)
 }
 
+// getHostEnvWithCredentials returns the host environment, with the resolved credentials taking
+// precedence, so host commands (E.g.: the AWS CLI) use the same identity as the SDK clients.
+func getHostEnvWithCredentials(credentials AWSCredentials) []string {
+	credEnvVars := GetCredentialsAsEnvVarsMap(credentials)
+
+	var env []string
+	for _, kv := range os.Environ() {
+		key := strings.SplitN(kv, "=", 2)[0]
+		// The profile is dropped as well, since the credentials were already resolved from it.
+		if _, ok := credEnvVars[key]; ok || key == "AWS_PROFILE" {
+			continue
+		}
+
+		env = append(env, kv)
+	}
+
+	for k, v := range credEnvVars {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return env
+}
+
 func AWSECRLogin(registry string, credentials AWSCredentials) error {
 	var out bytes.Buffer
 	uxLog := tui.NewTUIMessage()
 	uxLogPrefix := "ECR-LOGIN"
 
-	cmd := exec.Command("aws", "ecr", "get-login-password", "--region", credentials.Region)
+	loginArgs := []string{"ecr", "get-login-password", "--region", credentials.Region}
+	if endpointURL := GetAWSEndpointURL(); endpointURL != "" {
+		loginArgs = append(loginArgs, "--endpoint-url", endpointURL)
+	}
+
+	cmd := exec.Command("aws", loginArgs...)
 	cmd.Stdout = &out
+	cmd.Env = getHostEnvWithCredentials(credentials)
 
 	uxLog.ShowInfo(uxLogPrefix, fmt.Sprintf("Getting ECR login password for %s", registry))
 
@@ -27,12 +27,13 @@ type ECSUpdateServiceOptions struct {
 	TaskDefARN         string
 }
 
-func GetECSTaskDefinition(client *ecs.Client, taskDefName string) (*ecs.DescribeTaskDefinitionOutput, error) {
+func GetECSTaskDefinition(ctx context.Context, client *ecs.Client,
+	taskDefName string) (*ecs.DescribeTaskDefinitionOutput, error) {
 	input := &ecs.DescribeTaskDefinitionInput{
 		TaskDefinition: &taskDefName,
 	}
 
-	taskDef, err := client.DescribeTaskDefinition(context.TODO(), input)
+	taskDef, err := client.DescribeTaskDefinition(ctx, input)
 	if err != nil {
 		return &ecs.DescribeTaskDefinitionOutput{}, err
 	}
@@ -40,7 +41,7 @@ func GetECSTaskDefinition(client *ecs.Client, taskDefName string) (*ecs.Describe
 	return taskDef, nil
 }
 
-func UpdateECSTaskContainerDefinition(client *ecs.Client,
+func UpdateECSTaskContainerDefinition(ctx context.Context, client *ecs.Client,
 	taskDef *ecs.DescribeTaskDefinitionOutput, opt ECSTaskDefContainerDefUpdateOptions) (string,
 	error) {
 	imageURL := common.NormaliseNoSpaces(opt.ImageURL)
@@ -81,7 +82,7 @@ func UpdateECSTaskContainerDefinition(client *ecs.Client,
 		Memory:                  taskDef.TaskDefinition.Memory,
 	}
 
-	updatedTask, err := client.RegisterTaskDefinition(context.TODO(), newTaskDefInput)
+	updatedTask, err := client.RegisterTaskDefinition(ctx, newTaskDefInput)
 	if err != nil {
 		return "", err
 	}
@@ -94,7 +95,8 @@ func UpdateECSTaskContainerDefinition(client *ecs.Client,
 	return updatedTaskDefARN, nil
 }
 
-func UpdateECSService(client *ecs.Client, opt ECSUpdateServiceOptions) error {
+func UpdateECSService(ctx context.Context, client *ecs.Client,
+	opt ECSUpdateServiceOptions) error {
 	input := &ecs.UpdateServiceInput{
 		Cluster:            aws.String(opt.Cluster),
 		Service:            aws.String(opt.Service),
@@ -102,7 +104,7 @@ func UpdateECSService(client *ecs.Client, opt ECSUpdateServiceOptions) error {
 		ForceNewDeployment: opt.ForceNewDeployment,
 	}
 
-	_, err := client.UpdateService(context.TODO(), input)
+	_, err := client.UpdateService(ctx, input)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,58 @@
+package awscloud
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"net/url"
+	"strings"
+)
+
+// GetAWSEndpointURL returns the custom AWS endpoint (E.g.: LocalStack), if it was set either
+// through the flag or the AWS_ENDPOINT_URL env var. A flag with a wrong type is ignored here
+// (the config validation reports it).
+func GetAWSEndpointURL() string {
+	awsCfg, _ := config.GetAWSConfig()
+
+	return getFirstNonEmpty(awsCfg.EndpointURL, "AWS_ENDPOINT_URL")
+}
+
+// ValidateEndpointURL checks that the custom endpoint is an absolute http(s) URL.
+func ValidateEndpointURL(endpointURL string) error {
+	if endpointURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(endpointURL)
+	if err != nil {
+		return errors.NewAWSCfgError(fmt.Sprintf("Invalid AWS endpoint URL: %s", endpointURL), err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.NewAWSCfgError(fmt.Sprintf("Invalid AWS endpoint URL: %s. "+
+			"It should be an absolute http(s) URL, E.g.: http://localhost:4566", endpointURL), nil)
+	}
+
+	return nil
+}
+
+// GetEndpointResolver returns a resolver that sends every service call to the custom endpoint
+// passed. If it's empty, nil is returned, and the SDK default endpoints are used.
+func GetEndpointResolver(endpointURL string) aws.EndpointResolverWithOptions {
+	if endpointURL == "" {
+		return nil
+	}
+
+	endpointURL = strings.TrimSuffix(endpointURL, "/")
+
+	return aws.EndpointResolverWithOptionsFunc(func(service, region string,
+		options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{
+			URL:               endpointURL,
+			SigningRegion:     region,
+			HostnameImmutable: true,
+			Source:            aws.EndpointSourceCustom,
+		}, nil
+	})
+}
@@ -0,0 +1,221 @@
+package awscloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"time"
+)
+
+const (
+	lambdaUpdatePollInterval = 3 * time.Second
+	lambdaUpdateWaitTimeout  = 5 * time.Minute
+)
+
+// LambdaCodeLocation is where the new function code is taken from. Only one of them should be
+// set: a zip file (in bytes), an S3 object, or a container image URI.
+type LambdaCodeLocation struct {
+	ZipFile  []byte
+	S3Bucket string
+	S3Key    string
+	ImageURI string
+}
+
+type LambdaDeployOptions struct {
+	FunctionName string
+	Code         LambdaCodeLocation
+	Alias        string
+	Description  string
+}
+
+type LambdaDeployResult struct {
+	FunctionARN string
+	Version     string
+	Alias       string
+	CodeSha256  string
+}
+
+func (l LambdaCodeLocation) validate() error {
+	sources := 0
+
+	if len(l.ZipFile) > 0 {
+		sources++
+	}
+
+	if l.S3Bucket != "" || l.S3Key != "" {
+		if l.S3Bucket == "" || l.S3Key == "" {
+			return fmt.Errorf("both the S3 bucket and the S3 key should be set")
+		}
+		sources++
+	}
+
+	if l.ImageURI != "" {
+		sources++
+	}
+
+	if sources != 1 {
+		return fmt.Errorf("exactly one code location (zip file, S3 object or image URI) should"+
+			" be set, got %d", sources)
+	}
+
+	return nil
+}
+
+// UpdateLambdaFunctionCode updates the function's code, without publishing a new version.
+func UpdateLambdaFunctionCode(ctx context.Context, client *lambda.Client,
+	functionName string, code LambdaCodeLocation) (*lambda.UpdateFunctionCodeOutput, error) {
+	if err := code.validate(); err != nil {
+		return nil, err
+	}
+
+	input := &lambda.UpdateFunctionCodeInput{
+		FunctionName: aws.String(functionName),
+	}
+
+	switch {
+	case len(code.ZipFile) > 0:
+		input.ZipFile = code.ZipFile
+	case code.ImageURI != "":
+		input.ImageUri = aws.String(code.ImageURI)
+	default:
+		input.S3Bucket = aws.String(code.S3Bucket)
+		input.S3Key = aws.String(code.S3Key)
+	}
+
+	return client.UpdateFunctionCode(ctx, input)
+}
+
+// WaitForLambdaUpdate polls the function configuration until its LastUpdateStatus is no longer
+// 'InProgress'. A 'Failed' status is returned as an error, along with its reason.
+func WaitForLambdaUpdate(ctx context.Context, client *lambda.Client,
+	functionName string, timeout time.Duration) (*lambda.GetFunctionConfigurationOutput, error) {
+	if timeout <= 0 {
+		timeout = lambdaUpdateWaitTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		cfg, err := client.GetFunctionConfiguration(ctx,
+			&lambda.GetFunctionConfigurationInput{FunctionName: aws.String(functionName)})
+		if err != nil {
+			return nil, err
+		}
+
+		switch cfg.LastUpdateStatus {
+		case types.LastUpdateStatusSuccessful, "":
+			return cfg, nil
+		case types.LastUpdateStatusFailed:
+			return nil, fmt.Errorf("the update of function %s failed (%s): %s", functionName,
+				cfg.LastUpdateStatusReasonCode, aws.ToString(cfg.LastUpdateStatusReason))
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for the update of function %s",
+				timeout, functionName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lambdaUpdatePollInterval):
+		}
+	}
+}
+
+// PublishLambdaVersion publishes a new version, pinned to the code SHA that was just deployed.
+func PublishLambdaVersion(ctx context.Context, client *lambda.Client,
+	functionName, codeSha256, description string) (*lambda.PublishVersionOutput, error) {
+	input := &lambda.PublishVersionInput{
+		FunctionName: aws.String(functionName),
+	}
+
+	if codeSha256 != "" {
+		input.CodeSha256 = aws.String(codeSha256)
+	}
+
+	if description != "" {
+		input.Description = aws.String(description)
+	}
+
+	return client.PublishVersion(ctx, input)
+}
+
+// MoveLambdaAlias points the alias to the version passed, creating the alias if it doesn't
+// exist yet.
+func MoveLambdaAlias(ctx context.Context, client *lambda.Client, functionName, alias,
+	version string) (string, error) {
+	updated, err := client.UpdateAlias(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+	})
+
+	if err == nil {
+		return aws.ToString(updated.AliasArn), nil
+	}
+
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return "", err
+	}
+
+	created, err := client.CreateAlias(ctx, &lambda.CreateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(created.AliasArn), nil
+}
+
+// DeployLambdaFunction updates the function code, waits until the update is completed,
+// publishes a new version and (optionally) moves the alias to it.
+func DeployLambdaFunction(ctx context.Context, client *lambda.Client,
+	opt LambdaDeployOptions) (LambdaDeployResult, error) {
+	if opt.FunctionName == "" {
+		return LambdaDeployResult{}, fmt.Errorf("the function name is empty")
+	}
+
+	updated, err := UpdateLambdaFunctionCode(ctx, client, opt.FunctionName, opt.Code)
+	if err != nil {
+		return LambdaDeployResult{}, fmt.Errorf("failed to update the code of function %s: %w",
+			opt.FunctionName, err)
+	}
+
+	if _, err := WaitForLambdaUpdate(ctx, client, opt.FunctionName, lambdaUpdateWaitTimeout); err != nil {
+		return LambdaDeployResult{}, err
+	}
+
+	published, err := PublishLambdaVersion(ctx, client, opt.FunctionName,
+		aws.ToString(updated.CodeSha256), opt.Description)
+	if err != nil {
+		return LambdaDeployResult{}, fmt.Errorf("failed to publish a new version of function %s: %w",
+			opt.FunctionName, err)
+	}
+
+	result := LambdaDeployResult{
+		FunctionARN: aws.ToString(published.FunctionArn),
+		Version:     aws.ToString(published.Version),
+		CodeSha256:  aws.ToString(published.CodeSha256),
+	}
+
+	if opt.Alias == "" {
+		return result, nil
+	}
+
+	if _, err := MoveLambdaAlias(ctx, client, opt.FunctionName, opt.Alias, result.Version); err != nil {
+		return LambdaDeployResult{}, fmt.Errorf("failed to move alias %s of function %s to version %s: %w",
+			opt.Alias, opt.FunctionName, result.Version, err)
+	}
+
+	result.Alias = opt.Alias
+
+	return result, nil
+}
@@ -0,0 +1,387 @@
+package awscloud
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	s3SyncDefaultConcurrency = 8
+	s3DeleteObjectsBatchSize = 1000
+)
+
+// S3CacheControlRule sets the Cache-Control header of the objects whose (relative) key matches
+// the glob. The first matching rule wins.
+type S3CacheControlRule struct {
+	Glob         string
+	CacheControl string
+}
+
+type S3SyncOptions struct {
+	Bucket            string
+	Prefix            string
+	SourceDir         string
+	CacheControlRules []S3CacheControlRule
+	Delete            bool
+	DryRun            bool
+	Concurrency       int
+}
+
+// S3SyncEntry is a file to be uploaded, with the metadata it'll be uploaded with.
+type S3SyncEntry struct {
+	Key          string
+	FilePath     string
+	ContentType  string
+	CacheControl string
+	Size         int64
+}
+
+// S3SyncPlan is the set of changes required to make the bucket (prefix) match the source dir.
+type S3SyncPlan struct {
+	Upload    []S3SyncEntry
+	Delete    []string
+	Unchanged int
+}
+
+// ParseS3CacheControlRules parses rules in the form 'glob=cache-control', E.g.:
+// '*.html=no-cache' or 'assets/*=public, max-age=31536000, immutable'.
+func ParseS3CacheControlRules(rules []string) ([]S3CacheControlRule, error) {
+	var parsed []S3CacheControlRule
+
+	for _, rule := range rules {
+		glob, value, found := strings.Cut(rule, "=")
+		glob = strings.TrimSpace(glob)
+		value = strings.TrimSpace(value)
+
+		if !found || glob == "" || value == "" {
+			return nil, fmt.Errorf("invalid cache-control rule '%s', "+
+				"expected the format 'glob=cache-control'", rule)
+		}
+
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob '%s' in cache-control rule: %w", glob, err)
+		}
+
+		parsed = append(parsed, S3CacheControlRule{Glob: glob, CacheControl: value})
+	}
+
+	return parsed, nil
+}
+
+// GetCacheControlForKey returns the Cache-Control of the first rule that matches the relative
+// key. Globs without a '/' are also matched against the file name, so '*.html' matches in any
+// directory.
+func GetCacheControlForKey(relKey string, rules []S3CacheControlRule) string {
+	for _, rule := range rules {
+		if ok, _ := path.Match(rule.Glob, relKey); ok {
+			return rule.CacheControl
+		}
+
+		if !strings.Contains(rule.Glob, "/") {
+			if ok, _ := path.Match(rule.Glob, path.Base(relKey)); ok {
+				return rule.CacheControl
+			}
+		}
+	}
+
+	return ""
+}
+
+// DetectContentType resolves the content type from the file extension, falling back to sniffing
+// the first bytes of the file.
+func DetectContentType(filePath string) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(filePath)); contentType != "" {
+		return contentType, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := f.Read(buf)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// getFileMD5 returns the MD5 hex digest of the file, which is what S3 uses as ETag for objects
+// that weren't uploaded in multiple parts.
+func getFileMD5(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func getS3Key(prefix, relKey string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return relKey
+	}
+
+	return prefix + "/" + relKey
+}
+
+// ListS3Objects returns the objects under the prefix, as a map of key to ETag (unquoted).
+func ListS3Objects(ctx context.Context, client *s3.Client, bucket,
+	prefix string) (map[string]string, error) {
+	objects := map[string]string{}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	}
+
+	if p := strings.Trim(prefix, "/"); p != "" {
+		input.Prefix = aws.String(p + "/")
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects in s3://%s/%s: %w", bucket, prefix, err)
+		}
+
+		for _, obj := range page.Contents {
+			objects[aws.ToString(obj.Key)] = strings.Trim(aws.ToString(obj.ETag), `"`)
+		}
+	}
+
+	return objects, nil
+}
+
+// PlanS3Sync compares the source dir with the objects in the bucket. Files whose MD5 matches the
+// object's ETag are skipped; objects without a local file are deleted, if enabled.
+func PlanS3Sync(ctx context.Context, client *s3.Client, opt S3SyncOptions) (S3SyncPlan, error) {
+	plan := S3SyncPlan{}
+
+	info, err := os.Stat(opt.SourceDir)
+	if err != nil || !info.IsDir() {
+		return plan, fmt.Errorf("the source directory %s does not exist, "+
+			"or it is not a directory", opt.SourceDir)
+	}
+
+	remote, err := ListS3Objects(ctx, client, opt.Bucket, opt.Prefix)
+	if err != nil {
+		return plan, err
+	}
+
+	local := map[string]bool{}
+
+	err = filepath.WalkDir(opt.SourceDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(opt.SourceDir, p)
+		if err != nil {
+			return err
+		}
+
+		relKey := filepath.ToSlash(rel)
+		key := getS3Key(opt.Prefix, relKey)
+		local[key] = true
+
+		checksum, err := getFileMD5(p)
+		if err != nil {
+			return err
+		}
+
+		// Multipart ETags (with a '-') aren't an MD5 of the content, so they're always uploaded.
+		if etag, ok := remote[key]; ok && etag == checksum {
+			plan.Unchanged++
+			return nil
+		}
+
+		contentType, err := DetectContentType(p)
+		if err != nil {
+			return err
+		}
+
+		fileInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		plan.Upload = append(plan.Upload, S3SyncEntry{
+			Key:          key,
+			FilePath:     p,
+			ContentType:  contentType,
+			CacheControl: GetCacheControlForKey(relKey, opt.CacheControlRules),
+			Size:         fileInfo.Size(),
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return plan, fmt.Errorf("failed to scan the source directory %s: %w", opt.SourceDir, err)
+	}
+
+	if opt.Delete {
+		for key := range remote {
+			if !local[key] {
+				plan.Delete = append(plan.Delete, key)
+			}
+		}
+
+		sort.Strings(plan.Delete)
+	}
+
+	return plan, nil
+}
+
+func uploadS3SyncEntry(ctx context.Context, client *s3.Client, bucket string,
+	entry S3SyncEntry) error {
+	f, err := os.Open(entry.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s to upload it to S3: %w", entry.FilePath, err)
+	}
+	defer f.Close()
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(entry.Key),
+		Body:        f,
+		ContentType: aws.String(entry.ContentType),
+	}
+
+	if entry.CacheControl != "" {
+		input.CacheControl = aws.String(entry.CacheControl)
+	}
+
+	if _, err := client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload file %s to s3://%s/%s: %w", entry.FilePath, bucket,
+			entry.Key, err)
+	}
+
+	return nil
+}
+
+// ApplyS3SyncPlan uploads the changed files concurrently, and then deletes the stale objects.
+func ApplyS3SyncPlan(ctx context.Context, client *s3.Client, plan S3SyncPlan,
+	opt S3SyncOptions) error {
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = s3SyncDefaultConcurrency
+	}
+
+	entries := make(chan S3SyncEntry)
+	errs := make(chan error, len(plan.Upload))
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				if err := uploadS3SyncEntry(ctx, client, opt.Bucket, entry); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	for _, entry := range plan.Upload {
+		entries <- entry
+	}
+
+	close(entries)
+	wg.Wait()
+	close(errs)
+
+	if err, failed := <-errs; failed {
+		return err
+	}
+
+	for start := 0; start < len(plan.Delete); start += s3DeleteObjectsBatchSize {
+		end := start + s3DeleteObjectsBatchSize
+		if end > len(plan.Delete) {
+			end = len(plan.Delete)
+		}
+
+		var objects []types.ObjectIdentifier
+		for _, key := range plan.Delete[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(opt.Bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: true},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete stale objects from s3://%s: %w", opt.Bucket, err)
+		}
+
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("failed to delete object s3://%s/%s: %s", opt.Bucket,
+				aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+	}
+
+	return nil
+}
+
+// SyncDirToS3 makes the bucket (prefix) match the source dir. On dry-run, the plan is returned
+// without applying it.
+func SyncDirToS3(ctx context.Context, client *s3.Client, opt S3SyncOptions) (S3SyncPlan, error) {
+	plan, err := PlanS3Sync(ctx, client, opt)
+	if err != nil || opt.DryRun {
+		return plan, err
+	}
+
+	return plan, ApplyS3SyncPlan(ctx, client, plan, opt)
+}
+
+// UploadFileToS3 uploads a host file into the bucket and key passed.
+func UploadFileToS3(ctx context.Context, client *s3.Client, bucket, key, filePath,
+	contentType string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s to upload it to S3: %w", filePath, err)
+	}
+	defer f.Close()
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	}
+
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	if _, err := client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload file %s to s3://%s/%s: %w", filePath, bucket, key, err)
+	}
+
+	return nil
+}
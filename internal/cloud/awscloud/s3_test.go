@@ -0,0 +1,53 @@
+package awscloud
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseS3CacheControlRules(t *testing.T) {
+	rules, err := ParseS3CacheControlRules([]string{"*.html=no-cache",
+		"assets/*=public, max-age=31536000, immutable"})
+
+	assert.NoError(t, err, "Valid rules should be parsed")
+	assert.Equal(t, "public, max-age=31536000, immutable", rules[1].CacheControl,
+		"The value can contain '=' and ','")
+
+	_, err = ParseS3CacheControlRules([]string{"no-cache"})
+	assert.Error(t, err, "A rule without glob should fail")
+
+	_, err = ParseS3CacheControlRules([]string{"[.html=no-cache"})
+	assert.Error(t, err, "A malformed glob should fail")
+}
+
+func TestGetCacheControlForKey(t *testing.T) {
+	rules := []S3CacheControlRule{
+		{Glob: "*.html", CacheControl: "no-cache"},
+		{Glob: "assets/*", CacheControl: "immutable"},
+		{Glob: "*", CacheControl: "max-age=60"},
+	}
+
+	assert.Equal(t, "no-cache", GetCacheControlForKey("docs/index.html", rules),
+		"Globs without '/' should match the file name in any directory")
+	assert.Equal(t, "immutable", GetCacheControlForKey("assets/app.js", rules))
+	assert.Equal(t, "max-age=60", GetCacheControlForKey("robots.txt", rules))
+	assert.Equal(t, "", GetCacheControlForKey("robots.txt", nil))
+}
+
+func TestDetectContentType(t *testing.T) {
+	dir := t.TempDir()
+
+	css := filepath.Join(dir, "style.css")
+	_ = os.WriteFile(css, []byte("body {}"), 0644)
+	contentType, err := DetectContentType(css)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/css; charset=utf-8", contentType)
+
+	noExt := filepath.Join(dir, "LICENSE")
+	_ = os.WriteFile(noExt, []byte("plain text"), 0644)
+	contentType, err = DetectContentType(noExt)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", contentType, "Files without extension are sniffed")
+}
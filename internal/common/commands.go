@@ -1,6 +1,10 @@
 package common
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
 
 // ValidateTerragruntCommands validates if the provided commands are valid terragrunt commands
 func ValidateTerragruntCommands(commands []string) error {
@@ -24,3 +28,158 @@ func IsValidCommand(command string, validCommands []string) bool {
 	}
 	return false
 }
+
+// ShellPrelude is prepended to the commands run through a shell (and to the scripts): it stops on
+// the first failing command, on unset variables and, if the shell supports it, on failures in
+// the middle of a pipeline.
+const ShellPrelude = "set -eu; (set -o pipefail) 2>/dev/null && set -o pipefail"
+
+var shellInterpreters = []string{"sh", "bash", "ash", "dash", "ksh", "zsh"}
+
+// SplitCommand splits a command line into its arguments, following the POSIX shell rules for
+// quotes, backslashes and comments. E.g.: `git commit -m "fix: a bug"` is
+// ['git', 'commit', '-m', 'fix: a bug']. Variables aren't expanded (run the command through a
+// shell for that).
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case r == '#' && !inWord:
+			// A comment runs until the end of the line.
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("invalid command '%s', it ends with an escape character",
+					command)
+			}
+
+			i++
+			// A backslash-newline is a line continuation.
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+
+			if end >= len(runes) {
+				return nil, fmt.Errorf("invalid command '%s', it has an unterminated single quote",
+					command)
+			}
+
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				// In double quotes, the backslash only escapes these characters.
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+
+				word.WriteRune(runes[i])
+			}
+
+			if i >= len(runes) {
+				return nil, fmt.Errorf("invalid command '%s', it has an unterminated double quote",
+					command)
+			}
+
+			inWord = true
+
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		args = append(args, word.String())
+	}
+
+	return args, nil
+}
+
+// JoinCommand is the inverse of SplitCommand: it joins the arguments into a command line,
+// single-quoting the ones that a shell would split or expand. E.g.: to show a command.
+func JoinCommand(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`#|&;()<>*?[]{}~") {
+			quoted = append(quoted, arg)
+			continue
+		}
+
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// JoinCommands joins each command (its arguments) into a command line, and the command lines
+// with the separator. E.g.: to show the default commands of a stack.
+func JoinCommands(commands [][]string, sep string) string {
+	lines := make([]string, 0, len(commands))
+	for _, args := range commands {
+		lines = append(lines, JoinCommand(args))
+	}
+
+	return strings.Join(lines, sep)
+}
+
+// GetShellCommand returns the arguments that run the command line through 'sh -c', so pipelines,
+// redirections and variables work.
+func GetShellCommand(command string) []string {
+	return []string{"sh", "-c", fmt.Sprintf("%s\n%s", ShellPrelude, command)}
+}
+
+// GetScriptCommand returns the arguments that run a script (its path, and its content). Scripts
+// for a shell (no shebang means 'sh') are sourced after the ShellPrelude, so its defaults apply;
+// other scripts (E.g.: '#!/usr/bin/env python3') run with the interpreter of their shebang.
+func GetScriptCommand(scriptPath string, content string) []string {
+	interpreter := []string{"sh"}
+
+	firstLine, _, _ := strings.Cut(content, "\n")
+	if strings.HasPrefix(firstLine, "#!") {
+		if fields := strings.Fields(strings.TrimPrefix(firstLine, "#!")); len(fields) > 0 {
+			interpreter = fields
+		}
+	}
+
+	// E.g.: '/usr/bin/env bash', or '/bin/bash -x'.
+	shell := filepath.Base(interpreter[0])
+	if shell == "env" && len(interpreter) > 1 {
+		shell = filepath.Base(interpreter[1])
+	}
+
+	if !IsValidCommand(shell, shellInterpreters) {
+		return append(interpreter, scriptPath)
+	}
+
+	return append(interpreter, "-c", fmt.Sprintf("%s\n. \"$0\"", ShellPrelude), scriptPath)
+}
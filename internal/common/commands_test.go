@@ -0,0 +1,122 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		command  string
+		expected []string
+	}{
+		{"pytest -q", []string{"pytest", "-q"}},
+		{"  ruff   check\t. ", []string{"ruff", "check", "."}},
+		{`git commit -m "fix: a bug"`, []string{"git", "commit", "-m", "fix: a bug"}},
+		{`echo 'single $HOME "quoted"'`, []string{"echo", `single $HOME "quoted"`}},
+		{`echo "double \"quoted\" \$HOME \n"`, []string{"echo", `double "quoted" $HOME \n`}},
+		{`echo a\ b c\"d`, []string{"echo", "a b", `c"d`}},
+		{`echo pre'fix'"ed"`, []string{"echo", "prefixed"}},
+		{`echo '' ""`, []string{"echo", "", ""}},
+		{"echo a \\\n b", []string{"echo", "a", "b"}},
+		{"echo a # a comment", []string{"echo", "a"}},
+		{"echo a#b", []string{"echo", "a#b"}},
+		{"", nil},
+	}
+
+	for _, c := range cases {
+		args, err := SplitCommand(c.command)
+		assert.NoError(t, err, c.command)
+		assert.Equal(t, c.expected, args, c.command)
+	}
+
+	for _, invalid := range []string{`echo "unterminated`, `echo 'unterminated`, `echo a\`} {
+		_, err := SplitCommand(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestJoinCommands(t *testing.T) {
+	assert.Equal(t, "npm ci; echo 'a b'", JoinCommands([][]string{{"npm", "ci"},
+		{"echo", "a b"}}, "; "))
+	assert.Empty(t, JoinCommands(nil, "; "))
+}
+
+func TestJoinCommand(t *testing.T) {
+	assert.Equal(t, "terragrunt plan -no-color", JoinCommand([]string{"terragrunt", "plan",
+		"-no-color"}))
+	assert.Equal(t, `git commit -m 'fix: a bug' '' 'it'\''s'`, JoinCommand([]string{"git",
+		"commit", "-m", "fix: a bug", "", "it's"}))
+
+	// It's the inverse of SplitCommand.
+	for _, args := range [][]string{
+		{"sh", "-c", "set -eu\necho \"$HOME\" | tr a-z A-Z"},
+		{"echo", `a\b`, "#not-a-comment", "~"},
+	} {
+		split, err := SplitCommand(JoinCommand(args))
+		assert.NoError(t, err)
+		assert.Equal(t, args, split)
+	}
+}
+
+func TestGetScriptCommand(t *testing.T) {
+	assert.Equal(t, []string{"sh", "-c", ShellPrelude + "\n. \"$0\"", "/s/build.sh"},
+		GetScriptCommand("/s/build.sh", "echo build\n"))
+
+	assert.Equal(t, []string{"/usr/bin/env", "bash", "-c", ShellPrelude + "\n. \"$0\"", "/s/build.sh"},
+		GetScriptCommand("/s/build.sh", "#!/usr/bin/env bash\necho build\n"))
+
+	assert.Equal(t, []string{"/usr/bin/env", "python3", "/s/build.py"},
+		GetScriptCommand("/s/build.py", "#!/usr/bin/env python3\nprint('build')\n"))
+}
+
+// runOnHost runs the command with the host shell, returning its output.
+func runOnHost(t *testing.T, args []string) (string, error) {
+	if _, err := exec.LookPath(args[0]); err != nil {
+		t.Skipf("%s is not available", args[0])
+	}
+
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+
+	return string(out), err
+}
+
+func TestGetShellCommand(t *testing.T) {
+	out, err := runOnHost(t, GetShellCommand(`echo "a b" | tr a-z A-Z; X=1; echo "x=$X"`))
+	assert.NoError(t, err)
+	assert.Equal(t, "A B\nx=1\n", out)
+
+	out, err = runOnHost(t, GetShellCommand("echo before; false; echo after"))
+	assert.Error(t, err)
+	assert.Equal(t, "before\n", out, "It should stop on the first failure")
+
+	_, err = runOnHost(t, GetShellCommand("echo $STILETTO_UNDEFINED_VAR"))
+	assert.Error(t, err, "Unset variables should fail")
+}
+
+func TestGetScriptCommandOnHost(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	path := write("ok.sh", "echo one\necho \"two three\"\n")
+	out, err := runOnHost(t, GetScriptCommand(path, "echo one\necho \"two three\"\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "one\ntwo three\n", out)
+
+	content := "echo one\nfalse\necho two\n"
+	out, err = runOnHost(t, GetScriptCommand(write("fail.sh", content), content))
+	assert.Error(t, err)
+	assert.Equal(t, "one\n", out, "The script should stop on the first failure")
+
+	content = "#!/usr/bin/env bash\nfalse | true\necho reached\n"
+	out, err = runOnHost(t, GetScriptCommand(write("pipefail.sh", content), content)[1:])
+	assert.Error(t, err, "A failure in a pipeline should fail the script")
+	assert.NotContains(t, out, "reached")
+}
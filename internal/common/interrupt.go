@@ -0,0 +1,99 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	interruptMu     sync.Mutex
+	interruptSignal os.Signal
+	cleanupHooks    []func()
+)
+
+// NotifyInterrupt returns a context that is cancelled on the first SIGINT or SIGTERM, so the
+// work in progress (dagger calls, AWS calls) is cancelled. A second signal exits right away,
+// running the cleanup hooks. The stop function releases the signal handling.
+func NotifyInterrupt(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			interruptMu.Lock()
+			interruptSignal = sig
+			interruptMu.Unlock()
+
+			_, _ = fmt.Fprintf(os.Stderr, "\nReceived %s, cancelling the run "+
+				"(send it again to exit right away)\n", sig)
+			cancel()
+		case <-done:
+			return
+		}
+
+		select {
+		case sig := <-signals:
+			RunCleanups()
+			os.Exit(GetInterruptExitCode(sig))
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			cancel()
+		})
+	}
+}
+
+// GetInterruptSignal returns the signal that cancelled the run, or nil if it wasn't interrupted.
+func GetInterruptSignal() os.Signal {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+
+	return interruptSignal
+}
+
+// GetInterruptExitCode returns the exit code of a process terminated by the signal passed,
+// following the shell convention (128 + the signal number): 130 for SIGINT, 143 for SIGTERM.
+func GetInterruptExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+
+	return 1
+}
+
+// RegisterCleanup adds a hook run by RunCleanups (E.g.: closing the dagger client).
+func RegisterCleanup(hook func()) {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+
+	cleanupHooks = append(cleanupHooks, hook)
+}
+
+// RunCleanups runs the cleanup hooks in the reverse order of their registration. Each hook runs
+// once, even if RunCleanups is called again.
+func RunCleanups() {
+	interruptMu.Lock()
+	hooks := cleanupHooks
+	cleanupHooks = nil
+	interruptMu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestGetInterruptExitCode(t *testing.T) {
+	assert.Equal(t, 130, GetInterruptExitCode(syscall.SIGINT))
+	assert.Equal(t, 143, GetInterruptExitCode(syscall.SIGTERM))
+	assert.Equal(t, 1, GetInterruptExitCode(fakeSignal{}))
+}
+
+type fakeSignal struct{}
+
+func (fakeSignal) String() string { return "fake" }
+func (fakeSignal) Signal()        {}
+
+func TestRunCleanups(t *testing.T) {
+	var order []int
+	RegisterCleanup(func() { order = append(order, 1) })
+	RegisterCleanup(func() { order = append(order, 2) })
+
+	RunCleanups()
+	RunCleanups()
+
+	assert.Equal(t, []int{2, 1}, order, "The hooks should run once, in reverse order")
+}
+
+func TestNotifyInterrupt(t *testing.T) {
+	ctx, stop := NotifyInterrupt(context.Background())
+	defer stop()
+
+	assert.Nil(t, GetInterruptSignal())
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("The context should be cancelled by the signal")
+	}
+
+	assert.Equal(t, syscall.SIGTERM, GetInterruptSignal())
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	DefaultRetryBackoff    = 2 * time.Second
+	DefaultRetryMaxBackoff = time.Minute
+)
+
+// RetryPolicy configures how many times an action is attempted, and the (exponential) wait
+// between the attempts. If RetryOn has patterns (regular expressions), only the errors that match
+// any of them are retried; otherwise, any error is.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	RetryOn     []string
+}
+
+// RetryAttempt is an attempt of an action.
+type RetryAttempt struct {
+	Number    int
+	StartedAt time.Time
+	Duration  time.Duration
+	Error     string // Empty if it succeeded.
+}
+
+// Validate checks that the retry-on patterns are valid regular expressions.
+func (p RetryPolicy) Validate() error {
+	for _, pattern := range p.RetryOn {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid retry-on pattern '%s': %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// IsRetryable returns true if the error message matches the retry-on patterns (or there's none).
+func (p RetryPolicy) IsRetryable(errMsg string) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.RetryOn {
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(errMsg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetBackoff returns the wait after the attempt passed (starting at 1): the backoff, doubled on
+// each attempt, up to the max backoff.
+func (p RetryPolicy) GetBackoff(attempt int) time.Duration {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+
+	return backoff
+}
+
+// Retry runs the action until it succeeds, its error isn't retryable, the attempts are exhausted
+// or the context is done. It returns the history of the attempts, and the error of the last one.
+func Retry(ctx context.Context, policy RetryPolicy, action func(attempt int) error) ([]RetryAttempt,
+	error) {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var attempts []RetryAttempt
+
+	for number := 1; ; number++ {
+		attempt := RetryAttempt{Number: number, StartedAt: time.Now()}
+		err := action(number)
+		attempt.Duration = time.Since(attempt.StartedAt)
+
+		if err == nil {
+			return append(attempts, attempt), nil
+		}
+
+		attempt.Error = err.Error()
+		attempts = append(attempts, attempt)
+
+		if number >= maxAttempts || !policy.IsRetryable(err.Error()) {
+			return attempts, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return attempts, err
+		case <-time.After(policy.GetBackoff(number)):
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyGetBackoff(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, p.GetBackoff(1))
+	assert.Equal(t, 2*time.Second, p.GetBackoff(2))
+	assert.Equal(t, 4*time.Second, p.GetBackoff(3))
+	assert.Equal(t, 5*time.Second, p.GetBackoff(4), "The backoff should be capped")
+
+	assert.Equal(t, DefaultRetryBackoff, RetryPolicy{}.GetBackoff(1))
+}
+
+func TestRetryPolicyIsRetryable(t *testing.T) {
+	assert.True(t, RetryPolicy{}.IsRetryable("anything"))
+
+	p := RetryPolicy{RetryOn: []string{"(?i)timeout", "Error acquiring the state lock"}}
+	assert.True(t, p.IsRetryable("push failed: i/o Timeout"))
+	assert.True(t, p.IsRetryable("stderr:\nError acquiring the state lock"))
+	assert.False(t, p.IsRetryable("unauthorized"))
+
+	assert.Error(t, RetryPolicy{RetryOn: []string{"("}}.Validate())
+	assert.NoError(t, p.Validate())
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, RetryOn: []string{"flaky"}}
+
+	calls := 0
+	attempts, err := Retry(context.Background(), policy, func(attempt int) error {
+		calls++
+		if attempt < 3 {
+			return errors.New("flaky push")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, attempts, 3)
+	assert.Equal(t, "flaky push", attempts[0].Error)
+	assert.Equal(t, "", attempts[2].Error)
+
+	attempts, err = Retry(context.Background(), policy, func(attempt int) error {
+		return errors.New("unauthorized")
+	})
+	assert.EqualError(t, err, "unauthorized")
+	assert.Len(t, attempts, 1, "Errors that don't match the patterns shouldn't be retried")
+
+	attempts, err = Retry(context.Background(), policy, func(attempt int) error {
+		return errors.New("flaky push")
+	})
+	assert.Error(t, err)
+	assert.Len(t, attempts, 3, "The attempts should be exhausted")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts, err = Retry(ctx, RetryPolicy{MaxAttempts: 3, Backoff: time.Hour},
+		func(attempt int) error {
+			return errors.New("flaky push")
+		})
+	assert.Error(t, err)
+	assert.Len(t, attempts, 1, "A done context should stop the retries")
+}
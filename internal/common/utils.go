@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -85,3 +86,44 @@ func MapInterfaceToString(input map[string]interface{}) (map[string]string, erro
 func IsImageURLIncludesTag(imageURL string) bool {
 	return strings.Contains(imageURL, ":")
 }
+
+// GetLastLines returns the last n lines of the text, without its trailing newline.
+func GetLastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// SecretMask replaces the secret values in the output shown or written by stiletto, as dagger
+// does with its own output.
+const SecretMask = "***"
+
+// MaskSecrets replaces each secret value in the text with the SecretMask. The longest values are
+// replaced first (so a secret containing another one is fully masked), and each line of a
+// multi-line secret is masked too, since commands often print them partially.
+func MaskSecrets(text string, secrets []string) string {
+	var values []string
+	for _, secret := range secrets {
+		values = append(values, secret)
+		if strings.Contains(secret, "\n") {
+			values = append(values, strings.Split(secret, "\n")...)
+		}
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		text = strings.ReplaceAll(text, value, SecretMask)
+	}
+
+	return text
+}
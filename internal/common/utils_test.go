@@ -0,0 +1,23 @@
+package common
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetLastLines(t *testing.T) {
+	assert.Equal(t, "c\nd", GetLastLines("a\nb\nc\nd\n", 2))
+	assert.Equal(t, "a\nb", GetLastLines("a\nb", 5))
+	assert.Equal(t, "a\nb", GetLastLines("a\nb\n", 0), "A non-positive n returns all the lines")
+	assert.Equal(t, "", GetLastLines("", 3))
+}
+
+func TestMaskSecrets(t *testing.T) {
+	secrets := []string{"s3cr3t", "s3cr3t-longer", "", "-----BEGIN KEY-----\nabc123\n-----END KEY-----"}
+
+	assert.Equal(t, "token=*** other=***", MaskSecrets("token=s3cr3t other=s3cr3t-longer", secrets))
+	assert.Equal(t, "key: ***", MaskSecrets("key: abc123", secrets),
+		"Each line of a multi-line secret should be masked")
+	assert.Equal(t, "nothing to mask", MaskSecrets("nothing to mask", secrets))
+	assert.Equal(t, "s3cr3t", MaskSecrets("s3cr3t", nil))
+}
@@ -0,0 +1,357 @@
+package daggerio
+
+import (
+	"crypto/sha256"
+	"dagger.io/dagger"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCacheNamespace is the namespace of the cache volumes, if none is passed.
+const DefaultCacheNamespace = "default"
+
+var cacheNameInvalidCharsRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// CacheMount is a well-known dependency cache directory. Its volume can be keyed by the hash of
+// the lock files (found in the target dir), so a dependency change starts from a fresh cache.
+type CacheMount struct {
+	Name      string
+	Path      string
+	LockFiles []string
+}
+
+var CacheMountsMap = map[string]CacheMount{
+	"npm": {Name: "npm", Path: "/root/.npm",
+		LockFiles: []string{"package-lock.json", "npm-shrinkwrap.json", "yarn.lock"}},
+	"pip": {Name: "pip", Path: "/root/.cache/pip",
+		LockFiles: []string{"requirements.txt", "Pipfile.lock", "poetry.lock"}},
+	"go-mod": {Name: "go-mod", Path: "/go/pkg/mod", LockFiles: []string{"go.sum"}},
+	"apk":    {Name: "apk", Path: "/var/cache/apk"},
+	"apt":    {Name: "apt", Path: "/var/cache/apt"},
+}
+
+// CacheOptions configures the cache volumes mounted into the containers of a job.
+type CacheOptions struct {
+	Disabled bool
+	// Isolates the volumes, E.g.: per branch.
+	Namespace string
+	// Keys the volumes by the hash of the lock files.
+	KeyByLockFiles bool
+}
+
+// CacheVolume is a named dagger cache volume, mounted at the path of a cache mount.
+type CacheVolume struct {
+	Name      string
+	Path      string
+	Mount     string
+	Stack     string
+	Namespace string
+}
+
+// NormaliseCacheNamespace returns the namespace in the form used in the volume names. E.g.:
+// 'feature/Login' is 'feature-login'.
+func NormaliseCacheNamespace(namespace string) string {
+	normalised := strings.Trim(cacheNameInvalidCharsRegex.ReplaceAllString(
+		strings.ToLower(namespace), "-"), "-")
+
+	if normalised == "" {
+		return DefaultCacheNamespace
+	}
+
+	return normalised
+}
+
+// GetCacheVolumeName returns the name of the volume of a cache mount. The generation changes
+// when the volumes are pruned, and the key (if any) is the hash of the lock files.
+func GetCacheVolumeName(namespace, stack, mount string, generation int, key string) string {
+	name := fmt.Sprintf("stiletto-%s-%s-%s-g%d", NormaliseCacheNamespace(namespace),
+		NormaliseCacheNamespace(stack), mount, generation)
+
+	if key != "" {
+		name = fmt.Sprintf("%s-%s", name, key)
+	}
+
+	return name
+}
+
+// GetLockFilesHash returns a short hash of the content of the lock files that exist in the dir,
+// or an empty string if none exists.
+func GetLockFilesHash(dir string, lockFiles []string) (string, error) {
+	h := sha256.New()
+	found := false
+
+	for _, name := range lockFiles {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return "", err
+		}
+
+		found = true
+		_, _ = fmt.Fprintf(h, "%s\x00%d\x00", name, len(content))
+		_, _ = h.Write(content)
+	}
+
+	if !found {
+		return "", nil
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// GetCacheMounts resolves the cache mounts of a stack: well-known ones (E.g.: 'npm'), or custom
+// ones in the form 'name=path' (E.g.: 'gradle=/root/.gradle').
+func GetCacheMounts(names []string) ([]CacheMount, error) {
+	var mounts []CacheMount
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		if customName, customPath, isCustom := strings.Cut(name, "="); isCustom {
+			customName = strings.TrimSpace(customName)
+			customPath = strings.TrimSpace(customPath)
+
+			if customName == "" || !strings.HasPrefix(customPath, "/") {
+				return nil, fmt.Errorf("invalid cache mount '%s', it should be in the form "+
+					"'name=/absolute/path'", name)
+			}
+
+			mounts = append(mounts, CacheMount{Name: NormaliseCacheNamespace(customName),
+				Path: customPath})
+			continue
+		}
+
+		mount, ok := CacheMountsMap[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cache mount '%s'", name)
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	return mounts, nil
+}
+
+// GetStackCacheVolumes returns the cache volumes of a stack. The lock files are looked up in the
+// dir passed (normally, the target dir).
+func GetStackCacheVolumes(stack StackDefinition, opts CacheOptions, lockFilesDir string,
+	state *CacheState) ([]CacheVolume, error) {
+	if opts.Disabled {
+		return nil, nil
+	}
+
+	mounts, err := GetCacheMounts(stack.CacheMounts)
+	if err != nil {
+		return nil, err
+	}
+
+	stackNormalised := common.NormaliseStringUpper(stack.Name)
+	namespace := NormaliseCacheNamespace(opts.Namespace)
+	generation := state.GetGeneration(namespace, stackNormalised)
+
+	var volumes []CacheVolume
+	for _, mount := range mounts {
+
+		key := ""
+		if opts.KeyByLockFiles && len(mount.LockFiles) > 0 {
+			hash, err := GetLockFilesHash(lockFilesDir, mount.LockFiles)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash the lock files of the cache %s: %w",
+					mount.Name, err)
+			}
+
+			key = hash
+		}
+
+		volumes = append(volumes, CacheVolume{
+			Name:      GetCacheVolumeName(namespace, stackNormalised, mount.Name, generation, key),
+			Path:      mount.Path,
+			Mount:     mount.Name,
+			Stack:     stackNormalised,
+			Namespace: namespace,
+		})
+	}
+
+	return volumes, nil
+}
+
+// SetCacheVolumesInContainer mounts the cache volumes into the container.
+func SetCacheVolumesInContainer(client *dagger.Client, c *dagger.Container,
+	volumes []CacheVolume) *dagger.Container {
+	for _, v := range volumes {
+		c = c.WithMountedCache(v.Path, client.CacheVolume(v.Name))
+	}
+
+	return c
+}
+
+// CacheVolumeRecord is a cache volume used by a run.
+type CacheVolumeRecord struct {
+	Stack     string    `json:"stack"`
+	Namespace string    `json:"namespace"`
+	Mount     string    `json:"mount"`
+	Path      string    `json:"path"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// CacheState tracks the cache volumes used on this host, and the generation of the volumes of
+// each namespace and stack. The dagger engine doesn't allow to remove a volume, so pruning
+// increments the generation: the next runs use new (empty) volumes, and the engine garbage
+// collects the unused ones.
+type CacheState struct {
+	Generations map[string]int               `json:"generations"`
+	Volumes     map[string]CacheVolumeRecord `json:"volumes"`
+
+	path string
+}
+
+// GetCacheStatePath returns the file where the cache state is stored, in the user cache dir.
+func GetCacheStatePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "stiletto", "cache-volumes.json"), nil
+}
+
+// LoadCacheState reads the cache state. A missing file is an empty state.
+func LoadCacheState(path string) (*CacheState, error) {
+	state := &CacheState{
+		Generations: map[string]int{},
+		Volumes:     map[string]CacheVolumeRecord{},
+		path:        path,
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("invalid cache state file %s: %w", path, err)
+	}
+
+	if state.Generations == nil {
+		state.Generations = map[string]int{}
+	}
+
+	if state.Volumes == nil {
+		state.Volumes = map[string]CacheVolumeRecord{}
+	}
+
+	return state, nil
+}
+
+// Save writes the cache state into the file it was loaded from.
+func (s *CacheState) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, content, 0644)
+}
+
+func getCacheGenerationKey(namespace, stack string) string {
+	return fmt.Sprintf("%s/%s", NormaliseCacheNamespace(namespace), common.NormaliseStringUpper(stack))
+}
+
+// GetGeneration returns the current generation of the volumes of the namespace and stack.
+func (s *CacheState) GetGeneration(namespace, stack string) int {
+	if s == nil {
+		return 0
+	}
+
+	return s.Generations[getCacheGenerationKey(namespace, stack)]
+}
+
+// cacheUsageResolution is how often the last use of a volume is refreshed, so the state file
+// isn't rewritten on every run.
+const cacheUsageResolution = 24 * time.Hour
+
+// RecordVolumes marks the volumes as used now, returning whether the state changed (a new
+// volume, or a last use older than the cacheUsageResolution) and has to be saved.
+func (s *CacheState) RecordVolumes(volumes []CacheVolume) bool {
+	changed := false
+	now := time.Now().UTC()
+
+	for _, v := range volumes {
+		record := CacheVolumeRecord{
+			Stack:     v.Stack,
+			Namespace: v.Namespace,
+			Mount:     v.Mount,
+			Path:      v.Path,
+			LastUsed:  now,
+		}
+
+		previous, exists := s.Volumes[v.Name]
+		if exists && now.Sub(previous.LastUsed) < cacheUsageResolution {
+			record.LastUsed = previous.LastUsed
+			if previous == record {
+				continue
+			}
+		}
+
+		s.Volumes[v.Name] = record
+		changed = true
+	}
+
+	return changed
+}
+
+// Prune discards the volumes of the namespace and stack (all of them if they're empty),
+// returning their (sorted) names. The generations are incremented, so the next runs start with
+// new volumes.
+func (s *CacheState) Prune(namespace, stack string) []string {
+	if namespace != "" {
+		namespace = NormaliseCacheNamespace(namespace)
+	}
+
+	stack = common.NormaliseStringUpper(stack)
+
+	var pruned []string
+	bumped := map[string]bool{}
+
+	for name, record := range s.Volumes {
+		if (namespace != "" && record.Namespace != namespace) || (stack != "" && record.Stack != stack) {
+			continue
+		}
+
+		pruned = append(pruned, name)
+		delete(s.Volumes, name)
+		bumped[getCacheGenerationKey(record.Namespace, record.Stack)] = true
+	}
+
+	// The generation is bumped even if no volume was recorded (E.g.: the state was removed).
+	if namespace != "" && stack != "" {
+		bumped[getCacheGenerationKey(namespace, stack)] = true
+	}
+
+	for key := range bumped {
+		s.Generations[key]++
+	}
+
+	sort.Strings(pruned)
+
+	return pruned
+}
@@ -0,0 +1,116 @@
+package daggerio
+
+import (
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func getTestStack(t *testing.T, name string) StackDefinition {
+	stacks, err := GetStacks(nil)
+	assert.NoError(t, err)
+
+	return stacks[common.NormaliseStringUpper(name)]
+}
+
+func TestGetStackCacheVolumes(t *testing.T) {
+	dir := t.TempDir()
+
+	volumes, err := GetStackCacheVolumes(getTestStack(t, "python"), CacheOptions{Namespace: "Feature/Login"}, dir, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []CacheVolume{
+		{Name: "stiletto-feature-login-python-pip-g0", Path: "/root/.cache/pip", Mount: "pip",
+			Stack: "PYTHON", Namespace: "feature-login"},
+		{Name: "stiletto-feature-login-python-apt-g0", Path: "/var/cache/apt", Mount: "apt",
+			Stack: "PYTHON", Namespace: "feature-login"},
+	}, volumes)
+
+	volumes, err = GetStackCacheVolumes(getTestStack(t, "python"), CacheOptions{Disabled: true}, dir, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, volumes)
+
+	volumes, err = GetStackCacheVolumes(StackDefinition{Name: "UNKNOWN"}, CacheOptions{}, dir, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, volumes, "Stacks without cache mounts have no volumes")
+
+	// Keyed by the lock files, only if they exist.
+	opts := CacheOptions{KeyByLockFiles: true}
+	volumes, err = GetStackCacheVolumes(getTestStack(t, "python"), opts, dir, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "stiletto-default-python-pip-g0", volumes[0].Name)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("flask==2.0\n"),
+		0o644))
+	volumes, err = GetStackCacheVolumes(getTestStack(t, "python"), opts, dir, nil)
+	assert.NoError(t, err)
+	first := volumes[0].Name
+	assert.Regexp(t, `^stiletto-default-python-pip-g0-[0-9a-f]{12}$`, first)
+	assert.Equal(t, "stiletto-default-python-apt-g0", volumes[1].Name, "apt has no lock files")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("flask==2.1\n"),
+		0o644))
+	volumes, err = GetStackCacheVolumes(getTestStack(t, "python"), opts, dir, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, volumes[0].Name, "A dependency change should change the volume")
+}
+
+func TestCacheStatePrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stiletto", "cache-volumes.json")
+
+	state, err := LoadCacheState(path)
+	assert.NoError(t, err)
+
+	for _, ns := range []string{"main", "feature-x"} {
+		volumes, err := GetStackCacheVolumes(getTestStack(t, "aws"), CacheOptions{Namespace: ns}, "", state)
+		assert.NoError(t, err)
+		state.RecordVolumes(volumes)
+	}
+
+	volumes, err := GetStackCacheVolumes(getTestStack(t, "docker"), CacheOptions{Namespace: "main"}, "", state)
+	assert.NoError(t, err)
+	assert.True(t, state.RecordVolumes(volumes))
+	assert.False(t, state.RecordVolumes(volumes), "Used again, the state shouldn't change")
+	assert.NoError(t, state.Save())
+
+	state, err = LoadCacheState(path)
+	assert.NoError(t, err)
+	assert.Len(t, state.Volumes, 9)
+
+	pruned := state.Prune("main", "aws")
+	assert.Len(t, pruned, 4)
+	assert.Equal(t, 1, state.GetGeneration("main", "AWS"))
+	assert.Equal(t, 0, state.GetGeneration("main", "DOCKER"))
+	assert.Equal(t, 0, state.GetGeneration("feature-x", "AWS"))
+
+	volumes, err = GetStackCacheVolumes(getTestStack(t, "aws"), CacheOptions{Namespace: "main"}, "", state)
+	assert.NoError(t, err)
+	assert.Equal(t, "stiletto-main-aws-apk-g1", volumes[0].Name,
+		"The next runs should use new volumes")
+
+	pruned = state.Prune("main", "")
+	assert.Equal(t, []string{"stiletto-main-docker-apk-g0"}, pruned)
+
+	pruned = state.Prune("", "")
+	assert.Len(t, pruned, 4, "All the namespaces should be pruned")
+	assert.Empty(t, state.Volumes)
+	assert.Equal(t, 1, state.GetGeneration("feature-x", "AWS"))
+
+	// Nothing recorded, but the generation is bumped anyway.
+	assert.Empty(t, state.Prune("main", "python"))
+	assert.Equal(t, 1, state.GetGeneration("main", "PYTHON"))
+}
+
+func TestGetCacheMounts(t *testing.T) {
+	mounts, err := GetCacheMounts([]string{"npm", "Gradle = /root/.gradle"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/root/.npm", mounts[0].Path)
+	assert.Equal(t, CacheMount{Name: "gradle", Path: "/root/.gradle"}, mounts[1])
+
+	_, err = GetCacheMounts([]string{"gradle=relative/path"})
+	assert.Error(t, err)
+
+	_, err = GetCacheMounts([]string{"unknown"})
+	assert.Error(t, err)
+}
@@ -25,3 +25,15 @@ func SetEnvVarsInContainer(c *dagger.Container, envVars map[string]string) (*dag
 
 	return c, nil
 }
+
+// LambdaRuntimeImagesMap maps an AWS Lambda runtime to the (language) container image used to
+// build and package the function code.
+var LambdaRuntimeImagesMap = map[string]string{
+	"PYTHON3.8":    "python:3.8-slim",
+	"PYTHON3.9":    "python:3.9-slim",
+	"PYTHON3.10":   "python:3.10-slim",
+	"NODEJS16.X":   "node:16-alpine",
+	"NODEJS18.X":   "node:18-alpine",
+	"GO1.X":        "golang:1.20-alpine",
+	"PROVIDED.AL2": "golang:1.20-alpine",
+}
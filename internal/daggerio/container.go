@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
 	"github.com/Excoriate/stiletto/internal/logger"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -56,47 +59,34 @@ func GetContainerImageCustom(imageURL, version string) (DaggerContainerImage, er
 	}, nil
 }
 
-// GetContainerImagePerStack returns the container image of the dagger client.
-func GetContainerImagePerStack(stack string, version string) (string, error) {
-	logPrinter := logger.PipelineLogger{}
-	logPrinter.InitLogger()
-
-	if stack == "" {
+// GetContainerImagePerStack returns the container image of the stack.
+func GetContainerImagePerStack(stack StackDefinition) (string, error) {
+	if stack.Name == "" {
 		return "", errors.NewDaggerEngineError("Unable to fetch container image, "+
 			"stack value is empty",
 			nil)
 	}
 
-	stackNormalised := common.NormaliseStringUpper(stack)
-
-	if version == "" {
-		logPrinter.LogWarn("Dagger Image Configuration",
-			fmt.Sprintf("The 'version' option is empty, "+
-				"it will use 'latest' as the image version for the image %s passed", stackNormalised))
-		version = "latest"
-	}
-
-	if _, ok := StackImagesMap[stackNormalised]; !ok {
+	if err := stack.Validate(); err != nil {
 		return "", errors.NewDaggerEngineError(fmt.Sprintf("Unable to fetch container image, "+
-			"stack %s is not supported or it could not be found", stackNormalised),
-			nil)
+			"stack %s is not valid", stack.Name), err)
 	}
 
-	imageFromStackConfig := StackImagesMap[stackNormalised]
-	// If the image has ":", it means that the user has passed the version as well.
-	if strings.Contains(imageFromStackConfig, ":") {
-		logPrinter.LogWarn("Dagger Image Configuration", "It seems that you have passed the"+
-			" version of the image as"+
-			" well along"+
-			" with the image name. The version will be ignored and the version passed will be used.")
+	return stack.GetImageRef(), nil
+}
 
-		imageURLFromDefault := common.NormaliseStringLower(strings.Split(imageFromStackConfig, ":")[0])
-		imageVersionFromDefault := common.NormaliseStringLower(strings.Split(imageFromStackConfig, ":")[1])
+// ScriptsMountPath is where the scripts of the host are mounted in the containers.
+const ScriptsMountPath = "/stiletto/scripts"
 
-		return fmt.Sprintf("%s:%s", imageURLFromDefault, imageVersionFromDefault), nil
-	}
+// MountHostFile mounts a file of the host into the container. Only the file is uploaded, not its
+// directory.
+func MountHostFile(c *dagger.Client, container *dagger.Container, hostPath,
+	containerPath string) *dagger.Container {
+	file := c.Host().Directory(filepath.Dir(hostPath), dagger.HostDirectoryOpts{
+		Include: []string{filepath.Base(hostPath)},
+	}).File(filepath.Base(hostPath))
 
-	return fmt.Sprintf("%s:%s", StackImagesMap[stackNormalised], version), nil
+	return container.WithMountedFile(containerPath, file)
 }
 
 // GetContainer returns the container of the dagger client.
@@ -147,6 +137,15 @@ func PushImage(container *dagger.Container, url string, ctx context.Context) (st
 	return addr, nil
 }
 
+// SetLabelsInContainer sets the labels (E.g.: OCI annotations) into the container image.
+func SetLabelsInContainer(c *dagger.Container, labels map[string]string) *dagger.Container {
+	for k, v := range labels {
+		c = c.WithLabel(k, v)
+	}
+
+	return c
+}
+
 // BuildImage builds the image of the dagger client.
 func BuildImage(dockerFilePath string, client *dagger.Client,
 	container *dagger.Container) (*dagger.Container, error) {
@@ -159,10 +158,156 @@ func BuildImage(dockerFilePath string, client *dagger.Client,
 			"docker file directory is empty", nil)
 	}
 
-	dockerFileDir, err := GetDaggerDirWithEntriesCheck(client, dockerFilePath)
+	// The build context honours its .dockerignore (and .stilettoignore) file.
+	filter, err := filesystem.ResolveUploadFilter(dockerFilePath, true, nil, nil)
+	if err != nil {
+		return nil, errors.NewDaggerEngineError("Unable to build image", err)
+	}
+
+	dockerFileDir, err := GetDaggerDirWithEntriesCheck(client, dockerFilePath,
+		GetHostDirectoryOpts(filter))
 	if err != nil {
 		return nil, errors.NewDaggerEngineError("Unable to build image", err)
 	}
 
 	return container.Build(dockerFileDir), nil
 }
+
+// ExportFile exports a file from the container into the host path passed.
+func ExportFile(container *dagger.Container, pathInContainer, hostPath string,
+	ctx context.Context) error {
+	if container == nil {
+		return errors.NewDaggerEngineError("Unable to export file, container is nil", nil)
+	}
+
+	if pathInContainer == "" || hostPath == "" {
+		return errors.NewDaggerEngineError(fmt.Sprintf("Unable to export file, "+
+			"the path in the container (%s) or in the host (%s) is empty", pathInContainer,
+			hostPath), nil)
+	}
+
+	ok, err := container.File(pathInContainer).Export(ctx, hostPath)
+	if err != nil {
+		return errors.NewDaggerEngineError(fmt.Sprintf("Unable to export file %s into %s",
+			pathInContainer, hostPath), err)
+	}
+
+	if !ok {
+		return errors.NewDaggerEngineError(fmt.Sprintf("Unable to export file %s into %s, "+
+			"the export was not completed", pathInContainer, hostPath), nil)
+	}
+
+	return nil
+}
+
+// ExportDir exports a directory from the container into the host path passed.
+func ExportDir(container *dagger.Container, pathInContainer, hostPath string,
+	ctx context.Context) error {
+	if container == nil {
+		return errors.NewDaggerEngineError("Unable to export directory, container is nil", nil)
+	}
+
+	if pathInContainer == "" || hostPath == "" {
+		return errors.NewDaggerEngineError(fmt.Sprintf("Unable to export directory, "+
+			"the path in the container (%s) or in the host (%s) is empty", pathInContainer,
+			hostPath), nil)
+	}
+
+	ok, err := container.Directory(pathInContainer).Export(ctx, hostPath)
+	if err != nil {
+		return errors.NewDaggerEngineError(fmt.Sprintf("Unable to export directory %s into %s",
+			pathInContainer, hostPath), err)
+	}
+
+	if !ok {
+		return errors.NewDaggerEngineError(fmt.Sprintf("Unable to export directory %s into %s, "+
+			"the export was not completed", pathInContainer, hostPath), nil)
+	}
+
+	return nil
+}
+
+// ContainerPath is a file or directory that exists in a container.
+type ContainerPath struct {
+	Path  string
+	IsDir bool
+}
+
+// ResolveContainerPaths returns the files and directories of the container that match the
+// pattern. Relative patterns are relative to the container's workdir, and each segment can be a
+// glob (E.g.: 'dist/*.zip' or 'build/*/reports'). A pattern without globs that doesn't exist
+// returns an error; a glob that matches nothing returns no paths.
+func ResolveContainerPaths(container *dagger.Container, pattern string,
+	ctx context.Context) ([]ContainerPath, error) {
+	if container == nil {
+		return nil, errors.NewDaggerEngineError("Unable to resolve the paths, container is nil", nil)
+	}
+
+	root := "/"
+	if !path.IsAbs(pattern) {
+		workDir, err := container.Workdir(ctx)
+		if err != nil {
+			return nil, errors.NewDaggerEngineError("Unable to resolve the container workdir", err)
+		}
+
+		if workDir != "" {
+			root = workDir
+		}
+	}
+
+	candidates := []string{root}
+	for _, segment := range strings.Split(path.Clean("/"+pattern), "/")[1:] {
+		if segment == "" {
+			continue
+		}
+
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, errors.NewDaggerEngineError(fmt.Sprintf("Invalid path pattern %s",
+				pattern), err)
+		}
+
+		var next []string
+		for _, candidate := range candidates {
+			if !strings.ContainsAny(segment, "*?[") {
+				next = append(next, path.Join(candidate, segment))
+				continue
+			}
+
+			// Only directories have entries, the rest of the candidates are discarded.
+			entries, err := container.Directory(candidate).Entries(ctx)
+			if err != nil {
+				continue
+			}
+
+			for _, entry := range entries {
+				if ok, _ := path.Match(segment, entry); ok {
+					next = append(next, path.Join(candidate, entry))
+				}
+			}
+		}
+
+		candidates = next
+	}
+
+	isGlob := strings.ContainsAny(pattern, "*?[")
+
+	var paths []ContainerPath
+	for _, candidate := range candidates {
+		if _, err := container.Directory(candidate).Entries(ctx); err == nil {
+			paths = append(paths, ContainerPath{Path: candidate, IsDir: true})
+			continue
+		}
+
+		if _, err := container.File(candidate).Size(ctx); err == nil {
+			paths = append(paths, ContainerPath{Path: candidate})
+			continue
+		}
+
+		if !isGlob {
+			return nil, errors.NewDaggerEngineError(fmt.Sprintf("The path %s does not exist in "+
+				"the container", candidate), nil)
+		}
+	}
+
+	return paths, nil
+}
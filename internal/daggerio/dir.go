@@ -9,10 +9,12 @@ import (
 	"github.com/Excoriate/stiletto/internal/filesystem"
 )
 
-// GetDaggerDir returns the working directory of the dagger client.
-func GetDaggerDir(c *dagger.Client, dir string) (*dagger.Directory, error) {
+// GetDaggerDir returns the working directory of the dagger client. The options (if any) filter
+// the files that are uploaded from the host.
+func GetDaggerDir(c *dagger.Client, dir string, opts ...dagger.HostDirectoryOpts) (*dagger.Directory,
+	error) {
 	if dir == "" {
-		return c.Host().Directory("."), nil // Which will map to the current directory.
+		return c.Host().Directory(".", opts...), nil // Which will map to the current directory.
 	}
 
 	if err := filesystem.DirIsValid(dir); err != nil {
@@ -21,7 +23,15 @@ func GetDaggerDir(c *dagger.Client, dir string) (*dagger.Directory, error) {
 			err)
 	}
 
-	return c.Host().Directory(dir), nil
+	return c.Host().Directory(dir, opts...), nil
+}
+
+// GetHostDirectoryOpts converts the upload filter into the options of a host directory.
+func GetHostDirectoryOpts(filter filesystem.UploadFilter) dagger.HostDirectoryOpts {
+	return dagger.HostDirectoryOpts{
+		Include: filter.Include,
+		Exclude: filter.Exclude,
+	}
 }
 
 func VerifyFileEntriesInMountedDir(c *dagger.Client, dir string, files []string,
@@ -56,9 +66,10 @@ func VerifyFileEntriesInMountedDir(c *dagger.Client, dir string, files []string,
 }
 
 // GetDaggerDirWithEntriesCheck returns the working directory of the dagger client.
-func GetDaggerDirWithEntriesCheck(c *dagger.Client, dir string) (*dagger.Directory, error) {
+func GetDaggerDirWithEntriesCheck(c *dagger.Client, dir string,
+	opts ...dagger.HostDirectoryOpts) (*dagger.Directory, error) {
 	if dir == "" {
-		return c.Host().Directory("."), nil // Which will map to the current directory.
+		return c.Host().Directory(".", opts...), nil // Which will map to the current directory.
 	}
 
 	if err := filesystem.DirExist(dir); err != nil {
@@ -74,11 +85,11 @@ func GetDaggerDirWithEntriesCheck(c *dagger.Client, dir string) (*dagger.Directo
 	}
 
 	ctx := context.Background()
-	if _, err := ListEntries(c.Host().Directory(dir), true, &ctx); err != nil {
+	if _, err := ListEntries(c.Host().Directory(dir, opts...), true, &ctx); err != nil {
 		return nil, err
 	}
 
-	return c.Host().Directory(dir), nil
+	return c.Host().Directory(dir, opts...), nil
 }
 
 // ListEntries lists the entries in a dagger directory.
@@ -0,0 +1,86 @@
+package daggerio
+
+import (
+	"context"
+	"dagger.io/dagger"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+)
+
+// EngineRunnerHostEnvVar points the dagger SDK to an engine that is already running (E.g.:
+// 'tcp://dagger:1234'), instead of provisioning one with the local container runtime.
+const EngineRunnerHostEnvVar = "_EXPERIMENTAL_DAGGER_RUNNER_HOST"
+
+// EngineCLIBinEnvVar points the dagger SDK to the dagger CLI to use, instead of downloading it.
+const EngineCLIBinEnvVar = "_EXPERIMENTAL_DAGGER_CLI_BIN"
+
+// EngineInfo describes the dagger engine that the pipelines connect to.
+type EngineInfo struct {
+	SDKVersion string
+	// Only known if the dagger CLI is on the PATH (or set in _EXPERIMENTAL_DAGGER_CLI_BIN).
+	CLIVersion string
+	Platform   string
+}
+
+// GetEngineInfo connects to the dagger engine (provisioning it, as the runs do), and returns its
+// default platform, along with the versions of the SDK and the CLI. The engine logs are discarded.
+func GetEngineInfo(ctx context.Context) (EngineInfo, error) {
+	info := EngineInfo{
+		SDKVersion: GetSDKVersion(),
+		CLIVersion: getCLIVersion(ctx),
+	}
+
+	client, err := dagger.Connect(ctx, dagger.WithLogOutput(io.Discard))
+	if err != nil {
+		return info, err
+	}
+
+	defer client.Close()
+
+	platform, err := client.DefaultPlatform(ctx)
+	if err != nil {
+		return info, err
+	}
+
+	info.Platform = string(platform)
+
+	return info, nil
+}
+
+// GetSDKVersion returns the version of the dagger SDK that stiletto is built with.
+func GetSDKVersion() string {
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, dep := range build.Deps {
+		if dep.Path == "dagger.io/dagger" {
+			return dep.Version
+		}
+	}
+
+	return ""
+}
+
+func getCLIVersion(ctx context.Context) string {
+	bin := os.Getenv(EngineCLIBinEnvVar)
+	if bin == "" {
+		path, err := exec.LookPath("dagger")
+		if err != nil {
+			return ""
+		}
+
+		bin = path
+	}
+
+	out, err := exec.CommandContext(ctx, bin, "version").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
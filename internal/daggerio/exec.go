@@ -0,0 +1,82 @@
+package daggerio
+
+import (
+	"context"
+	"dagger.io/dagger"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// execErrExitCodeRegex matches the exit code in the error the engine returns when a command
+// fails. E.g.: 'process "pytest" did not complete successfully: exit code: 1'.
+var execErrExitCodeRegex = regexp.MustCompile(`did not complete successfully: exit code: (\d+)`)
+
+// ExecResult is the captured output of a command run in a container.
+type ExecResult struct {
+	Command  []string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ParseExecError returns the result of a failed command from the error of the engine: its exit
+// code and, if the engine includes them, its stdout and stderr. It returns false if the error
+// isn't a failed command (E.g.: the engine couldn't pull the image).
+func ParseExecError(cmd []string, err error) (ExecResult, bool) {
+	result := ExecResult{Command: cmd}
+
+	match := execErrExitCodeRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return result, false
+	}
+
+	result.ExitCode, _ = strconv.Atoi(match[1])
+
+	output := err.Error()[strings.Index(err.Error(), match[0])+len(match[0]):]
+	if i := strings.Index(output, "\nStdout:\n"); i >= 0 {
+		output = output[i+len("\nStdout:\n"):]
+		if j := strings.Index(output, "\nStderr:\n"); j >= 0 {
+			result.Stdout = output[:j]
+			result.Stderr = output[j+len("\nStderr:\n"):]
+		} else {
+			result.Stdout = output
+		}
+	} else if i := strings.Index(output, "\nStderr:\n"); i >= 0 {
+		result.Stderr = output[i+len("\nStderr:\n"):]
+	}
+
+	return result, true
+}
+
+// ExecCaptured runs the command in the container, capturing its stdout, stderr and exit code
+// (even if it fails) through the dagger API. The command is run as is, so the image doesn't
+// need a shell. The error is only set if the engine failed to run the command; a non-zero exit
+// code is reported in the result, and the container returned is nil.
+func ExecCaptured(container *dagger.Container, cmd []string,
+	ctx context.Context) (*dagger.Container, ExecResult, error) {
+	result := ExecResult{Command: cmd}
+	executed := container.WithExec(cmd)
+
+	exitCode, err := executed.ExitCode(ctx)
+	if err != nil {
+		failed, ok := ParseExecError(cmd, err)
+		if !ok {
+			return nil, result, err
+		}
+
+		return nil, failed, nil
+	}
+
+	result.ExitCode = exitCode
+
+	if result.Stdout, err = executed.Stdout(ctx); err != nil {
+		return nil, result, err
+	}
+
+	if result.Stderr, err = executed.Stderr(ctx); err != nil {
+		return nil, result, err
+	}
+
+	return executed, result, nil
+}
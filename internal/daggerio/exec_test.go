@@ -0,0 +1,26 @@
+package daggerio
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseExecError(t *testing.T) {
+	cmd := []string{"pytest", "-q"}
+
+	result, ok := ParseExecError(cmd, fmt.Errorf("input:1: container.from.withExec.exitCode "+
+		"process \"pytest -q\" did not complete successfully: exit code: 2\n\nStdout:\n1 failed"+
+		"\nStderr:\nboom\n"))
+	assert.True(t, ok)
+	assert.Equal(t, ExecResult{Command: cmd, ExitCode: 2, Stdout: "1 failed", Stderr: "boom\n"},
+		result)
+
+	result, ok = ParseExecError(cmd, fmt.Errorf("process \"pytest -q\" did not complete "+
+		"successfully: exit code: 1"))
+	assert.True(t, ok)
+	assert.Equal(t, ExecResult{Command: cmd, ExitCode: 1}, result)
+
+	_, ok = ParseExecError(cmd, fmt.Errorf("failed to resolve image: not found"))
+	assert.False(t, ok)
+}
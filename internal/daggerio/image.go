@@ -1,16 +1,201 @@
 package daggerio
 
-var StackImagesMap = map[string]string{
-	"PYTHON":           "python:3.8.5-slim-buster",
-	"DOCKER":           "docker:23.0.1-dind",
-	"INFRA:TERRAFORM":  "hashicorp/terraform",
-	"INFRA:TERRAGRUNT": "alpine/terragrunt",
-	//"AWS":        "amazon/aws-cli:latest",
-	"AWS":    "alpine:latest",
-	"ALPINE": "alpine:latest",
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"sort"
+	"strings"
+)
+
+const (
+	StackSourceBuiltIn    = "built-in"
+	StackSourceConfig     = "config"
+	StackSourceOverridden = "built-in (overridden)"
+)
+
+// StackDefinition describes the container of a stack: its image (pinned by version or digest),
+// and the defaults of the containers created from it.
+type StackDefinition struct {
+	Name    string
+	Image   string
+	Version string
+	Digest  string
+
+	Env           map[string]string
+	WorkDir       string
+	CacheMounts   []string   // Names of CacheMountsMap, or custom 'name=path' mounts.
+	PreRequisites []string   // Files that should exist in the target dir.
+	Commands      [][]string // Default commands (their arguments), if the task doesn't pass any.
+
+	Source string // One of the StackSource* values.
+}
+
+// BuiltInStacks are the stacks available without any configuration. They can be overridden,
+// E.g.: to pin another version of their image.
+var BuiltInStacks = map[string]StackDefinition{
+	"PYTHON": {Image: "python:3.8.5-slim-buster", CacheMounts: []string{"pip", "apt"}},
+	"DOCKER": {Image: "docker:23.0.1-dind", CacheMounts: []string{"apk"},
+		PreRequisites: []string{"Dockerfile"}},
+	"INFRA:TERRAFORM":  {Image: "hashicorp/terraform", CacheMounts: []string{"apk"}},
+	"INFRA:TERRAGRUNT": {Image: "alpine/terragrunt", CacheMounts: []string{"apk"}},
+	// The AWS stack builds (E.g.: lambda functions) with language images, so it declares their
+	// caches.
+	"AWS":    {Image: "alpine:latest", CacheMounts: []string{"apk", "npm", "pip", "go-mod"}},
+	"ALPINE": {Image: "alpine:latest", CacheMounts: []string{"apk"}},
 }
 
 type DaggerContainerImage struct {
 	Image   string
 	Version string
 }
+
+// splitImageTag splits the tag of an image, if any. The registry port (E.g.:
+// 'localhost:5000/app') isn't a tag.
+func splitImageTag(image string) (string, string) {
+	if idx := strings.Index(image, "@"); idx >= 0 {
+		image = image[:idx]
+	}
+
+	lastSlash := strings.LastIndex(image, "/")
+	if idx := strings.LastIndex(image, ":"); idx > lastSlash {
+		return image[:idx], image[idx+1:]
+	}
+
+	return image, ""
+}
+
+// GetImageRef returns the image reference of the stack: 'image@digest' if it's pinned by
+// digest, 'image:version' otherwise (being 'latest' the default version). Only the repository is
+// lowercased, the tags are case-sensitive.
+func (s StackDefinition) GetImageRef() string {
+	image, tag := splitImageTag(strings.TrimSpace(s.Image))
+	image = common.NormaliseStringLower(image)
+
+	if s.Digest != "" {
+		return fmt.Sprintf("%s@%s", image, s.Digest)
+	}
+
+	if s.Version != "" {
+		tag = s.Version
+	}
+
+	if tag == "" {
+		tag = "latest"
+	}
+
+	return fmt.Sprintf("%s:%s", image, tag)
+}
+
+// Validate checks that the stack can be used to create containers.
+func (s StackDefinition) Validate() error {
+	if strings.TrimSpace(s.Image) == "" {
+		return fmt.Errorf("the stack %s has no image", s.Name)
+	}
+
+	if s.Digest != "" && !strings.HasPrefix(s.Digest, "sha256:") {
+		return fmt.Errorf("the digest '%s' of the stack %s should be in the form "+
+			"'sha256:<hex>'", s.Digest, s.Name)
+	}
+
+	if _, err := GetCacheMounts(s.CacheMounts); err != nil {
+		return fmt.Errorf("the stack %s has invalid cache mounts: %w", s.Name, err)
+	}
+
+	return nil
+}
+
+// MergeStackDefinition overrides the fields of the base stack that are set in the override. The
+// env vars are merged, the override winning.
+func MergeStackDefinition(base, override StackDefinition) StackDefinition {
+	merged := base
+
+	if override.Image != "" {
+		merged.Image = override.Image
+		// A new image doesn't inherit the pinning of the base one.
+		merged.Version, merged.Digest = "", ""
+	}
+
+	if override.Version != "" {
+		merged.Version = override.Version
+	}
+
+	if override.Digest != "" {
+		merged.Digest = override.Digest
+	}
+
+	if override.WorkDir != "" {
+		merged.WorkDir = override.WorkDir
+	}
+
+	if override.CacheMounts != nil {
+		merged.CacheMounts = override.CacheMounts
+	}
+
+	if override.PreRequisites != nil {
+		merged.PreRequisites = override.PreRequisites
+	}
+
+	if override.Commands != nil {
+		merged.Commands = override.Commands
+	}
+
+	if len(override.Env) > 0 {
+		env := map[string]string{}
+		for k, v := range base.Env {
+			env[k] = v
+		}
+
+		for k, v := range override.Env {
+			env[k] = v
+		}
+
+		merged.Env = env
+	}
+
+	return merged
+}
+
+// GetStacks returns the effective stacks: the built-in ones, overridden or extended by the ones
+// passed (E.g.: from the config file).
+func GetStacks(overrides map[string]StackDefinition) (map[string]StackDefinition, error) {
+	stacks := map[string]StackDefinition{}
+
+	for name, stack := range BuiltInStacks {
+		stack.Name = name
+		stack.Source = StackSourceBuiltIn
+		stacks[name] = stack
+	}
+
+	for name, override := range overrides {
+		name = common.NormaliseStringUpper(name)
+
+		base, isBuiltIn := stacks[name]
+		stack := MergeStackDefinition(base, override)
+		stack.Name = name
+		stack.Source = StackSourceConfig
+
+		if isBuiltIn {
+			stack.Source = StackSourceOverridden
+		}
+
+		if err := stack.Validate(); err != nil {
+			return nil, err
+		}
+
+		stacks[name] = stack
+	}
+
+	return stacks, nil
+}
+
+// GetStackNames returns the (sorted) names of the stacks.
+func GetStackNames(stacks map[string]StackDefinition) []string {
+	var names []string
+	for name := range stacks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,77 @@
+package daggerio
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStackDefinitionGetImageRef(t *testing.T) {
+	cases := []struct {
+		stack    StackDefinition
+		expected string
+	}{
+		{StackDefinition{Image: "alpine/terragrunt"}, "alpine/terragrunt:latest"},
+		{StackDefinition{Image: "python:3.8.5-slim-buster"}, "python:3.8.5-slim-buster"},
+		{StackDefinition{Image: "alpine/terragrunt", Version: "1.5.7"}, "alpine/terragrunt:1.5.7"},
+		{StackDefinition{Image: "alpine:3.17", Version: "3.18"}, "alpine:3.18"},
+		{StackDefinition{Image: "localhost:5000/app"}, "localhost:5000/app:latest"},
+		{StackDefinition{Image: "alpine:3.18", Digest: "sha256:abc"}, "alpine@sha256:abc"},
+		{StackDefinition{Image: "Acme/App:v1.0-RC1"}, "acme/app:v1.0-RC1"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.stack.GetImageRef(), c.stack.Image)
+	}
+}
+
+func TestGetStacks(t *testing.T) {
+	stacks, err := GetStacks(map[string]StackDefinition{
+		"infra:terragrunt": {Version: "1.5.7"},
+		"DOCKER":           {Env: map[string]string{"DOCKER_BUILDKIT": "1"}},
+		"node": {Image: "node:18-alpine", WorkDir: "/app", CacheMounts: []string{"npm"},
+			Commands: [][]string{{"npm", "ci"}, {"npm", "test"}}},
+	})
+	assert.NoError(t, err)
+
+	terragrunt := stacks["INFRA:TERRAGRUNT"]
+	assert.Equal(t, "alpine/terragrunt:1.5.7", terragrunt.GetImageRef())
+	assert.Equal(t, StackSourceOverridden, terragrunt.Source)
+	assert.Equal(t, []string{"apk"}, terragrunt.CacheMounts, "Unset fields should be inherited")
+
+	docker := stacks["DOCKER"]
+	assert.Equal(t, "docker:23.0.1-dind", docker.GetImageRef())
+	assert.Equal(t, []string{"Dockerfile"}, docker.PreRequisites)
+	assert.Equal(t, map[string]string{"DOCKER_BUILDKIT": "1"}, docker.Env)
+
+	node := stacks["NODE"]
+	assert.Equal(t, StackSourceConfig, node.Source)
+	assert.Equal(t, "node:18-alpine", node.GetImageRef())
+	assert.Equal(t, "/app", node.WorkDir)
+
+	assert.Equal(t, StackSourceBuiltIn, stacks["PYTHON"].Source)
+	assert.Equal(t, "hashicorp/terraform:latest", stacks["INFRA:TERRAFORM"].GetImageRef())
+	assert.Contains(t, GetStackNames(stacks), "NODE")
+}
+
+func TestGetStacksInvalid(t *testing.T) {
+	_, err := GetStacks(map[string]StackDefinition{"node": {WorkDir: "/app"}})
+	assert.Error(t, err, "A new stack needs an image")
+
+	_, err = GetStacks(map[string]StackDefinition{"alpine": {Digest: "abc"}})
+	assert.Error(t, err)
+
+	_, err = GetStacks(map[string]StackDefinition{"alpine": {CacheMounts: []string{"unknown"}}})
+	assert.Error(t, err)
+}
+
+func TestMergeStackDefinition(t *testing.T) {
+	base := StackDefinition{Image: "alpine", Version: "3.17", Env: map[string]string{"A": "1", "B": "2"}}
+
+	merged := MergeStackDefinition(base, StackDefinition{Env: map[string]string{"B": "3"}})
+	assert.Equal(t, map[string]string{"A": "1", "B": "3"}, merged.Env)
+	assert.Equal(t, map[string]string{"A": "1", "B": "2"}, base.Env, "The base shouldn't change")
+	assert.Equal(t, "alpine:3.17", merged.GetImageRef())
+
+	merged = MergeStackDefinition(base, StackDefinition{Image: "busybox"})
+	assert.Equal(t, "busybox:latest", merged.GetImageRef(), "A new image shouldn't inherit the version")
+}
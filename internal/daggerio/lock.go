@@ -0,0 +1,237 @@
+package daggerio
+
+import (
+	"context"
+	"dagger.io/dagger"
+	"encoding/json"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// LockFileName is the lock file of the stack images, in the work dir.
+	LockFileName    = "stiletto.lock"
+	lockFileVersion = 1
+)
+
+const (
+	// LockStatusPinned stacks are pinned by digest in their definition, so they aren't locked.
+	LockStatusPinned = "pinned"
+	// LockStatusLocked stacks use the digest of the lock file.
+	LockStatusLocked = "locked"
+	// LockStatusStale stacks have changed their image since they were locked.
+	LockStatusStale = "stale"
+	// LockStatusMissing stacks aren't in the lock file.
+	LockStatusMissing = "missing"
+)
+
+const (
+	LockModeWarn   = "warn"
+	LockModeStrict = "strict"
+	LockModeIgnore = "ignore"
+)
+
+var LockModes = []string{LockModeWarn, LockModeStrict, LockModeIgnore}
+
+// LockedImage is the digest an image (as referenced by the stack, E.g.: 'alpine:latest') was
+// resolved to.
+type LockedImage struct {
+	Image      string    `json:"image"`
+	Digest     string    `json:"digest"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// LockFile pins the image of each stack to a digest, so the runs don't drift when a tag moves.
+type LockFile struct {
+	Version int                    `json:"version"`
+	Stacks  map[string]LockedImage `json:"stacks"`
+}
+
+// NewLockFile returns an empty lock file.
+func NewLockFile() *LockFile {
+	return &LockFile{Version: lockFileVersion, Stacks: map[string]LockedImage{}}
+}
+
+// LoadLockFile reads the lock file. A missing file returns nil, with no error.
+func LoadLockFile(path string) (*LockFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	lock := NewLockFile()
+	if err := json.Unmarshal(content, lock); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", path, err)
+	}
+
+	if lock.Version != lockFileVersion {
+		return nil, fmt.Errorf("unsupported version %d of the lock file %s", lock.Version, path)
+	}
+
+	if lock.Stacks == nil {
+		lock.Stacks = map[string]LockedImage{}
+	}
+
+	return lock, nil
+}
+
+// Save writes the lock file.
+func (l *LockFile) Save(path string) error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// GetStatus returns the lock status of the stack. The lock of a nil lock file is missing.
+func (l *LockFile) GetStatus(stack StackDefinition) string {
+	if stack.Digest != "" {
+		return LockStatusPinned
+	}
+
+	if l == nil {
+		return LockStatusMissing
+	}
+
+	locked, ok := l.Stacks[common.NormaliseStringUpper(stack.Name)]
+	if !ok {
+		return LockStatusMissing
+	}
+
+	if locked.Image != stack.GetImageRef() {
+		return LockStatusStale
+	}
+
+	return LockStatusLocked
+}
+
+// Apply pins the stack to the digest of the lock file, if its lock is up-to-date. It returns the
+// stack, and its lock status.
+func (l *LockFile) Apply(stack StackDefinition) (StackDefinition, string) {
+	status := l.GetStatus(stack)
+	if status == LockStatusLocked {
+		stack.Digest = l.Stacks[common.NormaliseStringUpper(stack.Name)].Digest
+	}
+
+	return stack, status
+}
+
+// GetStacksToResolve returns the (sorted) stacks whose digest should be resolved. If no stack is
+// passed to update, these are the missing and stale ones; otherwise, only the ones passed.
+func (l *LockFile) GetStacksToResolve(stacks map[string]StackDefinition, update []string) ([]string,
+	error) {
+	var toResolve []string
+
+	if len(update) > 0 {
+		for _, name := range update {
+			name = common.NormaliseStringUpper(name)
+
+			stack, ok := stacks[name]
+			if !ok {
+				return nil, fmt.Errorf("the stack %s is not supported, or declared in the config file",
+					name)
+			}
+
+			if l.GetStatus(stack) == LockStatusPinned {
+				return nil, fmt.Errorf("the stack %s is pinned by digest in its definition, "+
+					"it can't be locked", name)
+			}
+
+			toResolve = append(toResolve, name)
+		}
+
+		return toResolve, nil
+	}
+
+	for _, name := range GetStackNames(stacks) {
+		status := l.GetStatus(stacks[name])
+		if status == LockStatusMissing || status == LockStatusStale {
+			toResolve = append(toResolve, name)
+		}
+	}
+
+	return toResolve, nil
+}
+
+// SelectStacks returns the stacks to lock: the named ones, or else the ones in use, which are
+// the stacks already in the lock file and the used ones (E.g.: declared in the config file).
+func (l *LockFile) SelectStacks(stacks map[string]StackDefinition, names,
+	used []string) (map[string]StackDefinition, error) {
+	selected := map[string]StackDefinition{}
+
+	if len(names) > 0 {
+		for _, name := range names {
+			name = common.NormaliseStringUpper(name)
+
+			stack, ok := stacks[name]
+			if !ok {
+				return nil, fmt.Errorf("the stack %s is not supported, or declared in the config file",
+					name)
+			}
+
+			selected[name] = stack
+		}
+
+		return selected, nil
+	}
+
+	for name := range l.Stacks {
+		if stack, ok := stacks[name]; ok {
+			selected[name] = stack
+		}
+	}
+
+	for _, name := range used {
+		name = common.NormaliseStringUpper(name)
+		if stack, ok := stacks[name]; ok {
+			selected[name] = stack
+		}
+	}
+
+	return selected, nil
+}
+
+// Prune removes the stacks that no longer exist, or are pinned by digest in their definition,
+// returning their names.
+func (l *LockFile) Prune(stacks map[string]StackDefinition) []string {
+	var pruned []string
+
+	for name := range l.Stacks {
+		if stack, ok := stacks[name]; !ok || stack.Digest != "" {
+			pruned = append(pruned, name)
+			delete(l.Stacks, name)
+		}
+	}
+
+	return pruned
+}
+
+// GetDigestFromImageRef returns the digest of a fully qualified image reference, E.g.:
+// 'docker.io/library/alpine:latest@sha256:...'.
+func GetDigestFromImageRef(ref string) (string, error) {
+	idx := strings.LastIndex(ref, "@")
+	if idx < 0 || !strings.HasPrefix(ref[idx+1:], "sha256:") {
+		return "", fmt.Errorf("the image reference %s has no digest", ref)
+	}
+
+	return ref[idx+1:], nil
+}
+
+// ResolveImageDigest asks the registry (through the dagger engine) the current digest of an image.
+func ResolveImageDigest(ctx context.Context, c *dagger.Client, image string) (string, error) {
+	ref, err := c.Container().From(image).ImageRef(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the image %s: %w", image, err)
+	}
+
+	return GetDigestFromImageRef(ref)
+}
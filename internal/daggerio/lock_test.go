@@ -0,0 +1,120 @@
+package daggerio
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestLockFileApply(t *testing.T) {
+	stacks, err := GetStacks(map[string]StackDefinition{"python": {Digest: testDigest}})
+	assert.NoError(t, err)
+
+	var missing *LockFile
+	_, status := missing.Apply(stacks["ALPINE"])
+	assert.Equal(t, LockStatusMissing, status, "A missing lock file locks nothing")
+
+	lock := NewLockFile()
+	lock.Stacks["ALPINE"] = LockedImage{Image: "alpine:latest", Digest: testDigest}
+	lock.Stacks["DOCKER"] = LockedImage{Image: "docker:20.10.0-dind", Digest: testDigest}
+
+	stack, status := lock.Apply(stacks["ALPINE"])
+	assert.Equal(t, LockStatusLocked, status)
+	assert.Equal(t, "alpine@"+testDigest, stack.GetImageRef())
+
+	stack, status = lock.Apply(stacks["DOCKER"])
+	assert.Equal(t, LockStatusStale, status)
+	assert.Equal(t, "docker:23.0.1-dind", stack.GetImageRef(), "Stale locks shouldn't be applied")
+
+	_, status = lock.Apply(stacks["AWS"])
+	assert.Equal(t, LockStatusMissing, status)
+
+	_, status = lock.Apply(stacks["PYTHON"])
+	assert.Equal(t, LockStatusPinned, status)
+}
+
+func TestLockFileGetStacksToResolve(t *testing.T) {
+	stacks, err := GetStacks(map[string]StackDefinition{"python": {Digest: testDigest}})
+	assert.NoError(t, err)
+
+	lock := NewLockFile()
+	lock.Stacks["ALPINE"] = LockedImage{Image: "alpine:latest", Digest: testDigest}
+	lock.Stacks["DOCKER"] = LockedImage{Image: "docker:20.10.0-dind", Digest: testDigest}
+	lock.Stacks["REMOVED"] = LockedImage{Image: "removed:latest", Digest: testDigest}
+
+	toResolve, err := lock.GetStacksToResolve(stacks, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AWS", "DOCKER", "INFRA:TERRAFORM", "INFRA:TERRAGRUNT"}, toResolve)
+
+	toResolve, err = lock.GetStacksToResolve(stacks, []string{"alpine"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ALPINE"}, toResolve)
+
+	_, err = lock.GetStacksToResolve(stacks, []string{"unknown"})
+	assert.Error(t, err)
+
+	_, err = lock.GetStacksToResolve(stacks, []string{"python"})
+	assert.Error(t, err, "Stacks pinned by digest can't be locked")
+
+	assert.Equal(t, []string{"REMOVED"}, lock.Prune(stacks))
+	assert.Len(t, lock.Stacks, 2)
+}
+
+func TestLockFileSelectStacks(t *testing.T) {
+	stacks, err := GetStacks(map[string]StackDefinition{"python": {Digest: testDigest}})
+	assert.NoError(t, err)
+
+	lock := NewLockFile()
+	lock.Stacks["ALPINE"] = LockedImage{Image: "alpine:latest", Digest: testDigest}
+	lock.Stacks["REMOVED"] = LockedImage{Image: "removed:latest", Digest: testDigest}
+
+	selected, err := lock.SelectStacks(stacks, nil, []string{"infra:terragrunt"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ALPINE", "INFRA:TERRAGRUNT"}, GetStackNames(selected),
+		"Only the locked and the used stacks should be selected, not all the built-in ones")
+
+	selected, err = lock.SelectStacks(stacks, []string{"docker"}, []string{"infra:terragrunt"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DOCKER"}, GetStackNames(selected))
+
+	_, err = lock.SelectStacks(stacks, []string{"unknown"}, nil)
+	assert.Error(t, err)
+
+	selected, err = NewLockFile().SelectStacks(stacks, nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, selected)
+}
+
+func TestLockFileSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+
+	lock, err := LoadLockFile(path)
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+
+	lock = NewLockFile()
+	lock.Stacks["ALPINE"] = LockedImage{Image: "alpine:latest", Digest: testDigest,
+		ResolvedAt: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)}
+	assert.NoError(t, lock.Save(path))
+
+	loaded, err := LoadLockFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, lock, loaded)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"version": 2}`), 0o644))
+	_, err = LoadLockFile(path)
+	assert.Error(t, err)
+}
+
+func TestGetDigestFromImageRef(t *testing.T) {
+	digest, err := GetDigestFromImageRef("docker.io/library/alpine:latest@" + testDigest)
+	assert.NoError(t, err)
+	assert.Equal(t, testDigest, digest)
+
+	_, err = GetDigestFromImageRef("docker.io/library/alpine:latest")
+	assert.Error(t, err)
+}
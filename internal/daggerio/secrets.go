@@ -1,6 +1,21 @@
 package daggerio
 
+import (
+	"dagger.io/dagger"
+)
+
 type DaggerSecret struct {
 	SecretId    string
 	SecretValue string
 }
+
+// SetSecretEnvVarsInContainer sets the env vars as Dagger secrets, so their values aren't
+// exposed in the container configuration, nor in the logs.
+func SetSecretEnvVarsInContainer(client *dagger.Client, c *dagger.Container,
+	secretEnvVars map[string]string) *dagger.Container {
+	for k, v := range secretEnvVars {
+		c = c.WithSecretVariable(k, client.SetSecret(k, v))
+	}
+
+	return c
+}
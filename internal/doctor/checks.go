@@ -0,0 +1,302 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names of the checks.
+const (
+	CheckConfig   = "config"
+	CheckRuntime  = "runtime"
+	CheckDagger   = "dagger"
+	CheckAWS      = "aws"
+	CheckECRLogin = "ecr-login"
+	CheckGit      = "git"
+	CheckDotEnv   = "dotenv"
+)
+
+// runtimeTimeout is the time to wait for the docker daemon.
+const runtimeTimeout = 30 * time.Second
+
+// DefaultDaggerTimeout is the time to wait for the dagger engine. The first connection
+// downloads the CLI and the engine image, so it's generous.
+const DefaultDaggerTimeout = 5 * time.Minute
+
+// Options are the settings of the runs that the checks validate.
+type Options struct {
+	WorkDir       string // Absolute.
+	TargetModule  string // The terragrunt module, relative to the work dir.
+	ECRRegistry   string
+	RunInVendor   bool
+	DotEnvFiles   []string
+	Environment   string
+	DaggerTimeout time.Duration
+}
+
+// getEngineInfo and getAWSCredentials are replaced in the tests.
+var (
+	getEngineInfo     = daggerio.GetEngineInfo
+	getAWSCredentials = awscloud.GetCredentials
+)
+
+// GetChecks returns all the checks, in the order they run.
+func GetChecks(opts Options) []Check {
+	return []Check{
+		{Name: CheckConfig, Description: "The config file can be read, and it's valid",
+			Run: func(ctx context.Context) Result { return checkConfigFile() }},
+		{Name: CheckRuntime, Description: "A container runtime to provision the dagger engine",
+			Run: checkContainerRuntime},
+		{Name: CheckDagger, Description: "The dagger engine is reachable",
+			Run: func(ctx context.Context) Result {
+				return checkDaggerEngine(ctx, opts.DaggerTimeout)
+			}},
+		{Name: CheckAWS, Description: "The AWS credentials and region are resolved",
+			Run: func(ctx context.Context) Result { return checkAWSCredentials(ctx) }},
+		{Name: CheckECRLogin, Description: "The aws and docker binaries, for the host ECR login",
+			Run: func(ctx context.Context) Result { return checkECRLoginBinaries(opts) }},
+		{Name: CheckGit, Description: "The terragrunt modules are in a git repository",
+			Run: func(ctx context.Context) Result { return checkGitRepository(opts) }},
+		{Name: CheckDotEnv, Description: "The .env files can be loaded",
+			Run: func(ctx context.Context) Result { return checkDotEnvFiles(opts) }},
+	}
+}
+
+func checkConfigFile() Result {
+	// The error was ignored when the config was loaded, so the command can report it.
+	loaded, err := config.GetLoadedConfig()
+	if err != nil {
+		return Result{Status: StatusFail, Detail: err.Error(),
+			Hint: "Fix the YAML syntax of the config files, or the --config and --profile passed"}
+	}
+
+	if len(loaded.Files) == 0 {
+		return Result{Status: StatusWarn, Detail: "No config file found, the defaults are used",
+			Hint: "Create .stiletto.yaml in the project, or ~/.stiletto.yaml, to set the " +
+				"stacks, the tasks or any flag"}
+	}
+
+	path := strings.Join(loaded.Files, ", ")
+	if loaded.Profile != "" {
+		path = fmt.Sprintf("%s (profile: %s)", path, loaded.Profile)
+	}
+
+	if err := config.ValidateConfig(); err != nil {
+		return Result{Status: StatusFail, Detail: err.Error(), Hint: "Fix the keys listed, " +
+			"see 'stiletto config schema' for the types of each one"}
+	}
+
+	return Result{Status: StatusOK, Detail: fmt.Sprintf("%s is valid", path)}
+}
+
+func checkContainerRuntime(ctx context.Context) Result {
+	if host := os.Getenv(daggerio.EngineRunnerHostEnvVar); host != "" {
+		return Result{Status: StatusOK, Detail: fmt.Sprintf("Using the dagger engine at %s (%s), "+
+			"no container runtime is needed", host, daggerio.EngineRunnerHostEnvVar)}
+	}
+
+	path, err := lookPath("docker")
+	if err != nil {
+		return Result{Status: StatusFail, Detail: "docker isn't in the PATH, the dagger engine " +
+			"can't be provisioned", Hint: fmt.Sprintf("Install docker (the dagger engine runs "+
+			"as a container), or set %s to a running engine", daggerio.EngineRunnerHostEnvVar)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runtimeTimeout)
+	defer cancel()
+
+	version, err := runCommand(ctx, path, "version", "--format", "{{.Server.Version}}")
+	if err != nil {
+		return Result{Status: StatusFail, Detail: fmt.Sprintf("%s is installed, but its daemon "+
+			"isn't reachable: %s", path, getLastLine(version, err)),
+			Hint: "Start the docker daemon (E.g.: Docker Desktop, or 'sudo systemctl start " +
+				"docker'), and check that your user can use it ('docker ps')"}
+	}
+
+	return Result{Status: StatusOK, Detail: fmt.Sprintf("docker %s (%s)", version, path)}
+}
+
+func checkDaggerEngine(ctx context.Context, timeout time.Duration) Result {
+	if timeout <= 0 {
+		timeout = DefaultDaggerTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	info, err := getEngineInfo(ctx)
+
+	versions := fmt.Sprintf("SDK %s", getValueOrUnknown(info.SDKVersion))
+	if info.CLIVersion != "" {
+		versions += fmt.Sprintf(", CLI %s", info.CLIVersion)
+	}
+
+	if err != nil {
+		hint := "Check the container runtime, and the logs of the dagger engine container " +
+			"('docker ps -a --filter name=dagger-engine')"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			hint = fmt.Sprintf("The engine didn't answer in %s. The first connection downloads "+
+				"the engine, retry with a longer --dagger-timeout", timeout)
+		}
+
+		return Result{Status: StatusFail, Detail: fmt.Sprintf("Failed to connect to the dagger "+
+			"engine (%s): %s", versions, err), Hint: hint}
+	}
+
+	return Result{Status: StatusOK, Detail: fmt.Sprintf("Connected (%s, platform %s)", versions,
+		info.Platform)}
+}
+
+func checkAWSCredentials(ctx context.Context) Result {
+	creds, err := getAWSCredentials(ctx)
+	if err != nil {
+		return Result{Status: StatusFail, Detail: fmt.Sprintf("Failed to resolve the AWS "+
+			"credentials: %s", err), Hint: "Export AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, " +
+			"set AWS_PROFILE (run 'aws sso login' for SSO profiles), or pass the --aws-creds-* " +
+			"flags"}
+	}
+
+	if creds.Region == "" {
+		return Result{Status: StatusFail, Detail: fmt.Sprintf("The credentials were resolved "+
+			"(source: %s), but not the region", creds.Source), Hint: "Export AWS_REGION, set " +
+			"the region of the AWS profile, or pass --aws-creds-region"}
+	}
+
+	detail := fmt.Sprintf("Resolved (source: %s, region: %s)", creds.Source, creds.Region)
+	if !creds.CanExpire {
+		return Result{Status: StatusOK, Detail: detail}
+	}
+
+	detail += fmt.Sprintf(", expiring at %s", creds.Expires.Format(time.RFC3339))
+	if time.Until(creds.Expires) < 5*time.Minute {
+		return Result{Status: StatusWarn, Detail: detail, Hint: "The credentials are about to " +
+			"expire, refresh them (E.g.: 'aws sso login') before a long run"}
+	}
+
+	return Result{Status: StatusOK, Detail: detail}
+}
+
+func checkECRLoginBinaries(opts Options) Result {
+	if opts.RunInVendor {
+		return Result{Status: StatusSkip, Detail: "Running in vendor automation " +
+			"(--run-in-vendor), there's no host ECR login"}
+	}
+
+	var found, missing []string
+	for _, bin := range []string{"aws", "docker"} {
+		path, err := lookPath(bin)
+		if err != nil {
+			missing = append(missing, bin)
+			continue
+		}
+
+		found = append(found, path)
+	}
+
+	if len(missing) == 0 {
+		return Result{Status: StatusOK, Detail: fmt.Sprintf("Found %s", strings.Join(found, ", "))}
+	}
+
+	// It's only an error if an ECR registry is configured, since the other jobs don't log in.
+	status := StatusWarn
+	if opts.ECRRegistry != "" {
+		status = StatusFail
+	}
+
+	return Result{Status: status, Detail: fmt.Sprintf("%s not found in the PATH, the host ECR "+
+		"login of 'stiletto aws ecr' would fail", strings.Join(missing, " and ")),
+		Hint: "Install the AWS CLI and docker, or pass --run-in-vendor if the CI vendor logs " +
+			"into ECR"}
+}
+
+func checkGitRepository(opts Options) Result {
+	dir := opts.WorkDir
+	if opts.TargetModule != "" {
+		dir = filepath.Join(opts.WorkDir, opts.TargetModule)
+	}
+
+	root, err := filesystem.IsGitRepository(dir, false, true)
+	if err == nil {
+		return Result{Status: StatusOK, Detail: fmt.Sprintf("%s is in the git repository %s", dir,
+			root)}
+	}
+
+	// It's only an error for a terragrunt module, the other jobs don't require git.
+	status := StatusWarn
+	if opts.TargetModule != "" {
+		status = StatusFail
+	}
+
+	return Result{Status: status, Detail: fmt.Sprintf("%s isn't in a git repository, terragrunt "+
+		"would fail to resolve its paths", dir), Hint: "Run 'git init' (or clone the " +
+		"repository), or pass the right --work-dir and --target-module"}
+}
+
+func checkDotEnvFiles(opts Options) Result {
+	files := filesystem.ResolveDotEnvFiles(opts.WorkDir, opts.Environment, opts.DotEnvFiles)
+
+	if len(files) == 0 {
+		path := filepath.Join(opts.WorkDir, ".env")
+		if filesystem.FileExist(path) == nil {
+			return Result{Status: StatusSkip, Detail: fmt.Sprintf("No .env files passed, %s "+
+				"exists but isn't loaded", path), Hint: "Pass --environment=<name> or " +
+				"--dot-env-file=.env to load it"}
+		}
+
+		return Result{Status: StatusSkip, Detail: "No .env files passed (--dot-env-file, " +
+			"--environment)"}
+	}
+
+	envVars, sources, err := filesystem.LoadDotEnvFiles(files)
+	if err != nil {
+		return Result{Status: StatusFail, Detail: err.Error(), Hint: "Fix the syntax of the " +
+			"file ('KEY=value' lines), or the path passed to --dot-env-file"}
+	}
+
+	if len(envVars) == 0 {
+		return Result{Status: StatusFail, Detail: "The .env files have no env vars, the runs " +
+			"would fail", Hint: "Add 'KEY=value' lines, or don't pass --dot-env-file and " +
+			"--environment"}
+	}
+
+	loaded := map[string]bool{}
+	var paths []string
+	for _, path := range sources {
+		if !loaded[path] {
+			loaded[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+
+	return Result{Status: StatusOK, Detail: fmt.Sprintf("%d env vars loaded from %s",
+		len(envVars), strings.Join(paths, ", "))}
+}
+
+func getLastLine(out string, err error) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+
+	return err.Error()
+}
+
+func getValueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+
+	return value
+}
@@ -0,0 +1,108 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"os/exec"
+	"strings"
+)
+
+// Status of a check.
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Result is the outcome of a check, with a hint to fix it if it isn't ok.
+type Result struct {
+	Check  string
+	Status string
+	Detail string
+	Hint   string
+}
+
+// Check is a diagnostic of the environment that the pipelines run on.
+type Check struct {
+	Name        string
+	Description string
+	Run         func(ctx context.Context) Result
+}
+
+// lookPath and runCommand are replaced in the tests.
+var (
+	lookPath   = exec.LookPath
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+		return strings.TrimSpace(string(out)), err
+	}
+)
+
+// GetCheckNames returns the names of the checks, in the order they run.
+func GetCheckNames(checks []Check) []string {
+	var names []string
+	for _, c := range checks {
+		names = append(names, c.Name)
+	}
+
+	return names
+}
+
+// FilterChecks returns the checks whose names are passed, or all of them if none is.
+func FilterChecks(checks []Check, names []string) ([]Check, error) {
+	if len(names) == 0 {
+		return checks, nil
+	}
+
+	var filtered []Check
+	for _, name := range names {
+		name = common.NormaliseStringLower(name)
+
+		found := false
+		for _, c := range checks {
+			if c.Name == name {
+				filtered = append(filtered, c)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown check '%s', it should be one of: %s", name,
+				strings.Join(GetCheckNames(checks), ", "))
+		}
+	}
+
+	return filtered, nil
+}
+
+// Run runs the checks in order. A check stops if the context is cancelled.
+func Run(ctx context.Context, checks []Check) []Result {
+	var results []Result
+	for _, c := range checks {
+		if ctx.Err() != nil {
+			results = append(results, Result{Check: c.Name, Status: StatusSkip,
+				Detail: "Cancelled"})
+			continue
+		}
+
+		r := c.Run(ctx)
+		r.Check = c.Name
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// HasFailures returns true if any check failed.
+func HasFailures(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,231 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeLookPath finds only the binaries passed, in /usr/bin.
+func fakeLookPath(t *testing.T, found ...string) {
+	original := lookPath
+	t.Cleanup(func() { lookPath = original })
+
+	lookPath = func(file string) (string, error) {
+		for _, f := range found {
+			if f == file {
+				return filepath.Join("/usr/bin", file), nil
+			}
+		}
+
+		return "", errors.New("executable file not found in $PATH")
+	}
+}
+
+func TestFilterChecks(t *testing.T) {
+	checks := GetChecks(Options{})
+
+	all, err := FilterChecks(checks, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{CheckConfig, CheckRuntime, CheckDagger, CheckAWS, CheckECRLogin,
+		CheckGit, CheckDotEnv}, GetCheckNames(all))
+
+	filtered, err := FilterChecks(checks, []string{"GIT", "aws"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{CheckGit, CheckAWS}, GetCheckNames(filtered))
+
+	_, err = FilterChecks(checks, []string{"kubernetes"})
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{Name: "ok", Run: func(ctx context.Context) Result { return Result{Status: StatusOK} }},
+		{Name: "warn", Run: func(ctx context.Context) Result { return Result{Status: StatusWarn} }},
+	}
+
+	results := Run(context.Background(), checks)
+	assert.Equal(t, "warn", results[1].Check)
+	assert.False(t, HasFailures(results), "Warnings aren't failures")
+
+	checks = append(checks, Check{Name: "fail", Run: func(ctx context.Context) Result {
+		return Result{Status: StatusFail}
+	}})
+	assert.True(t, HasFailures(Run(context.Background(), checks)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, r := range Run(ctx, checks) {
+		assert.Equal(t, StatusSkip, r.Status, "The checks shouldn't run once cancelled")
+	}
+}
+
+func TestCheckContainerRuntime(t *testing.T) {
+	t.Setenv(daggerio.EngineRunnerHostEnvVar, "")
+
+	fakeLookPath(t)
+	assert.Equal(t, StatusFail, checkContainerRuntime(context.Background()).Status)
+
+	fakeLookPath(t, "docker")
+
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		return "24.0.2", nil
+	}
+
+	r := checkContainerRuntime(context.Background())
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Contains(t, r.Detail, "24.0.2")
+
+	runCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		return "Client: 24.0.2\nCannot connect to the Docker daemon", errors.New("exit status 1")
+	}
+
+	r = checkContainerRuntime(context.Background())
+	assert.Equal(t, StatusFail, r.Status)
+	assert.Contains(t, r.Detail, "Cannot connect to the Docker daemon")
+	assert.NotEmpty(t, r.Hint)
+
+	t.Setenv(daggerio.EngineRunnerHostEnvVar, "tcp://dagger:1234")
+	assert.Equal(t, StatusOK, checkContainerRuntime(context.Background()).Status,
+		"No runtime is needed with a running engine")
+}
+
+func TestCheckDaggerEngine(t *testing.T) {
+	original := getEngineInfo
+	t.Cleanup(func() { getEngineInfo = original })
+
+	getEngineInfo = func(ctx context.Context) (daggerio.EngineInfo, error) {
+		return daggerio.EngineInfo{SDKVersion: "v0.5.2", Platform: "linux/amd64"}, nil
+	}
+
+	r := checkDaggerEngine(context.Background(), time.Second)
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Equal(t, "Connected (SDK v0.5.2, platform linux/amd64)", r.Detail)
+
+	getEngineInfo = func(ctx context.Context) (daggerio.EngineInfo, error) {
+		<-ctx.Done()
+		return daggerio.EngineInfo{SDKVersion: "v0.5.2"}, ctx.Err()
+	}
+
+	r = checkDaggerEngine(context.Background(), 10*time.Millisecond)
+	assert.Equal(t, StatusFail, r.Status)
+	assert.Contains(t, r.Hint, "--dagger-timeout")
+}
+
+func TestCheckAWSCredentials(t *testing.T) {
+	original := getAWSCredentials
+	t.Cleanup(func() { getAWSCredentials = original })
+
+	cases := []struct {
+		creds    awscloud.AWSCredentials
+		err      error
+		expected string
+	}{
+		{err: errors.New("no credentials"), expected: StatusFail},
+		{creds: awscloud.AWSCredentials{Source: "EnvConfigCredentials"}, expected: StatusFail},
+		{creds: awscloud.AWSCredentials{Source: "EnvConfigCredentials", Region: "us-east-1"},
+			expected: StatusOK},
+		{creds: awscloud.AWSCredentials{Source: "SSOProvider", Region: "us-east-1",
+			CanExpire: true, Expires: time.Now().Add(time.Minute)}, expected: StatusWarn},
+	}
+
+	for _, c := range cases {
+		getAWSCredentials = func(context.Context) (awscloud.AWSCredentials, error) {
+			return c.creds, c.err
+		}
+
+		assert.Equal(t, c.expected, checkAWSCredentials(context.Background()).Status, c.creds.Source)
+	}
+}
+
+func TestCheckECRLoginBinaries(t *testing.T) {
+	fakeLookPath(t, "docker")
+
+	assert.Equal(t, StatusWarn, checkECRLoginBinaries(Options{}).Status)
+	assert.Equal(t, StatusFail, checkECRLoginBinaries(Options{ECRRegistry: "123.dkr.ecr"}).Status,
+		"It's an error if a registry is configured")
+	assert.Equal(t, StatusSkip, checkECRLoginBinaries(Options{ECRRegistry: "123.dkr.ecr",
+		RunInVendor: true}).Status)
+
+	fakeLookPath(t, "aws", "docker")
+	assert.Equal(t, StatusOK, checkECRLoginBinaries(Options{ECRRegistry: "123.dkr.ecr"}).Status)
+}
+
+func TestCheckGitRepository(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "infra", "vpc"), 0o755))
+
+	assert.Equal(t, StatusWarn, checkGitRepository(Options{WorkDir: dir}).Status)
+	assert.Equal(t, StatusFail, checkGitRepository(Options{WorkDir: dir,
+		TargetModule: "infra/vpc"}).Status)
+
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o755))
+	assert.Equal(t, StatusOK, checkGitRepository(Options{WorkDir: dir,
+		TargetModule: "infra/vpc"}).Status)
+}
+
+func TestCheckDotEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.Equal(t, StatusSkip, checkDotEnvFiles(Options{WorkDir: dir}).Status)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1\nB=2\n"), 0o644))
+	r := checkDotEnvFiles(Options{WorkDir: dir})
+	assert.Equal(t, StatusSkip, r.Status)
+	assert.Contains(t, r.Hint, "--environment")
+
+	r = checkDotEnvFiles(Options{WorkDir: dir, Environment: "dev"})
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Contains(t, r.Detail, "2 env vars")
+
+	assert.Equal(t, StatusFail, checkDotEnvFiles(Options{WorkDir: dir,
+		DotEnvFiles: []string{filepath.Join(dir, "missing.env")}}).Status)
+
+	invalid := filepath.Join(dir, "invalid.env")
+	assert.NoError(t, os.WriteFile(invalid, []byte("A='unterminated\n"), 0o644))
+	assert.Equal(t, StatusFail, checkDotEnvFiles(Options{WorkDir: dir,
+		DotEnvFiles: []string{invalid}}).Status)
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".stiletto.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("profiles:\n  dev:\n    ecs-cluster: dev\n"),
+		0o644))
+
+	assert.NoError(t, config.LoadConfigFiles([]string{path}, "dev"))
+	r := checkConfigFile()
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Contains(t, r.Detail, "(profile: dev)")
+
+	assert.Error(t, config.LoadConfigFiles([]string{path}, "prod"))
+	r = checkConfigFile()
+	assert.Equal(t, StatusFail, r.Status)
+	assert.Contains(t, r.Detail, "dev", "The available profiles should be listed")
+}
+
+func TestCheckConfigFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".stiletto.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("ecs-cluster: [a, b]\nlock-mode: sometimes\n"+
+		"profiles:\n  dev:\n    retries: many\ntasks:\n  build:\n    timeout: soon\n"), 0o644))
+
+	assert.NoError(t, config.LoadConfigFiles([]string{path}, ""))
+	r := checkConfigFile()
+	assert.Equal(t, StatusFail, r.Status)
+
+	for _, problem := range []string{"ecs-cluster: expected a string", "lock-mode: 'sometimes'",
+		"profiles.dev.retries: expected an integer", "tasks.build"} {
+		assert.Contains(t, r.Detail, problem, "All the problems should be reported")
+	}
+}
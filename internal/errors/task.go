@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 const taskConfigErrorPrefix = "Task configuration error: "
 const taskExecutionErrorPrefix = "Task execution error: "
@@ -42,3 +45,25 @@ func NewTaskExecutionError(details string, err error) *TaskExecutionError {
 		Err:     err,
 	}
 }
+
+// TaskSkippedError is returned when a task doesn't have to run, E.g.: because nothing changed
+// under its directories. It's not a failure.
+type TaskSkippedError struct {
+	Details string
+}
+
+func (e *TaskSkippedError) Error() string {
+	return e.Details
+}
+
+func NewTaskSkippedError(details string) *TaskSkippedError {
+	return &TaskSkippedError{
+		Details: details,
+	}
+}
+
+// IsTaskSkippedError returns true if the error (or any error it wraps) is a TaskSkippedError.
+func IsTaskSkippedError(err error) bool {
+	var skipped *TaskSkippedError
+	return errors.As(err, &skipped)
+}
@@ -1,51 +1,359 @@
 package filesystem
 
 import (
-	"bufio"
 	"fmt"
-	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/errors"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 )
 
-func GetEnvVarsFromDotFile(filepath string) (map[string]string, error) {
-	file, err := os.Open(filepath)
+var dotEnvKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// DotEnvSyntaxError is returned when a .env file can't be parsed, pointing to the offending line.
+type DotEnvSyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *DotEnvSyntaxError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// DotEnvLookup resolves the variables referenced in a .env file, that aren't defined (earlier)
+// in the file itself.
+type DotEnvLookup func(key string) (string, bool)
+
+type dotEnvParser struct {
+	file   string
+	lines  []string
+	idx    int
+	env    map[string]string
+	lookup DotEnvLookup
+}
+
+// ParseDotEnv parses a .env file content. It supports:
+//   - Comments (full line, or after an unquoted value preceded by a whitespace).
+//   - The 'export' prefix.
+//   - Single quoted and backtick quoted values, taken literally.
+//   - Double quoted values, with the escapes \n, \r, \t, \", \\ and \$.
+//   - Multiline quoted values.
+//   - ${VAR}, ${VAR:-default} and ${VAR-default} interpolation, in unquoted and double
+//     quoted values, from earlier keys in the file or (if not found) from the lookup.
+func ParseDotEnv(r io.Reader, file string, lookup DotEnvLookup) (map[string]string, error) {
+	content, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	env := make(map[string]string)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
+	if lookup == nil {
+		lookup = func(string) (string, bool) { return "", false }
+	}
+
+	normalised := strings.ReplaceAll(string(content), "\r\n", "\n")
+
+	p := &dotEnvParser{
+		file:   file,
+		lines:  strings.Split(normalised, "\n"),
+		env:    map[string]string{},
+		lookup: lookup,
+	}
+
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+
+	return p.env, nil
+}
+
+func (p *dotEnvParser) syntaxError(line int, format string, args ...interface{}) error {
+	return &DotEnvSyntaxError{File: p.file, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *dotEnvParser) parse() error {
+	for p.idx < len(p.lines) {
+		lineNo := p.idx + 1
+		// Only the leading spaces are trimmed, the trailing ones can be part of a quoted value.
+		line := strings.TrimLeft(p.lines[p.idx], " \t")
+		p.idx++
 
-		if line == "" {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			return nil, errors.NewInternalPipelineError(fmt.Sprintf("Invalid line in ."+
-				"env file: %s", line))
+		if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+			line = strings.TrimLeft(line[len("export"):], " \t")
+		}
+
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return p.syntaxError(lineNo, "invalid line, expected 'KEY=VALUE'")
+		}
+
+		key := strings.TrimSpace(line[:eq])
+		if !dotEnvKeyRegex.MatchString(key) {
+			return p.syntaxError(lineNo, "invalid key '%s'", key)
+		}
+
+		value, err := p.parseValue(strings.TrimLeft(line[eq+1:], " \t"), lineNo)
+		if err != nil {
+			return err
+		}
+
+		p.env[key] = value
+	}
+
+	return nil
+}
+
+func (p *dotEnvParser) parseValue(raw string, lineNo int) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	quote := raw[0]
+	if quote != '\'' && quote != '"' && quote != '`' {
+		// Unquoted values end where an inline comment starts.
+		for i := 1; i < len(raw); i++ {
+			if raw[i] == '#' && (raw[i-1] == ' ' || raw[i-1] == '\t') {
+				raw = raw[:i]
+				break
+			}
 		}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		value = common.RemoveDoubleQuotes(value)
+		return p.expand(strings.TrimSpace(raw), false, lineNo)
+	}
+
+	// Quoted values can span multiple lines, until the closing quote is found.
+	body := raw[1:]
+	for {
+		end := findClosingQuote(body, quote)
+		if end >= 0 {
+			trailing := strings.TrimSpace(body[end+1:])
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return "", p.syntaxError(lineNo, "unexpected characters after the quoted value")
+			}
+
+			body = body[:end]
+			break
+		}
+
+		if p.idx >= len(p.lines) {
+			return "", p.syntaxError(lineNo, "unterminated quoted value, missing closing %c",
+				quote)
+		}
+
+		body += "\n" + p.lines[p.idx]
+		p.idx++
+	}
+
+	if quote == '"' {
+		return p.expand(body, true, lineNo)
+	}
+
+	return body, nil
+}
+
+// findClosingQuote returns the index of the closing quote, skipping the escaped ones in double
+// quoted values. It returns -1 if it isn't found.
+func findClosingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
 
-		env[key] = value
+		if s[i] == quote {
+			return i
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
+	return -1
+}
+
+func (p *dotEnvParser) expand(s string, escapes bool, lineNo int) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if escapes && c == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+
+			continue
+		}
+
+		if c == '$' && i+1 < len(s) && s[i+1] == '{' {
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", p.syntaxError(lineNo, "unterminated variable reference, missing '}'")
+			}
+
+			value, err := p.resolve(s[i+2:i+2+end], lineNo)
+			if err != nil {
+				return "", err
+			}
+
+			b.WriteString(value)
+			i += end + 2
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String(), nil
+}
+
+func (p *dotEnvParser) resolve(expr string, lineNo int) (string, error) {
+	name, defaultValue := expr, ""
+	var hasDefault, defaultIfEmpty bool
+
+	if idx := strings.Index(expr, ":-"); idx >= 0 {
+		name, defaultValue = expr[:idx], expr[idx+2:]
+		hasDefault, defaultIfEmpty = true, true
+	} else if idx := strings.IndexByte(expr, '-'); idx >= 0 {
+		name, defaultValue = expr[:idx], expr[idx+1:]
+		hasDefault = true
+	}
+
+	if !dotEnvKeyRegex.MatchString(name) {
+		return "", p.syntaxError(lineNo, "invalid variable reference '${%s}'", expr)
+	}
+
+	value, found := p.env[name]
+	if !found {
+		value, found = p.lookup(name)
+	}
+
+	if hasDefault && (!found || (defaultIfEmpty && value == "")) {
+		return defaultValue, nil
+	}
+
+	return value, nil
+}
+
+// GetEnvVarsFromDotFile parses the .env file passed, interpolating the variables that aren't
+// defined in the file from the host environment.
+func GetEnvVarsFromDotFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+
+	env, err := ParseDotEnv(file, path, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid .env file: %w", err)
+	}
 
 	if len(env) == 0 {
-		return nil, errors.NewInternalPipelineError(fmt.Sprintf(" .env file %s is empty"))
+		return nil, errors.NewInternalPipelineError(fmt.Sprintf(".env file %s is empty", path))
 	}
 
 	return env, nil
 }
+
+// DotEnvFileRef is a .env file to load. Optional files are skipped if they don't exist.
+type DotEnvFileRef struct {
+	Path     string
+	Optional bool
+}
+
+// GetDotEnvFilesForEnvironment returns the .env files of an environment, in the order they're
+// loaded (later files win): .env, .env.<name>, .env.local and .env.<name>.local. All of them
+// are optional, and relative to the work dir.
+func GetDotEnvFilesForEnvironment(workDir, environment string) []DotEnvFileRef {
+	names := []string{".env", ".env." + environment, ".env.local", ".env." + environment + ".local"}
+
+	var files []DotEnvFileRef
+	for _, name := range names {
+		files = append(files, DotEnvFileRef{Path: filepath.Join(workDir, name), Optional: true})
+	}
+
+	return files
+}
+
+// ResolveDotEnvFiles returns the .env files to load: the environment ones (if an environment is
+// passed) first, and then the ones passed explicitly, that take precedence and are required.
+func ResolveDotEnvFiles(workDir, environment string, files []string) []DotEnvFileRef {
+	var refs []DotEnvFileRef
+
+	if environment != "" {
+		refs = append(refs, GetDotEnvFilesForEnvironment(workDir, environment)...)
+	}
+
+	for _, f := range files {
+		if f != "" {
+			refs = append(refs, DotEnvFileRef{Path: f})
+		}
+	}
+
+	return refs
+}
+
+// LoadDotEnvFiles loads the .env files in order, being the later ones the winners. Each file can
+// interpolate the keys of the previous ones. It returns the env vars, along with the file that
+// supplied each key. The optional files can be missing (or empty), the required ones can't.
+func LoadDotEnvFiles(files []DotEnvFileRef) (EnvVars, map[string]string, error) {
+	env := EnvVars{}
+	sources := map[string]string{}
+
+	lookup := func(key string) (string, bool) {
+		if value, ok := env[key]; ok {
+			return value, true
+		}
+
+		return os.LookupEnv(key)
+	}
+
+	for _, ref := range files {
+		file, err := os.Open(ref.Path)
+		if err != nil {
+			if ref.Optional && os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, nil, fmt.Errorf("failed to open .env file %s: %w", ref.Path, err)
+		}
+
+		parsed, err := ParseDotEnv(file, ref.Path, lookup)
+		_ = file.Close()
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid .env file: %w", err)
+		}
+
+		// The files passed explicitly are required, so they should set something.
+		if !ref.Optional && len(parsed) == 0 {
+			return nil, nil, fmt.Errorf("the .env file %s is empty", ref.Path)
+		}
+
+		for key, value := range parsed {
+			env[key] = value
+			sources[key] = ref.Path
+		}
+	}
+
+	return env, sources, nil
+}
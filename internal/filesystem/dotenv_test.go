@@ -0,0 +1,176 @@
+package filesystem
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func noHostVars(string) (string, bool) {
+	return "", false
+}
+
+func TestParseDotEnvConformance(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string]string
+	}{
+		{"Simple", "KEY=value", map[string]string{"KEY": "value"}},
+		{"Spaces around key and value", "  KEY =  value  ", map[string]string{"KEY": "value"}},
+		{"Empty value", "KEY=", map[string]string{"KEY": ""}},
+		{"Empty double quoted value", `KEY=""`, map[string]string{"KEY": ""}},
+		{"Value with equals", "URL=postgres://u:p@h/db?ssl=true", map[string]string{"URL": "postgres://u:p@h/db?ssl=true"}},
+		{"Comments and blank lines", "# comment\n\n  # indented comment\nKEY=value\n", map[string]string{"KEY": "value"}},
+		{"Inline comment", "KEY=value # comment", map[string]string{"KEY": "value"}},
+		{"Hash without space is part of the value", "KEY=va#lue", map[string]string{"KEY": "va#lue"}},
+		{"Export prefix", "export KEY=value", map[string]string{"KEY": "value"}},
+		{"Export as key", "export=value", map[string]string{"export": "value"}},
+		{"Single quotes are literal", `KEY='a\nb ${X}'`, map[string]string{"KEY": `a\nb ${X}`}},
+		{"Single quotes keep spaces", "KEY='  a  '", map[string]string{"KEY": "  a  "}},
+		{"Double quote escapes", `KEY="a\nb\tc\\d\"e\$f"`, map[string]string{"KEY": "a\nb\tc\\d\"e$f"}},
+		{"Unknown escapes are kept", `KEY="a\qb"`, map[string]string{"KEY": `a\qb`}},
+		{"Backticks are literal", "KEY=`a 'b' \"c\"`", map[string]string{"KEY": `a 'b' "c"`}},
+		{"Quoted value with inline comment", `KEY="a # b" # comment`, map[string]string{"KEY": "a # b"}},
+		{"Multiline double quoted", "KEY=\"a\nb\"\nNEXT=1", map[string]string{"KEY": "a\nb", "NEXT": "1"}},
+		{"Multiline single quoted", "KEY='a\n  b'", map[string]string{"KEY": "a\n  b"}},
+		{"Windows line endings", "A=1\r\nB=2\r\n", map[string]string{"A": "1", "B": "2"}},
+		{"Interpolation from earlier keys", "A=x\nB=${A}-y", map[string]string{"A": "x", "B": "x-y"}},
+		{"Interpolation in double quotes", "A=x\nB=\"${A} y\"", map[string]string{"A": "x", "B": "x y"}},
+		{"Undefined variable is empty", "B=${UNDEFINED}", map[string]string{"B": ""}},
+		{"Default when unset", "B=${UNDEFINED:-def}", map[string]string{"B": "def"}},
+		{"Default when empty", "A=\nB=${A:-def}", map[string]string{"A": "", "B": "def"}},
+		{"Unset-only default keeps empty", "A=\nB=${A-def}", map[string]string{"A": "", "B": ""}},
+		{"Escaped interpolation", `B="\${A}"`, map[string]string{"B": "${A}"}},
+		{"Lone dollar is literal", "B=pa$$word", map[string]string{"B": "pa$$word"}},
+		{"Later keys win", "A=1\nA=2", map[string]string{"A": "2"}},
+		{"Dotted keys", "spring.profile=dev", map[string]string{"spring.profile": "dev"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := ParseDotEnv(strings.NewReader(tt.content), "", noHostVars)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, env)
+		})
+	}
+}
+
+func TestParseDotEnvSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+	}{
+		{"Missing equals", "A=1\n# comment\nINVALID", 3},
+		{"Invalid key", "A=1\n1KEY=value", 2},
+		{"Key with spaces", "MY KEY=value", 1},
+		{"Unterminated double quote", "A=1\nB=\"abc\nC=2", 2},
+		{"Unterminated single quote", "A='abc", 1},
+		{"Characters after the closing quote", `A="abc"def`, 1},
+		{"Unterminated variable reference", "A=${B", 1},
+		{"Invalid variable reference", "A=${1B}", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDotEnv(strings.NewReader(tt.content), ".env", noHostVars)
+
+			var syntaxErr *DotEnvSyntaxError
+			assert.True(t, errors.As(err, &syntaxErr), "A syntax error should be returned")
+			assert.Equal(t, tt.line, syntaxErr.Line)
+			assert.Equal(t, ".env", syntaxErr.File)
+		})
+	}
+}
+
+func TestGetEnvVarsFromDotFile(t *testing.T) {
+	t.Setenv("STILETTO_TEST_HOST_VAR", "from-host")
+
+	env, err := GetEnvVarsFromDotFile(filepath.Join("testdata", "conformance.env"))
+
+	assert.NoError(t, err, "The conformance .env file should be parsed")
+	assert.Equal(t, map[string]string{
+		"APP_NAME":                 "stiletto",
+		"APP_ENV":                  "staging",
+		"SINGLE":                   `literal ${APP_NAME} \n`,
+		"DOUBLE":                   "hello\tworld \"quoted\" $HOME",
+		"BACKTICK":                 `it's "mixed"`,
+		"HASH_IN_QUOTES":           "value # not a comment",
+		"HASH_IN_VALUE":            "abc#def",
+		"PRIVATE_KEY":              "-----BEGIN KEY-----\nline 1\n-----END KEY-----",
+		"URL":                      "https://stiletto.staging.example.com",
+		"FROM_HOST":                "from-host",
+		"WITH_DEFAULT":             "fallback",
+		"EMPTY":                    "",
+		"EMPTY_WITH_DEFAULT":       "used",
+		"EMPTY_WITH_UNSET_DEFAULT": "",
+	}, env)
+
+	_, err = GetEnvVarsFromDotFile(filepath.Join(t.TempDir(), ".env"))
+	assert.Error(t, err, "A missing .env file should fail")
+}
+
+func TestLoadDotEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o600))
+		return p
+	}
+
+	write(".env", "STAGE=dev\nREGION=eu-west-1\nNAME=app\n")
+	write(".env.staging", "STAGE=staging\nURL=https://${STAGE}.${NAME}.example.com\n")
+	write(".env.staging.local", "REGION=us-east-1\n")
+	explicit := write("override.env", "NAME=app-override\n")
+
+	files := ResolveDotEnvFiles(dir, "staging", []string{explicit})
+	assert.Len(t, files, 5, "The 4 environment files, plus the explicit one")
+	assert.True(t, files[2].Optional, ".env.local is optional")
+	assert.False(t, files[4].Optional, "Explicit files are required")
+
+	env, sources, err := LoadDotEnvFiles(files)
+	assert.NoError(t, err, "Missing optional files should be tolerated")
+
+	assert.Equal(t, EnvVars{
+		"STAGE":  "staging",
+		"REGION": "us-east-1",
+		"NAME":   "app-override",
+		"URL":    "https://staging.app.example.com",
+	}, env, "Later files should win, and interpolate the previous ones")
+
+	assert.Equal(t, map[string]string{
+		"STAGE":  filepath.Join(dir, ".env.staging"),
+		"REGION": filepath.Join(dir, ".env.staging.local"),
+		"NAME":   explicit,
+		"URL":    filepath.Join(dir, ".env.staging"),
+	}, sources)
+
+	_, _, err = LoadDotEnvFiles(ResolveDotEnvFiles(dir, "", []string{filepath.Join(dir, "missing.env")}))
+	assert.Error(t, err, "Missing explicit files should fail")
+
+	env, _, err = LoadDotEnvFiles(ResolveDotEnvFiles(t.TempDir(), "dev", nil))
+	assert.NoError(t, err, "The environment files are optional, even if none of them exists")
+	assert.Empty(t, env)
+
+	_, _, err = LoadDotEnvFiles(ResolveDotEnvFiles(dir, "", []string{write("empty.env", "# No vars\n")}))
+	assert.Error(t, err, "Empty explicit files should fail")
+}
+
+func TestDotEnvSyntaxErrorIsWrapped(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid.env")
+	assert.NoError(t, os.WriteFile(invalid, []byte("A=1\nB='abc\n"), 0o600))
+
+	var syntaxErr *DotEnvSyntaxError
+
+	_, err := GetEnvVarsFromDotFile(invalid)
+	assert.True(t, errors.As(err, &syntaxErr), "The syntax error should be wrapped")
+
+	_, _, err = LoadDotEnvFiles([]DotEnvFileRef{{Path: invalid}})
+	assert.True(t, errors.As(err, &syntaxErr), "The syntax error should be wrapped")
+	assert.Equal(t, 2, syntaxErr.Line)
+}
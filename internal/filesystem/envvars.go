@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/Excoriate/stiletto/internal/common"
 	"os"
+	"path"
+	"sort"
 	"strings"
 )
 
@@ -62,45 +64,128 @@ func FetchEnvVarsAsMap(keys []string, optionalKeys []string) (EnvVars, error) {
 	return result, nil
 }
 
+// FetchAllEnvVarsFromHost returns the whole host environment. Values containing '=' are kept
+// as they are.
 func FetchAllEnvVarsFromHost() (EnvVars, error) {
 	result := make(EnvVars)
 
 	for _, env := range os.Environ() {
-		keyValue := strings.Split(env, "=")
-		result[keyValue[0]] = common.RemoveDoubleQuotes(keyValue[1])
+		key, value, found := strings.Cut(env, "=")
+		if !found || key == "" {
+			continue
+		}
+
+		result[key] = common.RemoveDoubleQuotes(value)
 	}
 
 	return result, nil
 }
 
-// ScanAWSCredentialsEnvVars scans the environment variables for AWS credentials.
-func ScanAWSCredentialsEnvVars() (EnvVars, error) {
-	//keys := []string{
-	//	"AWS_ACCESS_KEY_ID",
-	//	"AWS_SECRET_ACCESS_KEY",
-	//	"AWS_SESSION_TOKEN",
-	//	"AWS_SECURITY_TOKEN",
-	//	"AWS_DEFAULT_REGION",
-	//	"AWS_REGION",
-	//	"AWS_PROFILE",
-	//}
+// DefaultHostEnvDenyList are the host specific, and CI internal variables that are never passed
+// into the containers when the whole host environment is scanned, unless they're explicitly
+// allowed.
+var DefaultHostEnvDenyList = []string{
+	// Host specific.
+	"_", "PATH", "HOME", "USER", "LOGNAME", "SHELL", "PWD", "OLDPWD", "SHLVL", "HOSTNAME",
+	"HOSTTYPE", "TERM", "TERM_*", "COLORTERM", "TMPDIR", "TMP", "TEMP", "LANG", "LANGUAGE", "LC_*",
+	"DISPLAY", "MAIL", "XDG_*", "DBUS_*", "SSH_*", "GPG_*", "GNUPGHOME", "DOCKER_*",
+	"_EXPERIMENTAL_DAGGER_*", "DAGGER_*",
+	// CI internal.
+	"GITHUB_TOKEN", "ACTIONS_*", "RUNNER_*", "CI_JOB_TOKEN", "CI_JOB_JWT*", "CI_REGISTRY_PASSWORD",
+	"CI_DEPLOY_PASSWORD", "CI_BUILD_TOKEN", "BUILDKITE_AGENT_*", "CIRCLE_OIDC_TOKEN*",
+	"SYSTEM_ACCESSTOKEN", "JENKINS_*", "HUDSON_*",
+}
+
+// HostEnvFilter selects the host variables to pass into the containers. A variable is passed if
+// it matches the Allow globs (or none is set), and it doesn't match the Deny globs. The
+// DefaultHostEnvDenyList is also applied, except for the variables explicitly allowed.
+type HostEnvFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// Validate checks that the globs are well-formed.
+func (f HostEnvFilter) Validate() error {
+	for _, glob := range append(append([]string{}, f.Allow...), f.Deny...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid env var glob '%s': %w", glob, err)
+		}
+	}
+
+	return nil
+}
+
+func matchesAnyEnvGlob(key string, globs []string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAllowed returns true if the variable can be passed into the containers.
+func (f HostEnvFilter) IsAllowed(key string) bool {
+	if matchesAnyEnvGlob(key, f.Deny) {
+		return false
+	}
+
+	explicitlyAllowed := matchesAnyEnvGlob(key, f.Allow)
+	if len(f.Allow) > 0 && !explicitlyAllowed {
+		return false
+	}
+
+	return explicitlyAllowed || !matchesAnyEnvGlob(key, DefaultHostEnvDenyList)
+}
+
+// FilterEnvVars applies the filter, returning the variables allowed and the (sorted) keys that
+// were excluded.
+func (f HostEnvFilter) FilterEnvVars(envVars EnvVars) (EnvVars, []string) {
+	allowed := make(EnvVars)
+	var excluded []string
+
+	for key, value := range envVars {
+		if f.IsAllowed(key) {
+			allowed[key] = value
+			continue
+		}
 
-	mandatoryKeys := []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+		excluded = append(excluded, key)
+	}
+
+	sort.Strings(excluded)
 
-	// Here' it's scanned the required environment variables
-	envs, err := FetchEnvVarsAsMap(mandatoryKeys, []string{
-		"AWS_REGION", "AWS_DEFAULT_REGION"})
+	return allowed, excluded
+}
+
+// FetchFilteredEnvVarsFromHost returns the host environment that passes the filter, along with
+// the keys that were excluded.
+func FetchFilteredEnvVarsFromHost(filter HostEnvFilter) (EnvVars, []string, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, nil, err
+	}
 
+	envVars, err := FetchAllEnvVarsFromHost()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Precedence rules
-	if defaultRegion, ok := envs["AWS_DEFAULT_REGION"]; ok {
-		envs["AWS_REGION"] = defaultRegion
+	allowed, excluded := filter.FilterEnvVars(envVars)
+
+	return allowed, excluded, nil
+}
+
+// GetSortedEnvVarKeys returns the keys of the variables, sorted.
+func GetSortedEnvVarKeys(envVars EnvVars) []string {
+	keys := make([]string, 0, len(envVars))
+	for key := range envVars {
+		keys = append(keys, key)
 	}
 
-	return envs, nil
+	sort.Strings(keys)
+
+	return keys
 }
 
 // FetchEnvVarsWithPrefix fetches environment variables that start with the specified prefix
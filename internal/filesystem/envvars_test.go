@@ -0,0 +1,41 @@
+package filesystem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFetchAllEnvVarsFromHostKeepsEquals(t *testing.T) {
+	t.Setenv("STILETTO_TEST_CONN", "host=db;user=app;opts=a=b")
+
+	envVars, err := FetchAllEnvVarsFromHost()
+	assert.NoError(t, err)
+	assert.Equal(t, "host=db;user=app;opts=a=b", envVars["STILETTO_TEST_CONN"],
+		"Values containing '=' should be preserved")
+}
+
+func TestHostEnvFilter(t *testing.T) {
+	envVars := EnvVars{
+		"PATH":          "/usr/bin",
+		"SSH_AUTH_SOCK": "/tmp/agent.sock",
+		"GITHUB_TOKEN":  "secret",
+		"APP_NAME":      "app",
+		"APP_TOKEN":     "secret",
+		"STAGE":         "dev",
+	}
+
+	allowed, excluded := HostEnvFilter{}.FilterEnvVars(envVars)
+	assert.Equal(t, EnvVars{"APP_NAME": "app", "APP_TOKEN": "secret", "STAGE": "dev"}, allowed,
+		"The default denylist should be applied")
+	assert.Equal(t, []string{"GITHUB_TOKEN", "PATH", "SSH_AUTH_SOCK"}, excluded)
+
+	allowed, _ = HostEnvFilter{Deny: []string{"*_TOKEN"}}.FilterEnvVars(envVars)
+	assert.Equal(t, EnvVars{"APP_NAME": "app", "STAGE": "dev"}, allowed)
+
+	allowed, _ = HostEnvFilter{Allow: []string{"APP_*", "PATH"},
+		Deny: []string{"APP_TOKEN"}}.FilterEnvVars(envVars)
+	assert.Equal(t, EnvVars{"APP_NAME": "app", "PATH": "/usr/bin"}, allowed,
+		"Only the allowed variables should pass, including the default denied ones")
+
+	assert.Error(t, HostEnvFilter{Deny: []string{"APP_["}}.Validate(), "Invalid globs should fail")
+}
@@ -3,6 +3,8 @@ package filesystem
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func FileExist(filePath string) error {
@@ -20,3 +22,57 @@ func FileExist(filePath string) error {
 
 	return nil
 }
+
+// GetUniqueFileName returns the name, or (if it's already taken) the name with the first free
+// numeric suffix before its extension. E.g.: 'app.zip', 'app-1.zip', 'app-2.zip'. The name
+// returned is marked as taken.
+func GetUniqueFileName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		// Hidden files without an extension, E.g.: '.env'.
+		ext = ""
+	}
+
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+
+	for i := 1; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+
+	taken[unique] = true
+
+	return unique
+}
+
+// FindFilesUpToGitRoot looks for the files (the first name found, per dir) in the dir and its
+// parents, up to the root of its git repository (or only in the dir, if it isn't in one). They're
+// returned from the outermost to the innermost, E.g.: to merge them with the nearest winning.
+func FindFilesUpToGitRoot(dir string, names []string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := IsGitRepository(dir, false, true)
+	if err != nil {
+		root = dir
+	}
+
+	var found []string
+	for current := dir; ; current = filepath.Dir(current) {
+		for _, name := range names {
+			path := filepath.Join(current, name)
+			if FileExist(path) == nil {
+				found = append([]string{path}, found...)
+				break
+			}
+		}
+
+		if current == root || current == filepath.Dir(current) {
+			break
+		}
+	}
+
+	return found, nil
+}
@@ -0,0 +1,57 @@
+package filesystem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetUniqueFileName(t *testing.T) {
+	taken := map[string]bool{}
+
+	assert.Equal(t, "app.zip", GetUniqueFileName("app.zip", taken))
+	assert.Equal(t, "app-1.zip", GetUniqueFileName("app.zip", taken))
+	assert.Equal(t, "app-2.zip", GetUniqueFileName("app.zip", taken))
+	assert.Equal(t, "dist", GetUniqueFileName("dist", taken))
+	assert.Equal(t, "dist-1", GetUniqueFileName("dist", taken))
+	assert.Equal(t, ".env", GetUniqueFileName(".env", taken))
+	assert.Equal(t, ".env-1", GetUniqueFileName(".env", taken))
+
+	taken["report-1.xml"] = true
+	assert.Equal(t, "report.xml", GetUniqueFileName("report.xml", taken))
+	assert.Equal(t, "report-2.xml", GetUniqueFileName("report.xml", taken))
+}
+
+func TestFindFilesUpToGitRoot(t *testing.T) {
+	names := []string{".stiletto.yaml", ".stiletto.yml"}
+
+	outside := t.TempDir()
+	repo := filepath.Join(outside, "repo")
+	module := filepath.Join(repo, "infra", "vpc")
+	assert.NoError(t, os.MkdirAll(module, 0o755))
+
+	write := func(path string) {
+		assert.NoError(t, os.WriteFile(path, []byte("task: plan\n"), 0o644))
+	}
+
+	write(filepath.Join(outside, ".stiletto.yaml"))
+	write(filepath.Join(repo, ".stiletto.yml"))
+	write(filepath.Join(module, ".stiletto.yaml"))
+
+	// Without a git repository, only the dir is looked at.
+	found, err := FindFilesUpToGitRoot(module, names)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(module, ".stiletto.yaml")}, found)
+
+	// The files above the git root are ignored.
+	assert.NoError(t, os.Mkdir(filepath.Join(repo, ".git"), 0o755))
+	found, err = FindFilesUpToGitRoot(module, names)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(repo, ".stiletto.yml"),
+		filepath.Join(module, ".stiletto.yaml")}, found)
+
+	found, err = FindFilesUpToGitRoot(filepath.Join(repo, "infra"), names)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(repo, ".stiletto.yml")}, found)
+}
@@ -1,9 +1,17 @@
 package filesystem
 
 import (
+	"errors"
 	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 func IsGitRepository(path string, checkUntilRoot bool, checkRecursivelyUntilIsFound bool) (string, error) {
@@ -65,3 +73,307 @@ func IsGitRepository(path string, checkUntilRoot bool, checkRecursivelyUntilIsFo
 
 	return absPath, nil
 }
+
+// GitMetadata describes the state of a git repository.
+type GitMetadata struct {
+	RootDir   string
+	SHA       string
+	ShortSHA  string
+	Branch    string // Empty, if HEAD is detached.
+	Tag       string // The nearest tag reachable from HEAD.
+	Dirty     bool   // True if there are uncommitted changes (untracked files are ignored).
+	Author    string // The author of the HEAD commit, as 'Name <email>'.
+	RemoteURL string // The 'origin' remote URL (or the first one), without credentials.
+}
+
+const gitTagSearchMaxCommits = 10000
+
+// InspectGitRepository reads the metadata of the git repository that contains the path with
+// go-git, so no 'git' binary is required.
+func InspectGitRepository(path string) (GitMetadata, error) {
+	rootDir, err := IsGitRepository(path, false, true)
+	if err != nil {
+		return GitMetadata{}, err
+	}
+
+	repo, err := openGitRepository(rootDir)
+	if err != nil {
+		return GitMetadata{}, err
+	}
+
+	metadata := GitMetadata{RootDir: rootDir, RemoteURL: readRemoteURL(repo)}
+
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return GitMetadata{}, fmt.Errorf("failed to read the HEAD of %s: %w", rootDir, err)
+	}
+
+	if head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		metadata.Branch = head.Target().Short()
+	}
+
+	head, err = repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// The branch has no commits yet.
+		return metadata, nil
+	}
+
+	if err != nil {
+		return GitMetadata{}, fmt.Errorf("failed to read the HEAD of %s: %w", rootDir, err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return GitMetadata{}, fmt.Errorf("failed to read the HEAD commit of %s: %w", rootDir, err)
+	}
+
+	metadata.SHA = commit.Hash.String()
+	metadata.ShortSHA = metadata.SHA[:7]
+	metadata.Author = fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email)
+	metadata.Tag = findNearestTag(repo, commit)
+
+	dirty, err := isDirty(repo)
+	if err != nil {
+		return GitMetadata{}, fmt.Errorf("failed to check the changes of %s: %w", rootDir, err)
+	}
+
+	metadata.Dirty = dirty
+
+	return metadata, nil
+}
+
+// openGitRepository opens the repository of the work dir, supporting '.git' files (worktrees
+// and submodules) that point to the actual git dir.
+func openGitRepository(rootDir string) (*git.Repository, error) {
+	repo, err := git.PlainOpenWithOptions(rootDir,
+		&git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the git repository %s: %w", rootDir, err)
+	}
+
+	return repo, nil
+}
+
+// findNearestTag walks the history (breadth first) from the commit, returning the first tag
+// found. If a commit has several tags, the greatest (sorted) one is returned.
+func findNearestTag(repo *git.Repository, commit *object.Commit) string {
+	tags := listTags(repo)
+	if len(tags) == 0 {
+		return ""
+	}
+
+	queue := []*object.Commit{commit}
+	visited := map[plumbing.Hash]bool{commit.Hash: true}
+
+	for len(queue) > 0 && len(visited) <= gitTagSearchMaxCommits {
+		current := queue[0]
+		queue = queue[1:]
+
+		if names, ok := tags[current.Hash]; ok {
+			return names[len(names)-1]
+		}
+
+		for _, parentHash := range current.ParentHashes {
+			if visited[parentHash] {
+				continue
+			}
+
+			visited[parentHash] = true
+
+			parent, err := repo.CommitObject(parentHash)
+			if err != nil {
+				// Shallow clones don't have the whole history.
+				continue
+			}
+
+			queue = append(queue, parent)
+		}
+	}
+
+	return ""
+}
+
+// listTags returns the (sorted) tag names by the commit they point to, peeling annotated tags.
+func listTags(repo *git.Repository) map[plumbing.Hash][]string {
+	tags := map[plumbing.Hash][]string{}
+
+	refs, err := repo.Tags()
+	if err != nil {
+		return tags
+	}
+
+	_ = refs.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// Tags of trees or blobs don't describe a commit.
+				return nil
+			}
+			hash = commit.Hash
+		}
+
+		tags[hash] = append(tags[hash], ref.Name().Short())
+		return nil
+	})
+
+	for _, names := range tags {
+		sort.Strings(names)
+	}
+
+	return tags
+}
+
+// isDirty checks for staged or unstaged changes in the work tree. Untracked files are ignored.
+func isDirty(repo *git.Repository) (bool, error) {
+	changed, err := getUncommittedChanges(repo, false)
+	if err != nil {
+		return false, err
+	}
+
+	return len(changed) > 0, nil
+}
+
+// getUncommittedChanges returns the files with staged or unstaged changes, and optionally the
+// untracked (not ignored) files.
+func getUncommittedChanges(repo *git.Repository, withUntracked bool) ([]string, error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var headTree *object.Tree
+	if head, err := repo.Head(); err == nil {
+		commit, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return nil, err
+		}
+
+		if headTree, err = commit.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	var changed []string
+	for file, fileStatus := range status {
+		if fileStatus.Staging == git.Untracked && fileStatus.Worktree == git.Untracked {
+			// go-git reports a staged deletion of a file that is still in the work tree as
+			// untracked, so it's checked against HEAD.
+			if withUntracked {
+				changed = append(changed, file)
+			} else if headTree != nil {
+				if _, err := headTree.FindEntry(file); err == nil {
+					changed = append(changed, file)
+				}
+			}
+			continue
+		}
+
+		if fileStatus.Staging != git.Unmodified || fileStatus.Worktree != git.Unmodified {
+			changed = append(changed, file)
+		}
+	}
+
+	return changed, nil
+}
+
+// readRemoteURL returns the URL of the 'origin' remote, or of the first remote (by name) found.
+func readRemoteURL(repo *git.Repository) string {
+	cfg, err := repo.Config()
+	if err != nil || len(cfg.Remotes) == 0 {
+		return ""
+	}
+
+	remote, ok := cfg.Remotes["origin"]
+	if !ok {
+		names := make([]string, 0, len(cfg.Remotes))
+		for name := range cfg.Remotes {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+		remote = cfg.Remotes[names[0]]
+	}
+
+	if len(remote.URLs) == 0 {
+		return ""
+	}
+
+	return RemoveURLCredentials(remote.URLs[0])
+}
+
+// RemoveURLCredentials removes the user info (E.g.: tokens) from HTTP(S) URLs.
+func RemoveURLCredentials(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User == nil {
+		return rawURL
+	}
+
+	u.User = nil
+
+	return u.String()
+}
+
+// GetGitSourceURL returns the browsable (https) URL of a remote, E.g.:
+// 'git@github.com:org/repo.git' becomes 'https://github.com/org/repo'.
+func GetGitSourceURL(remoteURL string) string {
+	source := remoteURL
+
+	switch {
+	case strings.HasPrefix(source, "ssh://"):
+		if u, err := url.Parse(source); err == nil {
+			source = "https://" + u.Hostname() + u.Path
+		}
+	case !strings.Contains(source, "://") && strings.Contains(source, ":"):
+		// scp-like syntax: 'user@host:path'.
+		host, repoPath, _ := strings.Cut(source, ":")
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		source = "https://" + host + "/" + strings.TrimPrefix(repoPath, "/")
+	}
+
+	return strings.TrimSuffix(source, ".git")
+}
+
+// EnvVars returns the metadata as STILETTO_GIT_* env vars.
+func (m GitMetadata) EnvVars() EnvVars {
+	envVars := EnvVars{
+		"STILETTO_GIT_SHA":        m.SHA,
+		"STILETTO_GIT_SHORT_SHA":  m.ShortSHA,
+		"STILETTO_GIT_BRANCH":     m.Branch,
+		"STILETTO_GIT_TAG":        m.Tag,
+		"STILETTO_GIT_DIRTY":      strconv.FormatBool(m.Dirty),
+		"STILETTO_GIT_AUTHOR":     m.Author,
+		"STILETTO_GIT_REMOTE_URL": m.RemoteURL,
+	}
+
+	// Unknown values (E.g.: the branch on a detached HEAD) aren't set.
+	for key, value := range envVars {
+		if value == "" {
+			delete(envVars, key)
+		}
+	}
+
+	return envVars
+}
+
+// OCILabels returns the OCI image annotations that describe the source of an image.
+func (m GitMetadata) OCILabels() map[string]string {
+	labels := map[string]string{}
+
+	if m.SHA != "" {
+		labels["org.opencontainers.image.revision"] = m.SHA
+	}
+
+	if m.RemoteURL != "" {
+		labels["org.opencontainers.image.source"] = GetGitSourceURL(m.RemoteURL)
+	}
+
+	return labels
+}
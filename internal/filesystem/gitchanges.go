@@ -0,0 +1,142 @@
+package filesystem
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GitChanges are the files changed in a git repository, relative to its root dir.
+type GitChanges struct {
+	RootDir string
+	Since   string // The merge base the changes are computed from, empty for uncommitted changes only.
+	Files   []string
+}
+
+// GetGitChangedFiles returns the files changed on HEAD since it diverged from the revision (E.g.:
+// 'origin/main', 'v1.2.0', 'HEAD~3' or a commit id), as 'git diff revision...HEAD' does, plus the
+// uncommitted (staged or not) changes. So the commits added to the revision after HEAD branched
+// off aren't reported. If the revision is empty, only the uncommitted changes are returned.
+// Untracked files are reported too, unless they're ignored (E.g.: by '.gitignore').
+func GetGitChangedFiles(path, since string) (GitChanges, error) {
+	rootDir, err := IsGitRepository(path, false, true)
+	if err != nil {
+		return GitChanges{}, err
+	}
+
+	repo, err := openGitRepository(rootDir)
+	if err != nil {
+		return GitChanges{}, err
+	}
+
+	changes := GitChanges{RootDir: rootDir}
+	changed := map[string]bool{}
+
+	if since != "" {
+		baseSHA, err := addCommittedChanges(repo, since, changed)
+		if err != nil {
+			return GitChanges{}, err
+		}
+
+		changes.Since = baseSHA
+	}
+
+	uncommitted, err := getUncommittedChanges(repo, true)
+	if err != nil {
+		return GitChanges{}, fmt.Errorf("failed to check the changes of %s: %w", rootDir, err)
+	}
+
+	for _, file := range uncommitted {
+		changed[file] = true
+	}
+
+	for file := range changed {
+		changes.Files = append(changes.Files, file)
+	}
+
+	sort.Strings(changes.Files)
+
+	return changes, nil
+}
+
+// addCommittedChanges adds the files changed between the merge base of HEAD and the revision,
+// and HEAD, returning the merge base.
+func addCommittedChanges(repo *git.Repository, since string, changed map[string]bool) (string,
+	error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", fmt.Errorf("failed to read the HEAD commit: %w", err)
+	}
+
+	sinceSHA, err := repo.ResolveRevision(plumbing.Revision(since))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the revision '%s': %w", since, err)
+	}
+
+	sinceCommit, err := repo.CommitObject(*sinceSHA)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the revision '%s': %w", since, err)
+	}
+
+	bases, err := headCommit.MergeBase(sinceCommit)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the merge base of HEAD and '%s': %w", since, err)
+	}
+
+	if len(bases) == 0 {
+		return "", fmt.Errorf("HEAD and '%s' have no common history", since)
+	}
+
+	baseTree, err := bases[0].Tree()
+	if err != nil {
+		return "", err
+	}
+
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	diff, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return "", fmt.Errorf("failed to compare HEAD with '%s': %w", since, err)
+	}
+
+	for _, change := range diff {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" {
+				changed[name] = true
+			}
+		}
+	}
+
+	return bases[0].Hash.String(), nil
+}
+
+// FilterChangedFiles returns the changed files that are under any of the (absolute) paths.
+func (c GitChanges) FilterChangedFiles(paths []string) []string {
+	var matched []string
+
+	for _, file := range c.Files {
+		filePath := filepath.Join(c.RootDir, filepath.FromSlash(file))
+
+		for _, p := range paths {
+			rel, err := filepath.Rel(filepath.Clean(p), filePath)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				matched = append(matched, file)
+				break
+			}
+		}
+	}
+
+	return matched
+}
@@ -0,0 +1,122 @@
+package filesystem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetGitChangedFiles(t *testing.T) {
+	dir, run := newTestGitRepository(t)
+
+	changes, err := GetGitChangedFiles(dir, "")
+	assert.NoError(t, err)
+	assert.Empty(t, changes.Files, "A clean repository has no uncommitted changes")
+
+	for _, packed := range []bool{false, true} {
+		if packed {
+			run("gc", "-q", "--aggressive")
+		}
+
+		for _, since := range []string{"v1.0.0", "HEAD~1", "HEAD^", "main~1",
+			run("rev-parse", "--short", "HEAD~1")} {
+			changes, err := GetGitChangedFiles(dir, since)
+			assert.NoError(t, err, since)
+			assert.Equal(t, []string{"src/main.go"}, changes.Files, since)
+			assert.Equal(t, run("rev-parse", "HEAD~1"), changes.Since, since)
+		}
+	}
+
+	changes, err = GetGitChangedFiles(dir, "HEAD")
+	assert.NoError(t, err)
+	assert.Empty(t, changes.Files)
+
+	for _, since := range []string{"nope", "HEAD~5", "v1.0.0~x"} {
+		_, err := GetGitChangedFiles(dir, since)
+		assert.Error(t, err, since)
+	}
+
+	// Uncommitted changes: modified, staged, and untracked (unless ignored).
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# changed\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docs.md"), []byte("docs\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("x"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "build.log"), []byte("x"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0o644))
+	run("add", "docs.md", ".gitignore")
+
+	changes, err = GetGitChangedFiles(filepath.Join(dir, "src"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".gitignore", "README.md", "docs.md", "untracked.txt"}, changes.Files)
+
+	changes, err = GetGitChangedFiles(dir, "v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".gitignore", "README.md", "docs.md", "src/main.go", "untracked.txt"},
+		changes.Files)
+
+	_, err = GetGitChangedFiles(t.TempDir(), "")
+	assert.Error(t, err, "It should fail outside of a git repository")
+}
+
+func TestGetGitChangedFilesSinceMergeBase(t *testing.T) {
+	dir, run := newTestGitRepository(t)
+	base := run("rev-parse", "HEAD")
+
+	// main moves on after the feature branch diverged: its changes aren't the branch's.
+	run("checkout", "-q", "-b", "feature")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "feature.txt"), []byte("x\n"), 0o644))
+	run("add", "feature.txt")
+	run("commit", "-q", "-m", "feature")
+
+	run("checkout", "-q", "main")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# main\n"), 0o644))
+	run("commit", "-q", "-am", "main")
+	run("checkout", "-q", "feature")
+
+	changes, err := GetGitChangedFiles(dir, "main")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"feature.txt"}, changes.Files)
+	assert.Equal(t, base, changes.Since)
+}
+
+func TestGetGitChangedFilesUntracked(t *testing.T) {
+	dir, run := newTestGitRepository(t)
+
+	// A new stack dir that was never added is a change, but not the files ignored in it.
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "stacks", "new", ".terraform"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "stacks", "new", "main.tf"), []byte("x"),
+		0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "stacks", "new", ".terraform", "state"),
+		[]byte("x"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "stacks", ".gitignore"),
+		[]byte(".terraform/\n"), 0o644))
+	run("add", "stacks/.gitignore")
+	run("commit", "-q", "-m", "ignore")
+
+	changes, err := GetGitChangedFiles(dir, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stacks/new/main.tf"}, changes.Files)
+	assert.Equal(t, []string{"stacks/new/main.tf"},
+		changes.FilterChangedFiles([]string{filepath.Join(dir, "stacks", "new")}))
+
+	// Untracked files still don't make the repository dirty.
+	metadata, err := InspectGitRepository(dir)
+	assert.NoError(t, err)
+	assert.False(t, metadata.Dirty)
+}
+
+func TestFilterChangedFiles(t *testing.T) {
+	changes := GitChanges{
+		RootDir: "/repo",
+		Files:   []string{"README.md", "modules/vpc/main.tf", "stacks/app/main.tf", "stacks/app2/x.tf"},
+	}
+
+	assert.Equal(t, []string{"stacks/app/main.tf"},
+		changes.FilterChangedFiles([]string{"/repo/stacks/app"}),
+		"Sibling dirs with the same prefix shouldn't match")
+	assert.Equal(t, []string{"modules/vpc/main.tf", "stacks/app/main.tf"},
+		changes.FilterChangedFiles([]string{"/repo/stacks/app", "/repo/modules/"}))
+	assert.Equal(t, []string{"README.md"}, changes.FilterChangedFiles([]string{"/repo/README.md"}))
+	assert.Len(t, changes.FilterChangedFiles([]string{"/repo"}), 4)
+	assert.Empty(t, changes.FilterChangedFiles([]string{"/other"}))
+}
@@ -0,0 +1,28 @@
+package filesystem
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const maxLogFileNameCmdLength = 48
+
+var logFileNameInvalidCharsRegex = regexp.MustCompile(`[^a-zA-Z0-9._]+`)
+
+// GetCommandLogFileName returns the name of the log file of a command: its step number, and a
+// file-safe (and truncated) form of the command. E.g.: step 1 of 'pytest -q' is '01-pytest-q.log'.
+func GetCommandLogFileName(step int, cmd []string) string {
+	name := strings.Trim(logFileNameInvalidCharsRegex.ReplaceAllString(strings.Join(cmd, " "), "-"),
+		"-.")
+
+	if len(name) > maxLogFileNameCmdLength {
+		name = strings.TrimRight(name[:maxLogFileNameCmdLength], "-.")
+	}
+
+	if name == "" {
+		name = "cmd"
+	}
+
+	return fmt.Sprintf("%02d-%s.log", step, name)
+}
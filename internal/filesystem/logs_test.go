@@ -0,0 +1,18 @@
+package filesystem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGetCommandLogFileName(t *testing.T) {
+	assert.Equal(t, "01-pytest-q.log", GetCommandLogFileName(1, []string{"pytest", "-q"}))
+	assert.Equal(t, "02-ruff-check.log", GetCommandLogFileName(2, []string{"ruff", "check", "."}))
+	assert.Equal(t, "12-sh-c-cat-a.txt-wc-l.log",
+		GetCommandLogFileName(12, []string{"sh", "-c", "cat a.txt | wc -l"}))
+	assert.Equal(t, "03-cmd.log", GetCommandLogFileName(3, []string{"/"}))
+
+	long := GetCommandLogFileName(4, []string{"echo", strings.Repeat("a", 100)})
+	assert.Equal(t, "04-echo-"+strings.Repeat("a", 43)+".log", long)
+}
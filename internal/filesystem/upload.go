@@ -0,0 +1,381 @@
+package filesystem
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	StilettoIgnoreFile = ".stilettoignore"
+	DockerIgnoreFile   = ".dockerignore"
+)
+
+// DefaultUploadExcludes are never uploaded, unless they're re-included: the files of stiletto
+// itself (E.g.: its logs, artifacts and lambda packages), at any depth.
+var DefaultUploadExcludes = []string{"**/.stiletto"}
+
+// UploadFilter selects the files of a host directory that are uploaded into the dagger engine.
+// The patterns use the .dockerignore syntax (relative to the directory, '**' matches any number
+// of directories, and '!' re-includes an excluded path). A path is uploaded if it matches the
+// Include patterns (or none is set), and the last Exclude pattern that matches it isn't negated.
+type UploadFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// UploadStats is the summary of the files that are uploaded from a host directory.
+type UploadStats struct {
+	Files int
+	Bytes int64
+	// Excluded entries, being an excluded directory counted once (its content isn't walked).
+	Excluded int
+}
+
+// ParseIgnoreFile reads the patterns of an ignore file, skipping the comments and the empty
+// lines. If gitIgnoreSyntax is true, the patterns are converted from the .gitignore syntax.
+func ParseIgnoreFile(r io.Reader, gitIgnoreSyntax bool) ([]string, error) {
+	var patterns []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if gitIgnoreSyntax {
+			if pattern := ConvertGitIgnorePattern(line); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, normaliseUploadPattern(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns, nil
+}
+
+// ReadIgnoreFile reads the patterns of an ignore file. A missing file has no patterns.
+func ReadIgnoreFile(path string, gitIgnoreSyntax bool) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseIgnoreFile(file, gitIgnoreSyntax)
+}
+
+// ConvertGitIgnorePattern converts a .gitignore line into the .dockerignore syntax. Patterns
+// without a slash (E.g.: 'node_modules' or '*.log') match at any depth, and the trailing slash
+// (directories only) is dropped. It returns an empty string for comments and empty lines.
+func ConvertGitIgnorePattern(line string) string {
+	// Trailing spaces are ignored, unless they're escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+
+	negated := strings.HasPrefix(line, "!")
+	if negated {
+		line = line[1:]
+	}
+
+	if strings.HasPrefix(line, "\\#") || strings.HasPrefix(line, "\\!") {
+		line = line[1:]
+	}
+
+	line = strings.TrimSuffix(line, "/")
+	if line == "" {
+		return ""
+	}
+
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	if !anchored && !strings.HasPrefix(line, "**") {
+		line = "**/" + line
+	}
+
+	if negated {
+		return "!" + line
+	}
+
+	return line
+}
+
+func normaliseUploadPattern(pattern string) string {
+	negated := strings.HasPrefix(pattern, "!")
+	if negated {
+		pattern = strings.TrimSpace(pattern[1:])
+	}
+
+	pattern = strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "/")
+
+	if negated {
+		return "!" + pattern
+	}
+
+	return pattern
+}
+
+// ResolveUploadFilter returns the filter of a host directory: the DefaultUploadExcludes, the
+// patterns of its .stilettoignore file, the ones of its .dockerignore file (if useDockerIgnore is
+// set) and the ones passed explicitly, in that order (so the latter win).
+func ResolveUploadFilter(dir string, useDockerIgnore bool, include, exclude []string) (UploadFilter,
+	error) {
+	filter := UploadFilter{Exclude: append([]string{}, DefaultUploadExcludes...)}
+
+	stilettoIgnore, err := ReadIgnoreFile(filepath.Join(dir, StilettoIgnoreFile), true)
+	if err != nil {
+		return UploadFilter{}, fmt.Errorf("failed to read the %s file: %w", StilettoIgnoreFile, err)
+	}
+
+	filter.Exclude = append(filter.Exclude, stilettoIgnore...)
+
+	if useDockerIgnore {
+		dockerIgnore, err := ReadIgnoreFile(filepath.Join(dir, DockerIgnoreFile), false)
+		if err != nil {
+			return UploadFilter{}, fmt.Errorf("failed to read the %s file: %w", DockerIgnoreFile, err)
+		}
+
+		filter.Exclude = append(filter.Exclude, dockerIgnore...)
+	}
+
+	for _, pattern := range include {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			filter.Include = append(filter.Include, normaliseUploadPattern(pattern))
+		}
+	}
+
+	for _, pattern := range exclude {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			filter.Exclude = append(filter.Exclude, normaliseUploadPattern(pattern))
+		}
+	}
+
+	if err := filter.Validate(); err != nil {
+		return UploadFilter{}, err
+	}
+
+	return filter, nil
+}
+
+// GetNestedUploadExcludes returns the patterns that exclude the paths (E.g.: the logs dir) that
+// are inside the directory. The other paths, and the directory itself, are skipped.
+func GetNestedUploadExcludes(dir string, paths ...string) []string {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	var patterns []string
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+
+		absPath, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(absDir, absPath)
+		if err != nil || rel == "." || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		patterns = append(patterns, normaliseUploadPattern(rel))
+	}
+
+	return patterns
+}
+
+// IsEmpty returns true if the filter uploads the whole directory.
+func (f UploadFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Validate checks that the patterns are well-formed.
+func (f UploadFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		for _, segment := range strings.Split(strings.TrimPrefix(pattern, "!"), "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid upload pattern '%s': %w", pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// IsUploaded returns true if the path (relative to the directory) is uploaded.
+func (f UploadFilter) IsUploaded(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+
+	if len(f.Include) > 0 && !matchesAnyUploadPattern(relPath, f.Include) {
+		return false
+	}
+
+	return !f.isExcluded(relPath)
+}
+
+func (f UploadFilter) isExcluded(relPath string) bool {
+	excluded := false
+
+	for _, pattern := range f.Exclude {
+		negated := strings.HasPrefix(pattern, "!")
+		if matchesUploadPattern(strings.TrimPrefix(pattern, "!"), relPath) {
+			excluded = !negated
+		}
+	}
+
+	return excluded
+}
+
+func (f UploadFilter) hasNegatedExcludes() bool {
+	for _, pattern := range f.Exclude {
+		if strings.HasPrefix(pattern, "!") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesAnyUploadPattern(relPath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchesUploadPattern(pattern, relPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesUploadPattern returns true if the pattern matches the path, or any of its parent
+// directories (so excluding a directory excludes its content).
+func matchesUploadPattern(pattern, relPath string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(relPath, "/")
+
+	for i := 1; i <= len(pathSegments); i++ {
+		if matchUploadSegments(patternSegments, pathSegments[:i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchUploadSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchUploadSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+
+	return matchUploadSegments(pattern[1:], segments[1:])
+}
+
+// GetUploadStats walks the directory, returning the number and size of the files the filter
+// uploads, and the number of the excluded entries.
+func GetUploadStats(dir string, filter UploadFilter) (UploadStats, error) {
+	stats := UploadStats{}
+	// Excluded directories can be skipped, unless a negated pattern re-includes part of them.
+	canSkipDirs := !filter.hasNegatedExcludes()
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		if d.IsDir() {
+			if canSkipDirs && filter.isExcluded(filepath.ToSlash(rel)) {
+				stats.Excluded++
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !filter.IsUploaded(rel) {
+			stats.Excluded++
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		stats.Files++
+		stats.Bytes += info.Size()
+
+		return nil
+	})
+
+	if err != nil {
+		return UploadStats{}, err
+	}
+
+	return stats, nil
+}
+
+// FormatBytes returns the size in a human-readable form. E.g.: '1.5 MiB'.
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,111 @@
+package filesystem
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertGitIgnorePattern(t *testing.T) {
+	cases := map[string]string{
+		"node_modules/":   "**/node_modules",
+		"*.log":           "**/*.log",
+		"/dist":           "dist",
+		"build/cache/":    "build/cache",
+		"!keep.log":       "!**/keep.log",
+		"**/tmp":          "**/tmp",
+		"\\#file":         "**/#file",
+		"# comment":       "",
+		"   ":             "",
+		".git  ":          "**/.git",
+		"docs/**/*.draft": "docs/**/*.draft",
+	}
+
+	for line, expected := range cases {
+		assert.Equal(t, expected, ConvertGitIgnorePattern(line), line)
+	}
+}
+
+func TestUploadFilterIsUploaded(t *testing.T) {
+	filter := UploadFilter{
+		Exclude: []string{"**/node_modules", ".git", "**/*.log", "!logs/keep.log"},
+	}
+
+	assert.True(t, filter.IsUploaded("src/main.go"))
+	assert.False(t, filter.IsUploaded("node_modules/pkg/index.js"))
+	assert.False(t, filter.IsUploaded("web/node_modules/pkg/index.js"))
+	assert.False(t, filter.IsUploaded(".git/HEAD"))
+	assert.True(t, filter.IsUploaded("web/.git/HEAD"), "'.git' is anchored to the root")
+	assert.False(t, filter.IsUploaded("logs/debug.log"))
+	assert.True(t, filter.IsUploaded("logs/keep.log"), "The last matching pattern wins")
+
+	filter.Include = []string{"src", "package*.json"}
+	assert.True(t, filter.IsUploaded("src/main.go"))
+	assert.True(t, filter.IsUploaded("package-lock.json"))
+	assert.False(t, filter.IsUploaded("README.md"))
+	assert.False(t, filter.IsUploaded("src/debug.log"), "Excludes apply to the included files")
+
+	assert.Error(t, UploadFilter{Exclude: []string{"[a-"}}.Validate())
+}
+
+func TestResolveUploadFilterAndStats(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		p := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		assert.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+
+	write(StilettoIgnoreFile, "# deps\nnode_modules/\n*.log\n")
+	write(DockerIgnoreFile, "/dist\n!dist/keep.txt\n")
+	write("src/main.go", "package main\n")
+	write("app.log", "log")
+	write("node_modules/pkg/index.js", strings.Repeat("x", 2048))
+	write("web/node_modules/pkg/index.js", "x")
+	write("dist/bundle.js", "x")
+	write("dist/keep.txt", "keep")
+	write("tmp/cache.bin", "x")
+	write(".stiletto/logs/run/build/01-sh.log", "x")
+	write("web/.stiletto/artifacts/dist.zip", "x")
+
+	filter, err := ResolveUploadFilter(dir, false, nil, []string{"tmp/"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"**/.stiletto", "**/node_modules", "**/*.log", "tmp"}, filter.Exclude)
+
+	stats, err := GetUploadStats(dir, filter)
+	assert.NoError(t, err)
+	// .stilettoignore, .dockerignore, src/main.go, dist/bundle.js and dist/keep.txt.
+	assert.Equal(t, 5, stats.Files)
+	assert.Equal(t, 6, stats.Excluded, "Excluded dirs should be counted once")
+
+	filter, err = ResolveUploadFilter(dir, true, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"**/.stiletto", "**/node_modules", "**/*.log", "dist",
+		"!dist/keep.txt"}, filter.Exclude)
+
+	stats, err = GetUploadStats(dir, filter)
+	assert.NoError(t, err)
+	// .stilettoignore, .dockerignore, src/main.go, dist/keep.txt and tmp/cache.bin.
+	assert.Equal(t, 5, stats.Files)
+	assert.Less(t, stats.Bytes, int64(2048))
+
+	_, err = ResolveUploadFilter(dir, false, nil, []string{"[a-"})
+	assert.Error(t, err)
+}
+
+func TestGetNestedUploadExcludes(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.Equal(t, []string{"build/logs", "out"}, GetNestedUploadExcludes(dir,
+		filepath.Join(dir, "build", "logs"), "", filepath.Join(dir, "out"), dir,
+		filepath.Dir(dir), filepath.Join(filepath.Dir(dir), "other")),
+		"Only the paths inside the dir should be excluded")
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0 MiB", FormatBytes(2*1024*1024))
+}
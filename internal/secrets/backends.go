@@ -0,0 +1,193 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	SchemeSSM            = "ssm"
+	SchemeSecretsManager = "secretsmanager"
+	SchemeFile           = "file"
+	SchemeEnv            = "env"
+)
+
+// SSMGetParameterAPI is the subset of the SSM client used to resolve 'ssm://' references.
+type SSMGetParameterAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput,
+		optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// SecretsManagerGetSecretValueAPI is the subset of the Secrets Manager client used to resolve
+// 'secretsmanager://' references.
+type SecretsManagerGetSecretValueAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput,
+		optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// SSMResolver resolves 'ssm:///path/to/param' references, decrypting SecureString parameters.
+// The client is built on the first use, so the AWS credentials are only required if an 'ssm://'
+// reference is found.
+type SSMResolver struct {
+	once      sync.Once
+	newClient func(ctx context.Context) (SSMGetParameterAPI, error)
+	client    SSMGetParameterAPI
+	clientErr error
+}
+
+func NewSSMResolver(newClient func(ctx context.Context) (SSMGetParameterAPI, error)) *SSMResolver {
+	return &SSMResolver{newClient: newClient}
+}
+
+func (r *SSMResolver) Scheme() string {
+	return SchemeSSM
+}
+
+func (r *SSMResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	r.once.Do(func() {
+		r.client, r.clientErr = r.newClient(ctx)
+	})
+
+	if r.clientErr != nil {
+		return "", r.clientErr
+	}
+
+	out, err := r.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(ref.Path),
+		WithDecryption: aws.Bool(true),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if out.Parameter == nil {
+		return "", fmt.Errorf("the parameter %s has no value", ref.Path)
+	}
+
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// SecretsManagerResolver resolves 'secretsmanager://name#key' references. If a key is passed,
+// the secret is parsed as a JSON object and the key's value is returned. As the SSMResolver, the
+// client is built on the first use.
+type SecretsManagerResolver struct {
+	once      sync.Once
+	newClient func(ctx context.Context) (SecretsManagerGetSecretValueAPI, error)
+	client    SecretsManagerGetSecretValueAPI
+	clientErr error
+}
+
+func NewSecretsManagerResolver(
+	newClient func(ctx context.Context) (SecretsManagerGetSecretValueAPI, error)) *SecretsManagerResolver {
+	return &SecretsManagerResolver{newClient: newClient}
+}
+
+func (r *SecretsManagerResolver) Scheme() string {
+	return SchemeSecretsManager
+}
+
+func (r *SecretsManagerResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	r.once.Do(func() {
+		r.client, r.clientErr = r.newClient(ctx)
+	})
+
+	if r.clientErr != nil {
+		return "", r.clientErr
+	}
+
+	out, err := r.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(ref.Path),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	secret := aws.ToString(out.SecretString)
+	if out.SecretString == nil {
+		secret = string(out.SecretBinary)
+	}
+
+	if ref.Field == "" {
+		return secret, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("the secret %s is not a JSON object, "+
+			"so the key '%s' can't be read from it", ref.Path, ref.Field)
+	}
+
+	value, ok := fields[ref.Field]
+	if !ok {
+		return "", fmt.Errorf("the key '%s' does not exist in the secret %s", ref.Field, ref.Path)
+	}
+
+	if s, isString := value.(string); isString {
+		return s, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// FileResolver resolves 'file://path' references, reading the file content (without the trailing
+// new line). Relative paths are relative to the base dir.
+type FileResolver struct {
+	BaseDir string
+}
+
+func (r *FileResolver) Scheme() string {
+	return SchemeFile
+}
+
+func (r *FileResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	path := ref.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.BaseDir, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// EnvResolver resolves 'env://VAR' references, from the lookup (normally, the host
+// environment).
+type EnvResolver struct {
+	Lookup func(key string) (string, bool)
+}
+
+func (r *EnvResolver) Scheme() string {
+	return SchemeEnv
+}
+
+func (r *EnvResolver) Resolve(ctx context.Context, ref Reference) (string, error) {
+	lookup := r.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	value, ok := lookup(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("the environment variable %s is not set", ref.Path)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,33 @@
+package secrets
+
+import (
+	"context"
+	"github.com/Excoriate/stiletto/internal/cloud/adapters/clients"
+	"os"
+)
+
+// NewDefaultRegistry returns a registry with the built-in resolvers: 'ssm://',
+// 'secretsmanager://', 'file://' (relative to the base dir) and 'env://' (from the host).
+// Other backends (E.g.: Vault) can be added with Register.
+func NewDefaultRegistry(baseDir string) *Registry {
+	return NewRegistry(
+		NewSSMResolver(func(ctx context.Context) (SSMGetParameterAPI, error) {
+			f, err := clients.GetAWSClientFactory(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			return f.SSM(), nil
+		}),
+		NewSecretsManagerResolver(func(ctx context.Context) (SecretsManagerGetSecretValueAPI, error) {
+			f, err := clients.GetAWSClientFactory(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			return f.SecretsManager(), nil
+		}),
+		&FileResolver{BaseDir: baseDir},
+		&EnvResolver{Lookup: os.LookupEnv},
+	)
+}
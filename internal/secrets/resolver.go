@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Reference is a secret written as an env value in the form 'scheme://path#field'. E.g.:
+// 'ssm:///app/db/password' or 'secretsmanager://prod/api#key'.
+type Reference struct {
+	Raw    string
+	Scheme string
+	Path   string
+	Field  string // Optional, E.g.: the JSON key of a Secrets Manager secret.
+}
+
+// Resolver fetches the value of the references of a given scheme, from its backend.
+type Resolver interface {
+	Scheme() string
+	Resolve(ctx context.Context, ref Reference) (string, error)
+}
+
+// Registry holds the resolvers per scheme. Values whose scheme isn't registered (E.g.: an
+// 'https://' URL) aren't considered references.
+type Registry struct {
+	mu        sync.Mutex
+	resolvers map[string]Resolver
+}
+
+func NewRegistry(resolvers ...Resolver) *Registry {
+	r := &Registry{resolvers: map[string]Resolver{}}
+	for _, resolver := range resolvers {
+		r.Register(resolver)
+	}
+
+	return r
+}
+
+// Register adds (or replaces) the resolver of its scheme.
+func (r *Registry) Register(resolver Resolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resolvers[strings.ToLower(resolver.Scheme())] = resolver
+}
+
+// Schemes returns the registered schemes, sorted.
+func (r *Registry) Schemes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var schemes []string
+	for scheme := range r.resolvers {
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// Parse returns the reference of the value, if it's written in a registered scheme.
+func (r *Registry) Parse(value string) (Reference, bool) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found {
+		return Reference{}, false
+	}
+
+	scheme = strings.ToLower(scheme)
+
+	r.mu.Lock()
+	_, registered := r.resolvers[scheme]
+	r.mu.Unlock()
+
+	if !registered {
+		return Reference{}, false
+	}
+
+	path, field, _ := strings.Cut(rest, "#")
+
+	return Reference{Raw: value, Scheme: scheme, Path: path, Field: field}, true
+}
+
+// IsReference returns true if the value is written in a registered scheme.
+func (r *Registry) IsReference(value string) bool {
+	_, ok := r.Parse(value)
+	return ok
+}
+
+// Resolve returns the secret value of a reference.
+func (r *Registry) Resolve(ctx context.Context, value string) (string, error) {
+	ref, ok := r.Parse(value)
+	if !ok {
+		return "", fmt.Errorf("'%s' is not a secret reference, the supported schemes are: %s",
+			value, strings.Join(r.Schemes(), ", "))
+	}
+
+	r.mu.Lock()
+	resolver := r.resolvers[ref.Scheme]
+	r.mu.Unlock()
+
+	if ref.Path == "" {
+		return "", fmt.Errorf("the secret reference '%s' has an empty path", value)
+	}
+
+	secret, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the secret reference '%s': %w", value, err)
+	}
+
+	return secret, nil
+}
+
+// ResolveEnvVars resolves the values of the env vars that are secret references. It returns
+// the resolved values per reference; the env vars that aren't references are ignored.
+func (r *Registry) ResolveEnvVars(ctx context.Context, envVars map[string]string) (map[string]string, error) {
+	resolved := map[string]string{}
+
+	var keys []string
+	for key := range envVars {
+		keys = append(keys, key)
+	}
+
+	// Sorted, so the errors are deterministic.
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := envVars[key]
+		if _, done := resolved[value]; done || !r.IsReference(value) {
+			continue
+		}
+
+		secret, err := r.Resolve(ctx, value)
+		if err != nil {
+			return nil, fmt.Errorf("env var %s: %w", key, err)
+		}
+
+		resolved[value] = secret
+	}
+
+	return resolved, nil
+}
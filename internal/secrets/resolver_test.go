@@ -0,0 +1,148 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeSSM struct {
+	params map[string]string
+	calls  int
+}
+
+func (f *fakeSSM) GetParameter(_ context.Context, params *ssm.GetParameterInput,
+	_ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	f.calls++
+
+	value, ok := f.params[aws.ToString(params.Name)]
+	if !ok {
+		return nil, errors.New("ParameterNotFound")
+	}
+
+	return &ssm.GetParameterOutput{Parameter: &types.Parameter{Value: aws.String(value)}}, nil
+}
+
+type fakeSecretsManager struct {
+	secrets map[string]string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(_ context.Context,
+	params *secretsmanager.GetSecretValueInput,
+	_ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	value, ok := f.secrets[aws.ToString(params.SecretId)]
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func newFakeRegistry(t *testing.T, ssmClient *fakeSSM) *Registry {
+	baseDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(baseDir, "secrets"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(baseDir, "secrets", "token"),
+		[]byte("file-token\n"), 0o600))
+
+	return NewRegistry(
+		NewSSMResolver(func(context.Context) (SSMGetParameterAPI, error) {
+			return ssmClient, nil
+		}),
+		NewSecretsManagerResolver(func(context.Context) (SecretsManagerGetSecretValueAPI, error) {
+			return &fakeSecretsManager{secrets: map[string]string{
+				"prod/api":   `{"key":"api-key","port":8080}`,
+				"prod/plain": "plain-secret",
+			}}, nil
+		}),
+		&FileResolver{BaseDir: baseDir},
+		&EnvResolver{Lookup: func(key string) (string, bool) {
+			if key == "OTHER_VAR" {
+				return "other-value", true
+			}
+			return "", false
+		}},
+	)
+}
+
+func TestRegistryParse(t *testing.T) {
+	r := newFakeRegistry(t, &fakeSSM{})
+
+	ref, ok := r.Parse("secretsmanager://prod/api#key")
+	assert.True(t, ok)
+	assert.Equal(t, Reference{Raw: "secretsmanager://prod/api#key", Scheme: "secretsmanager",
+		Path: "prod/api", Field: "key"}, ref)
+
+	ref, ok = r.Parse("ssm:///app/db/password")
+	assert.True(t, ok)
+	assert.Equal(t, "/app/db/password", ref.Path)
+
+	assert.False(t, r.IsReference("https://example.com"), "Unregistered schemes aren't references")
+	assert.False(t, r.IsReference("plain-value"))
+}
+
+func TestRegistryResolve(t *testing.T) {
+	ssmClient := &fakeSSM{params: map[string]string{"/app/db/password": "db-password"}}
+	r := newFakeRegistry(t, ssmClient)
+
+	tests := map[string]string{
+		"ssm:///app/db/password":         "db-password",
+		"secretsmanager://prod/api#key":  "api-key",
+		"secretsmanager://prod/api#port": "8080",
+		"secretsmanager://prod/plain":    "plain-secret",
+		"file://./secrets/token":         "file-token",
+		"env://OTHER_VAR":                "other-value",
+	}
+
+	for ref, expected := range tests {
+		value, err := r.Resolve(context.Background(), ref)
+		assert.NoError(t, err, ref)
+		assert.Equal(t, expected, value, ref)
+	}
+
+	for _, ref := range []string{"ssm:///missing", "secretsmanager://prod/plain#key",
+		"secretsmanager://prod/api#missing", "file://./missing", "env://MISSING", "env://"} {
+		_, err := r.Resolve(context.Background(), ref)
+		assert.Error(t, err, ref)
+	}
+}
+
+func TestRegistryResolveEnvVars(t *testing.T) {
+	ssmClient := &fakeSSM{params: map[string]string{"/app/db/password": "db-password"}}
+	r := newFakeRegistry(t, ssmClient)
+
+	resolved, err := r.ResolveEnvVars(context.Background(), map[string]string{
+		"DB_PASSWORD":       "ssm:///app/db/password",
+		"DB_PASSWORD_AGAIN": "ssm:///app/db/password",
+		"STAGE":             "dev",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"ssm:///app/db/password": "db-password"}, resolved,
+		"Only the references should be resolved")
+	assert.Equal(t, 1, ssmClient.calls, "Each reference should be resolved once")
+
+	_, err = r.ResolveEnvVars(context.Background(), map[string]string{"API_KEY": "ssm:///missing"})
+	assert.ErrorContains(t, err, "API_KEY")
+}
+
+func TestLazyClientIsOnlyBuiltWhenUsed(t *testing.T) {
+	built := false
+	r := NewRegistry(NewSSMResolver(func(context.Context) (SSMGetParameterAPI, error) {
+		built = true
+		return nil, errors.New("no credentials")
+	}), &EnvResolver{Lookup: func(string) (string, bool) { return "v", true }})
+
+	_, err := r.Resolve(context.Background(), "env://VAR")
+	assert.NoError(t, err)
+	assert.False(t, built, "The SSM client shouldn't be built without 'ssm://' references")
+
+	_, err = r.Resolve(context.Background(), "ssm:///app/param")
+	assert.ErrorContains(t, err, "no credentials")
+}
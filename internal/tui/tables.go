@@ -0,0 +1,12 @@
+package tui
+
+import (
+	"github.com/pterm/pterm"
+)
+
+// ShowTable renders the rows as a table, being the first row the header.
+func ShowTable(rows [][]string) {
+	pterm.Println()
+	_ = pterm.DefaultTable.WithHasHeader().WithBoxed().WithData(rows).Render()
+	pterm.Println()
+}
@@ -1,19 +1,20 @@
 package config
 
 type PipelineOptions struct {
-	WorkDir               string
-	WorkDirPath           string
-	MountDir              string
-	MountDirPath          string
-	TargetDir             string
-	TargetDirPath         string
-	TaskName              string
-	EnvVarsDotEnvFilePath string
-	EnvVarsToScanAndSet   []string
-	EnvVarsToScanByPrefix []string
-	EnvKeyValuePairsToSet map[string]string
-	EnvVarsFromDotEnvFile map[string]string
-	EnvVarsAWSKeysToScan  map[string]string
+	WorkDir                string
+	WorkDirPath            string
+	MountDir               string
+	MountDirPath           string
+	TargetDir              string
+	TargetDirPath          string
+	TaskName               string
+	EnvVarsDotEnvFilePaths []string
+	Environment            string
+	EnvVarsToScanAndSet    []string
+	EnvVarsToScanByPrefix  []string
+	EnvKeyValuePairsToSet  map[string]string
+	EnvVarsFromDotEnvFile  map[string]string
+	EnvVarsAWSKeysToScan   map[string]string
 	// Automatic discovery of environment variables, for well-known use cases.
 	IsAWSEnvVarKeysToScanEnabled   bool
 	IsTerraformVarsScanEnabled     bool
@@ -1,8 +1,8 @@
 package config
 
 import (
+	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/tui"
-	"github.com/spf13/viper"
 )
 
 type CLIGlobalArgs struct {
@@ -16,72 +16,106 @@ type CLIGlobalArgs struct {
 	ScanAWSKeys                    bool
 	ScanTerraformVars              bool
 	ScanEnvVarsWithPrefix          []string
-	DotEnvFile                     string
+	DotEnvFiles                    []string
+	Environment                    string
 	ScanAllEnvVars                 bool
+	EnvAllow                       []string
+	EnvDeny                        []string
 	CustomCommands                 []string
 	InitDaggerWithWorkDirByDefault bool
 	RunInVendor                    bool
+	OnlyIfChanged                  bool
+	Since                          string
+	ChangedPaths                   []string
+	UploadInclude                  []string
+	UploadExclude                  []string
+	Outputs                        []string
+	ArtifactsDir                   string
+	CacheNamespace                 string
+	CacheLockFileKey               bool
+	NoCacheVolumes                 bool
+	LockMode                       string
+	LogsDir                        string
+	ShowOutput                     bool
+	StderrTailLines                int
+	TaskPolicy                     TaskPolicy
+	DryRun                         bool
+	DryRunFormat                   string
+	Profile                        string
 }
 
 func GetCLIGlobalArgs() (CLIGlobalArgs, error) {
-	cfg := Cfg{}
-	defaultEmptyMap := make(map[string]interface{})
-
-	// 'set-env' option
-	var setEnvValue map[string]interface{}
-	keValuePairsFromViper, err := cfg.GetStringInterfaceMapFromViper("set-env")
+	globalCfg, err := GetGlobalConfig()
 	if err != nil {
-		setEnvValue = defaultEmptyMap
-	} else {
-		setEnvValue = keValuePairsFromViper.Value.(map[string]interface{})
+		return CLIGlobalArgs{}, err
 	}
 
-	// 'scan-env' option
-	var scanEnvVarKeys []string
-	scanEnvVarKeysFromViper, err := cfg.GetStringSliceFromViper("scan-env")
-	if err != nil {
-		scanEnvVarKeys = []string{}
-	} else {
-		scanEnvVarKeys = scanEnvVarKeysFromViper.Value.([]string)
+	// 'set-env' option, also kept untyped for the callers that merge it.
+	setEnvValue := make(map[string]interface{})
+	envKeyValuePairToSetString := make(map[string]string)
+	for k, v := range globalCfg.SetEnv {
+		setEnvValue[k] = v
+		envKeyValuePairToSetString[k] = v
 	}
 
-	// Scan env vars with prefix
-	var scanEnvVarsWithPrefix []string
-	scanEnvVarsWithPrefixFromViper, err := cfg.GetStringSliceFromViper("scan-env-vars-prefix")
-	if err != nil {
-		scanEnvVarsWithPrefix = []string{}
-	} else {
-		scanEnvVarsWithPrefix = scanEnvVarsWithPrefixFromViper.Value.([]string)
-	}
+	// Custom commands, from both '--commands' and '--custom-cmds'.
+	customCommands := append(append([]string{}, globalCfg.Commands...), globalCfg.CustomCmds...)
 
-	envKeyValuePairToSetString := make(map[string]string)
-	if len(setEnvValue) > 0 {
-		for k, v := range setEnvValue {
-			if v != nil {
-				envKeyValuePairToSetString[k] = v.(string)
-			}
-		}
+	args := CLIGlobalArgs{
+		WorkingDir:                     globalCfg.WorkDir,
+		MountDir:                       globalCfg.MountDir,
+		TargetDir:                      globalCfg.TargetDir,
+		TaskName:                       globalCfg.Task,
+		ScanEnvVarKeys:                 globalCfg.ScanEnv,
+		EnvKeyValuePairsToSet:          setEnvValue,
+		EnvKeyValuePairsToSetString:    envKeyValuePairToSetString,
+		ScanAWSKeys:                    globalCfg.ScanAWSKeys,
+		ScanTerraformVars:              globalCfg.ScanTerraformVars,
+		ScanEnvVarsWithPrefix:          globalCfg.ScanEnvVarsPrefix,
+		ScanAllEnvVars:                 globalCfg.ScanAllEnvVars,
+		EnvAllow:                       globalCfg.EnvAllow,
+		EnvDeny:                        globalCfg.EnvDeny,
+		DotEnvFiles:                    globalCfg.DotEnvFiles,
+		Environment:                    globalCfg.Environment,
+		CustomCommands:                 customCommands,
+		InitDaggerWithWorkDirByDefault: globalCfg.InitDaggerWithWorkDir,
+		RunInVendor:                    globalCfg.RunInVendor,
+		OnlyIfChanged:                  globalCfg.OnlyIfChanged,
+		Since:                          globalCfg.Since,
+		ChangedPaths:                   globalCfg.ChangedPaths,
+		UploadInclude:                  globalCfg.Include,
+		UploadExclude:                  globalCfg.Exclude,
+		Outputs:                        globalCfg.Output,
+		ArtifactsDir:                   globalCfg.ArtifactsDir,
+		CacheNamespace:                 globalCfg.CacheNamespace,
+		CacheLockFileKey:               globalCfg.CacheLockFileKey,
+		NoCacheVolumes:                 globalCfg.NoCacheVolumes,
+		LockMode:                       globalCfg.LockMode,
+		LogsDir:                        globalCfg.LogsDir,
+		ShowOutput:                     globalCfg.ShowOutput,
+		StderrTailLines:                globalCfg.StderrTail,
+		DryRun:                         globalCfg.DryRun,
+		DryRunFormat:                   globalCfg.DryRunFormat,
+		Profile:                        globalCfg.Profile,
 	}
 
-	args := CLIGlobalArgs{
-		WorkingDir:                  viper.GetString("work-dir"),
-		MountDir:                    viper.GetString("mount-dir"),
-		TargetDir:                   viper.GetString("target-dir"),
-		TaskName:                    viper.GetString("task"),
-		ScanEnvVarKeys:              scanEnvVarKeys,
-		EnvKeyValuePairsToSet:       setEnvValue,
-		EnvKeyValuePairsToSetString: envKeyValuePairToSetString,
-		ScanAWSKeys:                 viper.GetBool("scan-aws-keys"),
-		ScanTerraformVars:           viper.GetBool("scan-terraform-vars"),
-		ScanEnvVarsWithPrefix:       scanEnvVarsWithPrefix,
-		ScanAllEnvVars:              viper.GetBool("scan-all-env-vars"),
-		DotEnvFile:                  viper.GetString("dot-env-file"),
-		//CustomCommands:                 viper.Get("custom-cmds").([]string),
-		CustomCommands:                 []string{},
-		InitDaggerWithWorkDirByDefault: viper.GetBool("init-dagger-with-workdir"),
-		RunInVendor:                    viper.GetBool("run-in-vendor"),
+	// Execution policy of the task, the config of the task winning over the flags. The settings
+	// of the task in its job are applied once the job is known.
+	taskPolicy, err := GetTaskPolicy("", args.TaskName, TaskPolicy{
+		Timeout: globalCfg.Timeout,
+		Retry: common.RetryPolicy{
+			MaxAttempts: globalCfg.Retries,
+			Backoff:     globalCfg.RetryBackoff,
+			MaxBackoff:  common.DefaultRetryMaxBackoff,
+			RetryOn:     globalCfg.RetryOn,
+		},
+	})
+	if err != nil {
+		return CLIGlobalArgs{}, err
 	}
 
+	args.TaskPolicy = taskPolicy
+
 	return args, nil
 }
 
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigFileNames are the names of the config files, in the home dir (the user-level one) and in
+// the project (discovered from the work dir up to the git root).
+var ConfigFileNames = []string{".stiletto.yaml", ".stiletto.yml"}
+
+// LoadedConfig describes the config files merged into the config, and the profile applied.
+type LoadedConfig struct {
+	Files   []string // In the order they're merged, being the later ones the winners.
+	Profile string
+}
+
+var (
+	loadedConfig  LoadedConfig
+	loadConfigErr error
+)
+
+// GetConfigFiles returns the config files to merge: the user-level one (in the home dir), and
+// the project ones, from the git root down to the work dir. The file passed explicitly (E.g.:
+// with --config) replaces the project ones.
+func GetConfigFiles(workDir, explicitFile string) ([]string, error) {
+	var files []string
+
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range ConfigFileNames {
+			path := filepath.Join(home, name)
+			if filesystem.FileExist(path) == nil {
+				files = append(files, path)
+				break
+			}
+		}
+	}
+
+	if explicitFile != "" {
+		path, err := filepath.Abs(explicitFile)
+		if err != nil {
+			return nil, errors.NewPipelineConfigurationError(fmt.Sprintf("Invalid config "+
+				"file %s", explicitFile), err)
+		}
+
+		if err := filesystem.FileExist(path); err != nil {
+			return nil, errors.NewPipelineConfigurationError(fmt.Sprintf("The config file %s "+
+				"doesn't exist", explicitFile), err)
+		}
+
+		return appendConfigFile(files, path), nil
+	}
+
+	if workDir == "" {
+		workDir = "."
+	}
+
+	projectFiles, err := filesystem.FindFilesUpToGitRoot(workDir, ConfigFileNames)
+	if err != nil {
+		return nil, errors.NewPipelineConfigurationError("Failed to look for the config files "+
+			"of the project", err)
+	}
+
+	for _, path := range projectFiles {
+		files = appendConfigFile(files, path)
+	}
+
+	return files, nil
+}
+
+// appendConfigFile skips the files already merged. E.g.: the user-level one, if the project is
+// the home dir.
+func appendConfigFile(files []string, path string) []string {
+	if common.IsStringInSlice(path, files) {
+		return files
+	}
+
+	return append(files, path)
+}
+
+// LoadConfigFiles merges the config files into viper in order, and then applies the profile
+// (the one passed, or the 'profile' set in the config files). Nothing is written.
+func LoadConfigFiles(files []string, profile string) error {
+	loadedConfig = LoadedConfig{}
+	loadConfigErr = loadConfigFiles(files, profile)
+
+	return loadConfigErr
+}
+
+func loadConfigFiles(files []string, profile string) error {
+	for _, path := range files {
+		// The files without an extension are YAML.
+		configType := strings.TrimPrefix(filepath.Ext(path), ".")
+		if configType == "" {
+			configType = "yaml"
+		}
+
+		viper.SetConfigType(configType)
+		viper.SetConfigFile(path)
+
+		if err := viper.MergeInConfig(); err != nil {
+			return errors.NewPipelineConfigurationError(fmt.Sprintf("Failed to read the config "+
+				"file %s", path), err)
+		}
+
+		loadedConfig.Files = append(loadedConfig.Files, path)
+	}
+
+	if profile == "" {
+		profile = viper.GetString("profile")
+	}
+
+	return ApplyProfile(profile)
+}
+
+// GetProfiles returns the names of the profiles declared in the config files.
+func GetProfiles() []string {
+	var profiles []string
+	for name := range viper.GetStringMap("profiles") {
+		profiles = append(profiles, name)
+	}
+
+	sort.Strings(profiles)
+
+	return profiles
+}
+
+// ApplyProfile merges the settings of the profile ('profiles.<name>') over the config files, so
+// they're the defaults of the flags (E.g.: 'ecr-registry', 'ecs-cluster' or 'scan-aws-keys').
+// The flags passed still win.
+func ApplyProfile(profile string) error {
+	profile = common.NormaliseStringLower(strings.TrimSpace(profile))
+	if profile == "" {
+		return nil
+	}
+
+	profiles := GetProfiles()
+	if !common.IsStringInSlice(profile, profiles) {
+		available := "none is declared in the 'profiles' section"
+		if len(profiles) > 0 {
+			available = fmt.Sprintf("the available ones are: %s", strings.Join(profiles, ", "))
+		}
+
+		return errors.NewPipelineConfigurationError(fmt.Sprintf("Unknown profile '%s', %s",
+			profile, available), nil)
+	}
+
+	settings := viper.GetStringMap(fmt.Sprintf("profiles.%s", profile))
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return errors.NewPipelineConfigurationError(fmt.Sprintf("Failed to apply the profile "+
+			"'%s'", profile), err)
+	}
+
+	loadedConfig.Profile = profile
+
+	return nil
+}
+
+// GetLoadedConfig returns the config files merged (and the profile applied), along with the error
+// that stopped the loading, if any.
+func GetLoadedConfig() (LoadedConfig, error) {
+	return loadedConfig, loadConfigErr
+}
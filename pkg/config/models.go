@@ -0,0 +1,235 @@
+package config
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"time"
+)
+
+// The typed configs of the commands. Each field is a key of the config files (and the flag,
+// or env var, that sets it), read from its 'mapstructure' tag; the 'description' and 'enum'
+// tags are used by the validation and the JSON Schema.
+
+// GlobalConfig is the config shared by all the commands.
+type GlobalConfig struct {
+	Task                  string            `mapstructure:"task" description:"The task to run. E.g.: push, deploy, plan."`
+	WorkDir               string            `mapstructure:"work-dir" description:"The working directory, mounted into the containers."`
+	TargetDir             string            `mapstructure:"target-dir" description:"The directory (relative to the work dir) where the task runs."`
+	MountDir              string            `mapstructure:"mount-dir" description:"The directory (relative to the work dir) to mount."`
+	ScanEnv               []string          `mapstructure:"scan-env" description:"Host env vars to scan and pass to the containers."`
+	SetEnv                map[string]string `mapstructure:"set-env" description:"Env vars to set in the containers."`
+	Commands              []string          `mapstructure:"commands" description:"Custom commands to run."`
+	CustomCmds            []string          `mapstructure:"custom-cmds" description:"Custom commands to run."`
+	ScanAWSKeys           bool              `mapstructure:"scan-aws-keys" description:"Scan the AWS credentials from the host."`
+	ScanTerraformVars     bool              `mapstructure:"scan-terraform-vars" description:"Scan the TF_VAR_* env vars from the host."`
+	ScanEnvVarsPrefix     []string          `mapstructure:"scan-env-vars-prefix" description:"Prefixes of the host env vars to scan."`
+	ScanAllEnvVars        bool              `mapstructure:"scan-all-env-vars" description:"Scan all the host env vars (see env-allow, env-deny)."`
+	EnvAllow              []string          `mapstructure:"env-allow" description:"Globs of the host env vars passed, with scan-all-env-vars."`
+	EnvDeny               []string          `mapstructure:"env-deny" description:"Globs of the host env vars excluded, with scan-all-env-vars."`
+	DotEnvFiles           []string          `mapstructure:"dot-env-file" description:"The .env files to load, the later ones win."`
+	Environment           string            `mapstructure:"environment" description:"The environment whose .env files are loaded. E.g.: dev."`
+	EnvPrecedence         []string          `mapstructure:"env-precedence" description:"The order in which the env var sources win."`
+	InitDaggerWithWorkDir bool              `mapstructure:"init-dagger-with-workdir" description:"Initialize the dagger client with the work dir."`
+	RunInVendor           bool              `mapstructure:"run-in-vendor" description:"Running in a vendor automation (E.g.: GitHub actions)."`
+	OnlyIfChanged         bool              `mapstructure:"only-if-changed" description:"Run the task only if the target dir changed."`
+	Since                 string            `mapstructure:"since" description:"The git ref the changes are compared against."`
+	ChangedPaths          []string          `mapstructure:"changed-paths" description:"Extra paths whose changes make the task run."`
+	Include               []string          `mapstructure:"include" description:"Globs of the files uploaded from the host dirs."`
+	Exclude               []string          `mapstructure:"exclude" description:"Globs of the files not uploaded from the host dirs."`
+	Output                []string          `mapstructure:"output" description:"Files or dirs exported from the containers into the artifacts dir."`
+	ArtifactsDir          string            `mapstructure:"artifacts-dir" description:"The host dir where the outputs are exported."`
+	CacheNamespace        string            `mapstructure:"cache-namespace" description:"The namespace of the cache volumes."`
+	CacheLockFileKey      bool              `mapstructure:"cache-lockfile-key" description:"Key the cache volumes by the lock files of the project."`
+	NoCacheVolumes        bool              `mapstructure:"no-cache-volumes" description:"Don't mount the cache volumes."`
+	LockMode              string            `mapstructure:"lock-mode" description:"How the stacks lock file is enforced." enum:"warn,strict,ignore"`
+	LogsDir               string            `mapstructure:"logs-dir" description:"The host dir where the output of each command is logged."`
+	ShowOutput            bool              `mapstructure:"show-output" description:"Print the output of each command once it completes."`
+	StderrTail            int               `mapstructure:"stderr-tail" description:"Lines of stderr shown when a command fails."`
+	Timeout               time.Duration     `mapstructure:"timeout" description:"Timeout of the task. E.g.: 10m."`
+	Retries               int               `mapstructure:"retries" description:"Attempts of the task."`
+	RetryBackoff          time.Duration     `mapstructure:"retry-backoff" description:"Time between the attempts of the task. E.g.: 5s."`
+	RetryOn               []string          `mapstructure:"retry-on" description:"Patterns of the errors that are retried."`
+	DryRun                bool              `mapstructure:"dry-run" description:"Explain the pipeline, without running it."`
+	DryRunFormat          string            `mapstructure:"dry-run-format" description:"Format of the dry-run report." enum:"text,json"`
+	Profile               string            `mapstructure:"profile" description:"The profile (in the 'profiles' section) to apply."`
+}
+
+// AWSConfig is the config shared by the 'aws' commands.
+type AWSConfig struct {
+	AccessKeyID           string `mapstructure:"aws-creds-access-key-id" description:"The AWS access key ID."`
+	SecretKey             string `mapstructure:"aws-creds-secret-key" description:"The AWS secret access key."`
+	Region                string `mapstructure:"aws-creds-region" description:"The AWS region."`
+	SessionToken          string `mapstructure:"aws-session-token" description:"The AWS session token."`
+	Profile               string `mapstructure:"aws-profile" description:"The AWS profile (shared config files)."`
+	AssumeRoleARN         string `mapstructure:"assume-role-arn" description:"The ARN of the IAM role to assume."`
+	AssumeRoleExternalID  string `mapstructure:"assume-role-external-id" description:"The external ID passed while assuming the role."`
+	AssumeRoleSessionName string `mapstructure:"assume-role-session-name" description:"The session name of the assumed role."`
+	WebIdentityTokenFile  string `mapstructure:"web-identity-token-file" description:"The OIDC token file, for web identity."`
+	GitHubOIDC            bool   `mapstructure:"github-oidc" description:"Assume the role with the GitHub Actions OIDC token."`
+	EndpointURL           string `mapstructure:"aws-endpoint-url" description:"A custom AWS endpoint. E.g.: localstack."`
+}
+
+// AWSECRConfig is the config of the 'aws ecr' command.
+type AWSECRConfig struct {
+	Repository        string `mapstructure:"ecr-repository" description:"The name of the ECR repository."`
+	Registry          string `mapstructure:"ecr-registry" description:"The name of the ECR registry."`
+	Tag               string `mapstructure:"tag" description:"The tag of the image pushed. The default is 'latest'."`
+	Dockerfile        string `mapstructure:"dockerfile" description:"The name of the Dockerfile."`
+	GenerateRandomTag bool   `mapstructure:"generate-random-tag" description:"Push the image with a random tag."`
+}
+
+// AWSECSConfig is the config of the 'aws ecs' command.
+type AWSECSConfig struct {
+	Service              string            `mapstructure:"ecs-service" description:"The name of the ECS service deployed."`
+	Cluster              string            `mapstructure:"ecs-cluster" description:"The name of the ECS cluster."`
+	TaskDefinition       string            `mapstructure:"task-definition" description:"The name of the ECS task definition."`
+	ImageURL             string            `mapstructure:"image-url" description:"The URL of the image deployed."`
+	ReleaseVersion       string            `mapstructure:"release-version" description:"The tag of the image deployed. The default is 'latest'."`
+	SetEnvFromHost       bool              `mapstructure:"set-env-from-host" description:"Set all the host env vars in the container definition."`
+	SetEnvFromKeys       []string          `mapstructure:"set-env-from-keys" description:"Host env vars set in the container definition."`
+	SetEnvVarsWithPrefix string            `mapstructure:"set-env-vars-with-prefix" description:"Prefix of the host env vars set in the container definition."`
+	SetEnvVarsCustom     map[string]string `mapstructure:"set-env-vars-custom" description:"Env vars set in the container definition."`
+}
+
+// AWSLambdaConfig is the config of the 'aws lambda' command.
+type AWSLambdaConfig struct {
+	FunctionName string `mapstructure:"lambda-function-name" description:"The name (or ARN) of the lambda function."`
+	Runtime      string `mapstructure:"lambda-runtime" description:"The lambda runtime. E.g.: python3.10, nodejs18.x."`
+	ZipFile      string `mapstructure:"lambda-zip-file" description:"The host path of the zip package."`
+	S3Bucket     string `mapstructure:"lambda-s3-bucket" description:"The S3 bucket where the zip package is published."`
+	S3Key        string `mapstructure:"lambda-s3-key" description:"The S3 key of the zip package."`
+	ImageURI     string `mapstructure:"lambda-image-uri" description:"The ECR image URI, for container image functions."`
+	Alias        string `mapstructure:"lambda-alias" description:"The alias moved to the published version."`
+	Description  string `mapstructure:"lambda-description" description:"The description of the published version."`
+}
+
+// AWSS3Config is the config of the 'aws s3' command.
+type AWSS3Config struct {
+	Bucket                      string   `mapstructure:"s3-bucket" description:"The name of the S3 bucket to sync into."`
+	Prefix                      string   `mapstructure:"s3-prefix" description:"The key prefix (folder) in the bucket."`
+	SourceDir                   string   `mapstructure:"s3-source-dir" description:"The dir (relative to the target dir) to sync."`
+	BuildCmd                    string   `mapstructure:"s3-build-cmd" description:"A command run before the sync. E.g.: npm run build."`
+	BuildImage                  string   `mapstructure:"s3-build-image" description:"The image of the container of the build command."`
+	CacheControl                []string `mapstructure:"s3-cache-control" description:"Cache-Control rules, in the form 'glob=value'."`
+	Delete                      bool     `mapstructure:"s3-delete" description:"Delete the objects that aren't in the source dir."`
+	DryRun                      bool     `mapstructure:"s3-dry-run" description:"List the changes, without applying them."`
+	Concurrency                 int      `mapstructure:"s3-concurrency" description:"The number of concurrent uploads."`
+	CloudFrontDistributionID    string   `mapstructure:"cloudfront-distribution-id" description:"The CloudFront distribution to invalidate."`
+	CloudFrontInvalidationPaths []string `mapstructure:"cloudfront-invalidation-paths" description:"The paths to invalidate."`
+	CloudFrontWait              bool     `mapstructure:"cloudfront-wait" description:"Wait until the invalidation is completed."`
+}
+
+// InfraConfig is the config shared by the 'infra' commands.
+type InfraConfig struct {
+	AWSAccessKeyID     string `mapstructure:"aws-access-key-id" description:"The AWS access key ID."`
+	AWSSecretAccessKey string `mapstructure:"aws-secret-access-key" description:"The AWS secret access key."`
+	AWSRegion          string `mapstructure:"aws-region" description:"The AWS region."`
+	Terraform          bool   `mapstructure:"terraform" description:"Use Terraform."`
+	Terragrunt         bool   `mapstructure:"terragrunt" description:"Use Terragrunt."`
+	TargetModule       string `mapstructure:"target-module" description:"The module (relative to the work dir) to run on."`
+}
+
+// InfraTerraGruntConfig is the config of the 'infra terragrunt' command.
+type InfraTerraGruntConfig struct {
+	TargetModule string   `mapstructure:"target-module" description:"The module (relative to the work dir) to run on."`
+	Plan         bool     `mapstructure:"plan" description:"Run a plan."`
+	PlanAll      bool     `mapstructure:"plan-all" description:"Run a plan-all."`
+	Apply        bool     `mapstructure:"apply" description:"Run an apply."`
+	ApplyAll     bool     `mapstructure:"apply-all" description:"Run an apply-all."`
+	Destroy      bool     `mapstructure:"destroy" description:"Run a destroy."`
+	DestroyAll   bool     `mapstructure:"destroy-all" description:"Run a destroy-all."`
+	Commands     []string `mapstructure:"tg-commands" description:"Custom terragrunt commands."`
+}
+
+// JobConfig is the config of a job, in the config file ('jobs.<job>').
+type JobConfig struct {
+	EnvPrecedence []string              `mapstructure:"env-precedence" description:"The order in which the env var sources win, for the job."`
+	Tasks         map[string]TaskConfig `mapstructure:"tasks" description:"The execution policy (timeout, retry) of each task of the job."`
+}
+
+// ConfigModel is the typed config of a command.
+type ConfigModel struct {
+	Command string
+	Model   interface{} // A pointer to the typed config. E.g.: &AWSECSConfig{}.
+}
+
+// GetConfigModels returns the typed configs of all the commands.
+func GetConfigModels() []ConfigModel {
+	return []ConfigModel{
+		{Command: "", Model: &GlobalConfig{}},
+		{Command: "aws", Model: &AWSConfig{}},
+		{Command: "aws ecr", Model: &AWSECRConfig{}},
+		{Command: "aws ecs", Model: &AWSECSConfig{}},
+		{Command: "aws lambda", Model: &AWSLambdaConfig{}},
+		{Command: "aws s3", Model: &AWSS3Config{}},
+		{Command: "infra", Model: &InfraConfig{}},
+		{Command: "infra terragrunt", Model: &InfraTerraGruntConfig{}},
+	}
+}
+
+// GetGlobalConfig returns the config shared by all the commands.
+func GetGlobalConfig() (GlobalConfig, error) {
+	var cfg GlobalConfig
+	err := GetTypedConfig(&cfg)
+
+	return cfg, err
+}
+
+// GetJobConfig returns the config of a job, in the config file ('jobs.<job>').
+func GetJobConfig(jobName string) (JobConfig, error) {
+	var cfg JobConfig
+	if problems := decodeConfig(fmt.Sprintf("jobs.%s.", common.NormaliseStringLower(jobName)),
+		&cfg); len(problems) > 0 {
+		return cfg, getConfigProblemsErr(problems)
+	}
+
+	return cfg, nil
+}
+
+// GetAWSConfig returns the config shared by the 'aws' commands.
+func GetAWSConfig() (AWSConfig, error) {
+	var cfg AWSConfig
+	err := GetTypedConfig(&cfg)
+
+	return cfg, err
+}
+
+// GetAWSECRConfig returns the config of the 'aws ecr' command.
+func GetAWSECRConfig() (AWSECRConfig, error) {
+	var cfg AWSECRConfig
+	err := GetTypedConfig(&cfg)
+
+	return cfg, err
+}
+
+// GetAWSECSConfig returns the config of the 'aws ecs' command.
+func GetAWSECSConfig() (AWSECSConfig, error) {
+	var cfg AWSECSConfig
+	err := GetTypedConfig(&cfg)
+
+	return cfg, err
+}
+
+// GetAWSLambdaConfig returns the config of the 'aws lambda' command.
+func GetAWSLambdaConfig() (AWSLambdaConfig, error) {
+	var cfg AWSLambdaConfig
+	err := GetTypedConfig(&cfg)
+
+	return cfg, err
+}
+
+// GetAWSS3Config returns the config of the 'aws s3' command.
+func GetAWSS3Config() (AWSS3Config, error) {
+	var cfg AWSS3Config
+	err := GetTypedConfig(&cfg)
+
+	return cfg, err
+}
+
+// GetInfraTerraGruntConfig returns the config of the 'infra terragrunt' command.
+func GetInfraTerraGruntConfig() (InfraTerraGruntConfig, error) {
+	var cfg InfraTerraGruntConfig
+	err := GetTypedConfig(&cfg)
+
+	return cfg, err
+}
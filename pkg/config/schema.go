@@ -0,0 +1,374 @@
+package config
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/spf13/viper"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSONSchemaDraft is the version of the JSON Schema of the config files.
+const JSONSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// durationPattern matches the durations, as parsed by time.ParseDuration. E.g.: 1h30m, 10s, 0.
+const durationPattern = `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+)$`
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// GetTypedConfig decodes the typed config (a pointer to it. E.g.: &AWSECSConfig{}) from the
+// config files, the flags and the env vars. All the keys with a wrong type are reported.
+func GetTypedConfig(model interface{}) error {
+	if problems := decodeConfig("", model); len(problems) > 0 {
+		return getConfigProblemsErr(problems)
+	}
+
+	return nil
+}
+
+// ValidateConfig validates the config (the config files, the profile, the flags and the env
+// vars merged) against the typed configs of all the commands, along with each profile and
+// the 'stacks', 'tasks' and 'jobs' sections. All the problems are reported at once.
+func ValidateConfig() error {
+	problems := decodeConfigModels("")
+
+	for _, profile := range GetProfiles() {
+		problems = append(problems, decodeConfigModels(fmt.Sprintf("profiles.%s.", profile))...)
+	}
+
+	for _, name := range getSectionKeys("jobs") {
+		jobProblems := decodeConfig(fmt.Sprintf("jobs.%s.", name), &JobConfig{})
+
+		if len(jobProblems) == 0 {
+			for _, task := range getSectionKeys(fmt.Sprintf("jobs.%s.tasks", name)) {
+				jobProblems = append(jobProblems, validateTaskConfig(fmt.Sprintf("jobs.%s.tasks.%s",
+					name, task), task)...)
+			}
+		}
+
+		problems = append(problems, jobProblems...)
+	}
+
+	stackProblems := decodeSection("stacks", func() interface{} { return &StackConfig{} })
+	if len(stackProblems) == 0 {
+		if _, err := GetStacks(); err != nil {
+			stackProblems = append(stackProblems, fmt.Sprintf("stacks: %s", getProblem(err)))
+		}
+	}
+
+	problems = append(problems, stackProblems...)
+
+	for _, name := range getSectionKeys("tasks") {
+		taskProblems := decodeConfig(fmt.Sprintf("tasks.%s.", name), &TaskConfig{})
+
+		if len(taskProblems) == 0 {
+			taskProblems = validateTaskConfig(fmt.Sprintf("tasks.%s", name), name)
+		}
+
+		problems = append(problems, taskProblems...)
+	}
+
+	if len(problems) > 0 {
+		return getConfigProblemsErr(problems)
+	}
+
+	return nil
+}
+
+// validateTaskConfig checks the durations and the retry policy of the task config in the path
+// (E.g.: 'tasks.build').
+func validateTaskConfig(path, taskName string) []string {
+	policy, err := applyTaskConfig(path, TaskPolicy{})
+	if err == nil {
+		_, err = validateTaskPolicy(taskName, policy)
+	}
+
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", path, getProblem(err))}
+	}
+
+	return nil
+}
+
+func getConfigProblemsErr(problems []string) error {
+	return errors.NewPipelineConfigurationError(fmt.Sprintf("The config has %d problem(s):\n  %s",
+		len(problems), strings.Join(problems, "\n  ")), nil)
+}
+
+// getProblem returns the message of a config error, without the prefixes of the error type.
+func getProblem(err error) string {
+	cfgErr, ok := err.(*errors.PipelineConfigurationError)
+	if !ok {
+		return err.Error()
+	}
+
+	problem := strings.TrimPrefix(cfgErr.Details, "Unable to start pipeline instance ")
+	if cfgErr.Err != nil {
+		problem = fmt.Sprintf("%s: %s", problem, cfgErr.Err)
+	}
+
+	return problem
+}
+
+// getSectionKeys returns the sorted keys of a section of the config. E.g.: the tasks.
+func getSectionKeys(section string) []string {
+	var keys []string
+	for key := range viper.GetStringMap(section) {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// decodeSection decodes each entry of a section (E.g.: 'stacks.<name>') into a new typed config.
+func decodeSection(section string, newModel func() interface{}) []string {
+	var problems []string
+	for _, name := range getSectionKeys(section) {
+		path := fmt.Sprintf("%s.%s", section, name)
+		if _, isMap := viper.Get(path).(map[string]interface{}); !isMap {
+			problems = append(problems, fmt.Sprintf("%s: expected an object, got %s", path,
+				describeValue(viper.Get(path))))
+			continue
+		}
+
+		problems = append(problems, decodeConfig(path+".", newModel())...)
+	}
+
+	return problems
+}
+
+// decodeConfigModels decodes the typed configs of all the commands. A key shared by several
+// commands (E.g.: 'target-module') is reported once.
+func decodeConfigModels(path string) []string {
+	var problems []string
+	for _, m := range GetConfigModels() {
+		for _, problem := range decodeConfig(path, m.Model) {
+			if !common.IsStringInSlice(problem, problems) {
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	return problems
+}
+
+// decodeConfig decodes each field of the typed config (a pointer to it) from its key, prefixed
+// by the path (E.g.: 'tasks.build.'). The problems are returned with the path of the key.
+func decodeConfig(path string, model interface{}) []string {
+	value := reflect.ValueOf(model).Elem()
+
+	var problems []string
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+
+		raw := viper.Get(path + key)
+		if raw == nil {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if _, isMap := raw.(map[string]interface{}); isMap && field.Type.Kind() == reflect.Struct {
+			problems = append(problems, decodeConfig(path+key+".",
+				fieldValue.Addr().Interface())...)
+			continue
+		}
+
+		// Each entry of a section (E.g.: 'jobs.<job>.tasks') is decoded on its own.
+		if _, isMap := raw.(map[string]interface{}); isMap && field.Type.Kind() == reflect.Map &&
+			field.Type.Elem().Kind() == reflect.Struct {
+			elemType := field.Type.Elem()
+			problems = append(problems, decodeSection(path+key, func() interface{} {
+				return reflect.New(elemType).Interface()
+			})...)
+			continue
+		}
+
+		if err := viper.UnmarshalKey(path+key, fieldValue.Addr().Interface()); err != nil {
+			fieldValue.Set(reflect.Zero(field.Type))
+			problems = append(problems, fmt.Sprintf("%s%s: expected %s, got %s%s", path, key,
+				describeType(field.Type), describeValue(raw), getValueSource(path+key)))
+
+			continue
+		}
+
+		enum := getEnum(field)
+		if fieldValue.Kind() == reflect.String && fieldValue.String() != "" && len(enum) > 0 &&
+			!common.IsStringInSlice(fieldValue.String(), enum) {
+			problems = append(problems, fmt.Sprintf("%s%s: '%s' should be one of: %s%s", path,
+				key, fieldValue.String(), strings.Join(enum, ", "), getValueSource(path+key)))
+		}
+	}
+
+	return problems
+}
+
+// getValueSource explains where the value comes from, if it's not the config files (since the
+// env vars are read for every key).
+func getValueSource(key string) string {
+	if viper.InConfig(key) {
+		return ""
+	}
+
+	envVar := strings.ToUpper(key)
+	if _, ok := os.LookupEnv(envVar); ok {
+		return fmt.Sprintf(" (from the env var %s)", envVar)
+	}
+
+	return ""
+}
+
+func getEnum(field reflect.StructField) []string {
+	enum := field.Tag.Get("enum")
+	if enum == "" {
+		return nil
+	}
+
+	return strings.Split(enum, ",")
+}
+
+func describeType(t reflect.Type) string {
+	if t == durationType {
+		return "a duration (E.g.: 10m, 1h30m)"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int:
+		return "an integer"
+	case reflect.Slice:
+		return "a list of strings"
+	case reflect.Map:
+		return "a map of strings (KEY: value)"
+	default:
+		return "an object"
+	}
+}
+
+func describeValue(raw interface{}) string {
+	switch raw.(type) {
+	case []interface{}, []string:
+		return fmt.Sprintf("a list (%v)", raw)
+	case map[string]interface{}, map[string]string:
+		return "an object"
+	case string:
+		return fmt.Sprintf("'%s'", raw)
+	default:
+		return fmt.Sprintf("%v", raw)
+	}
+}
+
+// GetJSONSchema returns the JSON Schema of the config files, for the editors and the linting
+// of the config files (E.g.: in CI). The settings (the keys of the typed configs of all the
+// commands) can be set at the top level, or in a profile.
+func GetJSONSchema() map[string]interface{} {
+	settings := map[string]interface{}{}
+	for _, m := range GetConfigModels() {
+		model := getStructSchema(reflect.TypeOf(m.Model).Elem())
+		for key, schema := range model["properties"].(map[string]interface{}) {
+			if _, exists := settings[key]; !exists {
+				settings[key] = schema
+			}
+		}
+	}
+
+	settingsRef := map[string]interface{}{"$ref": "#/definitions/settings"}
+
+	return map[string]interface{}{
+		"$schema":     JSONSchemaDraft,
+		"title":       "Stiletto config file",
+		"description": "The .stiletto.yaml config files. Any flag can be set, by its name.",
+		"type":        "object",
+		"allOf":       []interface{}{settingsRef},
+		"definitions": map[string]interface{}{
+			"settings": map[string]interface{}{"type": "object", "properties": settings},
+		},
+		"properties": map[string]interface{}{
+			"profiles": map[string]interface{}{
+				"description":          "Named sets of settings, applied with --profile (or 'profile').",
+				"type":                 "object",
+				"additionalProperties": settingsRef,
+			},
+			"stacks": getSectionSchema("The stacks the jobs run on, declared or overridden.",
+				reflect.TypeOf(StackConfig{})),
+			"tasks": getSectionSchema("The execution policy (timeout, retry) of each task.",
+				reflect.TypeOf(TaskConfig{})),
+			"jobs": getSectionSchema("The settings of each job. E.g.: ecs, terragrunt.",
+				reflect.TypeOf(JobConfig{})),
+		},
+	}
+}
+
+func getSectionSchema(description string, t reflect.Type) map[string]interface{} {
+	return map[string]interface{}{
+		"description":          description,
+		"type":                 "object",
+		"additionalProperties": getStructSchema(t),
+	}
+}
+
+func getStructSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+
+		schema := getTypeSchema(field.Type)
+		if description := field.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+
+		if enum := getEnum(field); len(enum) > 0 {
+			schema["enum"] = enum
+		}
+
+		properties[key] = schema
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func getTypeSchema(t reflect.Type) map[string]interface{} {
+	// The durations can also be integers (in nanoseconds), as they're decoded by viper.
+	if t == durationType {
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "integer"},
+			map[string]interface{}{"type": "string", "pattern": durationPattern},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": getTypeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object",
+			"additionalProperties": getTypeSchema(t.Elem())}
+	case reflect.Struct:
+		return getStructSchema(t)
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
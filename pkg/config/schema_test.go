@@ -0,0 +1,107 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func getSettingsSchema(schema map[string]interface{}) map[string]interface{} {
+	definitions := schema["definitions"].(map[string]interface{})
+	settings := definitions["settings"].(map[string]interface{})
+
+	return settings["properties"].(map[string]interface{})
+}
+
+func TestValidateConfigReportsAllTheProblemsWithTheirPath(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.Set("retries", "many")
+	viper.Set("timeout", 0)
+	viper.Set("retry-backoff", "0")
+	viper.Set("profiles.ci.stderr-tail", "lots")
+	viper.Set("profiles.ci.lock-mode", "loose")
+	viper.Set("tasks.build.retry.max-attempts", "twice")
+	viper.Set("tasks.deploy.timeout", "ten minutes")
+	viper.Set("jobs.ecs.tasks.deploy.retry.backoff", "soon")
+
+	err := ValidateConfig()
+	assert.Error(t, err)
+
+	msg := err.Error()
+	assert.Contains(t, msg, "The config has 6 problem(s)")
+	assert.Contains(t, msg, "retries: expected an integer, got 'many'")
+	assert.Contains(t, msg, "profiles.ci.stderr-tail: expected an integer, got 'lots'")
+	assert.Contains(t, msg, "profiles.ci.lock-mode: 'loose' should be one of: warn, strict, ignore")
+	assert.Contains(t, msg, "tasks.build.retry.max-attempts: expected an integer, got 'twice'")
+	assert.Contains(t, msg, "tasks.deploy: Invalid 'tasks.deploy.timeout' duration")
+	assert.Contains(t, msg, "jobs.ecs.tasks.deploy: Invalid 'jobs.ecs.tasks.deploy.retry.backoff' "+
+		"duration")
+	assert.NotContains(t, msg, "\n  timeout:", "A zero duration should be valid")
+	assert.NotContains(t, msg, "retry-backoff:", "A zero duration should be valid")
+}
+
+func TestValidateConfigPassesAValidConfig(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.Set("retries", 3)
+	viper.Set("timeout", "1h30m")
+	viper.Set("profiles.ci.lock-mode", "strict")
+	viper.Set("tasks.build.timeout", "10m")
+
+	assert.NoError(t, ValidateConfig())
+}
+
+func TestGetJSONSchemaShape(t *testing.T) {
+	schema := GetJSONSchema()
+
+	assert.Equal(t, JSONSchemaDraft, schema["$schema"])
+	assert.Equal(t, "object", schema["type"])
+
+	settingsRef := map[string]interface{}{"$ref": "#/definitions/settings"}
+	assert.Equal(t, []interface{}{settingsRef}, schema["allOf"])
+
+	settings := getSettingsSchema(schema)
+
+	// The keys of the typed configs of all the commands.
+	for _, key := range []string{"task", "retries", "ecr-registry", "ecs-cluster", "s3-bucket",
+		"target-module"} {
+		assert.Contains(t, settings, key)
+	}
+
+	assert.Equal(t, map[string]interface{}{"type": "integer",
+		"description": "Attempts of the task."}, settings["retries"])
+	assert.Equal(t, []string{"warn", "strict", "ignore"},
+		settings["lock-mode"].(map[string]interface{})["enum"])
+
+	properties := schema["properties"].(map[string]interface{})
+	profiles := properties["profiles"].(map[string]interface{})
+	assert.Equal(t, settingsRef, profiles["additionalProperties"])
+
+	for _, section := range []string{"stacks", "tasks", "jobs"} {
+		sectionSchema := properties[section].(map[string]interface{})
+		entry := sectionSchema["additionalProperties"].(map[string]interface{})
+		assert.Equal(t, "object", entry["type"], section)
+		assert.Equal(t, false, entry["additionalProperties"], section)
+	}
+}
+
+func TestGetJSONSchemaDurationsMatchTheDecoding(t *testing.T) {
+	timeout := getSettingsSchema(GetJSONSchema())["timeout"].(map[string]interface{})
+
+	oneOf := timeout["oneOf"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer"}, oneOf[0])
+	assert.Equal(t, "string", oneOf[1].(map[string]interface{})["type"])
+
+	pattern := regexp.MustCompile(oneOf[1].(map[string]interface{})["pattern"].(string))
+	for _, valid := range []string{"0", "10s", "1h30m", "1.5h", ".5s", "-5s", "300ms", "2µs"} {
+		assert.True(t, pattern.MatchString(valid), valid)
+	}
+
+	for _, invalid := range []string{"", "10", "ten", "10 s", "1d", "s"} {
+		assert.False(t, pattern.MatchString(invalid), invalid)
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/spf13/viper"
+	"strings"
+)
+
+// StackConfig is a stack declared in the config file ('stacks.<name>'). It overrides the
+// built-in stack with the same name (only the fields that are set), or declares a new one.
+type StackConfig struct {
+	Image         string   `mapstructure:"image" description:"The image. E.g.: node:18-alpine."`
+	Version       string   `mapstructure:"version" description:"The version (tag) of the image."`
+	Digest        string   `mapstructure:"digest" description:"The digest the image is pinned to."`
+	Env           []string `mapstructure:"env" description:"Env vars, in the form KEY=VALUE."` // So the case is kept.
+	WorkDir       string   `mapstructure:"workdir" description:"The working dir of the containers."`
+	CacheMounts   []string `mapstructure:"cache-mounts" description:"The cache volumes. E.g.: npm, apk."`
+	PreRequisites []string `mapstructure:"prerequisites" description:"Files that the target dir should have."`
+	Commands      []string `mapstructure:"commands" description:"The default commands of the containers, each one a command line. E.g.: npm ci."`
+}
+
+// ToStackDefinition converts the stack config into the stack definition used by the jobs.
+func (s StackConfig) ToStackDefinition(name string) (daggerio.StackDefinition, error) {
+	env := map[string]string{}
+
+	for _, pair := range s.Env {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+
+		if !ok || key == "" {
+			return daggerio.StackDefinition{}, fmt.Errorf("invalid env var '%s' in the stack %s, "+
+				"it should be in the form KEY=VALUE", pair, name)
+		}
+
+		env[key] = value
+	}
+
+	// Each command is a command line, split following the shell quoting rules.
+	var commands [][]string
+	if s.Commands != nil {
+		commands = [][]string{}
+	}
+
+	for _, command := range s.Commands {
+		args, err := common.SplitCommand(command)
+		if err != nil || len(args) == 0 {
+			return daggerio.StackDefinition{}, fmt.Errorf("invalid command '%s' in the stack %s, "+
+				"it should be a command line. E.g.: npm ci", command, name)
+		}
+
+		commands = append(commands, args)
+	}
+
+	return daggerio.StackDefinition{
+		Name:          name,
+		Image:         strings.TrimSpace(s.Image),
+		Version:       strings.TrimSpace(s.Version),
+		Digest:        strings.TrimSpace(s.Digest),
+		Env:           env,
+		WorkDir:       strings.TrimSpace(s.WorkDir),
+		CacheMounts:   s.CacheMounts,
+		PreRequisites: s.PreRequisites,
+		Commands:      commands,
+	}, nil
+}
+
+// GetStacks returns the effective stacks: the built-in ones, merged with the ones declared in
+// the config file.
+func GetStacks() (map[string]daggerio.StackDefinition, error) {
+	var stacksCfg map[string]StackConfig
+	if err := viper.UnmarshalKey("stacks", &stacksCfg); err != nil {
+		return nil, errors.NewPipelineConfigurationError("Failed to read the 'stacks' "+
+			"from the config file", err)
+	}
+
+	overrides := map[string]daggerio.StackDefinition{}
+	for name, stackCfg := range stacksCfg {
+		name = common.NormaliseStringUpper(name)
+
+		stack, err := stackCfg.ToStackDefinition(name)
+		if err != nil {
+			return nil, errors.NewPipelineConfigurationError("Invalid stack in the config file", err)
+		}
+
+		overrides[name] = stack
+	}
+
+	stacks, err := daggerio.GetStacks(overrides)
+	if err != nil {
+		return nil, errors.NewPipelineConfigurationError("Invalid stack in the config file", err)
+	}
+
+	return stacks, nil
+}
+
+// GetConfiguredStackNames returns the (normalised) names of the stacks declared in the config
+// file.
+func GetConfiguredStackNames() []string {
+	var names []string
+	for _, name := range getSectionKeys("stacks") {
+		names = append(names, common.NormaliseStringUpper(name))
+	}
+
+	return names
+}
+
+// GetStack returns the effective definition of a stack.
+func GetStack(name string) (daggerio.StackDefinition, error) {
+	stacks, err := GetStacks()
+	if err != nil {
+		return daggerio.StackDefinition{}, err
+	}
+
+	stack, ok := stacks[common.NormaliseStringUpper(name)]
+	if !ok {
+		return daggerio.StackDefinition{}, errors.NewPipelineConfigurationError(
+			fmt.Sprintf("The stack %s is not supported, or declared in the config file (available: %s)",
+				common.NormaliseStringUpper(name), strings.Join(daggerio.GetStackNames(stacks), ", ")),
+			nil)
+	}
+
+	return stack, nil
+}
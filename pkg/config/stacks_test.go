@@ -0,0 +1,23 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStackConfigToStackDefinitionSplitsTheCommands(t *testing.T) {
+	stack, err := StackConfig{Image: "node:18-alpine", Commands: []string{"npm ci",
+		`npm run build -- --env "prod eu"`}}.ToStackDefinition("NODE")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"npm", "ci"}, {"npm", "run", "build", "--", "--env", "prod eu"}},
+		stack.Commands)
+
+	stack, err = StackConfig{Image: "node:18-alpine"}.ToStackDefinition("NODE")
+	assert.NoError(t, err)
+	assert.Nil(t, stack.Commands, "Unset commands should be inherited from the built-in stack")
+
+	for _, invalid := range []string{"", "npm run 'build"} {
+		_, err = StackConfig{Commands: []string{invalid}}.ToStackDefinition("NODE")
+		assert.Error(t, err, invalid)
+	}
+}
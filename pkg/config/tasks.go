@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// TaskRetryConfig is the retry policy of a task, in the config file.
+type TaskRetryConfig struct {
+	MaxAttempts int      `mapstructure:"max-attempts" description:"Attempts of the task."`
+	Backoff     string   `mapstructure:"backoff" description:"Time between the attempts. E.g.: 5s."`
+	MaxBackoff  string   `mapstructure:"max-backoff" description:"Maximum time between the attempts. E.g.: 1m."`
+	RetryOn     []string `mapstructure:"retry-on" description:"Patterns of the errors that are retried."`
+}
+
+// TaskConfig is the execution policy of a task, in the config file ('tasks.<task>', or
+// 'jobs.<job>.tasks.<task>' for the task of a single job).
+type TaskConfig struct {
+	Timeout string          `mapstructure:"timeout" description:"Timeout of the task. E.g.: 10m."`
+	Retry   TaskRetryConfig `mapstructure:"retry" description:"Retry policy of the task."`
+}
+
+// TaskPolicy is how a task (its action) is executed: its timeout (zero means none), and its
+// retry policy.
+type TaskPolicy struct {
+	Timeout time.Duration
+	Retry   common.RetryPolicy
+}
+
+func parseTaskDuration(path, key, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.NewPipelineConfigurationError(fmt.Sprintf("Invalid '%s.%s' "+
+			"duration '%s' in the config file", path, key, value), err)
+	}
+
+	return duration, nil
+}
+
+// GetTaskPolicy resolves the execution policy of a task of a job. The same task name can mean
+// different actions in each job (E.g.: 'deploy' in the ecs and lambda jobs), so the settings of
+// the task in the job ('jobs.<job>.tasks.<task>' in the config file) win over the ones shared by
+// all the jobs ('tasks.<task>'), which win over the global ones (E.g.: '--timeout', '--retries').
+// If the job is empty, only the shared settings are applied.
+func GetTaskPolicy(jobName, taskName string, global TaskPolicy) (TaskPolicy, error) {
+	jobName = common.NormaliseStringLower(jobName)
+	taskName = common.NormaliseStringLower(taskName)
+
+	if taskName == "" {
+		return validateTaskPolicy(taskName, global)
+	}
+
+	paths := []string{fmt.Sprintf("tasks.%s", taskName)}
+	if jobName != "" {
+		paths = append(paths, fmt.Sprintf("jobs.%s.tasks.%s", jobName, taskName))
+	}
+
+	policy := global
+	for _, path := range paths {
+		var err error
+		if policy, err = applyTaskConfig(path, policy); err != nil {
+			return TaskPolicy{}, err
+		}
+	}
+
+	return validateTaskPolicy(taskName, policy)
+}
+
+// applyTaskConfig applies the settings of the task config in the path (E.g.: 'tasks.build') over
+// the policy.
+func applyTaskConfig(path string, policy TaskPolicy) (TaskPolicy, error) {
+	var taskCfg TaskConfig
+	if err := viper.UnmarshalKey(path, &taskCfg); err != nil {
+		return TaskPolicy{}, errors.NewPipelineConfigurationError(fmt.Sprintf("Failed to read "+
+			"'%s' from the config file", path), err)
+	}
+
+	var err error
+	if taskCfg.Timeout != "" {
+		if policy.Timeout, err = parseTaskDuration(path, "timeout", taskCfg.Timeout); err != nil {
+			return TaskPolicy{}, err
+		}
+	}
+
+	if taskCfg.Retry.MaxAttempts > 0 {
+		policy.Retry.MaxAttempts = taskCfg.Retry.MaxAttempts
+	}
+
+	if taskCfg.Retry.Backoff != "" {
+		if policy.Retry.Backoff, err = parseTaskDuration(path, "retry.backoff",
+			taskCfg.Retry.Backoff); err != nil {
+			return TaskPolicy{}, err
+		}
+	}
+
+	if taskCfg.Retry.MaxBackoff != "" {
+		if policy.Retry.MaxBackoff, err = parseTaskDuration(path, "retry.max-backoff",
+			taskCfg.Retry.MaxBackoff); err != nil {
+			return TaskPolicy{}, err
+		}
+	}
+
+	if len(taskCfg.Retry.RetryOn) > 0 {
+		policy.Retry.RetryOn = taskCfg.Retry.RetryOn
+	}
+
+	return policy, nil
+}
+
+func validateTaskPolicy(taskName string, policy TaskPolicy) (TaskPolicy, error) {
+	if policy.Timeout < 0 {
+		return TaskPolicy{}, errors.NewPipelineConfigurationError(fmt.Sprintf("Invalid timeout "+
+			"%s of the task %s, it can't be negative", policy.Timeout, taskName), nil)
+	}
+
+	if err := policy.Retry.Validate(); err != nil {
+		return TaskPolicy{}, errors.NewPipelineConfigurationError(fmt.Sprintf("Invalid retry "+
+			"policy of the task %s", taskName), err)
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetTaskPolicyIsKeyedByJob(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.Set("tasks.deploy.timeout", "10m")
+	viper.Set("tasks.deploy.retry.max-attempts", 2)
+	viper.Set("jobs.lambda.tasks.deploy.timeout", "2m")
+
+	global := TaskPolicy{Timeout: time.Hour, Retry: common.RetryPolicy{MaxAttempts: 1}}
+
+	policy, err := GetTaskPolicy("", "deploy", global)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, policy.Timeout, "The shared task settings win over the flags")
+	assert.Equal(t, 2, policy.Retry.MaxAttempts)
+
+	policy, err = GetTaskPolicy("LAMBDA", "deploy", global)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, policy.Timeout, "The settings of the job's task win")
+	assert.Equal(t, 2, policy.Retry.MaxAttempts, "The unset settings come from the shared ones")
+
+	policy, err = GetTaskPolicy("ECS", "deploy", global)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, policy.Timeout, "Another job's settings shouldn't apply")
+
+	viper.Set("jobs.ecs.tasks.deploy.timeout", "-1m")
+	_, err = GetTaskPolicy("ECS", "deploy", global)
+	assert.Error(t, err)
+}
@@ -216,10 +216,5 @@ func (c *Cfg) GetFromAny(key string) (CfgValue, error) {
 }
 
 func (c *Cfg) IsRunningInVendorAutomation() bool {
-	runInVendor := viper.Get("run-in-vendor")
-	if runInVendor == nil {
-		return false
-	}
-
-	return runInVendor.(bool)
+	return viper.GetBool("run-in-vendor")
 }
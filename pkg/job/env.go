@@ -0,0 +1,285 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/secrets"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"sort"
+	"strings"
+)
+
+// Sources of the environment variables that a job sets in its containers.
+const (
+	EnvSourceHost      = "host"      // --scan-all-env-vars
+	EnvSourceGit       = "git"       // The work dir's git metadata, as STILETTO_GIT_* env vars.
+	EnvSourcePrefix    = "prefix"    // --scan-env-vars-prefix
+	EnvSourceCustom    = "custom"    // --scan-env
+	EnvSourceTerraform = "terraform" // --scan-terraform-vars
+	EnvSourceAWS       = "aws"       // --scan-aws-keys
+	EnvSourceDotEnv    = "dotenv"    // --dot-env-file, --environment
+	EnvSourceSet       = "set"       // --set-env
+)
+
+// DefaultEnvPrecedence is the order in which the sources are merged, from the lowest to the
+// highest precedence. The whole host environment goes first, so it never overrides a variable
+// that was explicitly scanned or set.
+var DefaultEnvPrecedence = []string{
+	EnvSourceHost,
+	EnvSourceGit,
+	EnvSourcePrefix,
+	EnvSourceCustom,
+	EnvSourceTerraform,
+	EnvSourceAWS,
+	EnvSourceDotEnv,
+	EnvSourceSet,
+}
+
+// SecretRefSources are the sources whose values can be secret references (E.g.:
+// 'ssm:///app/db/password'), that are resolved when the job is initialised.
+var SecretRefSources = []string{EnvSourceDotEnv, EnvSourceSet}
+
+// EnvVarsOrigins details where the variables of a source came from, per source and key. E.g.:
+// the .env file that supplied each key of the 'dotenv' source.
+type EnvVarsOrigins map[string]map[string]string
+
+// EnvVarShadowed is a value that was overridden by a source with higher precedence.
+type EnvVarShadowed struct {
+	Source string
+	Origin string
+	Value  string
+}
+
+// EnvVarProvenance describes where the final value of an environment variable came from.
+type EnvVarProvenance struct {
+	Key      string
+	Value    string
+	Source   string
+	Origin   string
+	Shadowed []EnvVarShadowed
+}
+
+// ValidateEnvPrecedence checks that the order only has known sources, without duplicates.
+func ValidateEnvPrecedence(order []string) error {
+	seen := map[string]bool{}
+
+	for _, source := range order {
+		sourceNormalised := common.NormaliseStringLower(source)
+
+		if !common.IsStringInSlice(sourceNormalised, DefaultEnvPrecedence) {
+			return errors.NewPipelineConfigurationError(fmt.Sprintf(
+				"Invalid env precedence, unknown source '%s'. Valid sources are: %s", source,
+				strings.Join(DefaultEnvPrecedence, ", ")), nil)
+		}
+
+		if seen[sourceNormalised] {
+			return errors.NewPipelineConfigurationError(fmt.Sprintf(
+				"Invalid env precedence, the source '%s' is duplicated", source), nil)
+		}
+
+		seen[sourceNormalised] = true
+	}
+
+	return nil
+}
+
+// NormaliseEnvPrecedence returns the full precedence order. The sources that aren't listed keep
+// their default relative order, and take a lower precedence than the listed ones.
+func NormaliseEnvPrecedence(order []string) ([]string, error) {
+	if err := ValidateEnvPrecedence(order); err != nil {
+		return nil, err
+	}
+
+	var listed []string
+	for _, source := range order {
+		listed = append(listed, common.NormaliseStringLower(source))
+	}
+
+	var normalised []string
+	for _, source := range DefaultEnvPrecedence {
+		if !common.IsStringInSlice(source, listed) {
+			normalised = append(normalised, source)
+		}
+	}
+
+	return append(normalised, listed...), nil
+}
+
+// GetEnvPrecedence resolves the precedence order of a job. The job specific order
+// ('jobs.<job>.env-precedence' in the config file) wins over the global one
+// ('--env-precedence', or 'env-precedence' in the config file).
+func GetEnvPrecedence(jobName string) ([]string, error) {
+	var order []string
+
+	if jobName != "" {
+		jobCfg, err := config.GetJobConfig(jobName)
+		if err != nil {
+			return nil, err
+		}
+
+		order = jobCfg.EnvPrecedence
+	}
+
+	if len(order) == 0 {
+		globalCfg, err := config.GetGlobalConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		order = globalCfg.EnvPrecedence
+	}
+
+	return NormaliseEnvPrecedence(order)
+}
+
+// ResolveEnvVars merges the sources following the precedence order passed, and reports the
+// provenance of each variable. Empty keys or values are ignored, as MergeEnvVars does.
+func ResolveEnvVars(sources map[string]filesystem.EnvVars, origins EnvVarsOrigins,
+	precedence []string) (filesystem.EnvVars, []EnvVarProvenance) {
+	resolved := filesystem.EnvVars{}
+	provenance := map[string]*EnvVarProvenance{}
+
+	for _, source := range precedence {
+		for key, value := range sources[source] {
+			if key == "" || value == "" {
+				continue
+			}
+
+			value = common.RemoveDoubleQuotes(value)
+			origin := origins[source][key]
+
+			if current, ok := provenance[key]; ok {
+				current.Shadowed = append(current.Shadowed, EnvVarShadowed{
+					Source: current.Source,
+					Origin: current.Origin,
+					Value:  current.Value,
+				})
+				current.Source = source
+				current.Origin = origin
+				current.Value = value
+			} else {
+				provenance[key] = &EnvVarProvenance{Key: key, Value: value, Source: source,
+					Origin: origin}
+			}
+
+			resolved[key] = value
+		}
+	}
+
+	var report []EnvVarProvenance
+	for _, p := range provenance {
+		report = append(report, *p)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Key < report[j].Key
+	})
+
+	return resolved, report
+}
+
+// ResolveSecretRefs resolves the secret references of the sources that allow them. It returns
+// the resolved values per reference.
+func ResolveSecretRefs(ctx context.Context, registry *secrets.Registry,
+	sources map[string]filesystem.EnvVars) (map[string]string, error) {
+	resolved := map[string]string{}
+
+	for _, source := range SecretRefSources {
+		values, err := registry.ResolveEnvVars(ctx, sources[source])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the secret references of the '%s' env vars: %w",
+				source, err)
+		}
+
+		for ref, value := range values {
+			resolved[ref] = value
+		}
+	}
+
+	return resolved, nil
+}
+
+// MaskEnvVarValue hides a value, keeping only a hint of it for the longer ones.
+func MaskEnvVarValue(value string) string {
+	if len(value) < 12 {
+		return "****"
+	}
+
+	return value[:2] + "****" + value[len(value)-2:]
+}
+
+// GetEnvVarsSources returns the environment variables scanned by the job, per source.
+func (j *Job) GetEnvVarsSources() map[string]filesystem.EnvVars {
+	return map[string]filesystem.EnvVars{
+		EnvSourceHost:      j.EnvVarsAllScanned,
+		EnvSourceGit:       j.EnvVarsGit,
+		EnvSourcePrefix:    j.EnvVarsFromPrefixScanned,
+		EnvSourceCustom:    j.EnvVarsCustomScanned,
+		EnvSourceTerraform: j.EnvVarsTerraformScanned,
+		EnvSourceAWS:       j.EnvVarsAWSScanned,
+		EnvSourceDotEnv:    j.EnvVarsFromDotEnvFile,
+		EnvSourceSet:       j.EnvVarsToSet,
+	}
+}
+
+// GetEnvVarsOrigins returns where the environment variables scanned by the job came from.
+func (j *Job) GetEnvVarsOrigins() EnvVarsOrigins {
+	return EnvVarsOrigins{
+		EnvSourceDotEnv: j.EnvVarsFromDotEnvFileSources,
+	}
+}
+
+// GetSecretValues returns the resolved values of the secret references, to mask them in the
+// captured output of the commands.
+func (j *Job) GetSecretValues() []string {
+	var values []string
+	for _, value := range j.EnvVarsSecretRefs {
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// SplitSecretEnvVars separates the merged env vars whose value is a resolved secret reference.
+// The secret ones are returned with their resolved value, to be set as Dagger secrets.
+func (j *Job) SplitSecretEnvVars(envVars filesystem.EnvVars) (filesystem.EnvVars,
+	filesystem.EnvVars) {
+	plain := filesystem.EnvVars{}
+	secret := filesystem.EnvVars{}
+
+	for key, value := range envVars {
+		if resolved, ok := j.EnvVarsSecretRefs[value]; ok {
+			secret[key] = resolved
+			continue
+		}
+
+		plain[key] = value
+	}
+
+	return plain, secret
+}
+
+// GetImageLabels returns the OCI labels (revision and source) of the images built by the job,
+// from the work dir's git metadata.
+func (j *Job) GetImageLabels() map[string]string {
+	metadata := filesystem.GitMetadata{
+		SHA:       j.EnvVarsGit["STILETTO_GIT_SHA"],
+		RemoteURL: j.EnvVarsGit["STILETTO_GIT_REMOTE_URL"],
+	}
+
+	return metadata.OCILabels()
+}
+
+// ResolveEnvVars merges the environment variables scanned by the job, following its
+// precedence order.
+func (j *Job) ResolveEnvVars() (filesystem.EnvVars, []EnvVarProvenance) {
+	precedence := j.EnvPrecedence
+	if len(precedence) == 0 {
+		precedence = DefaultEnvPrecedence
+	}
+
+	return ResolveEnvVars(j.GetEnvVarsSources(), j.GetEnvVarsOrigins(), precedence)
+}
@@ -0,0 +1,110 @@
+package job
+
+import (
+	"context"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/secrets"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormaliseEnvPrecedence(t *testing.T) {
+	order, err := NormaliseEnvPrecedence(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultEnvPrecedence, order, "An empty order should use the default one")
+
+	order, err = NormaliseEnvPrecedence([]string{"SET", "host"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"git", "prefix", "custom", "terraform", "aws", "dotenv", "set", "host"},
+		order, "The listed sources should take the highest precedence")
+
+	_, err = NormaliseEnvPrecedence([]string{"host", "unknown"})
+	assert.Error(t, err, "Unknown sources should fail")
+
+	_, err = NormaliseEnvPrecedence([]string{"host", "HOST"})
+	assert.Error(t, err, "Duplicated sources should fail")
+}
+
+func TestGetEnvPrecedence(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.Set("env-precedence", []string{"host"})
+	viper.Set("jobs.lambda.env-precedence", []string{"set", "dotenv"})
+
+	order, err := GetEnvPrecedence("LAMBDA")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host", "git", "prefix", "custom", "terraform", "aws", "set", "dotenv"},
+		order, "The job's order should win over the global one")
+
+	order, err = GetEnvPrecedence("ecs")
+	assert.NoError(t, err)
+	assert.Equal(t, "host", order[len(order)-1], "The global order should apply to the other jobs")
+
+	viper.Set("jobs.ecs.env-precedence", map[string]interface{}{"host": 1})
+	_, err = GetEnvPrecedence("ecs")
+	assert.Error(t, err, "An order with a wrong type should fail, instead of panicking")
+}
+
+func TestResolveEnvVars(t *testing.T) {
+	sources := map[string]filesystem.EnvVars{
+		EnvSourceHost:   {"STAGE": "host-stage", "PATH": "/usr/bin"},
+		EnvSourceDotEnv: {"STAGE": "dotenv-stage", "EMPTY": ""},
+		EnvSourceSet:    {"STAGE": `"set-stage"`},
+	}
+
+	origins := EnvVarsOrigins{EnvSourceDotEnv: {"STAGE": ".env.staging"}}
+
+	resolved, report := ResolveEnvVars(sources, origins, DefaultEnvPrecedence)
+
+	assert.Equal(t, "set-stage", resolved["STAGE"], "The highest precedence source should win")
+	assert.Equal(t, "/usr/bin", resolved["PATH"])
+	assert.NotContains(t, resolved, "EMPTY", "Empty values should be ignored")
+
+	assert.Len(t, report, 2)
+	assert.Equal(t, "STAGE", report[1].Key, "The report should be sorted by key")
+	assert.Equal(t, EnvSourceSet, report[1].Source)
+	assert.Equal(t, []EnvVarShadowed{
+		{Source: EnvSourceHost, Value: "host-stage"},
+		{Source: EnvSourceDotEnv, Origin: ".env.staging", Value: "dotenv-stage"},
+	}, report[1].Shadowed)
+
+	resolved, report = ResolveEnvVars(sources, origins, []string{"set", "host", "dotenv"})
+	assert.Equal(t, "dotenv-stage", resolved["STAGE"], "A custom order should be honoured")
+	assert.Equal(t, ".env.staging", report[1].Origin, "The origin of the winner should be kept")
+}
+
+func TestMaskEnvVarValue(t *testing.T) {
+	assert.Equal(t, "****", MaskEnvVarValue("short"))
+	assert.Equal(t, "ve****23", MaskEnvVarValue("verysecretvalue123"))
+}
+
+func TestResolveSecretRefs(t *testing.T) {
+	registry := secrets.NewRegistry(&secrets.EnvResolver{Lookup: func(key string) (string, bool) {
+		return "resolved-" + key, true
+	}})
+
+	sources := map[string]filesystem.EnvVars{
+		EnvSourceHost:   {"HOST_REF": "env://IGNORED"},
+		EnvSourceDotEnv: {"API_KEY": "env://API_KEY"},
+		EnvSourceSet:    {"DB_PASSWORD": "env://DB_PASSWORD", "STAGE": "dev"},
+	}
+
+	refs, err := ResolveSecretRefs(context.Background(), registry, sources)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"env://API_KEY":     "resolved-API_KEY",
+		"env://DB_PASSWORD": "resolved-DB_PASSWORD",
+	}, refs, "Only the sources that allow references should be resolved")
+
+	j := &Job{EnvVarsSecretRefs: refs}
+	plain, secret := j.SplitSecretEnvVars(filesystem.EnvVars{
+		"API_KEY":  "env://API_KEY",
+		"HOST_REF": "env://IGNORED",
+		"STAGE":    "dev",
+	})
+
+	assert.Equal(t, filesystem.EnvVars{"HOST_REF": "env://IGNORED", "STAGE": "dev"}, plain)
+	assert.Equal(t, filesystem.EnvVars{"API_KEY": "resolved-API_KEY"}, secret)
+}
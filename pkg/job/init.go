@@ -3,11 +3,14 @@ package job
 import (
 	"dagger.io/dagger"
 	"fmt"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
 	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/daggerio"
 	"github.com/Excoriate/stiletto/internal/errors"
 	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/secrets"
 	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"strings"
 )
 
 const uxPrefix = "JOB-INIT"
@@ -47,78 +50,56 @@ func NewJob(p *pipeline.Config, new InitOptions) (*Job, error) {
 		return nil, err
 	}
 
-	// 4. Scan (if applicable) AWS keys environment variables.
-	awsEnvVars, err := i.ScanEnvVarsAWSKeys(new.ScanAWSEnvVars)
-	if err != nil {
-		return nil, err
-	}
-
-	// 5. Scan (if applicable) Terraform environment variables.
-	terraformEnvVars, err := i.ScanEnvVarsTerraform(new.ScanTerraformEnvVars)
+	// 3.1. Mount the dependency cache volumes of the stack.
+	cacheVolumes, err := i.InitCacheVolumes()
 	if err != nil {
 		return nil, err
 	}
 
-	// 6. Scan (if applicable) custom environment variables.
-	customEnvVars, err := i.ScanEnvVarsCustom(new.EnvVarsToScan)
-	if err != nil {
-		return nil, err
-	}
+	ct = daggerio.SetCacheVolumesInContainer(c, ct, cacheVolumes)
 
-	// 7. Scan (if applicable) env vars from dotenv File
-	envVarsFromDotEnv, err := i.ScanEnvVarsFromDotEnvFile(new.DotEnvFile)
+	// 4. Scan (if applicable) the environment variables, from all the sources.
+	envVarsSources, envVarsOrigins, err := i.ScanEnvVarsSources()
 	if err != nil {
 		return nil, err
 	}
 
-	// 8. Scan (if applicable) env vars with prefix
-	envVarsFromPrefix, err := i.ScanEnvVarsFromPrefix(new.EnvVarsWithPrefixToScan)
+	envVarsSecretRefs, err := i.ResolveEnvVarsSecretRefs(envVarsSources)
 	if err != nil {
 		return nil, err
 	}
 
-	// 8. Validate and set environment variables.
-	envVarsToSet, err := i.ValidatedEnvVarsPassed(new.EnvVarsToSet)
+	envPrecedence, err := NormaliseEnvPrecedence(new.EnvPrecedence)
 	if err != nil {
 		return nil, err
 	}
 
-	// 9 RootDir in dagger format.
+	// 5. RootDir in dagger format.
 	rootDir, err := i.BuildRootDir(c)
 	if err != nil {
 		return nil, err
 	}
 
-	// 10. WorkDir in dagger format.
+	// 6. WorkDir in dagger format.
 	workDir, err := i.BuildWorkDir(c, new.WorkDir)
 	if err != nil {
 		return nil, err
 	}
 
-	// 11. MountDir in dagger format.
+	// 7. MountDir in dagger format.
 	mountDirPath := p.PipelineOpts.MountDirPath
 	mountDir, err := i.BuildMountDir(c, mountDirPath)
 	if err != nil {
 		return nil, err
 	}
 
-	// 12. Target dir in dagger format.
+	// 8. Target dir in dagger format.
 	targetDirPath := p.PipelineOpts.TargetDirPath
 	targetDir, err := i.BuildTargetDir(c, targetDirPath)
 	if err != nil {
 		return nil, err
 	}
 
-	var envVarsAllScanned map[string]string
-
-	isAllEnvVarsToBeScanned := p.PipelineOpts.IsAllEnvVarsToScanEnabled
-	if isAllEnvVarsToBeScanned {
-		envVarsAllScanned, err = i.ScanAllEnvVars()
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	//targetDir := p.PipelineOpts.TargetDir
 	//mountDir := p.PipelineOpts.MountDir
 	//workDir := p.PipelineOpts.WorkDir
@@ -126,19 +107,28 @@ func NewJob(p *pipeline.Config, new InitOptions) (*Job, error) {
 		Id:                jobId,
 		Name:              common.NormaliseStringUpper(new.Name),
 		Stack:             common.NormaliseStringUpper(new.Stack),
+		StackDefinition:   new.StackDefinition,
 		PipelineCfg:       p,
 		Client:            c,
 		ContainerImageURL: im,
 		ContainerDefault:  ct,
 
 		// Environment variables
-		EnvVarsAWSScanned:        awsEnvVars,
-		EnvVarsTerraformScanned:  terraformEnvVars,
-		EnvVarsCustomScanned:     customEnvVars,
-		EnvVarsToSet:             envVarsToSet,
-		EnvVarsAllScanned:        envVarsAllScanned,
-		EnvVarsFromDotEnvFile:    envVarsFromDotEnv,
-		EnvVarsFromPrefixScanned: envVarsFromPrefix,
+		EnvVarsAWSScanned:        envVarsSources[EnvSourceAWS],
+		EnvVarsTerraformScanned:  envVarsSources[EnvSourceTerraform],
+		EnvVarsCustomScanned:     envVarsSources[EnvSourceCustom],
+		EnvVarsToSet:             envVarsSources[EnvSourceSet],
+		EnvVarsAllScanned:        envVarsSources[EnvSourceHost],
+		EnvVarsFromDotEnvFile:    envVarsSources[EnvSourceDotEnv],
+		EnvVarsFromPrefixScanned: envVarsSources[EnvSourcePrefix],
+		EnvVarsGit:               envVarsSources[EnvSourceGit],
+		EnvPrecedence:            envPrecedence,
+
+		EnvVarsFromDotEnvFileSources: envVarsOrigins[EnvSourceDotEnv],
+		EnvVarsSecretRefs:            envVarsSecretRefs,
+
+		UploadFilter: new.UploadFilter,
+		CacheVolumes: cacheVolumes,
 
 		// Directories (dagger format).
 		RootDir:   rootDir,
@@ -175,14 +165,163 @@ func (i *Instance) InitDagger() (*dagger.Client, error) {
 		return nil, errors.NewDaggerEngineError(msg, err)
 	}
 
+	// Closes the dagger session when the run completes, or it's interrupted.
+	common.RegisterCleanup(func() {
+		_ = c.Close()
+	})
+
 	ux.ShowInfo(uxPrefix, GetInfoMsg(jobName, jobId,
 		"Dagger client initialised"))
 
 	return c, nil
 }
 
+// ScanEnvVarsSources scans (if applicable) the environment variables of every source,
+// without requiring the Dagger engine.
+func (i *Instance) ScanEnvVarsSources() (map[string]filesystem.EnvVars, EnvVarsOrigins, error) {
+	new := i.InitOptions
+
+	awsEnvVars, err := i.ScanEnvVarsAWSKeys(new.ScanAWSEnvVars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	terraformEnvVars, err := i.ScanEnvVarsTerraform(new.ScanTerraformEnvVars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	customEnvVars, err := i.ScanEnvVarsCustom(new.EnvVarsToScan)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envVarsFromDotEnv, dotEnvFileSources, err := i.ScanEnvVarsFromDotEnvFiles(new.DotEnvFiles,
+		new.Environment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envVarsFromPrefix, err := i.ScanEnvVarsFromPrefix(new.EnvVarsWithPrefixToScan)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envVarsToSet, err := i.ValidatedEnvVarsPassed(new.EnvVarsToSet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envVarsAllScanned := map[string]string{}
+	if new.PipelineCfg.PipelineOpts.IsAllEnvVarsToScanEnabled {
+		envVarsAllScanned, err = i.ScanAllEnvVars()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	envVarsGit, err := i.ScanEnvVarsGit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return map[string]filesystem.EnvVars{
+		EnvSourceHost:      envVarsAllScanned,
+		EnvSourceGit:       envVarsGit,
+		EnvSourcePrefix:    envVarsFromPrefix,
+		EnvSourceCustom:    customEnvVars,
+		EnvSourceTerraform: terraformEnvVars,
+		EnvSourceAWS:       awsEnvVars,
+		EnvSourceDotEnv:    envVarsFromDotEnv,
+		EnvSourceSet:       envVarsToSet,
+	}, EnvVarsOrigins{EnvSourceDotEnv: dotEnvFileSources}, nil
+}
+
+// ResolveEnvVarsSecretRefs resolves the secret references (E.g.: 'ssm:///app/db/password') of
+// the env vars set, and scanned from .env files.
+func (i *Instance) ResolveEnvVarsSecretRefs(sources map[string]filesystem.EnvVars) (
+	map[string]string, error) {
+	ux := i.InitOptions.PipelineCfg.UXMessage
+
+	registry := i.InitOptions.SecretsRegistry
+	if registry == nil {
+		registry = secrets.NewDefaultRegistry(i.InitOptions.PipelineCfg.PipelineOpts.WorkDirPath)
+	}
+
+	resolved, err := ResolveSecretRefs(i.InitOptions.PipelineCfg.Ctx, registry, sources)
+	if err != nil {
+		errMsg := GetErrMsg(i.JobName, i.JobId, "Failed to resolve the secret references", nil)
+		return nil, errors.NewPipelineConfigurationError(errMsg, err)
+	}
+
+	if len(resolved) > 0 {
+		ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+			fmt.Sprintf("%d secret references resolved, they'll be set as secrets", len(resolved))))
+	}
+
+	return resolved, nil
+}
+
+// ScanEnvVarsSources scans the environment variables that a job would set in its containers,
+// without initialising the job (nor the Dagger engine). E.g.: to explain them.
+func ScanEnvVarsSources(p *pipeline.Config, new InitOptions) (map[string]filesystem.EnvVars,
+	EnvVarsOrigins, error) {
+	new.PipelineCfg = p
+	i := &Instance{
+		InitOptions: &new,
+		JobName:     new.Name,
+		JobId:       common.GetUUID(),
+	}
+
+	return i.ScanEnvVarsSources()
+}
+
+// ScanEnvVarsGit inspects the git repository of the work dir, returning its metadata as
+// STILETTO_GIT_* env vars. If the work dir isn't in a git repository, none is returned.
+func (i *Instance) ScanEnvVarsGit() (map[string]string, error) {
+	ux := i.InitOptions.PipelineCfg.UXMessage
+	workDir := i.InitOptions.PipelineCfg.PipelineOpts.WorkDirPath
+
+	if _, err := filesystem.IsGitRepository(workDir, false, true); err != nil {
+		ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+			"The work dir is not in a git repository, skipping the git metadata"))
+		return map[string]string{}, nil
+	}
+
+	// The git metadata is informative, so a repository that can't be read doesn't fail the job.
+	metadata, err := filesystem.InspectGitRepository(workDir)
+	if err != nil {
+		ux.ShowWarning(uxPrefix, GetInfoMsg(i.JobName, i.JobId, fmt.Sprintf("Failed to read "+
+			"the git metadata of the work dir, skipping it: %s", err)))
+		return map[string]string{}, nil
+	}
+
+	ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId, fmt.Sprintf("Git metadata: commit %s, "+
+		"branch '%s', tag '%s', dirty: %t", metadata.ShortSHA, metadata.Branch, metadata.Tag,
+		metadata.Dirty)))
+
+	return metadata.EnvVars(), nil
+}
+
+// ScanAllEnvVars scans the host environment, excluding the variables that don't pass the host env
+// filter. Only the keys are shown.
 func (i *Instance) ScanAllEnvVars() (map[string]string, error) {
-	return filesystem.FetchAllEnvVarsFromHost()
+	ux := i.InitOptions.PipelineCfg.UXMessage
+
+	envVars, excluded, err := filesystem.FetchFilteredEnvVarsFromHost(i.InitOptions.HostEnvFilter)
+	if err != nil {
+		errMsg := GetErrMsg(i.JobName, i.JobId, "Failed to scan all the host env vars", nil)
+		return nil, errors.NewPipelineConfigurationError(errMsg, err)
+	}
+
+	ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+		fmt.Sprintf("%d host env vars passed through: %s", len(envVars),
+			strings.Join(filesystem.GetSortedEnvVarKeys(envVars), ", "))))
+
+	ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+		fmt.Sprintf("%d host env vars excluded: %s", len(excluded), strings.Join(excluded, ", "))))
+
+	return envVars, nil
 }
 
 // InitContainerImage 2. Get the container image.
@@ -196,7 +335,7 @@ func (i *Instance) InitContainerImage() (string, error) {
 		return "", errors.NewDaggerEngineError(errMsg, nil)
 	}
 
-	image, err := daggerio.GetContainerImagePerStack(stack, "")
+	image, err := daggerio.GetContainerImagePerStack(init.StackDefinition)
 	if err != nil {
 		errMsg := GetErrMsg(i.JobName, i.JobId,
 			fmt.Sprintf("Failed to get container image for stack: %s", stack), nil)
@@ -226,12 +365,70 @@ func (i *Instance) InitContainer(c *dagger.Client, imageURL string) (*dagger.Con
 		return nil, errors.NewDaggerEngineError(errMsg, err)
 	}
 
+	// Defaults of the stack. The env vars resolved later win over these.
+	stack := i.InitOptions.StackDefinition
+	for _, key := range filesystem.GetSortedEnvVarKeys(stack.Env) {
+		container = container.WithEnvVariable(key, stack.Env[key])
+	}
+
+	if stack.WorkDir != "" {
+		ux.ShowInfo(uxPrefix, GetInfoMsg(jobName, jobId,
+			fmt.Sprintf("Using the stack default working dir: %s", stack.WorkDir)))
+		container = container.WithWorkdir(stack.WorkDir)
+	}
+
 	ux.ShowInfo(uxPrefix, GetInfoMsg(jobName, jobId,
 		"Container successfully initialised"))
 
 	return container, nil
 }
 
+// InitCacheVolumes 3.1. Resolve the dependency cache volumes of the stack. The volumes used are
+// recorded in the cache state, so they can be pruned later.
+func (i *Instance) InitCacheVolumes() ([]daggerio.CacheVolume, error) {
+	ux := i.InitOptions.PipelineCfg.UXMessage
+	opts := i.InitOptions.CacheOptions
+
+	if opts.Disabled {
+		ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId, "Cache volumes are disabled"))
+		return nil, nil
+	}
+
+	var state *daggerio.CacheState
+	statePath, err := daggerio.GetCacheStatePath()
+	if err == nil {
+		state, err = daggerio.LoadCacheState(statePath)
+	}
+
+	if err != nil {
+		// Without the state, the volumes of the first generation are used.
+		ux.ShowWarning(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+			fmt.Sprintf("Failed to load the cache state: %s", err)))
+		state = nil
+	}
+
+	volumes, err := daggerio.GetStackCacheVolumes(i.InitOptions.StackDefinition, opts,
+		i.InitOptions.PipelineCfg.PipelineOpts.TargetDirPath, state)
+	if err != nil {
+		errMsg := GetErrMsg(i.JobName, i.JobId, "Failed to resolve the cache volumes", nil)
+		return nil, errors.NewDaggerConfigurationError(errMsg, err)
+	}
+
+	if state != nil && state.RecordVolumes(volumes) {
+		if err := state.Save(); err != nil {
+			ux.ShowWarning(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+				fmt.Sprintf("Failed to save the cache state: %s", err)))
+		}
+	}
+
+	for _, v := range volumes {
+		ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+			fmt.Sprintf("Mounting the cache volume %s at %s", v.Name, v.Path)))
+	}
+
+	return volumes, nil
+}
+
 // ScanEnvVarsAWSKeys 4. Scan (if applicable) AWS keys environment variables.
 func (i *Instance) ScanEnvVarsAWSKeys(scanAWSVars bool) (map[string]string, error) {
 	ux := i.InitOptions.PipelineCfg.UXMessage
@@ -241,14 +438,22 @@ func (i *Instance) ScanEnvVarsAWSKeys(scanAWSVars bool) (map[string]string, erro
 		return map[string]string{}, nil
 	}
 
-	envVars, err := filesystem.ScanAWSCredentialsEnvVars()
+	// The credentials are resolved through the whole AWS chain (static keys, profiles, SSO,
+	// assumed roles or web identity), and the resulting ones are injected into the container.
+	creds, err := awscloud.GetCredentials(i.InitOptions.PipelineCfg.Ctx)
 	if err != nil {
 		errMsg := GetErrMsg(i.JobName, i.JobId,
 			"Failed to scan AWS env vars", nil)
 		return nil, errors.NewDaggerEngineError(errMsg, err)
 	}
 
-	ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId, "AWS env vars scanned successfully"))
+	ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+		fmt.Sprintf("AWS credentials resolved successfully (source: %s)", creds.Source)))
+
+	envVars := awscloud.GetCredentialsAsEnvVarsMap(creds)
+	if endpointURL := awscloud.GetAWSEndpointURL(); endpointURL != "" {
+		envVars["AWS_ENDPOINT_URL"] = endpointURL
+	}
 
 	return envVars, nil
 }
@@ -295,24 +500,32 @@ func (i *Instance) ScanEnvVarsCustom(scanCustomVars []string) (map[string]string
 	return envVars, nil
 }
 
-func (i *Instance) ScanEnvVarsFromDotEnvFile(dotEnvFile string) (map[string]string, error) {
+// ScanEnvVarsFromDotEnvFiles loads the .env files of the environment (if passed), and the ones
+// passed explicitly, being the later ones the winners. It also returns the file that supplied
+// each key.
+func (i *Instance) ScanEnvVarsFromDotEnvFiles(dotEnvFiles []string,
+	environment string) (map[string]string, map[string]string, error) {
 	ux := i.InitOptions.PipelineCfg.UXMessage
 
 	if i.InitOptions.IsScanEnvVarsFromDotEnv {
-		envVars, err := filesystem.GetEnvVarsFromDotFile(dotEnvFile)
+		workDir := i.InitOptions.PipelineCfg.PipelineOpts.WorkDirPath
+		files := filesystem.ResolveDotEnvFiles(workDir, environment, dotEnvFiles)
+
+		envVars, sources, err := filesystem.LoadDotEnvFiles(files)
 		if err != nil {
 			errMsg := GetErrMsg(i.JobName, i.JobId,
-				"Failed to scan env vars from .env file", nil)
-			return nil, errors.NewDaggerEngineError(errMsg, err)
+				"Failed to scan env vars from .env files", nil)
+			return nil, nil, errors.NewDaggerEngineError(errMsg, err)
 		}
 
-		ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId, "Env vars scanned successfully from .env file"))
+		ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId,
+			fmt.Sprintf("%d env vars scanned successfully from .env files", len(envVars))))
 
-		return envVars, nil
+		return envVars, sources, nil
 	}
 
 	ux.ShowInfo(uxPrefix, GetInfoMsg(i.JobName, i.JobId, "Skipping env var scan from .env file"))
-	return map[string]string{}, nil
+	return map[string]string{}, map[string]string{}, nil
 }
 
 func (i *Instance) ScanEnvVarsFromPrefix(prefixes []string) (map[string]string, error) {
@@ -397,6 +610,13 @@ func (i *Instance) BuildMountDir(client *dagger.Client, mountDir string) (*dagge
 
 func (i *Instance) BuildTargetDir(client *dagger.Client, targetDir string) (*dagger.Directory,
 	error) {
-	// FIXME: Check whether this function will be actually required, or it can be deprecated.
-	return nil, nil
+	dir, err := daggerio.GetDaggerDir(client, targetDir)
+
+	if err != nil {
+		errMsg := GetErrMsg(i.JobName, i.JobId,
+			"Failed to get dagger target directory", nil)
+		return nil, errors.NewDaggerConfigurationError(errMsg, err)
+	}
+
+	return dir, nil
 }
@@ -3,6 +3,9 @@ package job
 import (
 	"context"
 	"dagger.io/dagger"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/secrets"
 	"github.com/Excoriate/stiletto/pkg/pipeline"
 )
 
@@ -10,6 +13,8 @@ type InitOptions struct {
 	Name        string
 	Stack       string
 	PipelineCfg *pipeline.Config
+	// Effective definition of the stack (built-in, or declared in the config file).
+	StackDefinition daggerio.StackDefinition
 
 	// Directories that the task will use.
 	WorkDir   string
@@ -24,7 +29,18 @@ type InitOptions struct {
 	EnvVarsWithPrefixToScan []string
 	IsScanEnvVarsFromDotEnv bool
 	IsScanEnvVarsFromPrefix bool
-	DotEnvFile              string
+	DotEnvFiles             []string
+	Environment             string
+	// Filter applied when all the host env vars are scanned.
+	HostEnvFilter filesystem.HostEnvFilter
+	// Resolvers of the secret references. If it's nil, the default ones are used.
+	SecretsRegistry *secrets.Registry
+	// Order in which the env vars sources are merged, from the lowest to the highest precedence.
+	EnvPrecedence []string
+	// Patterns passed explicitly to filter the files uploaded from the host directories.
+	UploadFilter filesystem.UploadFilter
+	// Dependency cache volumes mounted into the containers.
+	CacheOptions daggerio.CacheOptions
 }
 
 type Job struct {
@@ -33,6 +49,9 @@ type Job struct {
 	Name  string
 	Stack string
 
+	// Effective definition of the stack: its image, and the defaults of its containers.
+	StackDefinition daggerio.StackDefinition
+
 	// PipelineCfg client.
 	PipelineCfg *pipeline.Config
 	Client      *dagger.Client
@@ -60,6 +79,19 @@ type Job struct {
 	EnvVarsToSet             map[string]string
 	EnvVarsFromDotEnvFile    map[string]string
 	EnvVarsFromPrefixScanned map[string]string
+	EnvVarsGit               map[string]string
+	EnvPrecedence            []string
+	// The .env file that supplied each key of EnvVarsFromDotEnvFile.
+	EnvVarsFromDotEnvFileSources map[string]string
+	// Resolved values of the secret references, per reference. They're never set as plain env vars.
+	EnvVarsSecretRefs map[string]string
+
+	// Patterns passed explicitly to filter the files uploaded from the host directories. The
+	// ignore files of each directory are added when it's uploaded.
+	UploadFilter filesystem.UploadFilter
+
+	// Dependency cache volumes of the stack, mounted into the containers.
+	CacheVolumes []daggerio.CacheVolume
 
 	Ctx context.Context
 }
@@ -68,15 +100,26 @@ type Runner interface {
 	InitDagger() (*dagger.Client, error)
 	InitContainerImage() (string, error)
 	InitContainer(c *dagger.Client, imageURL string) (*dagger.Container, error)
+	InitCacheVolumes() ([]daggerio.CacheVolume, error)
 	ScanEnvVarsAWSKeys(scanAWSVars bool) (map[string]string, error)
 	ScanEnvVarsTerraform(scanTerraformVars bool) (map[string]string, error)
 	ScanEnvVarsCustom(scanCustomVars []string) (map[string]string, error)
 	ScanAllEnvVars() (map[string]string, error)
-	ScanEnvVarsFromDotEnvFile(dotEnvFile string) (map[string]string, error)
+	ScanEnvVarsGit() (map[string]string, error)
+	ScanEnvVarsFromDotEnvFiles(dotEnvFiles []string, environment string) (map[string]string,
+		map[string]string, error)
 	ScanEnvVarsFromPrefix(prefixes []string) (map[string]string, error)
+	ScanEnvVarsSources() (map[string]filesystem.EnvVars, EnvVarsOrigins, error)
+	ResolveEnvVarsSecretRefs(sources map[string]filesystem.EnvVars) (map[string]string, error)
 	ValidatedEnvVarsPassed(envVarsToSet map[string]string) (map[string]string, error)
 	BuildRootDir(client *dagger.Client) (*dagger.Directory, error)
 	BuildWorkDir(client *dagger.Client, workDir string) (*dagger.Directory, error)
 	BuildMountDir(client *dagger.Client, mountDir string) (*dagger.Directory, error)
 	BuildTargetDir(client *dagger.Client, targetDir string) (*dagger.Directory, error)
 }
+
+// SetCacheVolumesInContainer mounts the dependency cache volumes of the job into a container,
+// E.g.: one created from a custom image by a task.
+func (j *Job) SetCacheVolumesInContainer(c *dagger.Container) *dagger.Container {
+	return daggerio.SetCacheVolumesInContainer(j.Client, c, j.CacheVolumes)
+}
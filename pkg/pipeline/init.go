@@ -4,6 +4,7 @@ import (
 	"context"
 	"dagger.io/dagger"
 	"fmt"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
 	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/errors"
 	"github.com/Excoriate/stiletto/internal/filesystem"
@@ -54,11 +55,11 @@ func isEnvVarsMapToSetValid(envVarsMapToSet map[string]string) error {
 	return nil
 }
 
-func isAWSKeysExported(isAWSKeysToScan bool) error {
+func isAWSKeysExported(ctx context.Context, isAWSKeysToScan bool) error {
 	if !isAWSKeysToScan {
 		return nil
 	}
-	if _, err := filesystem.ScanAWSCredentialsEnvVars(); err != nil {
+	if _, err := awscloud.GetCredentials(ctx); err != nil {
 		return errors.NewPipelineConfigurationError("PipelineCfg cant initialise", err)
 	}
 
@@ -79,18 +80,13 @@ func isTFEnvVarsExported(isTFEnvVarsToScan bool) error {
 	return nil
 }
 
-func isDotEnvFileValidToScan(isScanFromDotEnvFileEnabled bool, dotEnvFilePath string) (map[string]string, error) {
+func isDotEnvFileValidToScan(isScanFromDotEnvFileEnabled bool,
+	dotEnvFiles []filesystem.DotEnvFileRef) (map[string]string, error) {
 	if isScanFromDotEnvFileEnabled {
-		envVars, err := filesystem.GetEnvVarsFromDotFile(dotEnvFilePath)
+		envVars, _, err := filesystem.LoadDotEnvFiles(dotEnvFiles)
 		if err != nil {
 			errMsg := fmt.Sprintf("PipelineCfg cant initialise, "+
-				"there is no .env file in the working directory: %s", dotEnvFilePath)
-			return nil, errors.NewPipelineConfigurationError(errMsg, err)
-		}
-
-		if len(envVars) == 0 {
-			errMsg := fmt.Sprintf("PipelineCfg cant initialise, "+
-				"there is no env vars in the .env file: %s", dotEnvFilePath)
+				"the .env files can't be loaded: %v", dotEnvFiles)
 			return nil, errors.NewPipelineConfigurationError(errMsg, err)
 		}
 
@@ -127,7 +123,8 @@ func isEnvVarsToScanFromPrefixValid(isScanFromPrefixEnabled bool, envVarsToScanF
 	return nil
 }
 
-func CheckPreConditions(args *config.PipelineOptions, pLog logger.Logger) error {
+func CheckPreConditions(ctx context.Context, args *config.PipelineOptions,
+	pLog logger.Logger) error {
 	ux := tui.TUIMessage{}
 
 	// 1. Validate the working directory.
@@ -179,7 +176,7 @@ func CheckPreConditions(args *config.PipelineOptions, pLog logger.Logger) error
 		return err
 	}
 
-	if err := isAWSKeysExported(args.IsAWSEnvVarKeysToScanEnabled); err != nil {
+	if err := isAWSKeysExported(ctx, args.IsAWSEnvVarKeysToScanEnabled); err != nil {
 		ux.ShowError("VALIDATION", "Preconditions failed", err)
 		return err
 	}
@@ -189,8 +186,11 @@ func CheckPreConditions(args *config.PipelineOptions, pLog logger.Logger) error
 		return err
 	}
 
+	dotEnvFiles := filesystem.ResolveDotEnvFiles(args.WorkDirPath, args.Environment,
+		args.EnvVarsDotEnvFilePaths)
+
 	if _, err := isDotEnvFileValidToScan(args.IsEnvVarsToScanFromDotEnvFile,
-		args.EnvVarsDotEnvFilePath); err != nil {
+		dotEnvFiles); err != nil {
 		ux.ShowError("VALIDATION", "Preconditions failed", err)
 		return err
 	}
@@ -204,9 +204,13 @@ func CheckPreConditions(args *config.PipelineOptions, pLog logger.Logger) error
 	return nil
 }
 
-func New(workDir, mountDir, targetDir, taskName string, envVarKeysToScan []string,
+// New initialises the pipeline. Its context (E.g.: cancelled on SIGINT/SIGTERM) is inherited by
+// the jobs and their tasks.
+func New(ctx context.Context, workDir, mountDir, targetDir, taskName string,
+	envVarKeysToScan []string,
 	envVarsMapToSet map[string]string, isAWSKeysToScan bool, isTFScanEnabled bool,
-	isAllEnvVarsToScan bool, dotEnvFile string, envVarsToScanByPrefix []string,
+	isAllEnvVarsToScan bool, dotEnvFiles []string, environment string,
+	envVarsToScanByPrefix []string,
 	initDaggerWithWorkDirByDefault bool) (*Config,
 	error) {
 
@@ -214,7 +218,7 @@ func New(workDir, mountDir, targetDir, taskName string, envVarKeysToScan []strin
 	logPrinter.InitLogger()
 
 	var isEnvVarsToScanFromDotEnvFile bool
-	if dotEnvFile == "" {
+	if len(dotEnvFiles) == 0 && environment == "" {
 		isEnvVarsToScanFromDotEnvFile = false
 	} else {
 		isEnvVarsToScanFromDotEnvFile = true
@@ -236,12 +240,13 @@ func New(workDir, mountDir, targetDir, taskName string, envVarKeysToScan []strin
 		// Task identifier, that'll be used to determine what to do.
 		TaskName: taskName,
 		// Specific environmental options passed.
-		EnvVarsToScanAndSet:   envVarKeysToScan,
-		EnvKeyValuePairsToSet: envVarsMapToSet,
-		EnvVarsDotEnvFilePath: dotEnvFile,
-		EnvVarsAWSKeysToScan:  map[string]string{},
-		EnvVarsToScanByPrefix: envVarsToScanByPrefix,
-		EnvVarsFromDotEnvFile: map[string]string{},
+		EnvVarsToScanAndSet:    envVarKeysToScan,
+		EnvKeyValuePairsToSet:  envVarsMapToSet,
+		EnvVarsDotEnvFilePaths: dotEnvFiles,
+		Environment:            environment,
+		EnvVarsAWSKeysToScan:   map[string]string{},
+		EnvVarsToScanByPrefix:  envVarsToScanByPrefix,
+		EnvVarsFromDotEnvFile:  map[string]string{},
 		// Scan options
 		IsAWSEnvVarKeysToScanEnabled:   isAWSKeysToScan,
 		IsTerraformVarsScanEnabled:     isTFScanEnabled,
@@ -251,7 +256,11 @@ func New(workDir, mountDir, targetDir, taskName string, envVarKeysToScan []strin
 		IsAllEnvVarsToScanEnabled:      isAllEnvVarsToScan,
 	}
 
-	if err := CheckPreConditions(&args, logPrinter); err != nil {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := CheckPreConditions(ctx, &args, logPrinter); err != nil {
 		return nil, err
 	}
 
@@ -269,6 +278,6 @@ func New(workDir, mountDir, targetDir, taskName string, envVarKeysToScan []strin
 		Platforms:    platformToArch,
 		UXMessage:    tui.NewTUIMessage(),
 		PipelineOpts: &args,
-		Ctx:          context.Background(),
+		Ctx:          ctx,
 	}, nil
 }
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"github.com/Excoriate/stiletto/internal/logger"
 	"github.com/Excoriate/stiletto/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -19,7 +20,7 @@ func TestCheckPreConditions(t *testing.T) {
 	t.Run("WorkDirIsEmpty", func(t *testing.T) {
 		args.WorkDir = ""
 		args.TaskName = "workdir-is-empty"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.NoError(t, err, "The CheckPreConditions should not return an error")
 	})
@@ -28,7 +29,7 @@ func TestCheckPreConditions(t *testing.T) {
 	t.Run("WorkDirIsDot", func(t *testing.T) {
 		args.WorkDir = "."
 		args.TaskName = "workdir-is-dot"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.NoError(t, err, "The CheckPreConditions should not return an error")
 	})
@@ -37,7 +38,7 @@ func TestCheckPreConditions(t *testing.T) {
 	t.Run("WorkDirIsInvalid", func(t *testing.T) {
 		args.WorkDir = "invalid-path"
 		args.TaskName = "workdir-is-invalid"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.Error(t, err, "The CheckPreConditions should return an error")
 	})
@@ -47,7 +48,7 @@ func TestCheckPreConditions(t *testing.T) {
 		currentDir, _ := os.Getwd()
 		args.WorkDir = currentDir
 		args.TaskName = "workdir-is-absolute"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.NoError(t, err, "The CheckPreConditions should not return an error")
 	})
@@ -58,7 +59,7 @@ func TestCheckPreConditions(t *testing.T) {
 		args.WorkDir = currentDir
 		args.MountDir = "invalid-mount-dir"
 		args.TaskName = "mount-dir-does-not-exist"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.Error(t, err, "The CheckPreConditions should return an error")
 	})
@@ -69,7 +70,7 @@ func TestCheckPreConditions(t *testing.T) {
 		args.WorkDir = currentDir
 		args.MountDir = "."
 		args.TaskName = "mount-dir-is-dot"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.NoError(t, err, "The CheckPreConditions should not return an error")
 	})
@@ -80,7 +81,7 @@ func TestCheckPreConditions(t *testing.T) {
 		args.WorkDir = currentDir
 		args.MountDir = filepath.Join(currentDir, "invalid-mount-dir")
 		args.TaskName = "mount-dir-is-absolute"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.Error(t, err, "The CheckPreConditions should return an error")
 	})
@@ -93,7 +94,7 @@ func TestCheckPreConditions(t *testing.T) {
 		args.MountDir = "."
 		args.TargetDir = "invalid-target-dir"
 		args.TaskName = "target-dir-does-not-exist"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.Error(t, err, "The CheckPreConditions should return an error")
 	})
@@ -105,7 +106,7 @@ func TestCheckPreConditions(t *testing.T) {
 		args.MountDir = "."
 		args.TargetDir = "."
 		args.TaskName = "target-dir-is-relative"
-		err := CheckPreConditions(args, logPrinter)
+		err := CheckPreConditions(context.Background(), args, logPrinter)
 
 		assert.NoError(t, err, "The CheckPreConditions should not return an error")
 	})
@@ -0,0 +1,187 @@
+package task
+
+import (
+	"dagger.io/dagger"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultArtifactsDir is where the outputs are exported, relative to the host target dir.
+const DefaultArtifactsDir = ".stiletto/artifacts"
+
+// artifactsManifest lists (one per line) the artifacts exported into the artifacts dir. Only
+// those are replaced by the next runs, anything else in the dir is never overwritten.
+const artifactsManifest = ".stiletto-artifacts"
+
+// Artifact is a declared output, exported from the container into the host.
+type Artifact struct {
+	Source   string // Path in the container.
+	HostPath string
+	IsDir    bool
+}
+
+// GetArtifactsDir returns the host dir the outputs are exported into. If it isn't set, the
+// DefaultArtifactsDir (under the target dir) is used. Relative paths are relative to the work dir.
+func GetArtifactsDir(artifactsDir, workDirPath, targetDirPath string) string {
+	if artifactsDir == "" {
+		return filepath.Join(targetDirPath, filepath.FromSlash(DefaultArtifactsDir))
+	}
+
+	if !filepath.IsAbs(artifactsDir) {
+		return filepath.Join(workDirPath, artifactsDir)
+	}
+
+	return artifactsDir
+}
+
+// ExportOutputs exports the declared outputs of the task from the (executed) container into the
+// artifacts dir. Each output keeps its base name; if two outputs share it, a numeric suffix is
+// added (E.g.: 'dist', 'dist-1'). Artifacts of previous runs with the same name are replaced, but
+// a file or directory that stiletto didn't export (E.g.: with --artifacts-dir .) is never touched.
+func ExportOutputs(t CoreTasker, container *dagger.Container, uxPrefix string) (Output, error) {
+	ux := t.GetPipelineUXLog()
+	core := t.GetCoreTask()
+	ctx := t.GetContext()
+
+	if len(core.OutputPaths) == 0 {
+		return Output{Commands: core.Result.Commands}, nil
+	}
+
+	if err := os.MkdirAll(core.ArtifactsDir, 0755); err != nil {
+		return Output{}, errors.NewTaskExecutionError(GetErrMsg(core, fmt.Sprintf(
+			"Failed to create the artifacts directory %s", core.ArtifactsDir), nil), err)
+	}
+
+	exported, err := readArtifactsManifest(core.ArtifactsDir)
+	if err != nil {
+		return Output{}, errors.NewTaskExecutionError(GetErrMsg(core, fmt.Sprintf(
+			"Failed to read the artifacts of the previous runs in %s", core.ArtifactsDir), nil), err)
+	}
+
+	out := Output{Commands: core.Result.Commands}
+	taken := map[string]bool{artifactsManifest: true}
+
+	for _, outputPath := range core.OutputPaths {
+		paths, err := daggerio.ResolveContainerPaths(container, outputPath, ctx)
+		if err != nil {
+			return Output{}, errors.NewTaskExecutionError(GetErrMsg(core, fmt.Sprintf(
+				"Failed to resolve the output %s", outputPath), nil), err)
+		}
+
+		if len(paths) == 0 {
+			ux.ShowWarning(uxPrefix, GetInfoMsg(core, fmt.Sprintf("The output %s didn't match "+
+				"any file or directory", outputPath)))
+			continue
+		}
+
+		for _, p := range paths {
+			name := path.Base(p.Path)
+			if name == "/" || name == "." || name == ".." {
+				return Output{}, errors.NewTaskExecutionError(GetErrMsg(core, fmt.Sprintf(
+					"The output %s resolves to %s, which can't be exported", outputPath, p.Path),
+					nil), nil)
+			}
+
+			name = filesystem.GetUniqueFileName(name, taken)
+			hostPath := filepath.Join(core.ArtifactsDir, name)
+
+			if err := replaceArtifact(core.ArtifactsDir, name, exported); err != nil {
+				return Output{}, errors.NewTaskExecutionError(GetErrMsg(core, fmt.Sprintf(
+					"Failed to replace the artifact %s", hostPath), nil), err)
+			}
+
+			if p.IsDir {
+				err = daggerio.ExportDir(container, p.Path, hostPath, ctx)
+				out.Directories = append(out.Directories, container.Directory(p.Path))
+			} else {
+				err = daggerio.ExportFile(container, p.Path, hostPath, ctx)
+				out.Files = append(out.Files, container.File(p.Path))
+			}
+
+			if err != nil {
+				return Output{}, errors.NewTaskExecutionError(GetErrMsg(core, fmt.Sprintf(
+					"Failed to export the output %s", p.Path), nil), err)
+			}
+
+			ux.ShowInfo(uxPrefix, GetInfoMsg(core, fmt.Sprintf("Exported %s into %s", p.Path,
+				hostPath)))
+
+			out.Artifacts = append(out.Artifacts, Artifact{
+				Source:   p.Path,
+				HostPath: hostPath,
+				IsDir:    p.IsDir,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// replaceArtifact removes the artifact of a previous run, and records the new one in the
+// manifest (before it's exported, so a partial export is still replaced by the next run). It
+// fails if the path exists, but it wasn't exported by stiletto.
+func replaceArtifact(artifactsDir, name string, exported map[string]bool) error {
+	hostPath := filepath.Join(artifactsDir, name)
+
+	_, err := os.Lstat(hostPath)
+	switch {
+	case err == nil && !exported[name]:
+		return fmt.Errorf("%s already exists and it wasn't exported by stiletto, refusing to "+
+			"overwrite it (use another --artifacts-dir)", hostPath)
+	case err == nil:
+		if err := os.RemoveAll(hostPath); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if exported[name] {
+		return nil
+	}
+
+	exported[name] = true
+
+	return writeArtifactsManifest(artifactsDir, exported)
+}
+
+func readArtifactsManifest(artifactsDir string) (map[string]bool, error) {
+	exported := map[string]bool{}
+
+	content, err := os.ReadFile(filepath.Join(artifactsDir, artifactsManifest))
+	if os.IsNotExist(err) {
+		return exported, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(string(content), "\n") {
+		// Only plain names, a tampered manifest can't point outside of the artifacts dir.
+		if name != "" && name == filepath.Base(name) && name != "." && name != ".." {
+			exported[name] = true
+		}
+	}
+
+	return exported, nil
+}
+
+func writeArtifactsManifest(artifactsDir string, exported map[string]bool) error {
+	var names []string
+	for name := range exported {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return os.WriteFile(filepath.Join(artifactsDir, artifactsManifest),
+		[]byte(strings.Join(names, "\n")+"\n"), 0o644)
+}
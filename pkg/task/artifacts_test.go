@@ -0,0 +1,37 @@
+package task
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceArtifact(t *testing.T) {
+	dir := t.TempDir()
+	userFile := filepath.Join(dir, "app")
+	assert.NoError(t, os.WriteFile(userFile, []byte("user"), 0o644))
+
+	exported, err := readArtifactsManifest(dir)
+	assert.NoError(t, err)
+	assert.Error(t, replaceArtifact(dir, "app", exported),
+		"A file that stiletto didn't export should never be overwritten")
+	assert.FileExists(t, userFile)
+
+	assert.NoError(t, replaceArtifact(dir, "dist", exported))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "dist", "js"), 0o755))
+
+	// The next run reads the manifest, and replaces its own artifact.
+	exported, err = readArtifactsManifest(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"dist": true}, exported)
+	assert.NoError(t, replaceArtifact(dir, "dist", exported))
+	assert.NoDirExists(t, filepath.Join(dir, "dist"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, artifactsManifest),
+		[]byte("../home\n/etc\n..\napp\n"), 0o644))
+	exported, err = readArtifactsManifest(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"app": true}, exported,
+		"Only plain names should be read from the manifest")
+}
@@ -5,32 +5,20 @@ import (
 	"github.com/Excoriate/stiletto/internal/common"
 )
 
-func RunTaskAWSECR(opt InitOptions) error {
+// awsECRTaskerOpts are the defaults of the ECR tasks, which build images.
+var awsECRTaskerOpts = TaskerOptions{DefaultCommands: listFilesCommands, UseDockerIgnore: true}
+
+func RunTaskAWSECR(opt InitOptions) (Output, error) {
 	taskSelector := common.NormaliseStringUpper(opt.Task)
 	taskPrefix := "AWS:ECR"
 
-	p := opt.PipelineCfg
-	j := opt.JobCfg
-
-	actionCMDs := opt.ActionCommands
-
 	switch taskSelector {
 	case "PUSH":
 		actionPrefix := fmt.Sprintf("%s:%s", taskPrefix, taskSelector)
-		// New (core) instance of a task
-		c := NewTask(p, j, actionCMDs, &opt)
-
-		// New specific instance of a task (E.g.: Docker, AWS, etc.)
-		t := NewTaskAWSECR(c, actionCMDs, &opt, actionPrefix)
-
-		// New action to execute (mapped to the --task passed from the command line)
-		a := NewAWSECRAction(t, actionPrefix)
 
-		// Run the action
-		_, err := a.Push()
-		if err != nil {
-			return err
-		}
+		return runTask(opt, actionPrefix, awsECRTaskerOpts, true, func(t CoreTasker) func() (Output, error) {
+			return NewAWSECRAction(t, actionPrefix).Push
+		})
 	}
-	return nil
+	return Output{}, nil
 }
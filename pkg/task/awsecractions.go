@@ -29,6 +29,7 @@ type AWSECRPushActionArgs struct {
 	AWSRegion         string
 	AWSAccessKey      string
 	AWSSecretKey      string
+	AWSSessionToken   string
 	Repository        string
 	Registry          string
 	Tag               string
@@ -40,8 +41,9 @@ type AWSECRPushActions interface {
 	Push() (Output, error)
 }
 
-func getBuildTagAndPushActionArgs(uxLog tui.TUIMessenger) (AWSECRPushActionArgs, error) {
-	awsCredentialsCfg, err := awscloud.GetCredentials()
+func getBuildTagAndPushActionArgs(uxLog tui.TUIMessenger,
+	ctx context.Context) (AWSECRPushActionArgs, error) {
+	awsCredentialsCfg, err := awscloud.GetCredentials(ctx)
 
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments, " +
@@ -50,36 +52,29 @@ func getBuildTagAndPushActionArgs(uxLog tui.TUIMessenger) (AWSECRPushActionArgs,
 		return AWSECRPushActionArgs{}, errors.NewActionCfgError(errMsg, err)
 	}
 
-	cfg := config.Cfg{}
-	registry, err := cfg.GetFromAny("ecr-registry")
-
+	ecrCfg, err := config.GetAWSECRConfig()
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments, " +
-			"ECR repository could not be met")
+		errMsg := "Failed to get 'buildTagAndPush' arguments, the config is invalid"
 		uxLog.ShowError("AWS:ECR:PUSH", errMsg, err)
 		return AWSECRPushActionArgs{}, errors.NewActionCfgError(errMsg, err)
 	}
 
-	tag, err := cfg.GetFromAny("tag")
-	if err != nil {
+	if ecrCfg.Registry == "" || ecrCfg.Repository == "" {
+		errMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments, " +
+			"ECR repository could not be met")
+		uxLog.ShowError("AWS:ECR:PUSH", errMsg, nil)
+		return AWSECRPushActionArgs{}, errors.NewActionCfgError(errMsg, nil)
+	}
+
+	tagToSet := ecrCfg.Tag
+	if tagToSet == "" {
 		warnMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments, " +
 			"tag could not be met. 'Latest' will be used if the --generate-random-tag option is" +
 			" not set.")
 		uxLog.ShowWarning("AWS:ECR:PUSH", warnMsg)
-
-		tag.Value = ""
-	}
-
-	tagToSet := tag.Value.(string)
-
-	repository, err := cfg.GetFromAny("ecr-repository")
-	if err != nil {
-		errMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments, " +
-			"ECR repository could not be met")
-		uxLog.ShowError("AWS:ECR:PUSH", errMsg, err)
-		return AWSECRPushActionArgs{}, errors.NewActionCfgError(errMsg, err)
 	}
 
+	cfg := config.Cfg{}
 	runInVendor := cfg.IsRunningInVendorAutomation()
 	if runInVendor {
 		uxLog.ShowWarning("AWS:ECR:PUSH", "Running in vendor automation. "+
@@ -87,15 +82,7 @@ func getBuildTagAndPushActionArgs(uxLog tui.TUIMessenger) (AWSECRPushActionArgs,
 			" provides (E.g.: GitHub action)")
 	}
 
-	generateRandomTag, err := cfg.GetFromViperOrDefault("generate-random-tag", false)
-	if err != nil {
-		errMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments, " +
-			"generate-random-tag could not be met")
-		uxLog.ShowError("AWS:ECR:PUSH", errMsg, err)
-		return AWSECRPushActionArgs{}, errors.NewActionCfgError(errMsg, err)
-	}
-
-	generateRandomTagValue := generateRandomTag.Value.(bool)
+	generateRandomTagValue := ecrCfg.GenerateRandomTag
 
 	if generateRandomTagValue && tagToSet != "" {
 		errMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments, " +
@@ -118,17 +105,38 @@ func getBuildTagAndPushActionArgs(uxLog tui.TUIMessenger) (AWSECRPushActionArgs,
 		AWSRegion:         awsCredentialsCfg.Region,
 		AWSAccessKey:      awsCredentialsCfg.AccessKeyID,
 		AWSSecretKey:      awsCredentialsCfg.SecretAccessKey,
-		Repository:        repository.Value.(string),
-		Registry:          registry.Value.(string),
+		AWSSessionToken:   awsCredentialsCfg.SessionToken,
+		Repository:        ecrCfg.Repository,
+		Registry:          ecrCfg.Registry,
 		Tag:               tagToSet,
 		RunECRLoginInHost: !runInVendor,
 	}, nil
 }
 
+// GetPublishAddress returns the address (registry, repository and tag) the image is pushed to.
+func (o AWSECRPushActionArgs) GetPublishAddress() string {
+	return awscloud.GetECRPublishAddress(o.Registry, awscloud.GetImageURL(o.Repository, o.Tag))
+}
+
+// GetPlannedSteps returns the steps that Push runs, to build the Dockerfile of the target dir
+// and push it to the ECR repository.
+func (o AWSECRPushActionArgs) GetPlannedSteps(targetDirPath string) []ActionStep {
+	var steps []ActionStep
+	if o.RunECRLoginInHost {
+		steps = append(steps, ActionStep{Kind: ActionStepKindHost,
+			Command:     []string{"aws", "ecr", "get-login-password"},
+			Description: fmt.Sprintf("Log into the ECR registry %s ('docker login')", o.Registry)})
+	}
+
+	return append(steps, ActionStep{Kind: ActionStepKindBuild,
+		Description: fmt.Sprintf("Build the Dockerfile of %s, and push it to %s", targetDirPath,
+			o.GetPublishAddress())})
+}
+
 func (a *AWSECRPushAction) Push() (Output, error) {
 	// Getting all the requirements.
 	uxLog := a.Task.GetPipelineUXLog()
-	opts, err := getBuildTagAndPushActionArgs(uxLog)
+	opts, err := getBuildTagAndPushActionArgs(uxLog, a.Task.GetContext())
 
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to get 'buildTagAndPush' arguments")
@@ -137,7 +145,7 @@ func (a *AWSECRPushAction) Push() (Output, error) {
 	}
 
 	// Specific container/runtime requirements.
-	ctx := a.Task.GetJob().Ctx
+	ctx := a.Task.GetContext()
 	container := a.Task.GetJobContainerDefault()
 	client := a.Task.GetClient()
 	targetDir := a.Task.GetJob().TargetDirPath
@@ -150,8 +158,7 @@ func (a *AWSECRPushAction) Push() (Output, error) {
 	}
 
 	// Resolving publish address, repository URL, and other parameters to pass to AWS ECR.
-	repositoryURL := awscloud.GetImageURL(opts.Repository, opts.Tag)
-	publishAddress := awscloud.GetECRPublishAddress(opts.Registry, repositoryURL)
+	publishAddress := opts.GetPublishAddress()
 	uxLog.ShowInfo(a.prefix, fmt.Sprintf("Pushing image to %s", publishAddress))
 
 	// Logging into AWS ECR.
@@ -159,6 +166,7 @@ func (a *AWSECRPushAction) Push() (Output, error) {
 		err = awscloud.AWSECRLogin(opts.Registry, awscloud.AWSCredentials{
 			AccessKeyID:     opts.AWSAccessKey,
 			SecretAccessKey: opts.AWSSecretKey,
+			SessionToken:    opts.AWSSessionToken,
 			Region:          opts.AWSRegion,
 		})
 
@@ -170,7 +178,12 @@ func (a *AWSECRPushAction) Push() (Output, error) {
 	}
 
 	// Publishing the image into ECR.
-	dockerFileDir, _ := a.Task.ConvertDir(client, targetDir)
+	dockerFileDir, err := a.Task.ConvertDir(client, targetDir)
+	if err != nil {
+		uxLog.ShowError(a.prefix, "Failed to upload the Dockerfile directory", err)
+		return Output{}, err
+	}
+
 	err = a.Task.PushImage(publishAddress, containerToUse, dockerFileDir, ctx)
 
 	if err != nil {
@@ -10,6 +10,7 @@ import (
 	"github.com/Excoriate/stiletto/internal/filesystem"
 	"github.com/Excoriate/stiletto/internal/tui"
 	"github.com/Excoriate/stiletto/pkg/config"
+	"strings"
 )
 
 type AWSECSDeployAction struct {
@@ -31,6 +32,7 @@ type AWSECSDeployActionArgs struct {
 	AWSRegion                string
 	AWSAccessKey             string
 	AWSSecretKey             string
+	AWSSessionToken          string
 	ClusterName              string
 	ServiceName              string
 	TaskDefinition           string
@@ -44,8 +46,9 @@ type AWSECSDeployActions interface {
 	DeployTask() (Output, error)
 }
 
-func getDeployActionArgs(log tui.TUIMessenger) (AWSECSDeployActionArgs, error) {
-	awsCredentialsCfg, err := awscloud.GetCredentials()
+func getDeployActionArgs(log tui.TUIMessenger,
+	ctx context.Context) (AWSECSDeployActionArgs, error) {
+	awsCredentialsCfg, err := awscloud.GetCredentials(ctx)
 	actionPrefix := "AWS:ECS:DEPLOY"
 
 	if err != nil {
@@ -54,43 +57,34 @@ func getDeployActionArgs(log tui.TUIMessenger) (AWSECSDeployActionArgs, error) {
 		return AWSECSDeployActionArgs{}, errors.NewActionCfgError(msg, err)
 	}
 
-	cfg := config.Cfg{}
-
-	ecsService, err := cfg.GetFromAny("ecs-service")
+	ecsCfg, err := config.GetAWSECSConfig()
 	if err != nil {
-		errMsg := fmt.Sprintf("Failed to get 'ecsDeployAction' arguments, " +
-			"'ecs-service' could not be met")
+		errMsg := "Failed to get 'ecsDeployAction' arguments, the config is invalid"
 		log.ShowError(actionPrefix, errMsg, err)
 		return AWSECSDeployActionArgs{}, errors.NewActionCfgError(errMsg, err)
 	}
 
-	ecsCluster, err := cfg.GetFromAny("ecs-cluster")
-	if err != nil {
-		errMsg := fmt.Sprintf("Failed to get 'ecsDeployAction' arguments, " +
-			"'ecs-service' could not be met")
-		log.ShowError(actionPrefix, errMsg, err)
-		return AWSECSDeployActionArgs{}, errors.NewActionCfgError(errMsg, err)
-	}
+	required := [][2]string{{"ecs-service", ecsCfg.Service}, {"ecs-cluster", ecsCfg.Cluster},
+		{"task-definition", ecsCfg.TaskDefinition}}
 
-	ecsTaskDefName, err := cfg.GetFromAny("task-definition")
-	if err != nil {
-		errMsg := fmt.Sprintf("Failed to get 'ecsDeployAction' arguments, " +
-			"'ecs-service' could not be met")
-		log.ShowError(actionPrefix, errMsg, err)
-		return AWSECSDeployActionArgs{}, errors.NewActionCfgError(errMsg, err)
+	for _, r := range required {
+		if key, value := r[0], r[1]; value == "" {
+			errMsg := fmt.Sprintf("Failed to get 'ecsDeployAction' arguments, "+
+				"'%s' could not be met", key)
+			log.ShowError(actionPrefix, errMsg, nil)
+			return AWSECSDeployActionArgs{}, errors.NewActionCfgError(errMsg, nil)
+		}
 	}
 
-	imageUrl, err := cfg.GetFromAny("image-url")
-	if err != nil {
+	if ecsCfg.ImageURL == "" {
 		log.ShowWarning(actionPrefix, "No 'image-url' found, "+
 			"using 'container-image' field that's set in the task definition as the default value")
-		imageUrl.Value = "use-task-def"
+		ecsCfg.ImageURL = "use-task-def"
 	}
 
-	tag, err := cfg.GetFromAny("release-version")
-	if err != nil {
+	if ecsCfg.ReleaseVersion == "" {
 		log.ShowWarning(actionPrefix, "No 'image-tag' found, the value 'latest' will be used")
-		tag.Value = "latest"
+		ecsCfg.ReleaseVersion = "latest"
 	}
 
 	// Env specific options.
@@ -99,111 +93,110 @@ func getDeployActionArgs(log tui.TUIMessenger) (AWSECSDeployActionArgs, error) {
 	//with environment variables.
 	var contDefEnvVarsScannedFromHost map[string]string
 	var contDefEnvVarsScannedFromKeys map[string]string
-	var contDefEnvVarsSetCustom map[string]string
 	var contDefEnvVarScannedByPrefix map[string]string
 
 	// 1. Scan from host.
-	envVarsScanFromHostCfg, err := cfg.GetBoolFromViper("set-env-from-host")
-	if err != nil {
-		log.ShowInfo(actionPrefix, "No 'set-env-from-host' found, "+
-			"no environment variables will be scanned from host")
-	} else {
-		isEnvVarsScanFromHostEnabled := envVarsScanFromHostCfg.Value.(bool)
-		if isEnvVarsScanFromHostEnabled {
-			log.ShowWarning(actionPrefix, "The 'set-env-from-host' is set. "+
-				"All the host environment variables will be scanned and set in the task definition/container def.")
-
-			contDefEnvVarsScannedFromHost, err = filesystem.FetchAllEnvVarsFromHost()
-			if err != nil {
-				log.ShowError(actionPrefix, "Failed to scan the host environment variables", err)
-				return AWSECSDeployActionArgs{}, errors.NewActionCfgError("Failed to scan the host environment variables", err)
-			}
-		} else {
-			log.ShowInfo(actionPrefix, "The option 'set-env-from-host' is disabled, "+
-				"no environment variables will be scanned from host")
+	if ecsCfg.SetEnvFromHost {
+		log.ShowWarning(actionPrefix, "The 'set-env-from-host' is set. "+
+			"All the host environment variables will be scanned and set in the task definition/container def.")
+
+		globalCfg, err := config.GetGlobalConfig()
+		if err != nil {
+			return AWSECSDeployActionArgs{}, errors.NewActionCfgError("Failed to get the "+
+				"filters of the host environment variables", err)
 		}
+
+		hostEnvFilter := filesystem.HostEnvFilter{Allow: globalCfg.EnvAllow,
+			Deny: globalCfg.EnvDeny}
+
+		var excluded []string
+		contDefEnvVarsScannedFromHost, excluded, err = filesystem.FetchFilteredEnvVarsFromHost(
+			hostEnvFilter)
+		if err != nil {
+			log.ShowError(actionPrefix, "Failed to scan the host environment variables", err)
+			return AWSECSDeployActionArgs{}, errors.NewActionCfgError("Failed to scan the host environment variables", err)
+		}
+
+		passedKeys := filesystem.GetSortedEnvVarKeys(contDefEnvVarsScannedFromHost)
+		log.ShowInfo(actionPrefix, fmt.Sprintf("Host environment variables passed through: %s "+
+			"(%d excluded)", strings.Join(passedKeys, ", "), len(excluded)))
+	} else {
+		log.ShowInfo(actionPrefix, "The option 'set-env-from-host' is disabled, "+
+			"no environment variables will be scanned from host")
 	}
 
 	// 2. Scan from specific keys passed.
-	envVarsScanFromKeysCfg, err := cfg.GetStringSliceFromViper("set-env-from-keys")
-	if err != nil {
+	if len(ecsCfg.SetEnvFromKeys) == 0 {
 		log.ShowInfo(actionPrefix, "No 'set-env-from-keys' found, "+
 			"no environment variables will be scanned from keys")
 	} else {
-		keysToScan := envVarsScanFromKeysCfg.Value.([]string)
-		if len(keysToScan) == 0 {
-			log.ShowInfo(actionPrefix, "The option 'set-env-from-keys' is set, "+
-				"however no keys were passed, no environment variables will be scanned from keys")
-		} else {
-			scannedFromKeys, err := filesystem.FetchEnvVarsAsMap(keysToScan, []string{})
-			if err != nil {
-				log.ShowError(actionPrefix, "Failed to scan the environment variables from keys", err)
-				return AWSECSDeployActionArgs{}, errors.NewActionCfgError("Failed to scan the environment variables from keys", err)
-			}
-
-			contDefEnvVarsScannedFromKeys = scannedFromKeys
+		scannedFromKeys, err := filesystem.FetchEnvVarsAsMap(ecsCfg.SetEnvFromKeys, []string{})
+		if err != nil {
+			log.ShowError(actionPrefix, "Failed to scan the environment variables from keys", err)
+			return AWSECSDeployActionArgs{}, errors.NewActionCfgError("Failed to scan the environment variables from keys", err)
 		}
+
+		contDefEnvVarsScannedFromKeys = scannedFromKeys
 	}
 
 	// 3. Scan from prefix.
-	envVarsScanFromPrefixCfg, err := cfg.GetStringFromViper("set-env-vars-with-prefix")
-	if err != nil {
+	prefix := common.NormaliseNoSpaces(ecsCfg.SetEnvVarsWithPrefix)
+	if prefix == "" {
 		log.ShowInfo(actionPrefix, "The option 'set-env-vars-with-prefix' is not set, no environment variables will be scanned from prefix")
 	} else {
-		prefix := envVarsScanFromPrefixCfg.Value.(string)
-		prefix = common.NormaliseNoSpaces(prefix)
-
-		if prefix == "" {
-			log.ShowInfo(actionPrefix, "The option 'set-env-vars-with-prefix' is set, "+
-				"however the prefix is empty")
-		} else {
-			log.ShowInfo(actionPrefix, fmt.Sprintf("Scanning the environment variables with the prefix '%s'", prefix))
-			scannedEnvVarsWithPrefix, err := filesystem.FetchEnvVarsWithPrefix(prefix)
-			if err != nil {
-				log.ShowError(actionPrefix, "Failed to scan the environment variables with the prefix", err)
-				return AWSECSDeployActionArgs{}, errors.NewActionCfgError("Failed to scan the environment variables with the prefix", err)
-			}
-
-			contDefEnvVarScannedByPrefix = scannedEnvVarsWithPrefix
+		log.ShowInfo(actionPrefix, fmt.Sprintf("Scanning the environment variables with the prefix '%s'", prefix))
+		scannedEnvVarsWithPrefix, err := filesystem.FetchEnvVarsWithPrefix(prefix)
+		if err != nil {
+			log.ShowError(actionPrefix, "Failed to scan the environment variables with the prefix", err)
+			return AWSECSDeployActionArgs{}, errors.NewActionCfgError("Failed to scan the environment variables with the prefix", err)
 		}
+
+		contDefEnvVarScannedByPrefix = scannedEnvVarsWithPrefix
 	}
 
 	// 4. Set custom and directly passed environment variables
-	envVarsSetCustomCfg, err := cfg.GetStringMapFromViper("set-env-vars-custom")
-	if err != nil {
+	if len(ecsCfg.SetEnvVarsCustom) == 0 {
 		log.ShowInfo(actionPrefix, "No 'set-env-vars-custom' found, so no custom environment variables will be set")
 	} else {
-		envVarsSetCustomValue := envVarsSetCustomCfg.Value
-		if len(envVarsSetCustomValue.(map[string]interface{})) == 0 {
-			log.ShowInfo(actionPrefix, "The option 'set-env-vars-custom' is set, "+
-				"however no custom environment variables were passed, no environment variables will be set")
-		} else {
-			log.ShowInfo(actionPrefix, "Setting the custom environment variables")
-			contDefEnvVarsSetCustom = envVarsSetCustomValue.(map[string]string)
-		}
+		log.ShowInfo(actionPrefix, "Setting the custom environment variables")
 	}
 
 	contDefVarsTotal = filesystem.MergeEnvVars(contDefEnvVarsScannedFromHost,
-		contDefEnvVarsScannedFromKeys, contDefEnvVarScannedByPrefix, contDefEnvVarsSetCustom)
+		contDefEnvVarsScannedFromKeys, contDefEnvVarScannedByPrefix, ecsCfg.SetEnvVarsCustom)
 
 	return AWSECSDeployActionArgs{
 		AWSRegion:                  awsCredentialsCfg.Region,
 		AWSAccessKey:               awsCredentialsCfg.AccessKeyID,
 		AWSSecretKey:               awsCredentialsCfg.SecretAccessKey,
-		ClusterName:                ecsCluster.Value.(string),
-		ServiceName:                ecsService.Value.(string),
-		TaskDefinition:             ecsTaskDefName.Value.(string),
-		ImageTagOrReleaseVersion:   tag.Value.(string),
-		Image:                      imageUrl.Value.(string),
+		AWSSessionToken:            awsCredentialsCfg.SessionToken,
+		ClusterName:                ecsCfg.Cluster,
+		ServiceName:                ecsCfg.Service,
+		TaskDefinition:             ecsCfg.TaskDefinition,
+		ImageTagOrReleaseVersion:   ecsCfg.ReleaseVersion,
+		Image:                      ecsCfg.ImageURL,
 		EnvVarsToSetInContainerDef: contDefVarsTotal,
 	}, nil
 
 }
 
+// GetPlannedSteps returns the AWS API calls that DeployTask makes.
+func (o AWSECSDeployActionArgs) GetPlannedSteps() []ActionStep {
+	return []ActionStep{
+		{Kind: ActionStepKindAPI, Description: fmt.Sprintf("ecs:DescribeTaskDefinition %s",
+			o.TaskDefinition)},
+		{Kind: ActionStepKindAPI, Description: fmt.Sprintf("ecs:RegisterTaskDefinition %s, "+
+			"with the image %s (version %s)", o.TaskDefinition, o.Image,
+			o.ImageTagOrReleaseVersion)},
+		{Kind: ActionStepKindAPI, Description: fmt.Sprintf("ecs:UpdateService %s (cluster "+
+			"%s), forcing a new deployment", o.ServiceName, o.ClusterName)},
+	}
+}
+
 func (a *AWSECSDeployAction) DeployTask() (Output, error) {
 	// Getting all the requirements.
 	uxLog := a.Task.GetPipelineUXLog()
-	opts, err := getDeployActionArgs(uxLog)
+	ctx := a.Task.GetContext()
+	opts, err := getDeployActionArgs(uxLog, ctx)
 
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to get 'ecsDeployAction' arguments")
@@ -212,7 +205,7 @@ func (a *AWSECSDeployAction) DeployTask() (Output, error) {
 	}
 
 	// Getting the AWS Client, to perform the actual deployment.
-	ecsClient, err := clients.GetAWSECSClient(opts.AWSRegion)
+	ecsClient, err := clients.GetAWSECSClient(ctx)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to get AWS ECS client")
 		uxLog.ShowError(a.prefix, errMsg, err)
@@ -220,7 +213,7 @@ func (a *AWSECSDeployAction) DeployTask() (Output, error) {
 	}
 
 	// Get the target task definition.
-	taskDef, err := awscloud.GetECSTaskDefinition(ecsClient, opts.TaskDefinition)
+	taskDef, err := awscloud.GetECSTaskDefinition(ctx, ecsClient, opts.TaskDefinition)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to get AWS ECS task definition")
 		uxLog.ShowError(a.prefix, errMsg, err)
@@ -228,7 +221,7 @@ func (a *AWSECSDeployAction) DeployTask() (Output, error) {
 	}
 
 	// Update the task definition.
-	updateTaskARN, err := awscloud.UpdateECSTaskContainerDefinition(ecsClient, taskDef,
+	updateTaskARN, err := awscloud.UpdateECSTaskContainerDefinition(ctx, ecsClient, taskDef,
 		awscloud.ECSTaskDefContainerDefUpdateOptions{
 			ImageURL:             opts.Image,
 			Version:              opts.ImageTagOrReleaseVersion,
@@ -242,7 +235,7 @@ func (a *AWSECSDeployAction) DeployTask() (Output, error) {
 	}
 
 	// Update the service, and perform the actual deployment.
-	err = awscloud.UpdateECSService(ecsClient, awscloud.ECSUpdateServiceOptions{
+	err = awscloud.UpdateECSService(ctx, ecsClient, awscloud.ECSUpdateServiceOptions{
 		Cluster:            opts.ClusterName,
 		Service:            opts.ServiceName,
 		TaskDefARN:         updateTaskARN,
@@ -251,6 +244,13 @@ func (a *AWSECSDeployAction) DeployTask() (Output, error) {
 
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to update AWS ECS service")
+		// The task definition is already registered, but the service may not use it yet.
+		if ctx.Err() != nil {
+			errMsg = fmt.Sprintf("The update of the AWS ECS service '%s' was cancelled, the task "+
+				"definition %s is registered but the service may not be using it", opts.ServiceName,
+				updateTaskARN)
+		}
+
 		uxLog.ShowError(a.prefix, errMsg, err)
 		return Output{}, errors.NewActionCfgError(errMsg, err)
 	}
@@ -0,0 +1,42 @@
+package task
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+)
+
+var awsLambdaTaskerOpts = TaskerOptions{DefaultCommands: listFilesCommands}
+
+func RunTaskAWSLambda(opt InitOptions) (Output, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+	taskPrefix := "AWS:LAMBDA"
+
+	actionPrefix := fmt.Sprintf("%s:%s", taskPrefix, taskSelector)
+
+	var newAction func(t CoreTasker) func() (Output, error)
+	idempotent := false
+
+	switch taskSelector {
+	case "PACKAGE":
+		idempotent = true
+		newAction = func(t CoreTasker) func() (Output, error) {
+			return NewAWSLambdaAction(t, actionPrefix).Package
+		}
+
+	case "PUBLISH":
+		newAction = func(t CoreTasker) func() (Output, error) {
+			return NewAWSLambdaAction(t, actionPrefix).Publish
+		}
+
+	case "DEPLOY":
+		newAction = func(t CoreTasker) func() (Output, error) {
+			return NewAWSLambdaAction(t, actionPrefix).Deploy
+		}
+
+	default:
+		return Output{}, getUnsupportedTaskErr(opt.Stack, opt.Task,
+			[]string{"PACKAGE", "PUBLISH", "DEPLOY"})
+	}
+
+	return runTask(opt, actionPrefix, awsLambdaTaskerOpts, idempotent, newAction)
+}
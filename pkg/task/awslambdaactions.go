@@ -0,0 +1,418 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/cloud/adapters/clients"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lambdaBuildDir is where the function code is packaged, inside the language container.
+const lambdaBuildDir = "/tmp/stiletto-lambda"
+
+// lambdaPackageExcludes are never copied into the function zip: the VCS dir, the stiletto dir
+// (logs and state) and the .env files, which may hold secrets.
+var lambdaPackageExcludes = []string{".git", ".stiletto", ".env", ".env.*"}
+
+// getLambdaCopyCommand returns the command that copies the function code into dir, without
+// the lambdaPackageExcludes.
+func getLambdaCopyCommand(dir string) string {
+	var excludes []string
+	for _, pattern := range lambdaPackageExcludes {
+		excludes = append(excludes, fmt.Sprintf("--exclude='%s'", pattern))
+	}
+
+	return fmt.Sprintf("tar -cf - %s . | tar -xf - -C %s", strings.Join(excludes, " "), dir)
+}
+
+type AWSLambdaAction struct {
+	Task   CoreTasker
+	prefix string // How the UX messages should be prefixed
+	Id     string // The ID of the task
+	Name   string // The name of the task
+	Ctx    context.Context
+}
+
+type AWSLambdaActionArgs struct {
+	FunctionName string
+	Runtime      string
+	ZipFile      string
+	S3Bucket     string
+	S3Key        string
+	ImageURI     string
+	Alias        string
+	Description  string
+}
+
+type AWSLambdaActions interface {
+	Package() (Output, error)
+	Publish() (Output, error)
+	Deploy() (Output, error)
+}
+
+func getLambdaActionArgs(log tui.TUIMessenger, workDirPath string) (AWSLambdaActionArgs,
+	error) {
+	actionPrefix := "AWS:LAMBDA"
+	lambdaCfg, err := config.GetAWSLambdaConfig()
+	if err != nil {
+		errMsg := "Failed to get 'lambda' arguments, the config is invalid"
+		log.ShowError(actionPrefix, errMsg, err)
+		return AWSLambdaActionArgs{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	if lambdaCfg.FunctionName == "" {
+		errMsg := "Failed to get 'lambda' arguments, 'lambda-function-name' could not be met"
+		log.ShowError(actionPrefix, errMsg, nil)
+		return AWSLambdaActionArgs{}, errors.NewActionCfgError(errMsg, nil)
+	}
+
+	if lambdaCfg.Runtime == "" {
+		lambdaCfg.Runtime = "python3.10"
+	}
+
+	if lambdaCfg.ZipFile == "" {
+		lambdaCfg.ZipFile = filepath.Join(workDirPath, ".stiletto", "lambda",
+			lambdaCfg.FunctionName+".zip")
+	}
+
+	return AWSLambdaActionArgs{
+		FunctionName: lambdaCfg.FunctionName,
+		Runtime:      lambdaCfg.Runtime,
+		ZipFile:      lambdaCfg.ZipFile,
+		S3Bucket:     lambdaCfg.S3Bucket,
+		S3Key:        lambdaCfg.S3Key,
+		ImageURI:     lambdaCfg.ImageURI,
+		Alias:        lambdaCfg.Alias,
+		Description:  lambdaCfg.Description,
+	}, nil
+}
+
+// getLambdaPackageScript returns the shell script that builds the function zip, based on the
+// runtime family. The dependencies are vendored into the zip, next to the function code.
+func getLambdaPackageScript(runtime string) (string, error) {
+	zipPath := filepath.Join(lambdaBuildDir, "function.zip")
+	pkgDir := filepath.Join(lambdaBuildDir, "package")
+	runtimeNormalised := common.NormaliseStringUpper(runtime)
+
+	switch {
+	case strings.HasPrefix(runtimeNormalised, "PYTHON"):
+		return fmt.Sprintf(`set -e
+rm -rf %[1]s && mkdir -p %[2]s
+%[4]s
+if [ -f requirements.txt ]; then pip install --no-cache-dir -r requirements.txt -t %[2]s; fi
+cd %[2]s && python -m zipfile -c %[3]s .`, lambdaBuildDir, pkgDir, zipPath,
+			getLambdaCopyCommand(pkgDir)), nil
+
+	case strings.HasPrefix(runtimeNormalised, "NODEJS"):
+		return fmt.Sprintf(`set -e
+rm -rf %[1]s && mkdir -p %[2]s
+%[4]s && cd %[2]s
+if [ -f package-lock.json ]; then npm ci --omit=dev; elif [ -f package.json ]; then npm install --omit=dev; fi
+apk add --no-cache zip > /dev/null
+zip -qr %[3]s .`, lambdaBuildDir, pkgDir, zipPath, getLambdaCopyCommand(pkgDir)), nil
+
+	case runtimeNormalised == "GO1.X" || runtimeNormalised == "PROVIDED.AL2":
+		// The 'go1.x' runtime runs the handler binary, 'provided.al2' always runs 'bootstrap'.
+		binary := "bootstrap"
+		if runtimeNormalised == "GO1.X" {
+			binary = "main"
+		}
+
+		return fmt.Sprintf(`set -e
+rm -rf %[1]s && mkdir -p %[1]s
+CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o %[1]s/%[2]s .
+apk add --no-cache zip > /dev/null
+cd %[1]s && zip -q %[3]s %[2]s`, lambdaBuildDir, binary, zipPath), nil
+	}
+
+	return "", fmt.Errorf("the lambda runtime '%s' is not supported", runtime)
+}
+
+// getLambdaS3Key returns the S3 key passed, or a key based on the zip's checksum, so each
+// published package is immutable.
+func getLambdaS3Key(args AWSLambdaActionArgs) (string, error) {
+	if args.S3Key != "" {
+		return args.S3Key, nil
+	}
+
+	content, err := os.ReadFile(args.ZipFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the lambda package %s: %w", args.ZipFile, err)
+	}
+
+	checksum := sha256.Sum256(content)
+
+	return fmt.Sprintf("%s/%s.zip", args.FunctionName, hex.EncodeToString(checksum[:])), nil
+}
+
+// getPackageCommand returns the image, and the command that builds the function zip in it.
+func (o AWSLambdaActionArgs) getPackageCommand() (string, []string, error) {
+	image, ok := daggerio.LambdaRuntimeImagesMap[common.NormaliseStringUpper(o.Runtime)]
+	if !ok {
+		return "", nil, errors.NewActionCfgError(fmt.Sprintf("The lambda runtime '%s' is not "+
+			"supported", o.Runtime), nil)
+	}
+
+	script, err := getLambdaPackageScript(o.Runtime)
+	if err != nil {
+		return "", nil, errors.NewActionCfgError("Failed to resolve the packaging commands", err)
+	}
+
+	return image, []string{"sh", "-c", script}, nil
+}
+
+// GetPackageSteps returns the steps that Package runs.
+func (o AWSLambdaActionArgs) GetPackageSteps() ([]ActionStep, error) {
+	image, command, err := o.getPackageCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	return []ActionStep{
+		{Kind: ActionStepKindContainer, Image: image, Command: command},
+		{Kind: ActionStepKindHost, Description: fmt.Sprintf("Export %s into %s",
+			filepath.Join(lambdaBuildDir, "function.zip"), o.ZipFile)},
+	}, nil
+}
+
+// GetPublishSteps returns the AWS API calls that Publish makes. The S3 key is only known once
+// the package exists, so a placeholder is used if it isn't set.
+func (o AWSLambdaActionArgs) GetPublishSteps() []ActionStep {
+	if o.ImageURI != "" {
+		return []ActionStep{{Kind: ActionStepKindHost, Description: fmt.Sprintf(
+			"Nothing to upload, the function is deployed from the image %s", o.ImageURI)}}
+	}
+
+	key := o.S3Key
+	if key == "" {
+		key = fmt.Sprintf("%s/<sha256 of the package>.zip", o.FunctionName)
+	}
+
+	return []ActionStep{{Kind: ActionStepKindAPI, Description: fmt.Sprintf(
+		"s3:PutObject %s into s3://%s/%s", o.ZipFile, o.S3Bucket, key)}}
+}
+
+// GetDeploySteps returns the AWS API calls that Deploy makes.
+func (o AWSLambdaActionArgs) GetDeploySteps() []ActionStep {
+	steps := []ActionStep{
+		{Kind: ActionStepKindAPI, Description: fmt.Sprintf("lambda:UpdateFunctionCode %s",
+			o.FunctionName)},
+		{Kind: ActionStepKindAPI, Description: fmt.Sprintf("lambda:PublishVersion %s",
+			o.FunctionName)},
+	}
+
+	if o.Alias != "" {
+		steps = append(steps, ActionStep{Kind: ActionStepKindAPI,
+			Description: fmt.Sprintf("lambda:UpdateAlias %s (or CreateAlias) to the new version",
+				o.Alias)})
+	}
+
+	return steps
+}
+
+func (a *AWSLambdaAction) Package() (Output, error) {
+	uxLog := a.Task.GetPipelineUXLog()
+	workDirPath := a.Task.GetPipeline().PipelineOpts.WorkDirPath
+	opts, err := getLambdaActionArgs(uxLog, workDirPath)
+
+	if err != nil {
+		errMsg := "Failed to get 'lambda' arguments"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	image, command, err := opts.getPackageCommand()
+	if err != nil {
+		uxLog.ShowError(a.prefix, "Failed to resolve the packaging commands", err)
+		return Output{}, err
+	}
+
+	// Reference required objects (container, client, context, etc.)
+	client := a.Task.GetClient()
+	ctx := a.Task.GetContext()
+	container, _ := a.Task.GetContainer(image)
+
+	// Inherit the environment variables from the job.
+	preConfiguredContainer, err := a.Task.SetEnvVarsFromJob(container)
+	if err != nil {
+		errMsg := "Failed to run action: 'Package' - Cannot set the environment variables from the job"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	// Mount required directories.
+	targetDir := a.Task.GetPipeline().PipelineOpts.TargetDir
+	configuredContainer, err := a.Task.MountDir(workDirPath, targetDir, client,
+		preConfiguredContainer, []string{}, ctx)
+	if err != nil {
+		return Output{}, err
+	}
+
+	uxLog.ShowInfo(a.prefix, fmt.Sprintf("Packaging lambda function '%s' using the image %s",
+		opts.FunctionName, image))
+
+	packaged := configuredContainer.WithExec(command)
+
+	if err := os.MkdirAll(filepath.Dir(opts.ZipFile), 0755); err != nil {
+		errMsg := fmt.Sprintf("Failed to create the directory for the lambda package %s", opts.ZipFile)
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewTaskExecutionError(errMsg, err)
+	}
+
+	if err := daggerio.ExportFile(packaged, filepath.Join(lambdaBuildDir, "function.zip"),
+		opts.ZipFile, ctx); err != nil {
+		uxLog.ShowError(a.prefix, "Failed to package the lambda function", err)
+		return Output{}, errors.NewTaskExecutionError("Failed to package the lambda function", err)
+	}
+
+	uxLog.ShowSuccess(a.prefix, fmt.Sprintf("Lambda function '%s' packaged into %s",
+		opts.FunctionName, opts.ZipFile))
+
+	out, err := ExportOutputs(a.Task, packaged, a.prefix)
+	if err != nil {
+		uxLog.ShowError(a.prefix, "Failed to export the outputs", err)
+		return Output{}, err
+	}
+
+	out.DaggerOutput = opts.ZipFile
+
+	return out, nil
+}
+
+func (a *AWSLambdaAction) Publish() (Output, error) {
+	uxLog := a.Task.GetPipelineUXLog()
+	ctx := a.Task.GetContext()
+	opts, err := getLambdaActionArgs(uxLog, a.Task.GetPipeline().PipelineOpts.WorkDirPath)
+
+	if err != nil {
+		errMsg := "Failed to get 'lambda' arguments"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	if opts.ImageURI != "" {
+		uxLog.ShowInfo(a.prefix, fmt.Sprintf("The lambda function '%s' is deployed from the"+
+			" image %s, nothing to upload", opts.FunctionName, opts.ImageURI))
+		return Output{DaggerOutput: opts.ImageURI}, nil
+	}
+
+	if opts.S3Bucket == "" {
+		errMsg := "Either 'lambda-s3-bucket' or 'lambda-image-uri' should be set to publish a lambda function"
+		uxLog.ShowError(a.prefix, errMsg, nil)
+		return Output{}, errors.NewActionCfgError(errMsg, nil)
+	}
+
+	key, err := getLambdaS3Key(opts)
+	if err != nil {
+		uxLog.ShowError(a.prefix, "Failed to resolve the S3 key of the lambda package", err)
+		return Output{}, errors.NewActionCfgError("Failed to resolve the S3 key of the lambda package", err)
+	}
+
+	f, err := clients.GetAWSClientFactory(a.Task.GetContext())
+	if err != nil {
+		errMsg := "Failed to get AWS S3 client"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	if err := awscloud.UploadFileToS3(ctx, f.S3(), opts.S3Bucket, key, opts.ZipFile,
+		"application/zip"); err != nil {
+		errMsg := fmt.Sprintf("Failed to publish the lambda package %s", opts.ZipFile)
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewTaskExecutionError(errMsg, err)
+	}
+
+	location := fmt.Sprintf("s3://%s/%s", opts.S3Bucket, key)
+	uxLog.ShowSuccess(a.prefix, fmt.Sprintf("Lambda package published to %s", location))
+
+	return Output{DaggerOutput: location}, nil
+}
+
+func (a *AWSLambdaAction) Deploy() (Output, error) {
+	uxLog := a.Task.GetPipelineUXLog()
+	ctx := a.Task.GetContext()
+	opts, err := getLambdaActionArgs(uxLog, a.Task.GetPipeline().PipelineOpts.WorkDirPath)
+
+	if err != nil {
+		errMsg := "Failed to get 'lambda' arguments"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	// The code location, in order of preference: image, S3 object, or the local zip.
+	var code awscloud.LambdaCodeLocation
+	switch {
+	case opts.ImageURI != "":
+		code.ImageURI = opts.ImageURI
+	case opts.S3Bucket != "":
+		key, err := getLambdaS3Key(opts)
+		if err != nil {
+			uxLog.ShowError(a.prefix, "Failed to resolve the S3 key of the lambda package", err)
+			return Output{}, errors.NewActionCfgError("Failed to resolve the S3 key of the lambda package", err)
+		}
+
+		code.S3Bucket = opts.S3Bucket
+		code.S3Key = key
+	default:
+		content, err := os.ReadFile(opts.ZipFile)
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to read the lambda package %s, "+
+				"run the 'package' task first", opts.ZipFile)
+			uxLog.ShowError(a.prefix, errMsg, err)
+			return Output{}, errors.NewActionCfgError(errMsg, err)
+		}
+
+		code.ZipFile = content
+	}
+
+	f, err := clients.GetAWSClientFactory(a.Task.GetContext())
+	if err != nil {
+		errMsg := "Failed to get AWS Lambda client"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	uxLog.ShowInfo(a.prefix, fmt.Sprintf("Deploying lambda function '%s'", opts.FunctionName))
+
+	result, err := awscloud.DeployLambdaFunction(ctx, f.Lambda(), awscloud.LambdaDeployOptions{
+		FunctionName: opts.FunctionName,
+		Code:         code,
+		Alias:        opts.Alias,
+		Description:  opts.Description,
+	})
+
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to deploy lambda function '%s'", opts.FunctionName)
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewTaskExecutionError(errMsg, err)
+	}
+
+	msg := fmt.Sprintf("Deployed lambda function '%s' - version %s", opts.FunctionName,
+		result.Version)
+	if result.Alias != "" {
+		msg = fmt.Sprintf("%s, alias '%s'", msg, result.Alias)
+	}
+
+	uxLog.ShowSuccess(a.prefix, msg)
+
+	return Output{DaggerOutput: result}, nil
+}
+
+func NewAWSLambdaAction(task CoreTasker, prefix string) AWSLambdaActions {
+	return &AWSLambdaAction{
+		Task:   task,
+		prefix: prefix,
+		Id:     common.GetUUID(),
+		Name:   "Package, publish and deploy AWS Lambda functions",
+	}
+}
@@ -0,0 +1,31 @@
+package task
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/pkg/config"
+)
+
+var awsS3TaskerOpts = TaskerOptions{DefaultCommands: listFilesCommands}
+
+func RunTaskAWSS3(opt InitOptions) (Output, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+	taskPrefix := "AWS:S3"
+
+	actionPrefix := fmt.Sprintf("%s:%s", taskPrefix, taskSelector)
+
+	switch taskSelector {
+	case "SYNC":
+		// Deleting the objects that aren't in the source dir isn't idempotent. An invalid config
+		// is reported by the action.
+		s3Cfg, err := config.GetAWSS3Config()
+		idempotent := err == nil && !s3Cfg.Delete
+
+		return runTask(opt, actionPrefix, awsS3TaskerOpts, idempotent, func(t CoreTasker) func() (Output, error) {
+			return NewAWSS3Action(t, actionPrefix).Sync
+		})
+
+	default:
+		return Output{}, getUnsupportedTaskErr(opt.Stack, opt.Task, []string{"SYNC"})
+	}
+}
@@ -0,0 +1,279 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/cloud/adapters/clients"
+	"github.com/Excoriate/stiletto/internal/cloud/awscloud"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"os"
+)
+
+type AWSS3Action struct {
+	Task   CoreTasker
+	prefix string // How the UX messages should be prefixed
+	Id     string // The ID of the task
+	Name   string // The name of the task
+	Ctx    context.Context
+}
+
+type AWSS3SyncActionArgs struct {
+	Bucket            string
+	Prefix            string
+	SourceDir         string
+	BuildCommand      string
+	BuildImage        string
+	CacheControlRules []awscloud.S3CacheControlRule
+	Delete            bool
+	DryRun            bool
+	Concurrency       int
+
+	CloudFrontDistributionID string
+	CloudFrontPaths          []string
+	CloudFrontWait           bool
+}
+
+type AWSS3Actions interface {
+	Sync() (Output, error)
+}
+
+func getS3SyncActionArgs(log tui.TUIMessenger) (AWSS3SyncActionArgs, error) {
+	actionPrefix := "AWS:S3:SYNC"
+	s3Cfg, err := config.GetAWSS3Config()
+	if err != nil {
+		errMsg := "Failed to get 's3 sync' arguments, the config is invalid"
+		log.ShowError(actionPrefix, errMsg, err)
+		return AWSS3SyncActionArgs{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	if s3Cfg.Bucket == "" {
+		errMsg := "Failed to get 's3 sync' arguments, 's3-bucket' could not be met"
+		log.ShowError(actionPrefix, errMsg, nil)
+		return AWSS3SyncActionArgs{}, errors.NewActionCfgError(errMsg, nil)
+	}
+
+	if s3Cfg.SourceDir == "" {
+		s3Cfg.SourceDir = "dist"
+	}
+
+	cacheControlRules, err := awscloud.ParseS3CacheControlRules(s3Cfg.CacheControl)
+	if err != nil {
+		errMsg := "Failed to get 's3 sync' arguments, 's3-cache-control' is invalid"
+		log.ShowError(actionPrefix, errMsg, err)
+		return AWSS3SyncActionArgs{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	return AWSS3SyncActionArgs{
+		Bucket:                   s3Cfg.Bucket,
+		Prefix:                   s3Cfg.Prefix,
+		SourceDir:                s3Cfg.SourceDir,
+		BuildCommand:             s3Cfg.BuildCmd,
+		BuildImage:               s3Cfg.BuildImage,
+		CacheControlRules:        cacheControlRules,
+		Delete:                   s3Cfg.Delete,
+		DryRun:                   s3Cfg.DryRun,
+		Concurrency:              s3Cfg.Concurrency,
+		CloudFrontDistributionID: s3Cfg.CloudFrontDistributionID,
+		CloudFrontPaths:          s3Cfg.CloudFrontInvalidationPaths,
+		CloudFrontWait:           s3Cfg.CloudFrontWait,
+	}, nil
+}
+
+// getBuildCommand returns the command that runs the build command in the container.
+func (o AWSS3SyncActionArgs) getBuildCommand() []string {
+	return []string{"sh", "-c", o.BuildCommand}
+}
+
+// GetPlannedSteps returns the steps that Sync runs: the (optional) build, the export of the
+// source dir, the sync itself and the (optional) CloudFront invalidation.
+func (o AWSS3SyncActionArgs) GetPlannedSteps() []ActionStep {
+	var steps []ActionStep
+	if o.BuildCommand != "" {
+		steps = append(steps, ActionStep{Kind: ActionStepKindContainer, Image: o.BuildImage,
+			Command: o.getBuildCommand()})
+	}
+
+	steps = append(steps, ActionStep{Kind: ActionStepKindHost,
+		Description: fmt.Sprintf("Export %s into a temporary dir", o.SourceDir)})
+
+	sync := fmt.Sprintf("Sync the exported dir into s3://%s/%s", o.Bucket, o.Prefix)
+	if o.Delete {
+		sync += ", deleting the stale objects"
+	}
+
+	if o.DryRun {
+		sync += " (s3 dry-run, nothing is uploaded)"
+	}
+
+	steps = append(steps, ActionStep{Kind: ActionStepKindAPI, Description: sync})
+
+	if o.CloudFrontDistributionID != "" && !o.DryRun {
+		steps = append(steps, ActionStep{Kind: ActionStepKindAPI, Description: fmt.Sprintf(
+			"cloudfront:CreateInvalidation %s (paths: %v)", o.CloudFrontDistributionID,
+			o.CloudFrontPaths)})
+	}
+
+	return steps
+}
+
+// exportSyncSourceDir runs the (optional) build command in the container, and exports the
+// source dir into a temporary host directory, along with the declared outputs.
+func (a *AWSS3Action) exportSyncSourceDir(opts AWSS3SyncActionArgs) (string, Output, error) {
+	uxLog := a.Task.GetPipelineUXLog()
+	client := a.Task.GetClient()
+	ctx := a.Task.GetContext()
+	container, _ := a.Task.GetContainer(opts.BuildImage)
+
+	// Inherit the environment variables from the job.
+	preConfiguredContainer, err := a.Task.SetEnvVarsFromJob(container)
+	if err != nil {
+		errMsg := "Failed to run action: 'Sync' - Cannot set the environment variables from the job"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return "", Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	// Mount required directories.
+	workDirPath := a.Task.GetPipeline().PipelineOpts.WorkDirPath
+	targetDir := a.Task.GetPipeline().PipelineOpts.TargetDir
+	configuredContainer, err := a.Task.MountDir(workDirPath, targetDir, client,
+		preConfiguredContainer, []string{}, ctx)
+	if err != nil {
+		return "", Output{}, err
+	}
+
+	if opts.BuildCommand != "" {
+		uxLog.ShowInfo(a.prefix, fmt.Sprintf("Running the build command '%s'", opts.BuildCommand))
+		configuredContainer = configuredContainer.WithExec(opts.getBuildCommand())
+	}
+
+	hostDir, err := os.MkdirTemp("", "stiletto-s3-sync-")
+	if err != nil {
+		return "", Output{}, errors.NewTaskExecutionError("Failed to create the temporary sync directory", err)
+	}
+
+	if err := daggerio.ExportDir(configuredContainer, opts.SourceDir, hostDir, ctx); err != nil {
+		_ = os.RemoveAll(hostDir)
+		uxLog.ShowError(a.prefix, fmt.Sprintf("Failed to export the directory %s", opts.SourceDir), err)
+		return "", Output{}, err
+	}
+
+	out, err := ExportOutputs(a.Task, configuredContainer, a.prefix)
+	if err != nil {
+		_ = os.RemoveAll(hostDir)
+		uxLog.ShowError(a.prefix, "Failed to export the outputs", err)
+		return "", Output{}, err
+	}
+
+	return hostDir, out, nil
+}
+
+func (a *AWSS3Action) Sync() (Output, error) {
+	uxLog := a.Task.GetPipelineUXLog()
+	ctx := a.Task.GetContext()
+	opts, err := getS3SyncActionArgs(uxLog)
+
+	if err != nil {
+		errMsg := "Failed to get 's3 sync' arguments"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	sourceDir, out, err := a.exportSyncSourceDir(opts)
+	if err != nil {
+		return Output{}, err
+	}
+
+	defer func() {
+		_ = os.RemoveAll(sourceDir)
+	}()
+
+	f, err := clients.GetAWSClientFactory(a.Task.GetContext())
+	if err != nil {
+		errMsg := "Failed to get AWS S3 client"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	destination := fmt.Sprintf("s3://%s/%s", opts.Bucket, opts.Prefix)
+	uxLog.ShowInfo(a.prefix, fmt.Sprintf("Syncing %s into %s", opts.SourceDir, destination))
+
+	plan, err := awscloud.SyncDirToS3(ctx, f.S3(), awscloud.S3SyncOptions{
+		Bucket:            opts.Bucket,
+		Prefix:            opts.Prefix,
+		SourceDir:         sourceDir,
+		CacheControlRules: opts.CacheControlRules,
+		Delete:            opts.Delete,
+		DryRun:            opts.DryRun,
+		Concurrency:       opts.Concurrency,
+	})
+
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to sync %s into %s", opts.SourceDir, destination)
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewTaskExecutionError(errMsg, err)
+	}
+
+	if opts.DryRun {
+		uxLog.ShowWarning(a.prefix, "Dry-run enabled, no changes will be applied")
+
+		for _, entry := range plan.Upload {
+			uxLog.ShowInfo(a.prefix, fmt.Sprintf("(dry-run) upload: %s (%s, %d bytes, "+
+				"cache-control: '%s')", entry.Key, entry.ContentType, entry.Size, entry.CacheControl))
+		}
+
+		for _, key := range plan.Delete {
+			uxLog.ShowInfo(a.prefix, fmt.Sprintf("(dry-run) delete: %s", key))
+		}
+
+		out.DaggerOutput = plan
+		return out, nil
+	}
+
+	uxLog.ShowSuccess(a.prefix, fmt.Sprintf("Synced into %s: %d uploaded, %d deleted, "+
+		"%d unchanged", destination, len(plan.Upload), len(plan.Delete), plan.Unchanged))
+
+	if opts.CloudFrontDistributionID == "" {
+		out.DaggerOutput = plan
+		return out, nil
+	}
+
+	uxLog.ShowInfo(a.prefix, fmt.Sprintf("Invalidating the paths %v on distribution %s",
+		opts.CloudFrontPaths, opts.CloudFrontDistributionID))
+
+	invalidationID, err := awscloud.InvalidateCloudFrontPaths(ctx, f.CloudFront(),
+		awscloud.CloudFrontInvalidationOptions{
+			DistributionID: opts.CloudFrontDistributionID,
+			Paths:          opts.CloudFrontPaths,
+			Wait:           opts.CloudFrontWait,
+		})
+
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to invalidate the distribution %s", opts.CloudFrontDistributionID)
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewTaskExecutionError(errMsg, err)
+	}
+
+	status := "created"
+	if opts.CloudFrontWait {
+		status = "completed"
+	}
+
+	uxLog.ShowSuccess(a.prefix, fmt.Sprintf("CloudFront invalidation %s %s on distribution %s",
+		invalidationID, status, opts.CloudFrontDistributionID))
+
+	out.DaggerOutput = plan
+	return out, nil
+}
+
+func NewAWSS3Action(task CoreTasker, prefix string) AWSS3Actions {
+	return &AWSS3Action{
+		Task:   task,
+		prefix: prefix,
+		Id:     common.GetUUID(),
+		Name:   "Sync directories into AWS S3, and invalidate CloudFront distributions",
+	}
+}
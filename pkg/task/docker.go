@@ -4,31 +4,20 @@ import (
 	"github.com/Excoriate/stiletto/internal/common"
 )
 
+// dockerTaskerOpts are the defaults of the docker tasks, which build images.
+var dockerTaskerOpts = TaskerOptions{DefaultCommands: listFilesCommands, UseDockerIgnore: true}
+
 // RunTaskDocker is the entry point for all Docker tasks.
-func RunTaskDocker(opt InitOptions) error {
+func RunTaskDocker(opt InitOptions) (Output, error) {
 	taskSelector := common.NormaliseStringUpper(opt.Task)
 
-	p := opt.PipelineCfg
-	j := opt.JobCfg
-
-	actionCMDs := opt.ActionCommands
-
 	switch taskSelector {
 	case "BUILD":
-		// New (core) instance of a task
-		c := NewTask(p, j, actionCMDs, &opt)
-
-		// New specific instance of a task (E.g.: Docker, AWS, etc.)
-		t := NewTaskDocker(c, actionCMDs, &opt, "DOCKER-BUILD")
-
-		// New action to execute (mapped to the --task passed from the command line)
-		a := NewDockerAction(t)
-
-		// Run the action
-		_, err := a.BuildTagAndPush("Dockerfile")
-		if err != nil {
-			return err
-		}
+		return runTask(opt, "DOCKER-BUILD", dockerTaskerOpts, true, func(t CoreTasker) func() (Output, error) {
+			return func() (Output, error) {
+				return NewDockerAction(t).BuildTagAndPush("Dockerfile")
+			}
+		})
 	}
-	return nil
+	return Output{}, nil
 }
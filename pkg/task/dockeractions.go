@@ -2,9 +2,14 @@ package task
 
 import (
 	"context"
+	"fmt"
 	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
 )
 
+// dockerInspectCommands run in the container before the build, to show what's being built.
+var dockerInspectCommands = [][]string{{"ls", "-ltrh"}, {"cat", "Dockerfile"}}
+
 type DockerBuildAction struct {
 	Task   CoreTasker
 	prefix string // How the UX messages should be prefixed
@@ -18,7 +23,7 @@ type DockerBuildActions interface {
 }
 
 func (a *DockerBuildAction) BuildTagAndPush(dockerFile string) (Output, error) {
-	ctx := a.Task.GetJob().Ctx
+	ctx := a.Task.GetContext()
 
 	container := a.Task.GetJobContainerDefault()
 	client := a.Task.GetClient()
@@ -31,15 +36,34 @@ func (a *DockerBuildAction) BuildTagAndPush(dockerFile string) (Output, error) {
 		return Output{}, err
 	}
 
-	targetDirDagger, _ := a.Task.ConvertDir(client, targetDir)
+	targetDirDagger, err := a.Task.ConvertDir(client, targetDir)
+	if err != nil {
+		return Output{}, err
+	}
+
+	for _, cmd := range dockerInspectCommands {
+		mountedContainer = mountedContainer.WithExec(cmd)
+	}
+
+	containerBuilt := mountedContainer.Build(targetDirDagger)
+
+	containerLabelled := daggerio.SetLabelsInContainer(containerBuilt,
+		a.Task.GetJob().GetImageLabels())
+
+	if _, err = containerLabelled.ExitCode(ctx); err != nil {
+		return Output{}, err
+	}
+
+	return ExportOutputs(a.Task, containerLabelled, a.prefix)
+}
 
-	_, err = mountedContainer.
-		WithExec([]string{"ls", "-ltrh"}).
-		WithExec([]string{"cat", "Dockerfile"}).
-		Build(targetDirDagger).
-		ExitCode(ctx)
+// getDockerBuildSteps returns the steps that BuildTagAndPush runs, to build the Dockerfile of
+// the target dir.
+func getDockerBuildSteps(targetDirPath string) []ActionStep {
+	steps := newContainerSteps(dockerInspectCommands)
 
-	return Output{}, nil
+	return append(steps, ActionStep{Kind: ActionStepKindBuild,
+		Description: fmt.Sprintf("Build the Dockerfile of %s", targetDirPath)})
 }
 
 func NewDockerAction(task CoreTasker) DockerBuildActions {
@@ -0,0 +1,37 @@
+package task
+
+import (
+	"dagger.io/dagger"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/job"
+	"strings"
+)
+
+// setEnvVarsFromJob sets the environment variables scanned by the job into the container,
+// merging its sources following the job's precedence order.
+func setEnvVarsFromJob(ux tui.TUIMessenger, uxPrefix string, j *job.Job,
+	container *dagger.Container) (*dagger.Container, error) {
+	envVars, provenance := j.ResolveEnvVars()
+
+	if len(envVars) == 0 {
+		ux.ShowInfo(uxPrefix, "No environment variables to set from the job")
+		return container, nil
+	}
+
+	var shadowed int
+	for _, p := range provenance {
+		shadowed += len(p.Shadowed)
+	}
+
+	plainEnvVars, secretEnvVars := j.SplitSecretEnvVars(envVars)
+
+	ux.ShowInfo(uxPrefix, fmt.Sprintf("Setting %d environment variables from the job ("+
+		"precedence: %s, %d values shadowed, %d set as secrets)", len(envVars),
+		strings.Join(j.EnvPrecedence, " < "), shadowed, len(secretEnvVars)))
+
+	container = daggerio.SetSecretEnvVarsInContainer(j.Client, container, secretEnvVars)
+
+	return daggerio.SetEnvVarsInContainer(container, plainEnvVars)
+}
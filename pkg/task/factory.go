@@ -6,6 +6,7 @@ import (
 	"github.com/Excoriate/stiletto/internal/filesystem"
 	"github.com/Excoriate/stiletto/pkg/job"
 	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"time"
 )
 
 func NewTask(p *pipeline.Config, job *job.Job, actions []string,
@@ -60,14 +61,24 @@ func NewTask(p *pipeline.Config, job *job.Job, actions []string,
 		},
 
 		PreReqs: PreRequisites{
-			Files: []string{"Dockerfile"},
+			Files: job.StackDefinition.PreRequisites,
 		},
 
 		Actions: Actions{
 			CustomCommands:  actions,
-			DefaultCommands: []string{"docker", "build", "-t", randomContainerName, "."},
+			DefaultCommands: job.StackDefinition.Commands,
 		},
 
+		OutputPaths:  init.Outputs,
+		ArtifactsDir: GetArtifactsDir(init.ArtifactsDir, p.PipelineOpts.WorkDirPath, job.TargetDirPath),
+
+		LogsDir: GetLogsDir(init.LogsDir, p.PipelineOpts.WorkDirPath,
+			GetRunId(time.Now(), job.Id), taskName),
+		ShowOutput:      init.ShowOutput,
+		StderrTailLines: init.StderrTailLines,
+
+		Policy: init.Policy,
+
 		Ctx: job.Ctx,
 	}
 
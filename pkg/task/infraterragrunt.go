@@ -6,91 +6,51 @@ import (
 	"github.com/Excoriate/stiletto/internal/errors"
 )
 
+var infraTerraGruntTaskerOpts = TaskerOptions{DefaultCommands: listFilesCommands}
+
 var allowedTasks = []string{"PLAN", "APPLY", "DESTROY", "PLAN-ALL", "APPLY-ALL", "DESTROY-ALL"}
 
-func RunTaskInfraTerraGrunt(opt InitOptions) error {
+func RunTaskInfraTerraGrunt(opt InitOptions) (Output, error) {
 	taskSelector := common.NormaliseStringUpper(opt.Task)
 
 	// Check if the task is allowed
 	if !common.IsStringInSlice(taskSelector, allowedTasks) {
-		return errors.NewArgumentError(fmt.Sprintf("Task '%s' is not allowed. "+
+		return Output{}, errors.NewArgumentError(fmt.Sprintf("Task '%s' is not allowed. "+
 			"Allowed tasks are: %s", taskSelector, allowedTasks), nil)
 	}
 
 	taskPrefix := opt.JobCfg.Stack
-
-	p := opt.PipelineCfg
-	j := opt.JobCfg
-
-	actionCMDs := opt.ActionCommands
 	actionPrefix := fmt.Sprintf("%s:%s", taskPrefix, taskSelector)
 
+	var newAction func(t CoreTasker) func() (Output, error)
+	idempotent := false
+
 	switch taskSelector {
 	case "PLAN":
-		// New (core) instance of a task
-		c := NewTask(p, j, actionCMDs, &opt)
-
-		// New specific instance of a task (E.g.: Docker, AWS, etc.)
-		t := NewTaskInfraTerraGrunt(c, actionCMDs, &opt, actionPrefix)
-
-		// New action to execute (mapped to the --task passed from the command line)
-		a := NewInfraTerraGruntAction(t, actionPrefix)
-
-		// Run the action
-		_, err := a.Plan()
-		if err != nil {
-			return err
+		idempotent = true
+		newAction = func(t CoreTasker) func() (Output, error) {
+			return NewInfraTerraGruntAction(t, actionPrefix).Plan
 		}
 
 	case "APPLY":
-		// New (core) instance of a task
-		c := NewTask(p, j, actionCMDs, &opt)
-
-		// New specific instance of a task (E.g.: Docker, AWS, etc.)
-		t := NewTaskInfraTerraGrunt(c, actionCMDs, &opt, actionPrefix)
-
-		// New action to execute (mapped to the --task passed from the command line)
-		a := NewInfraTerraGruntAction(t, actionPrefix)
-
-		// Run the action
-		_, err := a.Apply()
-		if err != nil {
-			return err
+		newAction = func(t CoreTasker) func() (Output, error) {
+			return NewInfraTerraGruntAction(t, actionPrefix).Apply
 		}
 
 	case "DESTROY":
-		// New (core) instance of a task
-		c := NewTask(p, j, actionCMDs, &opt)
-
-		// New specific instance of a task (E.g.: Docker, AWS, etc.)
-		t := NewTaskInfraTerraGrunt(c, actionCMDs, &opt, actionPrefix)
-
-		// New action to execute (mapped to the --task passed from the command line)
-		a := NewInfraTerraGruntAction(t, actionPrefix)
-
-		// Run the action
-		_, err := a.Destroy()
-		if err != nil {
-			return err
+		newAction = func(t CoreTasker) func() (Output, error) {
+			return NewInfraTerraGruntAction(t, actionPrefix).Destroy
 		}
 
 	case "VALIDATE":
-		// New (core) instance of a task
-		c := NewTask(p, j, actionCMDs, &opt)
-
-		// New specific instance of a task (E.g.: Docker, AWS, etc.)
-		t := NewTaskInfraTerraGrunt(c, actionCMDs, &opt, actionPrefix)
-
-		// New action to execute (mapped to the --task passed from the command line)
-		a := NewInfraTerraGruntAction(t, actionPrefix)
-
-		// Run the action
-		_, err := a.Validate()
-		if err != nil {
-			return err
+		idempotent = true
+		newAction = func(t CoreTasker) func() (Output, error) {
+			return NewInfraTerraGruntAction(t, actionPrefix).Validate
 		}
 
+	default:
+		return Output{}, nil
 	}
 
-	return nil
+	return runTask(opt, actionPrefix, infraTerraGruntTaskerOpts, idempotent, newAction)
 }
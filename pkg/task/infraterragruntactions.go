@@ -28,6 +28,7 @@ type InfraTerraGruntActionArgs struct {
 	AWSRegion       string
 	AWSAccessKey    string
 	AWSSecretKey    string
+	AWSSessionToken string
 	TargetModuleDir string
 	Commands        []string
 	TgConfigFile    string
@@ -56,21 +57,26 @@ func (a *InfraTerraGruntAction) GetOptions() (InfraTerraGruntActionArgs, error)
 		awsCred := a.Task.GetJob().EnvVarsAWSScanned
 
 		args = InfraTerraGruntActionArgs{
-			AWSRegion:    awsCred["AWS_REGION"],
-			AWSAccessKey: awsCred["AWS_ACCESS_KEY_ID"],
-			AWSSecretKey: awsCred["AWS_SECRET_ACCESS_KEY"],
+			AWSRegion:       awsCred["AWS_REGION"],
+			AWSAccessKey:    awsCred["AWS_ACCESS_KEY_ID"],
+			AWSSecretKey:    awsCred["AWS_SECRET_ACCESS_KEY"],
+			AWSSessionToken: awsCred["AWS_SESSION_TOKEN"],
 		}
 	}
 
 	// If module dir is not set, then it'll fail.
-	cfg := config.Cfg{}
-	tgModuleDirCfg, err := cfg.GetFromViper("target-module")
+	tgCfg, err := config.GetInfraTerraGruntConfig()
 	if err != nil {
 		return InfraTerraGruntActionArgs{}, errors.NewActionCfgError("Failed to run this Terragrunt action, "+
-			"it cannot find the target module dir.", err)
+			"the config is invalid.", err)
 	}
 
-	tgModuleDirValue := tgModuleDirCfg.Value.(string)
+	if tgCfg.TargetModule == "" {
+		return InfraTerraGruntActionArgs{}, errors.NewActionCfgError("Failed to run this Terragrunt action, "+
+			"it cannot find the target module dir.", nil)
+	}
+
+	tgModuleDirValue := tgCfg.TargetModule
 	workDirPath := a.Task.GetPipeline().PipelineOpts.WorkDirPath
 
 	// The target module dir should be a relative of the working directory
@@ -94,13 +100,7 @@ func (a *InfraTerraGruntAction) GetOptions() (InfraTerraGruntActionArgs, error)
 	ux.ShowInfo(a.prefix, "The target module dir is: "+workDirPath)
 
 	// If commands are passed, validate them and use them
-	tgCmds, err := cfg.GetFromViperOrDefault("tg-commands", []string{})
-	if err != nil {
-		return InfraTerraGruntActionArgs{}, errors.NewActionCfgError("Failed to run this Terragrunt action, "+
-			"it cannot fetch or retrieve the 'tg-commands' configuration.", err)
-	}
-
-	tgCommands := tgCmds.Value.([]string)
+	tgCommands := tgCfg.Commands
 
 	if err := common.ValidateTerragruntCommands(tgCommands); err != nil {
 		return InfraTerraGruntActionArgs{}, errors.NewActionCfgError("Failed to run this Terragrunt action, "+
@@ -130,7 +130,7 @@ func (a *InfraTerraGruntAction) RunTGCommand(commands [][]string) (Output, error
 	// Reference required objects (container, client, context, etc.)
 	container := a.Task.GetJobContainerDefault()
 	client := a.Task.GetClient()
-	ctx := a.Task.GetJob().Ctx
+	ctx := a.Task.GetContext()
 	preRequiredFiles := []string{opts.TgConfigFile}
 
 	// Inherit the environment variables from the job.
@@ -157,43 +157,49 @@ func (a *InfraTerraGruntAction) RunTGCommand(commands [][]string) (Output, error
 		cmdsToRun = [][]string{opts.Commands}
 	}
 
-	if err := a.Task.RunCmdInContainer(configuredContainer, cmdsToRun, false, ctx); err != nil {
+	executedContainer, err := a.Task.RunCmdInContainer(configuredContainer, cmdsToRun, false, ctx)
+	if err != nil {
 		return Output{}, err
 	}
 
-	return Output{}, nil
+	return ExportOutputs(a.Task, executedContainer, a.prefix)
 }
 
-func (a *InfraTerraGruntAction) Plan() (Output, error) {
+// terraGruntActionCommands are the terragrunt commands of each action.
+var terraGruntActionCommands = map[string][]string{
+	"PLAN":     {"terragrunt", "plan"},
+	"APPLY":    {"terragrunt", "apply", "-auto-approve"},
+	"DESTROY":  {"terragrunt", "destroy", "-auto-approve"},
+	"VALIDATE": {"terragrunt", "validate"},
+}
+
+// getTerraGruntCommands returns the commands run by a terragrunt action (E.g.: 'PLAN'): the
+// config file is printed first, for troubleshooting. It returns nil if the action is unknown.
+func getTerraGruntCommands(action string) [][]string {
+	cmd, ok := terraGruntActionCommands[common.NormaliseStringUpper(action)]
+	if !ok {
+		return nil
+	}
+
 	inspectCfgFile := []string{"cat", "terragrunt.hcl"}
-	planCmd := []string{"terragrunt", "plan"}
 
-	cmds := [][]string{inspectCfgFile, planCmd}
-	return a.RunTGCommand(cmds)
+	return [][]string{inspectCfgFile, cmd}
 }
 
-func (a *InfraTerraGruntAction) Apply() (Output, error) {
-	inspectCfgFile := []string{"cat", "terragrunt.hcl"}
-	applyCmd := []string{"terragrunt", "apply", "-auto-approve"}
+func (a *InfraTerraGruntAction) Plan() (Output, error) {
+	return a.RunTGCommand(getTerraGruntCommands("PLAN"))
+}
 
-	cmds := [][]string{inspectCfgFile, applyCmd}
-	return a.RunTGCommand(cmds)
+func (a *InfraTerraGruntAction) Apply() (Output, error) {
+	return a.RunTGCommand(getTerraGruntCommands("APPLY"))
 }
 
 func (a *InfraTerraGruntAction) Destroy() (Output, error) {
-	inspectCfgFile := []string{"cat", "terragrunt.hcl"}
-	destroyCmd := []string{"terragrunt", "destroy", "-auto-approve"}
-
-	cmds := [][]string{inspectCfgFile, destroyCmd}
-	return a.RunTGCommand(cmds)
+	return a.RunTGCommand(getTerraGruntCommands("DESTROY"))
 }
 
 func (a *InfraTerraGruntAction) Validate() (Output, error) {
-	inspectCfgFile := []string{"cat", "terragrunt.hcl"}
-	validateCmd := []string{"terragrunt", "validate"}
-
-	cmds := [][]string{inspectCfgFile, validateCmd}
-	return a.RunTGCommand(cmds)
+	return a.RunTGCommand(getTerraGruntCommands("VALIDATE"))
 }
 
 func NewInfraTerraGruntAction(task CoreTasker, prefix string) *InfraTerraGruntAction {
@@ -1,6 +1,7 @@
 package task
 
 import (
+	"github.com/Excoriate/stiletto/pkg/config"
 	"github.com/Excoriate/stiletto/pkg/job"
 	"github.com/Excoriate/stiletto/pkg/pipeline"
 )
@@ -19,4 +20,20 @@ type InitOptions struct {
 
 	// Behaviour
 	ActionCommands []string
+	// Run the action commands through 'sh -c', instead of splitting them into their arguments.
+	ShellCommands bool
+	// Host script (relative to the work dir) run after the action commands.
+	Script string
+
+	// Outputs (paths in the container, globs allowed) to export into the artifacts dir.
+	Outputs      []string
+	ArtifactsDir string
+
+	// Logs of the commands.
+	LogsDir         string
+	ShowOutput      bool
+	StderrTailLines int
+
+	// Timeout and retry policy of the action.
+	Policy config.TaskPolicy
 }
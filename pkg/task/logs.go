@@ -0,0 +1,174 @@
+package task
+
+import (
+	"context"
+	"dagger.io/dagger"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLogsDir is where the output of the commands is written, relative to the work dir.
+	DefaultLogsDir = ".stiletto/logs"
+	// DefaultStderrTailLines is the number of stderr lines reported when a command fails.
+	DefaultStderrTailLines = 20
+)
+
+// CommandOutput is the captured output of a command run by a task.
+type CommandOutput struct {
+	Step     int
+	Command  []string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	LogFile  string // Empty if it couldn't be written.
+}
+
+// GetRunId returns the identifier of a run, used to group its logs: the start time, and the
+// (short) id of the job.
+func GetRunId(startedAt time.Time, jobId string) string {
+	if len(jobId) > 8 {
+		jobId = jobId[:8]
+	}
+
+	return fmt.Sprintf("%s-%s", startedAt.UTC().Format("20060102T150405Z"), jobId)
+}
+
+// GetLogsDir returns the host dir where the logs of the commands of a task are written:
+// '<logs dir>/<run>/<task>'. Relative logs dirs are relative to the work dir.
+func GetLogsDir(logsDir, workDirPath, runId, taskName string) string {
+	if logsDir == "" {
+		logsDir = filepath.FromSlash(DefaultLogsDir)
+	}
+
+	if !filepath.IsAbs(logsDir) {
+		logsDir = filepath.Join(workDirPath, logsDir)
+	}
+
+	return filepath.Join(logsDir, runId, common.NormaliseStringLower(taskName))
+}
+
+// getLogsRootDir returns the logs dir passed to GetLogsDir, which nests the logs of the task
+// under '<run>/<task>'.
+func getLogsRootDir(taskLogsDir string) string {
+	if taskLogsDir == "" {
+		return ""
+	}
+
+	return filepath.Dir(filepath.Dir(taskLogsDir))
+}
+
+func writeCommandLog(path string, out CommandOutput) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("$ %s\n--- stdout ---\n%s\n--- stderr ---\n%s\n--- exit code: %d ---\n",
+		strings.Join(out.Command, " "), strings.TrimRight(out.Stdout, "\n"),
+		strings.TrimRight(out.Stderr, "\n"), out.ExitCode)
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// printOutput writes each line of the output, prefixed with '[job/task/step]'.
+func printOutput(w io.Writer, prefix, output string) {
+	if output == "" {
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		_, _ = fmt.Fprintf(w, "%s %s\n", prefix, line)
+	}
+}
+
+// getCommandOutput returns the output of the command run, with the resolved secrets masked (the
+// output of a failed command comes from the engine error, which isn't scrubbed).
+func getCommandOutput(step int, result daggerio.ExecResult, secrets []string) CommandOutput {
+	return CommandOutput{
+		Step:     step,
+		Command:  result.Command,
+		Stdout:   common.MaskSecrets(result.Stdout, secrets),
+		Stderr:   common.MaskSecrets(result.Stderr, secrets),
+		ExitCode: result.ExitCode,
+	}
+}
+
+// runCommandsInContainer runs the commands sequentially, chained, so each one runs on the result
+// of the previous one. The stdout and stderr of each command (with the secrets masked) are
+// captured into the task result, written into its log file and, if show is set (or the task
+// shows its output), printed once each command completes (dagger doesn't stream the output of a
+// single command). It stops on the first command that fails, reporting the last lines of its
+// stderr.
+func runCommandsInContainer(t CoreTasker, uxPrefix string, container *dagger.Container,
+	commands [][]string, show bool, ctx context.Context) (*dagger.Container, error) {
+	ux := t.GetPipelineUXLog()
+	core := t.GetCoreTask()
+	show = show || core.ShowOutput
+	secrets := core.JobCfg.GetSecretValues()
+
+	for _, cmd := range commands {
+		step := len(core.Result.Commands) + 1
+		ux.ShowInfo(uxPrefix, fmt.Sprintf("Running command %s", cmd))
+
+		executed, result, err := daggerio.ExecCaptured(container, cmd, ctx)
+		if err != nil {
+			// The engine errors may include the output of the command.
+			err = fmt.Errorf("%s", common.MaskSecrets(err.Error(), secrets))
+			ux.ShowError(uxPrefix, fmt.Sprintf("Failed to run command %s", cmd), err)
+			return nil, errors.NewTaskExecutionError(fmt.Sprintf("Failed to run command %s",
+				cmd), err)
+		}
+
+		out := getCommandOutput(step, result, secrets)
+
+		if core.LogsDir != "" {
+			logFile := filepath.Join(core.LogsDir, filesystem.GetCommandLogFileName(step, cmd))
+			if err := writeCommandLog(logFile, out); err != nil {
+				ux.ShowWarning(uxPrefix, GetInfoMsg(core, fmt.Sprintf("Failed to write the log "+
+					"file %s: %s", logFile, err)))
+			} else {
+				out.LogFile = logFile
+			}
+		}
+
+		if show {
+			linePrefix := fmt.Sprintf("[%s/%s/%d]", common.NormaliseStringLower(core.JobCfg.Name),
+				common.NormaliseStringLower(core.Name), step)
+			printOutput(os.Stdout, linePrefix, out.Stdout)
+			printOutput(os.Stderr, linePrefix, out.Stderr)
+		}
+
+		core.Result.Commands = append(core.Result.Commands, out)
+
+		if out.ExitCode != 0 {
+			tailLines := core.StderrTailLines
+			if tailLines <= 0 {
+				tailLines = DefaultStderrTailLines
+			}
+
+			errMsg := fmt.Sprintf("Command %s failed with exit code %d", cmd, out.ExitCode)
+			if out.LogFile != "" {
+				errMsg = fmt.Sprintf("%s (log: %s)", errMsg, out.LogFile)
+			}
+
+			if stderr := common.GetLastLines(out.Stderr, tailLines); stderr != "" {
+				errMsg = fmt.Sprintf("%s. Last lines of stderr:\n%s", errMsg, stderr)
+			}
+
+			ux.ShowError(uxPrefix, fmt.Sprintf("Failed to run command %s", cmd), nil)
+			return nil, errors.NewTaskExecutionError(GetErrMsg(core, errMsg, nil), nil)
+		}
+
+		container = executed
+	}
+
+	return container, nil
+}
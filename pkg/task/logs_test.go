@@ -0,0 +1,30 @@
+package task
+
+import (
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommandLogMasksSecrets(t *testing.T) {
+	secret := "hunter2-from-ssm"
+	result := daggerio.ExecResult{
+		Command:  []string{"sh", "-c", "echo $DB_PASSWORD"},
+		Stdout:   "connecting with " + secret + "\n",
+		Stderr:   "auth failed for " + secret + "\n",
+		ExitCode: 1,
+	}
+
+	out := getCommandOutput(1, result, []string{secret})
+	logFile := filepath.Join(t.TempDir(), "logs", "01-sh.log")
+	assert.NoError(t, writeCommandLog(logFile, out))
+
+	content, err := os.ReadFile(logFile)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), secret, "A secret should never be written in a log")
+	assert.Contains(t, string(content), "connecting with ***")
+	assert.Contains(t, string(content), "auth failed for ***")
+	assert.NotContains(t, out.Stderr, secret, "The stderr is also reported in the errors")
+}
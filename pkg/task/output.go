@@ -0,0 +1,102 @@
+package task
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"os"
+	"strings"
+	"time"
+)
+
+// Status of a task run.
+const (
+	OutputStatusSucceeded = "succeeded"
+	OutputStatusSkipped   = "skipped"
+	OutputStatusCancelled = "cancelled"
+)
+
+// NewSkippedOutput returns the output of a task that didn't run, E.g.: because nothing changed.
+func NewSkippedOutput(reason string) Output {
+	return Output{
+		Status:       OutputStatusSkipped,
+		StatusReason: reason,
+	}
+}
+
+// NewCancelledOutput returns the output of a task interrupted by a signal (E.g.: Ctrl-C).
+func NewCancelledOutput(sig os.Signal) Output {
+	return Output{
+		ExitCode:     common.GetInterruptExitCode(sig),
+		IsError:      true,
+		Status:       OutputStatusCancelled,
+		StatusReason: fmt.Sprintf("interrupted by %s", sig),
+	}
+}
+
+// ShowIfCancelled reports the task as cancelled, if the run was interrupted (E.g.: by Ctrl-C),
+// returning whether it was. Execute runs the cleanup hooks (E.g.: closing the dagger session) and
+// exits with the code of the signal (130 for SIGINT, 143 for SIGTERM).
+func ShowIfCancelled(stack, job, taskName string) bool {
+	sig := common.GetInterruptSignal()
+	if sig == nil {
+		return false
+	}
+
+	ShowOutputStatus(stack, job, taskName, NewCancelledOutput(sig))
+
+	return true
+}
+
+// ShowOutputStatus reports the status of a task run.
+func ShowOutputStatus(stack, job, taskName string, out Output) {
+	ux := tui.NewTUIMessage()
+	prefix := fmt.Sprintf("%s:%s", stack, job)
+	msg := fmt.Sprintf("Task '%s' %s", taskName, out.Status)
+
+	if out.StatusReason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, out.StatusReason)
+	}
+
+	switch out.Status {
+	case OutputStatusSucceeded:
+		ux.ShowSuccess(prefix, msg)
+	case OutputStatusSkipped, OutputStatusCancelled:
+		ux.ShowWarning(prefix, msg)
+	default:
+		ux.ShowInfo(prefix, msg)
+	}
+
+	// The attempts are only worth showing if the action was retried.
+	if len(out.Attempts) > 1 {
+		for _, attempt := range out.Attempts {
+			result := "succeeded"
+			if attempt.Error != "" {
+				result = fmt.Sprintf("failed: %s", attempt.Error)
+			}
+
+			ux.ShowInfo(prefix, fmt.Sprintf("Attempt %d (%s): %s", attempt.Number,
+				attempt.Duration.Round(time.Millisecond), result))
+		}
+	}
+
+	for _, cmd := range out.Commands {
+		cmdMsg := fmt.Sprintf("Command %d: %s (exit code %d)", cmd.Step, strings.Join(cmd.Command, " "),
+			cmd.ExitCode)
+		if cmd.LogFile != "" {
+			cmdMsg = fmt.Sprintf("%s, log: %s", cmdMsg, cmd.LogFile)
+		}
+
+		ux.ShowInfo(prefix, cmdMsg)
+	}
+
+	for _, artifact := range out.Artifacts {
+		kind := "file"
+		if artifact.IsDir {
+			kind = "dir"
+		}
+
+		ux.ShowInfo(prefix, fmt.Sprintf("Artifact (%s): %s (from %s)", kind, artifact.HostPath,
+			artifact.Source))
+	}
+}
@@ -0,0 +1,306 @@
+package task
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"os"
+	"path/filepath"
+)
+
+// Kind of the steps of an action plan.
+const (
+	// ActionStepKindContainer steps run a command in the container.
+	ActionStepKindContainer = "container"
+	// ActionStepKindBuild steps build (and maybe push) the Dockerfile of a directory.
+	ActionStepKindBuild = "build"
+	// ActionStepKindHost steps run on the host, E.g.: exporting a file from the container.
+	ActionStepKindHost = "host"
+	// ActionStepKindAPI steps call a cloud provider API.
+	ActionStepKindAPI = "api"
+)
+
+// ActionStep is a step that an action would run.
+type ActionStep struct {
+	Kind        string   `json:"kind"`
+	Image       string   `json:"image,omitempty"` // Only set if it's not the job image.
+	Command     []string `json:"command,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// PlanMount is a host path mounted into the container by the action.
+type PlanMount struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+// PreRequisiteCheck is a file that should exist (in the host) before the action runs.
+type PreRequisiteCheck struct {
+	File   string `json:"file"`
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+}
+
+// ActionPlan is what the action of a task would do, resolved without running it (nor connecting
+// to the dagger engine, or to AWS).
+type ActionPlan struct {
+	Action string `json:"action"`
+	// Workdir of the container, if it isn't the target dir. E.g.: the terragrunt module.
+	ContainerWorkDir string              `json:"container_work_dir,omitempty"`
+	PreRequisites    []PreRequisiteCheck `json:"pre_requisites"`
+	Mounts           []PlanMount         `json:"mounts,omitempty"`
+	Steps            []ActionStep        `json:"steps"`
+	Outputs          []string            `json:"outputs,omitempty"`
+}
+
+// PlanFunc returns the plan of the task of the options passed. The job of the options isn't
+// initialised (it'd require the dagger engine), so the stack is passed instead.
+type PlanFunc func(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error)
+
+// CheckPreRequisites checks that the files exist in the host dir.
+func CheckPreRequisites(dir string, files []string) []PreRequisiteCheck {
+	checks := []PreRequisiteCheck{}
+
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		_, err := os.Stat(path)
+		checks = append(checks, PreRequisiteCheck{File: file, Path: path, Exists: err == nil})
+	}
+
+	return checks
+}
+
+func newContainerSteps(commands [][]string) []ActionStep {
+	var steps []ActionStep
+	for _, cmd := range commands {
+		steps = append(steps, ActionStep{Kind: ActionStepKindContainer, Command: cmd})
+	}
+
+	return steps
+}
+
+func getUnsupportedTaskErr(stack, task string, supported []string) error {
+	return errors.NewArgumentError(fmt.Sprintf("The task '%s' is not supported by the %s "+
+		"stack. Supported tasks are: %s", task, stack, supported), nil)
+}
+
+// getCfgValueOrPlaceholder returns the value of the key (from the flags, the config file or the
+// env vars), or a placeholder if it isn't set.
+func getCfgValueOrPlaceholder(key string) string {
+	cfg := config.Cfg{}
+
+	value, err := cfg.GetFromAny(key)
+	if err != nil {
+		return fmt.Sprintf("<%s not set>", key)
+	}
+
+	return fmt.Sprintf("%v", value.Value)
+}
+
+// PlanTaskRun returns the plan of the generic 'run' task.
+func PlanTaskRun(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error) {
+	workDirPath := opt.PipelineCfg.PipelineOpts.WorkDirPath
+
+	commands, err := getRunCommands(opt.ActionCommands, opt.ShellCommands)
+	if err != nil {
+		return ActionPlan{}, err
+	}
+
+	if len(commands) == 0 && opt.Script == "" && len(stack.Commands) > 0 {
+		commands = stack.Commands
+	}
+
+	plan := ActionPlan{
+		Action:        fmt.Sprintf("%s:RUN", stack.Name),
+		PreRequisites: CheckPreRequisites(opt.PipelineCfg.PipelineOpts.TargetDirPath, stack.PreRequisites),
+		Outputs:       opt.Outputs,
+	}
+
+	if opt.Script != "" {
+		scriptPath, scriptMountPath, scriptCommand, err := getRunScript(workDirPath, opt.Script)
+		if err != nil {
+			return ActionPlan{}, errors.NewActionCfgError(fmt.Sprintf("Cannot read the script %s",
+				opt.Script), err)
+		}
+
+		plan.Mounts = append(plan.Mounts, PlanMount{Source: scriptPath, Target: scriptMountPath,
+			Kind: "file"})
+		commands = append(commands, scriptCommand)
+	}
+
+	if len(commands) == 0 {
+		return ActionPlan{}, errors.NewActionCfgError(fmt.Sprintf("No commands (--cmd) or "+
+			"script (--script) were passed, and the stack %s has no default commands",
+			stack.Name), nil)
+	}
+
+	plan.Steps = newContainerSteps(commands)
+
+	return plan, nil
+}
+
+// PlanTaskDocker returns the plan of the docker tasks.
+func PlanTaskDocker(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+	if taskSelector != "BUILD" {
+		return ActionPlan{}, getUnsupportedTaskErr(stack.Name, opt.Task, []string{"BUILD"})
+	}
+
+	targetDirPath := opt.PipelineCfg.PipelineOpts.TargetDirPath
+
+	return ActionPlan{
+		Action:        "DOCKER-BUILD",
+		PreRequisites: CheckPreRequisites(targetDirPath, []string{"Dockerfile"}),
+		Steps:         getDockerBuildSteps(targetDirPath),
+		Outputs:       opt.Outputs,
+	}, nil
+}
+
+// PlanTaskInfraTerraGrunt returns the plan of the terragrunt tasks.
+func PlanTaskInfraTerraGrunt(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+
+	commands := getTerraGruntCommands(taskSelector)
+	if !common.IsStringInSlice(taskSelector, allowedTasks) || commands == nil {
+		return ActionPlan{}, getUnsupportedTaskErr(stack.Name, opt.Task, allowedTasks)
+	}
+
+	tgCfg, err := config.GetInfraTerraGruntConfig()
+	if err != nil {
+		return ActionPlan{}, err
+	}
+
+	targetModuleDir := filepath.Join(opt.PipelineCfg.PipelineOpts.WorkDirPath, tgCfg.TargetModule)
+
+	return ActionPlan{
+		Action:           fmt.Sprintf("%s:%s", stack.Name, taskSelector),
+		ContainerWorkDir: daggerio.NormaliseDaggerPath(tgCfg.TargetModule),
+		PreRequisites:    CheckPreRequisites(targetModuleDir, []string{"terragrunt.hcl"}),
+		Steps:            newContainerSteps(commands),
+		Outputs:          opt.Outputs,
+	}, nil
+}
+
+// PlanTaskAWSECR returns the plan of the AWS ECR tasks.
+func PlanTaskAWSECR(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+	if taskSelector != "PUSH" {
+		return ActionPlan{}, getUnsupportedTaskErr(stack.Name, opt.Task, []string{"PUSH"})
+	}
+
+	ecrCfg, err := config.GetAWSECRConfig()
+	if err != nil {
+		return ActionPlan{}, err
+	}
+
+	cfg := config.Cfg{}
+	args := AWSECRPushActionArgs{
+		Repository:        getCfgValueOrPlaceholder("ecr-repository"),
+		Registry:          getCfgValueOrPlaceholder("ecr-registry"),
+		Tag:               "latest",
+		RunECRLoginInHost: !cfg.IsRunningInVendorAutomation(),
+	}
+
+	if ecrCfg.Tag != "" {
+		args.Tag = ecrCfg.Tag
+	} else if ecrCfg.GenerateRandomTag {
+		args.Tag = "<random>"
+	}
+
+	targetDirPath := opt.PipelineCfg.PipelineOpts.TargetDirPath
+
+	return ActionPlan{
+		Action:        fmt.Sprintf("AWS:ECR:%s", taskSelector),
+		PreRequisites: CheckPreRequisites(targetDirPath, []string{"Dockerfile"}),
+		Steps:         args.GetPlannedSteps(targetDirPath),
+	}, nil
+}
+
+// PlanTaskAWSECS returns the plan of the AWS ECS tasks.
+func PlanTaskAWSECS(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+	if taskSelector != "DEPLOY" {
+		return ActionPlan{}, getUnsupportedTaskErr(stack.Name, opt.Task, []string{"DEPLOY"})
+	}
+
+	args := AWSECSDeployActionArgs{
+		ClusterName:              getCfgValueOrPlaceholder("ecs-cluster"),
+		ServiceName:              getCfgValueOrPlaceholder("ecs-service"),
+		TaskDefinition:           getCfgValueOrPlaceholder("task-definition"),
+		ImageTagOrReleaseVersion: getCfgValueOrPlaceholder("release-version"),
+		Image:                    getCfgValueOrPlaceholder("image-url"),
+	}
+
+	return ActionPlan{
+		Action:        fmt.Sprintf("AWS:ECS:%s", taskSelector),
+		PreRequisites: []PreRequisiteCheck{},
+		Steps:         args.GetPlannedSteps(),
+	}, nil
+}
+
+// PlanTaskAWSLambda returns the plan of the AWS Lambda tasks.
+func PlanTaskAWSLambda(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+	workDirPath := opt.PipelineCfg.PipelineOpts.WorkDirPath
+
+	args, err := getLambdaActionArgs(opt.PipelineCfg.UXMessage, workDirPath)
+	if err != nil {
+		return ActionPlan{}, err
+	}
+
+	plan := ActionPlan{
+		Action:        fmt.Sprintf("AWS:LAMBDA:%s", taskSelector),
+		PreRequisites: []PreRequisiteCheck{},
+	}
+
+	switch taskSelector {
+	case "PACKAGE":
+		steps, err := args.GetPackageSteps()
+		if err != nil {
+			return ActionPlan{}, err
+		}
+
+		plan.Steps = steps
+		plan.Outputs = opt.Outputs
+
+	case "PUBLISH":
+		if args.ImageURI == "" {
+			plan.PreRequisites = CheckPreRequisites(filepath.Dir(args.ZipFile),
+				[]string{filepath.Base(args.ZipFile)})
+		}
+
+		plan.Steps = args.GetPublishSteps()
+
+	case "DEPLOY":
+		plan.Steps = args.GetDeploySteps()
+
+	default:
+		return ActionPlan{}, getUnsupportedTaskErr(stack.Name, opt.Task,
+			[]string{"PACKAGE", "PUBLISH", "DEPLOY"})
+	}
+
+	return plan, nil
+}
+
+// PlanTaskAWSS3 returns the plan of the AWS S3 tasks.
+func PlanTaskAWSS3(opt InitOptions, stack daggerio.StackDefinition) (ActionPlan, error) {
+	taskSelector := common.NormaliseStringUpper(opt.Task)
+	if taskSelector != "SYNC" {
+		return ActionPlan{}, getUnsupportedTaskErr(stack.Name, opt.Task, []string{"SYNC"})
+	}
+
+	args, err := getS3SyncActionArgs(opt.PipelineCfg.UXMessage)
+	if err != nil {
+		return ActionPlan{}, err
+	}
+
+	return ActionPlan{
+		Action:        fmt.Sprintf("AWS:S3:%s", taskSelector),
+		PreRequisites: []PreRequisiteCheck{},
+		Steps:         args.GetPlannedSteps(),
+		Outputs:       opt.Outputs,
+	}, nil
+}
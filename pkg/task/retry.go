@@ -0,0 +1,70 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/errors"
+)
+
+// runAction runs the action of the task following its execution policy: each attempt has the
+// timeout as deadline (which cancels the dagger calls in progress), and the failed attempts are
+// retried following the retry policy. An action that isn't idempotent (E.g.: a deploy) is only
+// retried on the errors matching the retry-on patterns, since a failed attempt may have applied
+// part of its changes. Each attempt runs the action with its own context, derived from the job's
+// one, which isn't modified. The attempts are recorded in the output.
+func runAction(c *Task, uxPrefix string, idempotent bool,
+	action func(ctx context.Context) (Output, error)) (Output, error) {
+	ux := c.PipelineCfg.UXMessage
+	j := c.JobCfg
+	policy := c.Policy
+
+	if !idempotent && policy.Retry.MaxAttempts > 1 && len(policy.Retry.RetryOn) == 0 {
+		ux.ShowWarning(uxPrefix, GetInfoMsg(c, "The action isn't idempotent, so it's only "+
+			"retried on the errors matching 'retry-on' (none was set): it runs once"))
+		policy.Retry.MaxAttempts = 1
+	}
+
+	parentCtx := j.Ctx
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+
+	var out Output
+	attempts, err := common.Retry(parentCtx, policy.Retry, func(attempt int) error {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if policy.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(parentCtx, policy.Timeout)
+		} else {
+			ctx, cancel = context.WithCancel(parentCtx)
+		}
+		defer cancel()
+
+		c.Result.Commands = nil
+
+		if attempt > 1 {
+			ux.ShowWarning(uxPrefix, GetInfoMsg(c, fmt.Sprintf("Retrying the action, attempt %d "+
+				"of %d", attempt, policy.Retry.MaxAttempts)))
+		}
+
+		var err error
+		out, err = action(ctx)
+
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return errors.NewTaskExecutionError(GetErrMsg(c, fmt.Sprintf("The action timed out "+
+				"after %s", policy.Timeout), nil), err)
+		}
+
+		return err
+	})
+
+	out.Attempts = attempts
+
+	if err != nil && len(attempts) > 1 {
+		return out, errors.NewTaskExecutionError(GetErrMsg(c, fmt.Sprintf("The action failed "+
+			"after %d attempts", len(attempts)), nil), err)
+	}
+
+	return out, err
+}
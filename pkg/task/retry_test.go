@@ -0,0 +1,85 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
+	"github.com/Excoriate/stiletto/pkg/job"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRunActionRetriesOnlyIdempotentActions(t *testing.T) {
+	newTask := func(retryOn []string) *Task {
+		return &Task{
+			Name:        "DEPLOY",
+			PipelineCfg: &pipeline.Config{UXMessage: tui.NewTUIMessage()},
+			JobCfg:      &job.Job{},
+			Policy: config.TaskPolicy{
+				Timeout: time.Minute,
+				Retry: common.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond,
+					RetryOn: retryOn},
+			},
+		}
+	}
+
+	runs := 0
+	failing := func(ctx context.Context) (Output, error) {
+		runs++
+		return Output{}, fmt.Errorf("throttled")
+	}
+
+	_, err := runAction(newTask(nil), "TEST", true, failing)
+	assert.Error(t, err)
+	assert.Equal(t, 3, runs, "An idempotent action should be retried on any error")
+
+	runs = 0
+	_, err = runAction(newTask(nil), "TEST", false, failing)
+	assert.Error(t, err)
+	assert.Equal(t, 1, runs, "A non-idempotent action shouldn't be retried without retry-on")
+
+	runs = 0
+	_, err = runAction(newTask([]string{"throttled"}), "TEST", false, failing)
+	assert.Error(t, err)
+	assert.Equal(t, 3, runs, "A non-idempotent action should be retried on the retry-on errors")
+}
+
+func TestRunActionUsesALocalContextPerAttempt(t *testing.T) {
+	type ctxKey struct{}
+
+	jobCtx := context.WithValue(context.Background(), ctxKey{}, "job")
+	j := &job.Job{Ctx: jobCtx}
+	c := &Task{
+		Name:        "BUILD",
+		PipelineCfg: &pipeline.Config{UXMessage: tui.NewTUIMessage()},
+		JobCfg:      j,
+		Ctx:         jobCtx,
+		Policy: config.TaskPolicy{
+			Timeout: time.Minute,
+			Retry:   common.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+		},
+	}
+
+	var attemptCtxs []context.Context
+	_, err := runAction(c, "TEST", true, func(ctx context.Context) (Output, error) {
+		attemptCtxs = append(attemptCtxs, ctx)
+
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline, "Each attempt should have the timeout of the task")
+		assert.Equal(t, "job", ctx.Value(ctxKey{}), "The attempt should derive from the job's one")
+		assert.Equal(t, jobCtx, j.Ctx, "The job context shouldn't be replaced")
+		assert.Equal(t, jobCtx, c.Ctx, "The task context shouldn't be replaced")
+
+		return Output{}, fmt.Errorf("failed")
+	})
+
+	assert.Error(t, err)
+	assert.Len(t, attemptCtxs, 2)
+	assert.NotEqual(t, attemptCtxs[0], attemptCtxs[1])
+	assert.Error(t, attemptCtxs[0].Err(), "The context of an attempt is cancelled once it ends")
+	assert.Equal(t, jobCtx, j.Ctx)
+}
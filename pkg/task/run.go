@@ -0,0 +1,52 @@
+package task
+
+import (
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/errors"
+)
+
+// RunTaskRun is the entry point of the generic 'run' task: it runs the commands passed (or the
+// default commands of the stack), and the script (if any), in the stack container.
+func RunTaskRun(opt InitOptions) (Output, error) {
+	actionPrefix := fmt.Sprintf("%s:RUN", opt.JobCfg.Stack)
+
+	commands, err := getRunCommands(opt.ActionCommands, opt.ShellCommands)
+	if err != nil {
+		return Output{}, err
+	}
+
+	// No default commands: the action falls back to the ones of the stack. The commands can do
+	// anything, so they aren't considered idempotent.
+	return runTask(opt, actionPrefix, TaskerOptions{}, false, func(t CoreTasker) func() (Output, error) {
+		return func() (Output, error) {
+			return NewRunAction(t, actionPrefix).RunCommands(commands, opt.Script)
+		}
+	})
+}
+
+// getRunCommands returns the arguments of the commands passed to the 'run' task: split following
+// the shell quoting rules, or wrapped into 'sh -c' if they run through a shell.
+func getRunCommands(cmds []string, shell bool) ([][]string, error) {
+	var commands [][]string
+	for _, cmd := range cmds {
+		if shell {
+			commands = append(commands, common.GetShellCommand(cmd))
+			continue
+		}
+
+		args, err := common.SplitCommand(cmd)
+		if err != nil {
+			return nil, errors.NewArgumentError(err.Error(), nil)
+		}
+
+		if len(args) == 0 {
+			return nil, errors.NewArgumentError(fmt.Sprintf("Invalid command '%s', "+
+				"it's empty", cmd), nil)
+		}
+
+		commands = append(commands, args)
+	}
+
+	return commands, nil
+}
@@ -0,0 +1,121 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/common"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+type RunAction struct {
+	Task   CoreTasker
+	prefix string // How the UX messages should be prefixed
+	Id     string // The ID of the task
+	Name   string // The name of the task
+	Ctx    context.Context
+}
+
+type RunActions interface {
+	RunCommands(commands [][]string, script string) (Output, error)
+}
+
+// RunCommands runs the commands sequentially in the stack container, with the target dir mounted
+// and the job env vars set, and then the script (if any). It stops on the first command that
+// fails.
+func (a *RunAction) RunCommands(commands [][]string, script string) (Output, error) {
+	uxLog := a.Task.GetPipelineUXLog()
+
+	if len(commands) == 0 && script == "" {
+		if defaultCommands := a.Task.GetCoreTask().Actions.DefaultCommands; len(defaultCommands) > 0 {
+			uxLog.ShowInfo(a.prefix, fmt.Sprintf("No commands passed, running the default "+
+				"commands of the stack: %s", common.JoinCommands(defaultCommands, "; ")))
+			commands = defaultCommands
+		}
+	}
+
+	if len(commands) == 0 && script == "" {
+		errMsg := fmt.Sprintf("Failed to run action: 'RunCommands' - No commands (--cmd) or "+
+			"script (--script) were passed, and the stack %s has no default commands",
+			a.Task.GetJob().Stack)
+		return Output{}, errors.NewActionCfgError(errMsg, nil)
+	}
+
+	// Reference required objects (container, client, context, etc.)
+	container := a.Task.GetJobContainerDefault()
+	client := a.Task.GetClient()
+	ctx := a.Task.GetContext()
+	preRequiredFiles := a.Task.GetCoreTask().PreReqs.Files
+
+	// Inherit the environment variables from the job.
+	preConfiguredContainer, err := a.Task.SetEnvVarsFromJob(container)
+	if err != nil {
+		errMsg := "Failed to run action: 'RunCommands' - Cannot set the environment variables from the job"
+		uxLog.ShowError(a.prefix, errMsg, err)
+		return Output{}, errors.NewActionCfgError(errMsg, err)
+	}
+
+	// Mount required directories.
+	workDirPath := a.Task.GetPipeline().PipelineOpts.WorkDirPath
+	targetDir := a.Task.GetCoreTask().Dirs.TargetDir
+	configuredContainer, err := a.Task.MountDir(workDirPath, targetDir, client,
+		preConfiguredContainer, preRequiredFiles, ctx)
+	if err != nil {
+		return Output{}, err
+	}
+
+	if script != "" {
+		scriptPath, scriptMountPath, scriptCommand, err := getRunScript(workDirPath, script)
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to run action: 'RunCommands' - Cannot read the script %s",
+				script)
+			uxLog.ShowError(a.prefix, errMsg, err)
+			return Output{}, errors.NewActionCfgError(errMsg, err)
+		}
+
+		configuredContainer = daggerio.MountHostFile(client, configuredContainer, scriptPath,
+			scriptMountPath)
+
+		uxLog.ShowInfo(a.prefix, fmt.Sprintf("Running the script %s (mounted at %s)", script,
+			scriptMountPath))
+		commands = append(commands, scriptCommand)
+	}
+
+	executedContainer, err := a.Task.RunCmdInContainer(configuredContainer, commands, false, ctx)
+	if err != nil {
+		return Output{}, err
+	}
+
+	return ExportOutputs(a.Task, executedContainer, a.prefix)
+}
+
+// getRunScript returns the host path of the script (relative to the work dir, unless it's
+// absolute), the path it's mounted at in the container, and the command that runs it.
+func getRunScript(workDirPath, script string) (string, string, []string, error) {
+	scriptPath := script
+	if !filepath.IsAbs(scriptPath) {
+		scriptPath = filepath.Join(workDirPath, scriptPath)
+	}
+
+	content, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	scriptMountPath := path.Join(daggerio.ScriptsMountPath, filepath.Base(scriptPath))
+
+	return scriptPath, scriptMountPath, common.GetScriptCommand(scriptMountPath, string(content)),
+		nil
+}
+
+func NewRunAction(task CoreTasker, prefix string) RunActions {
+	return &RunAction{
+		Task:   task,
+		prefix: prefix,
+		Id:     common.GetUUID(),
+		Name:   "Run Commands",
+	}
+}
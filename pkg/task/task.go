@@ -3,8 +3,10 @@ package task
 import (
 	"context"
 	"dagger.io/dagger"
+	"github.com/Excoriate/stiletto/internal/common"
 	"github.com/Excoriate/stiletto/internal/daggerio"
 	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/config"
 	"github.com/Excoriate/stiletto/pkg/job"
 	"github.com/Excoriate/stiletto/pkg/pipeline"
 )
@@ -15,6 +17,7 @@ type CoreTasker interface {
 	GetPipelineUXLog() tui.TUIMessenger
 	ConvertDir(c *dagger.Client, dir string) (*dagger.Directory, error)
 	GetJob() *job.Job
+	GetContext() context.Context
 	GetCoreTask() *Task
 	GetJobContainerImage() string
 	GetJobContainerDefault() *dagger.Container
@@ -33,7 +36,7 @@ type CoreTasker interface {
 		filesPreRequisites []string, ctx context.Context) (*dagger.Container, error)
 
 	RunCmdInContainer(container *dagger.Container, commands [][]string,
-		stdOutEnabled bool, ctx context.Context) error
+		stdOutEnabled bool, ctx context.Context) (*dagger.Container, error)
 }
 
 type Runner struct {
@@ -61,6 +64,19 @@ type Task struct {
 	PreReqs PreRequisites
 	Actions Actions
 
+	// Paths (in the container) exported into the host artifacts dir, after the execution.
+	OutputPaths  []string
+	ArtifactsDir string
+
+	// Output of the commands: the dir of their log files, whether it's printed as each command
+	// completes, and the stderr lines reported when one fails.
+	LogsDir         string
+	ShowOutput      bool
+	StderrTailLines int
+
+	// Timeout and retry policy of the action.
+	Policy config.TaskPolicy
+
 	// Output
 	Result Output
 
@@ -84,11 +100,20 @@ type Output struct {
 	ExitCode     int
 	DaggerOutput interface{}
 	IsError      bool
+	Status       string // One of the OutputStatus* values.
+	StatusReason string
+	// Declared outputs, exported into the host artifacts dir.
+	Artifacts []Artifact
+	// Captured output of the commands run, in order.
+	Commands []CommandOutput
+	// Attempts of the action, in order (more than one if it was retried).
+	Attempts []common.RetryAttempt
 }
 
 type Actions struct {
-	CustomCommands  []string
-	DefaultCommands []string
+	CustomCommands []string
+	// Commands declared by the stack, run if the task doesn't pass any.
+	DefaultCommands [][]string
 }
 
 type PreRequisites struct {
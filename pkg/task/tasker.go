@@ -0,0 +1,247 @@
+package task
+
+import (
+	"context"
+	"dagger.io/dagger"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/tui"
+	"github.com/Excoriate/stiletto/pkg/job"
+	"github.com/Excoriate/stiletto/pkg/pipeline"
+	"path/filepath"
+)
+
+// Tasker is the CoreTasker of all the tasks (E.g.: Docker, AWS, etc.), which only differ in their
+// TaskerOptions. What each task does is defined by its actions.
+type Tasker struct {
+	Init     *InitOptions
+	Cfg      *Task
+	Actions  []string
+	UXPrefix string
+	Opts     TaskerOptions
+	// Ctx is the context of the attempt that runs the actions, with the timeout of the task.
+	Ctx context.Context
+}
+
+// TaskerOptions are the defaults of a task.
+type TaskerOptions struct {
+	// DefaultCommands are run if no commands are passed. E.g.: to list the mounted files.
+	DefaultCommands [][]string
+	// UseDockerIgnore filters the uploaded files with the .dockerignore file too (for the tasks
+	// that build images).
+	UseDockerIgnore bool
+}
+
+// listFilesCommands list the files mounted in the container.
+var listFilesCommands = [][]string{{"ls", "-ltrh"}}
+
+func (t *Tasker) RunCmdInContainer(container *dagger.Container, commands [][]string,
+	stdOutEnabled bool, ctx context.Context) (*dagger.Container, error) {
+	if len(commands) == 0 {
+		commands = t.Opts.DefaultCommands
+	}
+
+	return runCommandsInContainer(t, t.UXPrefix, container, commands, stdOutEnabled, ctx)
+}
+
+func (t *Tasker) SetEnvVarsFromJob(container *dagger.Container) (*dagger.Container, error) {
+	return setEnvVarsFromJob(t.Cfg.PipelineCfg.UXMessage, t.UXPrefix, t.GetJob(), container)
+}
+
+func (t *Tasker) MountDir(workDirPath, targetDir string, client *dagger.Client,
+	container *dagger.Container,
+	filesPreRequisites []string, ctx context.Context) (*dagger.Container, error) {
+	ux := tui.NewTUIMessage()
+
+	if targetDir == "" {
+		ux.ShowWarning(t.UXPrefix, "An empty directory was passed to be a Target directory ("+
+			"also known as Execution path), "+
+			"hence the default working directory will be used resolved from the '.' value")
+
+		targetDir = "."
+	}
+
+	if workDirPath == "" {
+		ux.ShowWarning(t.UXPrefix, "An empty directory was passed to be a Working directory ("+
+			"also known as Execution path), "+
+			"hence the default working directory will be used resolved from the '.' value")
+
+		workDirPath = "."
+	}
+
+	if targetDir != "." && len(filesPreRequisites) > 0 {
+		ux.ShowInfo(t.UXPrefix, "The target directory is not the working directory, "+
+			"therefore the files pre-requisites will be verified before mounting the directory")
+
+		var targetDirFullPath string
+		if workDirPath != "" && workDirPath != "." {
+			targetDirFullPath = filepath.Join(workDirPath, targetDir)
+		} else {
+			targetDirFullPath = targetDir
+		}
+
+		if err := daggerio.VerifyFileEntriesInMountedDir(client, targetDirFullPath,
+			filesPreRequisites, ctx); err != nil {
+			ux.ShowError(t.UXPrefix, "Failed to mount the directory", err)
+			return nil, err
+		}
+	}
+
+	uploadOpts, err := GetUploadDirOpts(t.Cfg, t.UXPrefix, workDirPath, t.Opts.UseDockerIgnore)
+	if err != nil {
+		ux.ShowError(t.UXPrefix, "Failed to resolve the files to upload", err)
+		return nil, err
+	}
+
+	workDirDagger, err := daggerio.GetDaggerDir(t.GetClient(), workDirPath, uploadOpts)
+
+	if err != nil {
+		ux.ShowError(t.UXPrefix,
+			fmt.Sprintf("Failed to mount the working directory (with value '.'), failed "+
+				"to build a dagger directory from the directory"), err)
+
+		return nil, err
+	}
+
+	containerMounted, err := daggerio.MountDir(container, workDirDagger, targetDir)
+
+	if err != nil {
+		ux.ShowError(t.UXPrefix,
+			fmt.Sprintf("Failed to mount directory %s", targetDir), err)
+
+		return nil, err
+	}
+
+	return containerMounted, nil
+}
+
+func (t *Tasker) GetPipelineUXLog() tui.TUIMessenger {
+	return t.Cfg.PipelineCfg.UXMessage
+}
+
+func (t *Tasker) GetClient() *dagger.Client {
+	return t.Cfg.JobCfg.Client
+}
+
+func (t *Tasker) GetPipeline() *pipeline.Config {
+	return t.Cfg.PipelineCfg
+}
+
+func (t *Tasker) GetJob() *job.Job {
+	return t.Cfg.JobCfg
+}
+
+// GetContext returns the context the actions run with: the one of the current attempt, or the
+// one of the job.
+func (t *Tasker) GetContext() context.Context {
+	if t.Ctx != nil {
+		return t.Ctx
+	}
+
+	return t.Cfg.JobCfg.Ctx
+}
+
+// ConvertDir uploads the host dir (E.g.: the build context of a Dockerfile), filtered the same
+// way as the mounted dirs.
+func (t *Tasker) ConvertDir(c *dagger.Client, dir string) (*dagger.Directory, error) {
+	uploadOpts, err := GetUploadDirOpts(t.Cfg, t.UXPrefix, dir, t.Opts.UseDockerIgnore)
+	if err != nil {
+		return nil, err
+	}
+
+	return daggerio.GetDaggerDir(c, dir, uploadOpts)
+}
+
+func (t *Tasker) GetCoreTask() *Task {
+	return t.Cfg
+}
+
+func (t *Tasker) GetJobContainerImage() string {
+	return t.Cfg.JobCfg.ContainerImageURL
+}
+
+func (t *Tasker) GetJobContainerDefault() *dagger.Container {
+	return t.Cfg.JobCfg.ContainerDefault
+}
+
+func (t *Tasker) GetJobEnvVars() map[string]string {
+	return t.Cfg.EnvVarsInheritFromJob
+}
+
+func (t *Tasker) PushImage(addr string, container *dagger.Container,
+	dockerFileDir *dagger.Directory, ctx context.Context) error {
+	containerBuilt := daggerio.SetLabelsInContainer(container.Build(dockerFileDir),
+		t.Cfg.JobCfg.GetImageLabels())
+	_, err := daggerio.PushImage(containerBuilt, addr, ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *Tasker) BuildImage(dockerFilePath string, container *dagger.Container,
+	ctx context.Context) (*dagger.Container, error) {
+	return daggerio.BuildImage(dockerFilePath, t.GetClient(), container)
+}
+
+func (t *Tasker) AuthWithRegistry(c *dagger.Client, container *dagger.Container,
+	opt daggerio.RegistryAuthOptions) (*dagger.Container, error) {
+	return daggerio.AuthWithRegistry(c, container, opt)
+}
+
+func (t *Tasker) SetEnvVars(envVars []map[string]string,
+	container *dagger.Container) (*dagger.Container, error) {
+	ux := t.Cfg.PipelineCfg.UXMessage
+
+	if len(envVars) == 0 {
+		ux.ShowInfo(t.UXPrefix, "There is no environment variables to be set in the container")
+		return container, nil
+	}
+
+	var envVarsMerged map[string]string
+
+	for _, envVar := range envVars {
+		envVarsMerged = filesystem.MergeEnvVars(envVarsMerged, envVar)
+	}
+
+	return daggerio.SetEnvVarsInContainer(container, envVarsMerged)
+}
+
+func (t *Tasker) GetContainer(fromImage string) (*dagger.Container,
+	error) {
+	if fromImage == "" {
+		return t.Cfg.JobCfg.ContainerDefault, nil
+	}
+
+	container := t.Cfg.JobCfg.Client.Container().From(fromImage)
+
+	return t.Cfg.JobCfg.SetCacheVolumesInContainer(container), nil
+}
+
+// NewTasker returns the CoreTasker of a task, on which its actions run with the context.
+func NewTasker(coreTask *Task, actions []string, init *InitOptions, uxPrefix string,
+	opts TaskerOptions, ctx context.Context) CoreTasker {
+	return &Tasker{
+		Init:     init,
+		Cfg:      coreTask,
+		Actions:  actions,
+		UXPrefix: uxPrefix,
+		Opts:     opts,
+		Ctx:      ctx,
+	}
+}
+
+// runTask creates the (core) task, and runs the action with the execution policy of the task.
+// Each attempt builds the action from its own tasker, which holds the context of the attempt.
+// Only the idempotent actions are retried on any error.
+func runTask(opt InitOptions, uxPrefix string, opts TaskerOptions, idempotent bool,
+	newAction func(t CoreTasker) func() (Output, error)) (Output, error) {
+	c := NewTask(opt.PipelineCfg, opt.JobCfg, opt.ActionCommands, &opt)
+
+	return runAction(c, uxPrefix, idempotent, func(ctx context.Context) (Output, error) {
+		return newAction(NewTasker(c, opt.ActionCommands, &opt, uxPrefix, opts, ctx))()
+	})
+}
@@ -0,0 +1,44 @@
+package task
+
+import (
+	"dagger.io/dagger"
+	"fmt"
+	"github.com/Excoriate/stiletto/internal/daggerio"
+	"github.com/Excoriate/stiletto/internal/errors"
+	"github.com/Excoriate/stiletto/internal/filesystem"
+	"github.com/Excoriate/stiletto/internal/tui"
+)
+
+// GetUploadDirOpts resolves the filter of the files uploaded from a host directory: its
+// .stilettoignore file, its .dockerignore file (for the tasks that build images) and the
+// --include/--exclude patterns. The logs and artifacts dirs are excluded too, if they're inside
+// the directory. It reports the size of the upload.
+func GetUploadDirOpts(t *Task, uxPrefix, dir string, useDockerIgnore bool) (dagger.HostDirectoryOpts,
+	error) {
+	ux := tui.NewTUIMessage()
+
+	exclude := append(filesystem.GetNestedUploadExcludes(dir, getLogsRootDir(t.LogsDir),
+		t.ArtifactsDir), t.JobCfg.UploadFilter.Exclude...)
+
+	filter, err := filesystem.ResolveUploadFilter(dir, useDockerIgnore, t.JobCfg.UploadFilter.Include,
+		exclude)
+	if err != nil {
+		return dagger.HostDirectoryOpts{}, errors.NewTaskConfigurationError(
+			GetErrMsg(t, fmt.Sprintf("Failed to resolve the files to upload from %s", dir), nil), err)
+	}
+
+	stats, err := filesystem.GetUploadStats(dir, filter)
+	if err != nil {
+		// The size is informative only, the upload itself doesn't depend on it.
+		ux.ShowWarning(uxPrefix, GetInfoMsg(t, fmt.Sprintf(
+			"Failed to compute the size of the upload from %s: %s", dir, err)))
+
+		return daggerio.GetHostDirectoryOpts(filter), nil
+	}
+
+	ux.ShowInfo(uxPrefix, GetInfoMsg(t, fmt.Sprintf("Uploading %s: %d files (%s), %d entries excluded "+
+		"by %d patterns", dir, stats.Files, filesystem.FormatBytes(stats.Bytes), stats.Excluded,
+		len(filter.Include)+len(filter.Exclude))))
+
+	return daggerio.GetHostDirectoryOpts(filter), nil
+}